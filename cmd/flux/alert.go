@@ -54,3 +54,7 @@ func (a alertListAdapter) asClientList() client.ObjectList {
 func (a alertListAdapter) len() int {
 	return len(a.AlertList.Items)
 }
+
+func (a alertListAdapter) clientObject(i int) client.Object {
+	return &a.AlertList.Items[i]
+}
@@ -54,3 +54,7 @@ func (a alertProviderListAdapter) asClientList() client.ObjectList {
 func (a alertProviderListAdapter) len() int {
 	return len(a.ProviderList.Items)
 }
+
+func (a alertProviderListAdapter) clientObject(i int) client.Object {
+	return &a.ProviderList.Items[i]
+}
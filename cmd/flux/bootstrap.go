@@ -17,12 +17,20 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/elliptic"
 	"fmt"
 	"os"
 
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/spf13/cobra"
+	kustypes "sigs.k8s.io/kustomize/api/types"
 
+	"github.com/fluxcd/flux2/internal/bootstrap/git"
+	"github.com/fluxcd/flux2/internal/bootstrap/git/gitcli"
+	"github.com/fluxcd/flux2/internal/bootstrap/git/gogit"
 	"github.com/fluxcd/flux2/internal/flags"
 	"github.com/fluxcd/flux2/internal/utils"
 	"github.com/fluxcd/flux2/pkg/manifestgen/sourcesecret"
@@ -42,6 +50,13 @@ type bootstrapFlags struct {
 	branch            string
 	recurseSubmodules bool
 	manifestsPath     string
+	fromBundle        string
+	noCache           bool
+	patchFile         string
+	patches           []kustypes.Patch
+	imageDigests      bool
+	imageDigestsFile  string
+	digests           map[string]string
 
 	defaultComponents  []string
 	extraComponents    []string
@@ -63,11 +78,25 @@ type bootstrapFlags struct {
 	networkPolicy      bool
 	clusterDomain      string
 	tolerationKeys     []string
+	namespaced         bool
+	targetNamespaces   []string
+	multiTenant        bool
+	defaultSA          string
 
 	authorName  string
 	authorEmail string
 
 	commitMessageAppendix string
+
+	gpgKeyRingPath string
+	gpgPassphrase  string
+	gpgKeyID       string
+
+	gitClient flags.GitClient
+
+	dryRun            bool
+	rollbackOnFailure bool
+	singleCommit      bool
 }
 
 const (
@@ -95,6 +124,16 @@ func init() {
 		"when enabled, configures the GitRepository source to initialize and include Git submodules in the artifact it produces")
 
 	bootstrapCmd.PersistentFlags().StringVar(&bootstrapArgs.manifestsPath, "manifests", "", "path to the manifest directory")
+	bootstrapCmd.PersistentFlags().StringVar(&bootstrapArgs.patchFile, "patch-file", "",
+		"path to a YAML list of Kustomize patches for the components, written to the kustomization.yaml of the sync path in place of the patches of a previous bootstrap")
+	bootstrapCmd.PersistentFlags().BoolVar(&bootstrapArgs.imageDigests, "image-digests", false,
+		"pin the components images to the digests of their tags, as resolved from the container registry")
+	bootstrapCmd.PersistentFlags().StringVar(&bootstrapArgs.imageDigestsFile, "image-digests-file", "",
+		"path to a YAML map of component names to image digests, the components missing from the map are resolved when --image-digests is set")
+	bootstrapCmd.PersistentFlags().BoolVar(&bootstrapArgs.noCache, "no-cache", false,
+		"download the release manifests even if they are in the local cache")
+	bootstrapCmd.PersistentFlags().StringVar(&bootstrapArgs.fromBundle, "from-bundle", "",
+		"path to an air-gapped install bundle created with 'flux install --export-bundle', the images are pushed to --registry when the bundle includes them")
 
 	bootstrapCmd.PersistentFlags().BoolVar(&bootstrapArgs.watchAllNamespaces, "watch-all-namespaces", true,
 		"watch for custom resources in all namespaces, if set to false it will only watch the namespace where the toolkit is installed")
@@ -104,6 +143,14 @@ func init() {
 		"when enabled, the personal access token will be used instead of SSH deploy key")
 	bootstrapCmd.PersistentFlags().Var(&bootstrapArgs.logLevel, "log-level", bootstrapArgs.logLevel.Description())
 	bootstrapCmd.PersistentFlags().StringVar(&bootstrapArgs.clusterDomain, "cluster-domain", rootArgs.defaults.ClusterDomain, "internal cluster domain")
+	bootstrapCmd.PersistentFlags().BoolVar(&bootstrapArgs.namespaced, "namespaced", false,
+		"limit the controllers RBAC to the install namespace and --target-namespaces, and leave out the cluster-scoped objects, requires --watch-all-namespaces=false")
+	bootstrapCmd.PersistentFlags().StringSliceVar(&bootstrapArgs.targetNamespaces, "target-namespaces", nil,
+		"namespaces the reconcilers can apply resources to in addition to the install namespace, requires --namespaced")
+	bootstrapCmd.PersistentFlags().BoolVar(&bootstrapArgs.multiTenant, "multi-tenant", false,
+		"deny cross-namespace references and make the reconcilers impersonate --default-service-account when no service account is set")
+	bootstrapCmd.PersistentFlags().StringVar(&bootstrapArgs.defaultSA, "default-service-account", rootArgs.defaults.DefaultServiceAccount,
+		"service account impersonated by the reconcilers by default, requires --multi-tenant")
 	bootstrapCmd.PersistentFlags().StringSliceVar(&bootstrapArgs.tolerationKeys, "toleration-keys", nil,
 		"list of toleration keys used to schedule the components pods onto nodes with matching taints")
 
@@ -120,6 +167,19 @@ func init() {
 
 	bootstrapCmd.PersistentFlags().StringVar(&bootstrapArgs.commitMessageAppendix, "commit-message-appendix", "", "string to add to the commit messages, e.g. '[ci skip]'")
 
+	bootstrapCmd.PersistentFlags().StringVar(&bootstrapArgs.gpgKeyRingPath, "gpg-key-ring", "", "path to secret gpg key ring for signing commits")
+	bootstrapCmd.PersistentFlags().StringVar(&bootstrapArgs.gpgPassphrase, "gpg-passphrase", "", "passphrase for decrypting GPG private key")
+	bootstrapCmd.PersistentFlags().StringVar(&bootstrapArgs.gpgKeyID, "gpg-key-id", "", "key id for selecting a particular key")
+
+	bootstrapCmd.PersistentFlags().Var(&bootstrapArgs.gitClient, "git-client", bootstrapArgs.gitClient.Description())
+
+	bootstrapCmd.PersistentFlags().BoolVar(&bootstrapArgs.dryRun, "dry-run", false,
+		"print the changes bootstrap would make to the Git repository and the cluster, without committing, pushing or applying them")
+	bootstrapCmd.PersistentFlags().BoolVar(&bootstrapArgs.rollbackOnFailure, "rollback-on-failure", false,
+		"when the health checks fail, revert the bootstrap changes in a new commit and wait for the previous revision to become healthy, cannot be used with --pull-request")
+	bootstrapCmd.PersistentFlags().BoolVar(&bootstrapArgs.singleCommit, "single-commit", false,
+		"commit the component and sync manifests in a single commit, retrying the push on top of concurrent changes to the branch, cannot be used with --pull-request")
+
 	bootstrapCmd.PersistentFlags().Var(&bootstrapArgs.arch, "arch", bootstrapArgs.arch.Description())
 	bootstrapCmd.PersistentFlags().MarkDeprecated("arch", "multi-arch container image is now available for AMD64, ARMv7 and ARM64")
 	bootstrapCmd.PersistentFlags().MarkHidden("manifests")
@@ -134,6 +194,7 @@ func NewBootstrapFlags() bootstrapFlags {
 		keyAlgorithm:       flags.PublicKeyAlgorithm(sourcesecret.RSAPrivateKeyAlgorithm),
 		keyRSABits:         2048,
 		keyECDSACurve:      flags.ECDSACurve{Curve: elliptic.P384()},
+		gitClient:          flags.GoGitClient,
 	}
 }
 
@@ -142,6 +203,9 @@ func bootstrapComponents() []string {
 }
 
 func buildEmbeddedManifestBase() (string, error) {
+	if bootstrapArgs.fromBundle != "" {
+		return buildBundleManifestBase()
+	}
 	if !isEmbeddedVersion(bootstrapArgs.version) {
 		return "", nil
 	}
@@ -155,6 +219,26 @@ func buildEmbeddedManifestBase() (string, error) {
 	return tmpBaseDir, nil
 }
 
+// buildBundleManifestBase extracts the bundle given with --from-bundle
+// and sets the bootstrap version to the one of the bundle.
+func buildBundleManifestBase() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	tmpBaseDir, err := os.MkdirTemp("", "flux-bundle-")
+	if err != nil {
+		return "", err
+	}
+	bundle, err := loadBundle(ctx, bootstrapArgs.fromBundle, tmpBaseDir, bootstrapArgs.registry,
+		bootstrapComponents(), !bootstrapArgs.dryRun)
+	if err != nil {
+		os.RemoveAll(tmpBaseDir)
+		return "", err
+	}
+	bootstrapArgs.version = bundle.Version
+	return bundle.ManifestsBase(), nil
+}
+
 func bootstrapValidate() error {
 	components := bootstrapComponents()
 	for _, component := range bootstrapArgs.requiredComponents {
@@ -167,9 +251,61 @@ func bootstrapValidate() error {
 		return err
 	}
 
+	if bootstrapArgs.namespaced && bootstrapArgs.watchAllNamespaces {
+		return fmt.Errorf("--namespaced requires --watch-all-namespaces=false")
+	}
+	if len(bootstrapArgs.targetNamespaces) > 0 && !bootstrapArgs.namespaced {
+		return fmt.Errorf("--target-namespaces requires --namespaced")
+	}
+
+	if bootstrapArgs.patchFile != "" {
+		patches, err := loadPatchFile(bootstrapArgs.patchFile)
+		if err != nil {
+			return err
+		}
+		// The patches replace those of a previous bootstrap, even when
+		// the file holds none
+		bootstrapArgs.patches = append([]kustypes.Patch{}, patches...)
+	}
+
+	if bootstrapArgs.imageDigestsFile != "" {
+		digests, err := loadImageDigestsFile(bootstrapArgs.imageDigestsFile)
+		if err != nil {
+			return err
+		}
+		bootstrapArgs.digests = digests
+	}
+
+	if bootstrapArgs.fromBundle != "" && bootstrapArgs.version != "" {
+		return fmt.Errorf("--version can't be used with --from-bundle, the version is read from the bundle")
+	}
+
+	if bootstrapArgs.gpgKeyRingPath == "" && (bootstrapArgs.gpgPassphrase != "" || bootstrapArgs.gpgKeyID != "") {
+		return fmt.Errorf("--gpg-passphrase and --gpg-key-id require --gpg-key-ring")
+	}
+
 	return nil
 }
 
+// bootstrapGitClient returns the Git client configured with --git-client
+// for the given working directory. The go-git auth method is translated
+// for the git binary, which otherwise relies on the Git configuration
+// of the user for authentication.
+func bootstrapGitClient(path string, auth transport.AuthMethod) (git.Git, error) {
+	if bootstrapArgs.gitClient != flags.CLIGitClient {
+		return gogit.New(path, auth), nil
+	}
+	cliAuth := &gitcli.Auth{}
+	switch a := auth.(type) {
+	case *http.BasicAuth:
+		cliAuth.Username = a.Username
+		cliAuth.Password = a.Password
+	case *ssh.PublicKeys:
+		cliAuth.PrivateKeyFile = bootstrapArgs.privateKeyFile
+	}
+	return gitcli.New(path, cliAuth)
+}
+
 func mapTeamSlice(s []string, defaultPermission string) map[string]string {
 	m := make(map[string]string, len(s))
 	for _, v := range s {
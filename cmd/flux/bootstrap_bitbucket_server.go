@@ -0,0 +1,305 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/spf13/cobra"
+
+	"github.com/fluxcd/flux2/internal/bootstrap"
+	"github.com/fluxcd/flux2/internal/bootstrap/provider"
+	"github.com/fluxcd/flux2/internal/flags"
+	"github.com/fluxcd/flux2/pkg/manifestgen/install"
+	"github.com/fluxcd/flux2/pkg/manifestgen/sourcesecret"
+	"github.com/fluxcd/flux2/pkg/manifestgen/sync"
+)
+
+var bootstrapBServerCmd = &cobra.Command{
+	Use:   "bitbucket-server",
+	Short: "Bootstrap toolkit components in a Bitbucket Server repository",
+	Long: `The bootstrap bitbucket-server command creates the Bitbucket Server repository if it doesn't exists and
+commits the toolkit components manifests to the main branch.
+Then it configures the target cluster to synchronize with the repository.
+If the toolkit components are present on the cluster,
+the bootstrap command will perform an upgrade if needed.
+Bitbucket Data Center is supported as well.`,
+	Example: `  # Create a Bitbucket Server HTTP access token and export it as an env var
+  export BITBUCKET_TOKEN=<my-token>
+
+  # Run bootstrap for a private repository in a Bitbucket Server project
+  flux bootstrap bitbucket-server --owner=<project key> --username=<user> --repository=<repository name> --hostname=<domain>
+
+  # Run bootstrap for a private repository and grant groups access to it
+  flux bootstrap bitbucket-server --owner=<project key> --username=<user> --repository=<repository name> --hostname=<domain> --group=<group1 name> --group=<group2 name>
+
+  # Run bootstrap for a repository path
+  flux bootstrap bitbucket-server --owner=<project key> --username=<user> --repository=<repository name> --hostname=<domain> --path=dev-cluster
+
+  # Run bootstrap for a public repository in a personal project
+  flux bootstrap bitbucket-server --owner=<user> --username=<user> --repository=<repository name> --hostname=<domain> --private=false --personal=true
+
+  # Run bootstrap for a private repository using SSH auth with a custom SSH hostname and port
+  flux bootstrap bitbucket-server --owner=<project key> --username=<user> --repository=<repository name> --hostname=<domain> --ssh-hostname=<domain>:<port>
+
+  # Run bootstrap for a private repository using HTTPS auth
+  flux bootstrap bitbucket-server --owner=<project key> --username=<user> --repository=<repository name> --hostname=<domain> --token-auth
+
+  # Run bootstrap for a protected branch by proposing the manifests in a pull request
+  flux bootstrap bitbucket-server --owner=<project key> --username=<user> --repository=<repository name> --hostname=<domain> --pull-request --wait-for-merge`,
+	RunE: bootstrapBServerCmdRun,
+}
+
+type bServerFlags struct {
+	owner        string
+	repository   string
+	interval     time.Duration
+	personal     bool
+	private      bool
+	hostname     string
+	path         flags.SafeRelativePath
+	username     string
+	groups       []string
+	readWriteKey bool
+	reconcile    bool
+	pullRequest  bool
+	waitForMerge bool
+}
+
+const (
+	bbsDefaultPermission = "push"
+	bbsDefaultSSHPort    = 7999
+	bbsTokenEnvVar       = "BITBUCKET_TOKEN"
+)
+
+var bServerArgs bServerFlags
+
+func init() {
+	bootstrapBServerCmd.Flags().StringVar(&bServerArgs.owner, "owner", "", "Bitbucket Server project key or user name")
+	bootstrapBServerCmd.Flags().StringVar(&bServerArgs.repository, "repository", "", "Bitbucket Server repository slug")
+	bootstrapBServerCmd.Flags().StringVarP(&bServerArgs.username, "username", "u", "git", "authentication username")
+	bootstrapBServerCmd.Flags().StringSliceVar(&bServerArgs.groups, "group", []string{}, "Bitbucket Server groups to be given write access (also accepts comma-separated values)")
+	bootstrapBServerCmd.Flags().BoolVar(&bServerArgs.personal, "personal", false, "if true, the owner is assumed to be a Bitbucket Server user; otherwise a project")
+	bootstrapBServerCmd.Flags().BoolVar(&bServerArgs.private, "private", true, "if true, the repository is setup or configured as private")
+	bootstrapBServerCmd.Flags().DurationVar(&bServerArgs.interval, "interval", time.Minute, "sync interval")
+	bootstrapBServerCmd.Flags().StringVar(&bServerArgs.hostname, "hostname", "", "Bitbucket Server hostname")
+	bootstrapBServerCmd.Flags().Var(&bServerArgs.path, "path", "path relative to the repository root, when specified the cluster sync will be scoped to this path")
+	bootstrapBServerCmd.Flags().BoolVar(&bServerArgs.readWriteKey, "read-write-key", false, "if true, the deploy key is configured with read/write permissions")
+	bootstrapBServerCmd.Flags().BoolVar(&bServerArgs.reconcile, "reconcile", false, "if true, the configured options are also reconciled if the repository already exists")
+	bootstrapBServerCmd.Flags().BoolVar(&bServerArgs.pullRequest, "pull-request", false, "if true, the manifests are committed to a new branch and proposed in a pull request instead of being pushed to the branch")
+	bootstrapBServerCmd.Flags().BoolVar(&bServerArgs.waitForMerge, "wait-for-merge", false, "if true, wait for the pull request to be merged and install the components on the cluster, requires --pull-request")
+
+	bootstrapCmd.AddCommand(bootstrapBServerCmd)
+}
+
+func bootstrapBServerCmdRun(cmd *cobra.Command, args []string) error {
+	bbToken := os.Getenv(bbsTokenEnvVar)
+	if bbToken == "" {
+		return fmt.Errorf("%s environment variable not found", bbsTokenEnvVar)
+	}
+
+	if bServerArgs.hostname == "" {
+		return fmt.Errorf("hostname is required")
+	}
+
+	if err := bootstrapValidate(); err != nil {
+		return err
+	}
+	if bServerArgs.waitForMerge && !bServerArgs.pullRequest {
+		return fmt.Errorf("--wait-for-merge requires --pull-request")
+	}
+	if bServerArgs.pullRequest && bootstrapArgs.rollbackOnFailure {
+		return fmt.Errorf("--rollback-on-failure cannot be used with --pull-request")
+	}
+	if bServerArgs.pullRequest && bootstrapArgs.singleCommit {
+		return fmt.Errorf("--single-commit cannot be used with --pull-request")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	kubeClient, err := newKubeClient()
+	if err != nil {
+		return err
+	}
+
+	// Manifest base
+	if ver, err := getVersion(bootstrapArgs.version); err == nil {
+		bootstrapArgs.version = ver
+	}
+	manifestsBase, err := buildEmbeddedManifestBase()
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(manifestsBase)
+
+	// Build Bitbucket Server provider
+	providerCfg := provider.Config{
+		Provider: provider.GitProviderBitbucketServer,
+		Hostname: bServerArgs.hostname,
+		Token:    bbToken,
+	}
+	providerClient, err := provider.BuildGitProvider(providerCfg)
+	if err != nil {
+		return err
+	}
+
+	// Lazy Git repository
+	tmpDir, err := os.MkdirTemp("", "flux-bootstrap-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary working dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	gitClient, err := bootstrapGitClient(tmpDir, &http.BasicAuth{
+		Username: bServerArgs.username,
+		Password: bbToken,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Install manifest config
+	releases, err := releaseSource()
+	if err != nil {
+		return err
+	}
+	installOptions := install.Options{
+		BaseURL:                rootArgs.defaults.BaseURL,
+		Version:                bootstrapArgs.version,
+		Namespace:              rootArgs.namespace,
+		Components:             bootstrapComponents(),
+		Registry:               bootstrapArgs.registry,
+		ImagePullSecret:        bootstrapArgs.imagePullSecret,
+		WatchAllNamespaces:     bootstrapArgs.watchAllNamespaces,
+		NetworkPolicy:          bootstrapArgs.networkPolicy,
+		LogLevel:               bootstrapArgs.logLevel.String(),
+		NotificationController: rootArgs.defaults.NotificationController,
+		ManifestFile:           rootArgs.defaults.ManifestFile,
+		Timeout:                rootArgs.timeout,
+		TargetPath:             bServerArgs.path.ToSlash(),
+		ClusterDomain:          bootstrapArgs.clusterDomain,
+		TolerationKeys:         bootstrapArgs.tolerationKeys,
+		CacheDir:               manifestsCacheDir(bootstrapArgs.noCache),
+		ReleaseSource:          releases,
+		Patches:                bootstrapArgs.patches,
+		Namespaced:             bootstrapArgs.namespaced,
+		TargetNamespaces:       bootstrapArgs.targetNamespaces,
+		MultiTenant:            bootstrapArgs.multiTenant,
+		DefaultServiceAccount:  bootstrapArgs.defaultSA,
+		ImageDigests:           bootstrapArgs.digests,
+		ResolveImageDigests:    bootstrapArgs.imageDigests,
+	}
+	if customBaseURL := bootstrapArgs.manifestsPath; customBaseURL != "" {
+		installOptions.BaseURL = customBaseURL
+	}
+
+	// Source generation and secret config
+	secretOpts := sourcesecret.Options{
+		Name:         bootstrapArgs.secretName,
+		Namespace:    rootArgs.namespace,
+		TargetPath:   bServerArgs.path.ToSlash(),
+		ManifestFile: sourcesecret.MakeDefaultOptions().ManifestFile,
+	}
+	if bootstrapArgs.tokenAuth {
+		secretOpts.Username = bServerArgs.username
+		secretOpts.Password = bbToken
+
+		if bootstrapArgs.caFile != "" {
+			secretOpts.CAFilePath = bootstrapArgs.caFile
+		}
+	} else {
+		secretOpts.PrivateKeyAlgorithm = sourcesecret.PrivateKeyAlgorithm(bootstrapArgs.keyAlgorithm)
+		secretOpts.RSAKeyBits = int(bootstrapArgs.keyRSABits)
+		secretOpts.ECDSACurve = bootstrapArgs.keyECDSACurve.Curve
+		// Bitbucket Server serves SSH on a dedicated port by default
+		secretOpts.SSHHostname = fmt.Sprintf("%s:%d", bServerArgs.hostname, bbsDefaultSSHPort)
+
+		if bootstrapArgs.sshHostname != "" {
+			secretOpts.SSHHostname = bootstrapArgs.sshHostname
+		}
+	}
+
+	// Sync manifest config
+	syncOpts := sync.Options{
+		Interval:          bServerArgs.interval,
+		Name:              rootArgs.namespace,
+		Namespace:         rootArgs.namespace,
+		Branch:            bootstrapArgs.branch,
+		Secret:            bootstrapArgs.secretName,
+		TargetPath:        bServerArgs.path.ToSlash(),
+		ManifestFile:      sync.MakeDefaultOptions().ManifestFile,
+		GitImplementation: sourceGitArgs.gitImplementation.String(),
+		RecurseSubmodules: bootstrapArgs.recurseSubmodules,
+	}
+
+	// Bootstrap config
+	bootstrapOpts := []bootstrap.GitProviderOption{
+		bootstrap.WithProviderRepository(bServerArgs.owner, bServerArgs.repository, bServerArgs.personal),
+		bootstrap.WithBranch(bootstrapArgs.branch),
+		bootstrap.WithBootstrapTransportType("https"),
+		bootstrap.WithAuthor(bootstrapArgs.authorName, bootstrapArgs.authorEmail),
+		bootstrap.WithCommitMessageAppendix(bootstrapArgs.commitMessageAppendix),
+		bootstrap.WithGitCommitSigning(bootstrapArgs.gpgKeyRingPath, bootstrapArgs.gpgPassphrase, bootstrapArgs.gpgKeyID),
+		bootstrap.WithProviderTeamPermissions(mapTeamSlice(bServerArgs.groups, bbsDefaultPermission)),
+		bootstrap.WithReadWriteKeyPermissions(bServerArgs.readWriteKey),
+		bootstrap.WithKubeconfig(rootArgs.kubeconfig, rootArgs.kubecontext),
+		bootstrap.WithLogger(logger),
+	}
+	if bootstrapArgs.sshHostname != "" {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithSSHHostname(bootstrapArgs.sshHostname))
+	}
+	if bootstrapArgs.tokenAuth {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithSyncTransportType("https"))
+	}
+	if !bServerArgs.private {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithProviderRepositoryConfig("", "", "public"))
+	}
+	if bServerArgs.reconcile {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithReconcile())
+	}
+	if bServerArgs.pullRequest {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithPullRequest(bServerArgs.waitForMerge))
+	}
+	if bootstrapArgs.dryRun {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithDryRun())
+	}
+	if bootstrapArgs.rollbackOnFailure {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithRollbackOnFailure())
+	}
+	if bootstrapArgs.singleCommit {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithSingleCommit())
+	}
+
+	// Setup bootstrapper with constructed configs
+	b, err := bootstrap.NewGitProviderBootstrapper(gitClient, providerClient, kubeClient, bootstrapOpts...)
+	if err != nil {
+		return err
+	}
+
+	// Run
+	if err = bootstrap.Run(ctx, b, manifestsBase, installOptions, secretOpts, syncOpts, rootArgs.pollInterval, rootArgs.timeout); err != nil {
+		return err
+	}
+	if bootstrapArgs.dryRun {
+		return b.Plan().Print(cmd.OutOrStdout())
+	}
+	return nil
+}
@@ -0,0 +1,478 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/fluxcd/flux2/internal/bootstrap"
+	"github.com/fluxcd/flux2/internal/bootstrap/provider"
+	"github.com/fluxcd/flux2/internal/flags"
+	"github.com/fluxcd/flux2/internal/utils"
+	"github.com/fluxcd/flux2/pkg/manifestgen/install"
+	"github.com/fluxcd/flux2/pkg/manifestgen/sourcesecret"
+	"github.com/fluxcd/flux2/pkg/manifestgen/sync"
+)
+
+var bootstrapFleetCmd = &cobra.Command{
+	Use:   "fleet",
+	Short: "Bootstrap toolkit components on a fleet of clusters from a shared repository",
+	Long: `The bootstrap fleet command bootstraps the clusters listed in a fleet file, each at its own
+path of a shared GitHub, GitLab or Gitea repository. The repository is cloned once and the clusters are
+bootstrapped concurrently, while the commits to the repository are made for one cluster at a time.
+The command keeps going when a cluster fails to bootstrap, and prints a summary of all clusters.`,
+	Example: `  # Create a personal access token for the provider of the repository and export it as an env var
+  export GITHUB_TOKEN=<my-token>
+
+  # Bootstrap the clusters listed in fleet.yaml, at most four at a time
+  flux bootstrap fleet -f fleet.yaml --concurrency=4
+
+  # Example fleet.yaml
+  repository:
+    provider: github
+    owner: my-org
+    repository: fleet-infra
+    branch: main
+  clusters:
+    - name: staging
+      context: staging-admin
+      path: clusters/staging
+    - name: production
+      context: production-admin
+      path: clusters/production
+      componentsExtra:
+        - image-reflector-controller
+        - image-automation-controller
+      interval: 10m`,
+	RunE: bootstrapFleetCmdRun,
+}
+
+type fleetFlags struct {
+	file        string
+	concurrency int
+}
+
+var fleetArgs = fleetFlags{
+	concurrency: 4,
+}
+
+// fleetSpec is the format of the fleet file.
+type fleetSpec struct {
+	Repository fleetRepositorySpec `json:"repository"`
+	Clusters   []fleetClusterSpec  `json:"clusters"`
+}
+
+type fleetRepositorySpec struct {
+	Provider   string   `json:"provider"`
+	Owner      string   `json:"owner"`
+	Repository string   `json:"repository"`
+	Hostname   string   `json:"hostname,omitempty"`
+	Personal   bool     `json:"personal,omitempty"`
+	Private    *bool    `json:"private,omitempty"`
+	Branch     string   `json:"branch,omitempty"`
+	Teams      []string `json:"teams,omitempty"`
+}
+
+// fleetClusterSpec holds the configuration of a single cluster, the
+// options which are not set default to the values of the flags.
+type fleetClusterSpec struct {
+	Name               string           `json:"name,omitempty"`
+	Context            string           `json:"context"`
+	Path               string           `json:"path"`
+	Interval           *metav1.Duration `json:"interval,omitempty"`
+	Components         []string         `json:"components,omitempty"`
+	ComponentsExtra    []string         `json:"componentsExtra,omitempty"`
+	Registry           string           `json:"registry,omitempty"`
+	ImagePullSecret    string           `json:"imagePullSecret,omitempty"`
+	WatchAllNamespaces *bool            `json:"watchAllNamespaces,omitempty"`
+	NetworkPolicy      *bool            `json:"networkPolicy,omitempty"`
+	ClusterDomain      string           `json:"clusterDomain,omitempty"`
+	TolerationKeys     []string         `json:"tolerationKeys,omitempty"`
+	LogLevel           string           `json:"logLevel,omitempty"`
+	ReadWriteKey       bool             `json:"readWriteKey,omitempty"`
+}
+
+// fleetProvider holds the defaults of a Git provider supported by the
+// fleet command.
+type fleetProvider struct {
+	domain            string
+	tokenEnvVar       string
+	defaultPermission string
+}
+
+var fleetProviders = map[provider.GitProvider]fleetProvider{
+	provider.GitProviderGitHub: {ghDefaultDomain, ghTokenEnvVar, ghDefaultPermission},
+	provider.GitProviderGitLab: {glDefaultDomain, glTokenEnvVar, glDefaultPermission},
+	provider.GitProviderGitea:  {gtDefaultDomain, gtTokenEnvVar, gtDefaultPermission},
+}
+
+func init() {
+	bootstrapFleetCmd.Flags().StringVarP(&fleetArgs.file, "file", "f", "", "path to the fleet file listing the clusters and the shared repository")
+	bootstrapFleetCmd.Flags().IntVar(&fleetArgs.concurrency, "concurrency", fleetArgs.concurrency, "maximum number of clusters bootstrapped at the same time")
+
+	bootstrapCmd.AddCommand(bootstrapFleetCmd)
+}
+
+func bootstrapFleetCmdRun(cmd *cobra.Command, args []string) error {
+	if fleetArgs.file == "" {
+		return fmt.Errorf("--file is required")
+	}
+	if err := bootstrapValidate(); err != nil {
+		return err
+	}
+	spec, err := loadFleetSpec(fleetArgs.file)
+	if err != nil {
+		return err
+	}
+
+	providerName := provider.GitProvider(spec.Repository.Provider)
+	defaults := fleetProviders[providerName]
+	token := os.Getenv(defaults.tokenEnvVar)
+	if token == "" {
+		return fmt.Errorf("%s environment variable not found", defaults.tokenEnvVar)
+	}
+	hostname := spec.Repository.Hostname
+	if hostname == "" {
+		hostname = defaults.domain
+	}
+	branch := spec.Repository.Branch
+	if branch == "" {
+		branch = bootstrapArgs.branch
+	}
+
+	// Manifest base
+	if ver, err := getVersion(bootstrapArgs.version); err == nil {
+		bootstrapArgs.version = ver
+	}
+	manifestsBase, err := buildEmbeddedManifestBase()
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(manifestsBase)
+
+	// Build provider
+	providerCfg := provider.Config{
+		Provider: providerName,
+		Hostname: hostname,
+		Token:    token,
+	}
+	// Workaround for: https://github.com/fluxcd/go-git-providers/issues/55
+	if providerName == provider.GitProviderGitLab && hostname != glDefaultDomain &&
+		!strings.HasPrefix(hostname, "https://") &&
+		!strings.HasPrefix(hostname, "http://") {
+		providerCfg.Hostname = "https://" + hostname
+	}
+	providerClient, err := provider.BuildGitProvider(providerCfg)
+	if err != nil {
+		return err
+	}
+
+	// Shared lazy Git repository
+	tmpDir, err := os.MkdirTemp("", "flux-bootstrap-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary working dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	username := spec.Repository.Owner
+	if providerName == provider.GitProviderGitLab {
+		username = "git"
+	}
+	gitClient, err := bootstrapGitClient(tmpDir, &http.BasicAuth{
+		Username: username,
+		Password: token,
+	})
+	if err != nil {
+		return err
+	}
+	fleet := bootstrap.NewFleet(gitClient, fleetArgs.concurrency)
+	releases, err := releaseSource()
+	if err != nil {
+		return err
+	}
+
+	var clusters []bootstrap.FleetCluster
+	var bootstrappers []*bootstrap.GitProviderBootstrapper
+	for _, c := range spec.Clusters {
+		kubeClient, err := utils.KubeClient(rootArgs.kubeconfig, c.Context)
+		if err != nil {
+			return fmt.Errorf("cluster %q: %w", c.Name, err)
+		}
+		cluster := newFleetCluster(c, spec.Repository, branch, hostname, token)
+		cluster.InstallOptions.ReleaseSource = releases
+
+		bootstrapOpts := []bootstrap.GitProviderOption{
+			bootstrap.WithProviderRepository(spec.Repository.Owner, spec.Repository.Repository, spec.Repository.Personal),
+			bootstrap.WithBranch(branch),
+			bootstrap.WithBootstrapTransportType("https"),
+			bootstrap.WithAuthor(bootstrapArgs.authorName, bootstrapArgs.authorEmail),
+			bootstrap.WithCommitMessageAppendix(bootstrapArgs.commitMessageAppendix),
+			bootstrap.WithGitCommitSigning(bootstrapArgs.gpgKeyRingPath, bootstrapArgs.gpgPassphrase, bootstrapArgs.gpgKeyID),
+			bootstrap.WithProviderTeamPermissions(mapTeamSlice(spec.Repository.Teams, defaults.defaultPermission)),
+			bootstrap.WithReadWriteKeyPermissions(c.ReadWriteKey),
+			bootstrap.WithKubeconfig(rootArgs.kubeconfig, c.Context),
+			bootstrap.WithLogger(prefixLogger{logger: logger, prefix: c.Name}),
+		}
+		if bootstrapArgs.sshHostname != "" {
+			bootstrapOpts = append(bootstrapOpts, bootstrap.WithSSHHostname(bootstrapArgs.sshHostname))
+		}
+		if bootstrapArgs.tokenAuth {
+			bootstrapOpts = append(bootstrapOpts, bootstrap.WithSyncTransportType("https"))
+		}
+		if spec.Repository.Private != nil && !*spec.Repository.Private {
+			bootstrapOpts = append(bootstrapOpts, bootstrap.WithProviderRepositoryConfig("", "", "public"))
+		}
+		if bootstrapArgs.dryRun {
+			bootstrapOpts = append(bootstrapOpts, bootstrap.WithDryRun())
+		}
+		if bootstrapArgs.rollbackOnFailure {
+			bootstrapOpts = append(bootstrapOpts, bootstrap.WithRollbackOnFailure())
+		}
+		if bootstrapArgs.singleCommit {
+			bootstrapOpts = append(bootstrapOpts, bootstrap.WithSingleCommit())
+		}
+
+		b, err := bootstrap.NewGitProviderBootstrapper(fleet.Git(), providerClient, kubeClient, bootstrapOpts...)
+		if err != nil {
+			return err
+		}
+		cluster.Reconciler = b
+		clusters = append(clusters, cluster)
+		bootstrappers = append(bootstrappers, b)
+	}
+
+	// Run, each cluster with its own timeout
+	results := fleet.Run(context.Background(), clusters, manifestsBase, rootArgs.pollInterval, rootArgs.timeout)
+
+	if bootstrapArgs.dryRun {
+		for i, b := range bootstrappers {
+			fmt.Fprintf(cmd.OutOrStdout(), "# %s\n", clusters[i].Name)
+			if err := b.Plan().Print(cmd.OutOrStdout()); err != nil {
+				return err
+			}
+		}
+	}
+	return printFleetResults(cmd, results)
+}
+
+// loadFleetSpec reads and validates the fleet file at the given path,
+// defaulting the name of the clusters to their context.
+func loadFleetSpec(path string) (*fleetSpec, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fleet file: %w", err)
+	}
+	var spec fleetSpec
+	if err := yaml.UnmarshalStrict(content, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse fleet file %q: %w", path, err)
+	}
+
+	if _, ok := fleetProviders[provider.GitProvider(spec.Repository.Provider)]; !ok {
+		return nil, fmt.Errorf("repository provider '%s' is not supported, must be one of: github, gitlab, gitea", spec.Repository.Provider)
+	}
+	if spec.Repository.Owner == "" || spec.Repository.Repository == "" {
+		return nil, fmt.Errorf("repository owner and repository name are required")
+	}
+	if len(spec.Clusters) == 0 {
+		return nil, fmt.Errorf("no clusters found in fleet file %q", path)
+	}
+
+	names := map[string]bool{}
+	paths := map[string]bool{}
+	for i := range spec.Clusters {
+		c := &spec.Clusters[i]
+		if c.Context == "" {
+			return nil, fmt.Errorf("cluster #%d: context is required", i+1)
+		}
+		if c.Name == "" {
+			c.Name = c.Context
+		}
+		var p flags.SafeRelativePath
+		if err := p.Set(c.Path); err != nil {
+			return nil, fmt.Errorf("cluster %q: %w", c.Name, err)
+		}
+		c.Path = p.ToSlash()
+		if names[c.Name] {
+			return nil, fmt.Errorf("cluster %q is listed more than once", c.Name)
+		}
+		if paths[c.Path] {
+			return nil, fmt.Errorf("cluster %q: path %q is used by another cluster", c.Name, c.Path)
+		}
+		names[c.Name], paths[c.Path] = true, true
+
+		components := fleetClusterComponents(*c)
+		for _, component := range bootstrapArgs.requiredComponents {
+			if !utils.ContainsItemString(components, component) {
+				return nil, fmt.Errorf("cluster %q: component %s is required", c.Name, component)
+			}
+		}
+		if err := utils.ValidateComponents(components); err != nil {
+			return nil, fmt.Errorf("cluster %q: %w", c.Name, err)
+		}
+		if c.LogLevel != "" {
+			var l flags.LogLevel
+			if err := l.Set(c.LogLevel); err != nil {
+				return nil, fmt.Errorf("cluster %q: %w", c.Name, err)
+			}
+		}
+	}
+	return &spec, nil
+}
+
+func fleetClusterComponents(c fleetClusterSpec) []string {
+	components := bootstrapArgs.defaultComponents
+	if len(c.Components) > 0 {
+		components = c.Components
+	}
+	extra := bootstrapArgs.extraComponents
+	if len(c.ComponentsExtra) > 0 {
+		extra = c.ComponentsExtra
+	}
+	return append(append([]string{}, components...), extra...)
+}
+
+// newFleetCluster returns the install, source secret and sync options
+// for the given cluster, defaulting to the values of the flags.
+func newFleetCluster(c fleetClusterSpec, repository fleetRepositorySpec, branch, hostname, token string) bootstrap.FleetCluster {
+	installOptions := install.Options{
+		BaseURL:                rootArgs.defaults.BaseURL,
+		Version:                bootstrapArgs.version,
+		Namespace:              rootArgs.namespace,
+		Components:             fleetClusterComponents(c),
+		Registry:               bootstrapArgs.registry,
+		ImagePullSecret:        bootstrapArgs.imagePullSecret,
+		WatchAllNamespaces:     bootstrapArgs.watchAllNamespaces,
+		NetworkPolicy:          bootstrapArgs.networkPolicy,
+		LogLevel:               bootstrapArgs.logLevel.String(),
+		NotificationController: rootArgs.defaults.NotificationController,
+		ManifestFile:           rootArgs.defaults.ManifestFile,
+		Timeout:                rootArgs.timeout,
+		TargetPath:             c.Path,
+		ClusterDomain:          bootstrapArgs.clusterDomain,
+		TolerationKeys:         bootstrapArgs.tolerationKeys,
+		CacheDir:               manifestsCacheDir(bootstrapArgs.noCache),
+		Patches:                bootstrapArgs.patches,
+		Namespaced:             bootstrapArgs.namespaced,
+		TargetNamespaces:       bootstrapArgs.targetNamespaces,
+		MultiTenant:            bootstrapArgs.multiTenant,
+		DefaultServiceAccount:  bootstrapArgs.defaultSA,
+		ImageDigests:           bootstrapArgs.digests,
+		ResolveImageDigests:    bootstrapArgs.imageDigests,
+	}
+	if customBaseURL := bootstrapArgs.manifestsPath; customBaseURL != "" {
+		installOptions.BaseURL = customBaseURL
+	}
+	if c.Registry != "" {
+		installOptions.Registry = c.Registry
+	}
+	if c.ImagePullSecret != "" {
+		installOptions.ImagePullSecret = c.ImagePullSecret
+	}
+	if c.WatchAllNamespaces != nil {
+		installOptions.WatchAllNamespaces = *c.WatchAllNamespaces
+	}
+	if c.NetworkPolicy != nil {
+		installOptions.NetworkPolicy = *c.NetworkPolicy
+	}
+	if c.ClusterDomain != "" {
+		installOptions.ClusterDomain = c.ClusterDomain
+	}
+	if len(c.TolerationKeys) > 0 {
+		installOptions.TolerationKeys = c.TolerationKeys
+	}
+	if c.LogLevel != "" {
+		installOptions.LogLevel = c.LogLevel
+	}
+
+	secretOpts := sourcesecret.Options{
+		Name:         bootstrapArgs.secretName,
+		Namespace:    rootArgs.namespace,
+		TargetPath:   c.Path,
+		ManifestFile: sourcesecret.MakeDefaultOptions().ManifestFile,
+	}
+	if bootstrapArgs.tokenAuth {
+		secretOpts.Username = "git"
+		secretOpts.Password = token
+
+		if bootstrapArgs.caFile != "" {
+			secretOpts.CAFilePath = bootstrapArgs.caFile
+		}
+	} else {
+		secretOpts.PrivateKeyAlgorithm = sourcesecret.PrivateKeyAlgorithm(bootstrapArgs.keyAlgorithm)
+		secretOpts.RSAKeyBits = int(bootstrapArgs.keyRSABits)
+		secretOpts.ECDSACurve = bootstrapArgs.keyECDSACurve.Curve
+		secretOpts.SSHHostname = hostname
+
+		if bootstrapArgs.sshHostname != "" {
+			secretOpts.SSHHostname = bootstrapArgs.sshHostname
+		}
+	}
+
+	syncOpts := sync.Options{
+		Interval:          time.Minute,
+		Name:              rootArgs.namespace,
+		Namespace:         rootArgs.namespace,
+		Branch:            branch,
+		Secret:            bootstrapArgs.secretName,
+		TargetPath:        c.Path,
+		ManifestFile:      sync.MakeDefaultOptions().ManifestFile,
+		GitImplementation: sourceGitArgs.gitImplementation.String(),
+		RecurseSubmodules: bootstrapArgs.recurseSubmodules,
+	}
+	if c.Interval != nil {
+		syncOpts.Interval = c.Interval.Duration
+	}
+
+	return bootstrap.FleetCluster{
+		Name:           c.Name,
+		InstallOptions: installOptions,
+		SecretOptions:  secretOpts,
+		SyncOptions:    syncOpts,
+	}
+}
+
+// printFleetResults prints a summary of the bootstrap of each cluster,
+// and returns an error if any of them failed.
+func printFleetResults(cmd *cobra.Command, results []bootstrap.FleetResult) error {
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tSTATUS\tMESSAGE")
+	var failed int
+	for _, r := range results {
+		status, message := "Succeeded", "bootstrap finished"
+		if r.Err != nil {
+			failed++
+			status, message = "Failed", r.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.Name, status, message)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d cluster(s) failed to bootstrap", failed, len(results))
+	}
+	return nil
+}
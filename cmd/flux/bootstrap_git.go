@@ -32,9 +32,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 
 	"github.com/fluxcd/flux2/internal/bootstrap"
-	"github.com/fluxcd/flux2/internal/bootstrap/git/gogit"
 	"github.com/fluxcd/flux2/internal/flags"
-	"github.com/fluxcd/flux2/internal/utils"
 	"github.com/fluxcd/flux2/pkg/manifestgen/install"
 	"github.com/fluxcd/flux2/pkg/manifestgen/sourcesecret"
 	"github.com/fluxcd/flux2/pkg/manifestgen/sync"
@@ -101,7 +99,7 @@ func bootstrapGitCmdRun(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
-	kubeClient, err := utils.KubeClient(rootArgs.kubeconfig, rootArgs.kubecontext)
+	kubeClient, err := newKubeClient()
 	if err != nil {
 		return err
 	}
@@ -116,15 +114,22 @@ func bootstrapGitCmdRun(cmd *cobra.Command, args []string) error {
 	}
 	defer os.RemoveAll(manifestsBase)
 
-	// Lazy go-git repository
+	// Lazy Git repository
 	tmpDir, err := os.MkdirTemp("", "flux-bootstrap-")
 	if err != nil {
 		return fmt.Errorf("failed to create temporary working dir: %w", err)
 	}
 	defer os.RemoveAll(tmpDir)
-	gitClient := gogit.New(tmpDir, gitAuth)
+	gitClient, err := bootstrapGitClient(tmpDir, gitAuth)
+	if err != nil {
+		return err
+	}
 
 	// Install manifest config
+	releases, err := releaseSource()
+	if err != nil {
+		return err
+	}
 	installOptions := install.Options{
 		BaseURL:                rootArgs.defaults.BaseURL,
 		Version:                bootstrapArgs.version,
@@ -141,6 +146,15 @@ func bootstrapGitCmdRun(cmd *cobra.Command, args []string) error {
 		TargetPath:             gitArgs.path.ToSlash(),
 		ClusterDomain:          bootstrapArgs.clusterDomain,
 		TolerationKeys:         bootstrapArgs.tolerationKeys,
+		CacheDir:               manifestsCacheDir(bootstrapArgs.noCache),
+		ReleaseSource:          releases,
+		Patches:                bootstrapArgs.patches,
+		Namespaced:             bootstrapArgs.namespaced,
+		TargetNamespaces:       bootstrapArgs.targetNamespaces,
+		MultiTenant:            bootstrapArgs.multiTenant,
+		DefaultServiceAccount:  bootstrapArgs.defaultSA,
+		ImageDigests:           bootstrapArgs.digests,
+		ResolveImageDigests:    bootstrapArgs.imageDigests,
 	}
 	if customBaseURL := bootstrapArgs.manifestsPath; customBaseURL != "" {
 		installOptions.BaseURL = customBaseURL
@@ -214,11 +228,22 @@ func bootstrapGitCmdRun(cmd *cobra.Command, args []string) error {
 		bootstrap.WithBranch(bootstrapArgs.branch),
 		bootstrap.WithAuthor(bootstrapArgs.authorName, bootstrapArgs.authorEmail),
 		bootstrap.WithCommitMessageAppendix(bootstrapArgs.commitMessageAppendix),
+		bootstrap.WithGitCommitSigning(bootstrapArgs.gpgKeyRingPath, bootstrapArgs.gpgPassphrase, bootstrapArgs.gpgKeyID),
 		bootstrap.WithKubeconfig(rootArgs.kubeconfig, rootArgs.kubecontext),
-		bootstrap.WithPostGenerateSecretFunc(promptPublicKey),
 		bootstrap.WithLogger(logger),
 		bootstrap.WithCABundle(caBundle),
 	}
+	if bootstrapArgs.dryRun {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithDryRun())
+	} else {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithPostGenerateSecretFunc(promptPublicKey))
+	}
+	if bootstrapArgs.rollbackOnFailure {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithRollbackOnFailure())
+	}
+	if bootstrapArgs.singleCommit {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithSingleCommit())
+	}
 
 	// Setup bootstrapper with constructed configs
 	b, err := bootstrap.NewPlainGitProvider(gitClient, kubeClient, bootstrapOpts...)
@@ -227,7 +252,13 @@ func bootstrapGitCmdRun(cmd *cobra.Command, args []string) error {
 	}
 
 	// Run
-	return bootstrap.Run(ctx, b, manifestsBase, installOptions, secretOpts, syncOpts, rootArgs.pollInterval, rootArgs.timeout)
+	if err = bootstrap.Run(ctx, b, manifestsBase, installOptions, secretOpts, syncOpts, rootArgs.pollInterval, rootArgs.timeout); err != nil {
+		return err
+	}
+	if bootstrapArgs.dryRun {
+		return b.Plan().Print(cmd.OutOrStdout())
+	}
+	return nil
 }
 
 // transportForURL constructs a transport.AuthMethod based on the scheme
@@ -26,7 +26,6 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/fluxcd/flux2/internal/bootstrap"
-	"github.com/fluxcd/flux2/internal/bootstrap/git/gogit"
 	"github.com/fluxcd/flux2/internal/bootstrap/provider"
 	"github.com/fluxcd/flux2/internal/flags"
 	"github.com/fluxcd/flux2/internal/utils"
@@ -65,7 +64,10 @@ the bootstrap command will perform an upgrade if needed.`,
   flux bootstrap github --owner=<organization> --repository=<repository name> --hostname=<domain> --token-auth
 
   # Run bootstrap for an existing repository with a branch named main
-  flux bootstrap github --owner=<organization> --repository=<repository name> --branch=main`,
+  flux bootstrap github --owner=<organization> --repository=<repository name> --branch=main
+
+  # Run bootstrap for a protected branch by proposing the manifests in a pull request
+  flux bootstrap github --owner=<organization> --repository=<repository name> --pull-request --wait-for-merge`,
 	RunE: bootstrapGitHubCmdRun,
 }
 
@@ -80,6 +82,13 @@ type githubFlags struct {
 	teams        []string
 	readWriteKey bool
 	reconcile    bool
+	pullRequest  bool
+	waitForMerge bool
+
+	webhookReceiver    bool
+	webhookReceiverURL string
+
+	commitStatus bool
 }
 
 const (
@@ -101,6 +110,11 @@ func init() {
 	bootstrapGitHubCmd.Flags().Var(&githubArgs.path, "path", "path relative to the repository root, when specified the cluster sync will be scoped to this path")
 	bootstrapGitHubCmd.Flags().BoolVar(&githubArgs.readWriteKey, "read-write-key", false, "if true, the deploy key is configured with read/write permissions")
 	bootstrapGitHubCmd.Flags().BoolVar(&githubArgs.reconcile, "reconcile", false, "if true, the configured options are also reconciled if the repository already exists")
+	bootstrapGitHubCmd.Flags().BoolVar(&githubArgs.pullRequest, "pull-request", false, "if true, the manifests are committed to a new branch and proposed in a pull request instead of being pushed to the branch")
+	bootstrapGitHubCmd.Flags().BoolVar(&githubArgs.waitForMerge, "wait-for-merge", false, "if true, wait for the pull request to be merged and install the components on the cluster, requires --pull-request")
+	bootstrapGitHubCmd.Flags().BoolVar(&githubArgs.webhookReceiver, "with-webhook-receiver", false, "if true, a Receiver is committed next to the sync manifests and registered as push webhook of the repository, requires --webhook-receiver-url")
+	bootstrapGitHubCmd.Flags().StringVar(&githubArgs.webhookReceiverURL, "webhook-receiver-url", "", "external URL the notification-controller webhook receiver is exposed at, e.g. https://flux-webhook.example.com")
+	bootstrapGitHubCmd.Flags().BoolVar(&githubArgs.commitStatus, "with-commit-status", false, "if true, a notification Provider and Alert reporting the reconciliation status on the commits of the repository are committed next to the sync manifests")
 
 	bootstrapCmd.AddCommand(bootstrapGitHubCmd)
 }
@@ -114,11 +128,31 @@ func bootstrapGitHubCmdRun(cmd *cobra.Command, args []string) error {
 	if err := bootstrapValidate(); err != nil {
 		return err
 	}
+	if githubArgs.waitForMerge && !githubArgs.pullRequest {
+		return fmt.Errorf("--wait-for-merge requires --pull-request")
+	}
+	if githubArgs.pullRequest && bootstrapArgs.rollbackOnFailure {
+		return fmt.Errorf("--rollback-on-failure cannot be used with --pull-request")
+	}
+	if githubArgs.pullRequest && bootstrapArgs.singleCommit {
+		return fmt.Errorf("--single-commit cannot be used with --pull-request")
+	}
+	if githubArgs.webhookReceiver {
+		if githubArgs.webhookReceiverURL == "" {
+			return fmt.Errorf("--with-webhook-receiver requires --webhook-receiver-url")
+		}
+		if !utils.ContainsItemString(bootstrapComponents(), "notification-controller") {
+			return fmt.Errorf("--with-webhook-receiver requires the notification-controller component")
+		}
+	}
+	if githubArgs.commitStatus && !utils.ContainsItemString(bootstrapComponents(), "notification-controller") {
+		return fmt.Errorf("--with-commit-status requires the notification-controller component")
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
-	kubeClient, err := utils.KubeClient(rootArgs.kubeconfig, rootArgs.kubecontext)
+	kubeClient, err := newKubeClient()
 	if err != nil {
 		return err
 	}
@@ -144,18 +178,25 @@ func bootstrapGitHubCmdRun(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	// Lazy go-git repository
+	// Lazy Git repository
 	tmpDir, err := os.MkdirTemp("", "flux-bootstrap-")
 	if err != nil {
 		return fmt.Errorf("failed to create temporary working dir: %w", err)
 	}
 	defer os.RemoveAll(tmpDir)
-	gitClient := gogit.New(tmpDir, &http.BasicAuth{
+	gitClient, err := bootstrapGitClient(tmpDir, &http.BasicAuth{
 		Username: githubArgs.owner,
 		Password: ghToken,
 	})
+	if err != nil {
+		return err
+	}
 
 	// Install manifest config
+	releases, err := releaseSource()
+	if err != nil {
+		return err
+	}
 	installOptions := install.Options{
 		BaseURL:                rootArgs.defaults.BaseURL,
 		Version:                bootstrapArgs.version,
@@ -172,6 +213,15 @@ func bootstrapGitHubCmdRun(cmd *cobra.Command, args []string) error {
 		TargetPath:             githubArgs.path.ToSlash(),
 		ClusterDomain:          bootstrapArgs.clusterDomain,
 		TolerationKeys:         bootstrapArgs.tolerationKeys,
+		CacheDir:               manifestsCacheDir(bootstrapArgs.noCache),
+		ReleaseSource:          releases,
+		Patches:                bootstrapArgs.patches,
+		Namespaced:             bootstrapArgs.namespaced,
+		TargetNamespaces:       bootstrapArgs.targetNamespaces,
+		MultiTenant:            bootstrapArgs.multiTenant,
+		DefaultServiceAccount:  bootstrapArgs.defaultSA,
+		ImageDigests:           bootstrapArgs.digests,
+		ResolveImageDigests:    bootstrapArgs.imageDigests,
 	}
 	if customBaseURL := bootstrapArgs.manifestsPath; customBaseURL != "" {
 		installOptions.BaseURL = customBaseURL
@@ -222,6 +272,7 @@ func bootstrapGitHubCmdRun(cmd *cobra.Command, args []string) error {
 		bootstrap.WithBootstrapTransportType("https"),
 		bootstrap.WithAuthor(bootstrapArgs.authorName, bootstrapArgs.authorEmail),
 		bootstrap.WithCommitMessageAppendix(bootstrapArgs.commitMessageAppendix),
+		bootstrap.WithGitCommitSigning(bootstrapArgs.gpgKeyRingPath, bootstrapArgs.gpgPassphrase, bootstrapArgs.gpgKeyID),
 		bootstrap.WithProviderTeamPermissions(mapTeamSlice(githubArgs.teams, ghDefaultPermission)),
 		bootstrap.WithReadWriteKeyPermissions(githubArgs.readWriteKey),
 		bootstrap.WithKubeconfig(rootArgs.kubeconfig, rootArgs.kubecontext),
@@ -239,6 +290,24 @@ func bootstrapGitHubCmdRun(cmd *cobra.Command, args []string) error {
 	if githubArgs.reconcile {
 		bootstrapOpts = append(bootstrapOpts, bootstrap.WithReconcile())
 	}
+	if githubArgs.pullRequest {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithPullRequest(githubArgs.waitForMerge))
+	}
+	if githubArgs.webhookReceiver {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithWebhookReceiver(githubArgs.webhookReceiverURL))
+	}
+	if githubArgs.commitStatus {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithCommitStatus(ghToken))
+	}
+	if bootstrapArgs.dryRun {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithDryRun())
+	}
+	if bootstrapArgs.rollbackOnFailure {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithRollbackOnFailure())
+	}
+	if bootstrapArgs.singleCommit {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithSingleCommit())
+	}
 
 	// Setup bootstrapper with constructed configs
 	b, err := bootstrap.NewGitProviderBootstrapper(gitClient, providerClient, kubeClient, bootstrapOpts...)
@@ -247,5 +316,11 @@ func bootstrapGitHubCmdRun(cmd *cobra.Command, args []string) error {
 	}
 
 	// Run
-	return bootstrap.Run(ctx, b, manifestsBase, installOptions, secretOpts, syncOpts, rootArgs.pollInterval, rootArgs.timeout)
+	if err = bootstrap.Run(ctx, b, manifestsBase, installOptions, secretOpts, syncOpts, rootArgs.pollInterval, rootArgs.timeout); err != nil {
+		return err
+	}
+	if bootstrapArgs.dryRun {
+		return b.Plan().Print(cmd.OutOrStdout())
+	}
+	return nil
 }
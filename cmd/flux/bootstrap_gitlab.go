@@ -28,7 +28,6 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/fluxcd/flux2/internal/bootstrap"
-	"github.com/fluxcd/flux2/internal/bootstrap/git/gogit"
 	"github.com/fluxcd/flux2/internal/bootstrap/provider"
 	"github.com/fluxcd/flux2/internal/flags"
 	"github.com/fluxcd/flux2/internal/utils"
@@ -64,7 +63,10 @@ the bootstrap command will perform an upgrade if needed.`,
   flux bootstrap gitlab --owner=<group> --repository=<repository name> --hostname=<domain> --token-auth
 
   # Run bootstrap for a an existing repository with a branch named main
-  flux bootstrap gitlab --owner=<organization> --repository=<repository name> --branch=main --token-auth`,
+  flux bootstrap gitlab --owner=<organization> --repository=<repository name> --branch=main --token-auth
+
+  # Run bootstrap for a protected branch by proposing the manifests in a pull request
+  flux bootstrap gitlab --owner=<group> --repository=<repository name> --pull-request --wait-for-merge`,
 	RunE: bootstrapGitLabCmdRun,
 }
 
@@ -86,6 +88,13 @@ type gitlabFlags struct {
 	teams        []string
 	readWriteKey bool
 	reconcile    bool
+	pullRequest  bool
+	waitForMerge bool
+
+	webhookReceiver    bool
+	webhookReceiverURL string
+
+	commitStatus bool
 }
 
 var gitlabArgs gitlabFlags
@@ -101,6 +110,11 @@ func init() {
 	bootstrapGitLabCmd.Flags().Var(&gitlabArgs.path, "path", "path relative to the repository root, when specified the cluster sync will be scoped to this path")
 	bootstrapGitLabCmd.Flags().BoolVar(&gitlabArgs.readWriteKey, "read-write-key", false, "if true, the deploy key is configured with read/write permissions")
 	bootstrapGitLabCmd.Flags().BoolVar(&gitlabArgs.reconcile, "reconcile", false, "if true, the configured options are also reconciled if the repository already exists")
+	bootstrapGitLabCmd.Flags().BoolVar(&gitlabArgs.pullRequest, "pull-request", false, "if true, the manifests are committed to a new branch and proposed in a pull request instead of being pushed to the branch")
+	bootstrapGitLabCmd.Flags().BoolVar(&gitlabArgs.waitForMerge, "wait-for-merge", false, "if true, wait for the pull request to be merged and install the components on the cluster, requires --pull-request")
+	bootstrapGitLabCmd.Flags().BoolVar(&gitlabArgs.webhookReceiver, "with-webhook-receiver", false, "if true, a Receiver is committed next to the sync manifests and registered as push webhook of the repository, requires --webhook-receiver-url")
+	bootstrapGitLabCmd.Flags().StringVar(&gitlabArgs.webhookReceiverURL, "webhook-receiver-url", "", "external URL the notification-controller webhook receiver is exposed at, e.g. https://flux-webhook.example.com")
+	bootstrapGitLabCmd.Flags().BoolVar(&gitlabArgs.commitStatus, "with-commit-status", false, "if true, a notification Provider and Alert reporting the reconciliation status on the commits of the repository are committed next to the sync manifests")
 
 	bootstrapCmd.AddCommand(bootstrapGitLabCmd)
 }
@@ -121,11 +135,31 @@ func bootstrapGitLabCmdRun(cmd *cobra.Command, args []string) error {
 	if err := bootstrapValidate(); err != nil {
 		return err
 	}
+	if gitlabArgs.waitForMerge && !gitlabArgs.pullRequest {
+		return fmt.Errorf("--wait-for-merge requires --pull-request")
+	}
+	if gitlabArgs.pullRequest && bootstrapArgs.rollbackOnFailure {
+		return fmt.Errorf("--rollback-on-failure cannot be used with --pull-request")
+	}
+	if gitlabArgs.pullRequest && bootstrapArgs.singleCommit {
+		return fmt.Errorf("--single-commit cannot be used with --pull-request")
+	}
+	if gitlabArgs.webhookReceiver {
+		if gitlabArgs.webhookReceiverURL == "" {
+			return fmt.Errorf("--with-webhook-receiver requires --webhook-receiver-url")
+		}
+		if !utils.ContainsItemString(bootstrapComponents(), "notification-controller") {
+			return fmt.Errorf("--with-webhook-receiver requires the notification-controller component")
+		}
+	}
+	if gitlabArgs.commitStatus && !utils.ContainsItemString(bootstrapComponents(), "notification-controller") {
+		return fmt.Errorf("--with-commit-status requires the notification-controller component")
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
-	kubeClient, err := utils.KubeClient(rootArgs.kubeconfig, rootArgs.kubecontext)
+	kubeClient, err := newKubeClient()
 	if err != nil {
 		return err
 	}
@@ -157,18 +191,25 @@ func bootstrapGitLabCmdRun(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	// Lazy go-git repository
+	// Lazy Git repository
 	tmpDir, err := os.MkdirTemp("", "flux-bootstrap-")
 	if err != nil {
 		return fmt.Errorf("failed to create temporary working dir: %w", err)
 	}
 	defer os.RemoveAll(tmpDir)
-	gitClient := gogit.New(tmpDir, &http.BasicAuth{
+	gitClient, err := bootstrapGitClient(tmpDir, &http.BasicAuth{
 		Username: gitlabArgs.owner,
 		Password: glToken,
 	})
+	if err != nil {
+		return err
+	}
 
 	// Install manifest config
+	releases, err := releaseSource()
+	if err != nil {
+		return err
+	}
 	installOptions := install.Options{
 		BaseURL:                rootArgs.defaults.BaseURL,
 		Version:                bootstrapArgs.version,
@@ -185,6 +226,15 @@ func bootstrapGitLabCmdRun(cmd *cobra.Command, args []string) error {
 		TargetPath:             gitlabArgs.path.ToSlash(),
 		ClusterDomain:          bootstrapArgs.clusterDomain,
 		TolerationKeys:         bootstrapArgs.tolerationKeys,
+		CacheDir:               manifestsCacheDir(bootstrapArgs.noCache),
+		ReleaseSource:          releases,
+		Patches:                bootstrapArgs.patches,
+		Namespaced:             bootstrapArgs.namespaced,
+		TargetNamespaces:       bootstrapArgs.targetNamespaces,
+		MultiTenant:            bootstrapArgs.multiTenant,
+		DefaultServiceAccount:  bootstrapArgs.defaultSA,
+		ImageDigests:           bootstrapArgs.digests,
+		ResolveImageDigests:    bootstrapArgs.imageDigests,
 	}
 	if customBaseURL := bootstrapArgs.manifestsPath; customBaseURL != "" {
 		installOptions.BaseURL = customBaseURL
@@ -238,6 +288,7 @@ func bootstrapGitLabCmdRun(cmd *cobra.Command, args []string) error {
 		bootstrap.WithBootstrapTransportType("https"),
 		bootstrap.WithAuthor(bootstrapArgs.authorName, bootstrapArgs.authorEmail),
 		bootstrap.WithCommitMessageAppendix(bootstrapArgs.commitMessageAppendix),
+		bootstrap.WithGitCommitSigning(bootstrapArgs.gpgKeyRingPath, bootstrapArgs.gpgPassphrase, bootstrapArgs.gpgKeyID),
 		bootstrap.WithProviderTeamPermissions(mapTeamSlice(gitlabArgs.teams, glDefaultPermission)),
 		bootstrap.WithReadWriteKeyPermissions(gitlabArgs.readWriteKey),
 		bootstrap.WithKubeconfig(rootArgs.kubeconfig, rootArgs.kubecontext),
@@ -255,6 +306,24 @@ func bootstrapGitLabCmdRun(cmd *cobra.Command, args []string) error {
 	if gitlabArgs.reconcile {
 		bootstrapOpts = append(bootstrapOpts, bootstrap.WithReconcile())
 	}
+	if gitlabArgs.pullRequest {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithPullRequest(gitlabArgs.waitForMerge))
+	}
+	if gitlabArgs.webhookReceiver {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithWebhookReceiver(gitlabArgs.webhookReceiverURL))
+	}
+	if gitlabArgs.commitStatus {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithCommitStatus(glToken))
+	}
+	if bootstrapArgs.dryRun {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithDryRun())
+	}
+	if bootstrapArgs.rollbackOnFailure {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithRollbackOnFailure())
+	}
+	if bootstrapArgs.singleCommit {
+		bootstrapOpts = append(bootstrapOpts, bootstrap.WithSingleCommit())
+	}
 
 	// Setup bootstrapper with constructed configs
 	b, err := bootstrap.NewGitProviderBootstrapper(gitClient, providerClient, kubeClient, bootstrapOpts...)
@@ -263,5 +332,11 @@ func bootstrapGitLabCmdRun(cmd *cobra.Command, args []string) error {
 	}
 
 	// Run
-	return bootstrap.Run(ctx, b, manifestsBase, installOptions, secretOpts, syncOpts, rootArgs.pollInterval, rootArgs.timeout)
+	if err = bootstrap.Run(ctx, b, manifestsBase, installOptions, secretOpts, syncOpts, rootArgs.pollInterval, rootArgs.timeout); err != nil {
+		return err
+	}
+	if bootstrapArgs.dryRun {
+		return b.Plan().Print(cmd.OutOrStdout())
+	}
+	return nil
 }
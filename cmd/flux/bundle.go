@@ -0,0 +1,105 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/fluxcd/flux2/pkg/manifestgen/install"
+)
+
+// exportBundle writes the air-gapped install bundle for the given options to
+// the file at bundlePath. The Kustomize base is taken from the embedded
+// manifests when the version matches the CLI version, otherwise it is
+// downloaded from GitHub.
+func exportBundle(ctx context.Context, opts install.Options, bundlePath string, includeLayers bool) error {
+	manifestsBase := ""
+	if isEmbeddedVersion(opts.Version) {
+		tmpDir, err := os.MkdirTemp("", "flux-manifests-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmpDir)
+		if err := writeEmbeddedManifests(tmpDir); err != nil {
+			return err
+		}
+		manifestsBase = tmpDir
+	}
+
+	f, err := os.Create(bundlePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := install.ExportBundle(ctx, opts, manifestsBase, includeLayers, f,
+		remote.WithAuthFromKeychain(authn.DefaultKeychain)); err != nil {
+		os.Remove(bundlePath)
+		return err
+	}
+	return f.Close()
+}
+
+// loadBundle extracts the air-gapped install bundle at bundlePath into dir.
+// When the bundle contains the image layers and the target registry differs
+// from the one the images were exported from, the images are pushed to the
+// target registry, unless push is false.
+func loadBundle(ctx context.Context, bundlePath, dir, registry string, components []string, push bool) (*install.Bundle, error) {
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	bundle, err := install.OpenBundle(f, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, component := range components {
+		found := false
+		for _, image := range bundle.Images {
+			if strings.HasSuffix(image.Name, "/"+component) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("component %s is not included in bundle %s", component, bundlePath)
+		}
+	}
+
+	if !bundle.HasImageLayers() || strings.TrimSuffix(registry, "/") == bundle.Registry {
+		return bundle, nil
+	}
+	if !push {
+		logger.Actionf("skipping push of %d image(s) to %s", len(bundle.Images), registry)
+		return bundle, nil
+	}
+	logger.Actionf("pushing %d image(s) to %s", len(bundle.Images), registry)
+	if err := bundle.PushImages(ctx, registry, remote.WithAuthFromKeychain(authn.DefaultKeychain)); err != nil {
+		return nil, err
+	}
+	logger.Successf("images pushed")
+	return bundle, nil
+}
@@ -19,21 +19,27 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"os"
-	"os/exec"
+	"strings"
 	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/spf13/cobra"
 	v1 "k8s.io/api/apps/v1"
-	apimachineryversion "k8s.io/apimachinery/pkg/version"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
 
+	helmv2 "github.com/fluxcd/helm-controller/api/v2beta1"
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
 	"github.com/fluxcd/pkg/version"
 
+	"github.com/fluxcd/flux2/internal/bootstrap/git/gitcli"
 	"github.com/fluxcd/flux2/internal/utils"
-	"github.com/fluxcd/flux2/pkg/manifestgen/install"
 	"github.com/fluxcd/flux2/pkg/status"
 )
 
@@ -41,199 +47,573 @@ var checkCmd = &cobra.Command{
 	Use:   "check",
 	Short: "Check requirements and installation",
 	Long: `The check command will perform a series of checks to validate that
-the local environment is configured correctly and if the installed components are healthy.`,
+the local environment is configured correctly and if the installed components are healthy.
+
+The command exits with code 1 if a check failed. Warnings, such as a newer
+version of the CLI being available, don't fail the command unless
+--fail-on-warning is set, in which case it exits with code 2 if no check
+failed but some reported warnings.`,
 	Example: `  # Run pre-installation checks
   flux check --pre
 
   # Run installation checks
-  flux check`,
+  flux check
+
+  # Check only the components and the CRDs, and print the results as JSON
+  flux check --check=components,crds -o json`,
 	RunE: runCheckCmd,
 }
 
 type checkFlags struct {
-	pre             bool
-	components      []string
-	extraComponents []string
+	pre              bool
+	components       []string
+	extraComponents  []string
+	checks           []string
+	output           string
+	as               string
+	asGroups         []string
+	failOnWarning    bool
+	networkPolicy    bool
+	namespaced       bool
+	targetNamespaces []string
+}
+
+var checkArgs checkFlags
+
+const (
+	checkExitFailure = 1
+	checkExitWarning = 2
+)
+
+// checkStatus is the outcome of a check.
+type checkStatus string
+
+const (
+	checkPassed  checkStatus = "passed"
+	checkWarning checkStatus = "warning"
+	checkFailed  checkStatus = "failed"
+	checkSkipped checkStatus = "skipped"
+)
+
+// checkResult is the result of a check for one of its subjects,
+// e.g. a component deployment for the components check.
+type checkResult struct {
+	Check       string      `json:"check"`
+	Name        string      `json:"name"`
+	Status      checkStatus `json:"status"`
+	Message     string      `json:"message"`
+	Remediation string      `json:"remediation,omitempty"`
+	// Details are printed after the message in the text output.
+	Details []string `json:"details,omitempty"`
 }
 
-type kubectlVersion struct {
-	ClientVersion *apimachineryversion.Info `json:"clientVersion"`
+// checkReport is the machine-readable output of the check command.
+type checkReport struct {
+	Status checkStatus   `json:"status"`
+	Checks []checkResult `json:"checks"`
 }
 
-var checkArgs checkFlags
+// checkDefinition is a named check, pre checks
+// can be run before Flux is installed.
+type checkDefinition struct {
+	name string
+	pre  bool
+	run  func(ctx context.Context) []checkResult
+}
+
+var checkDefinitions = []checkDefinition{
+	{name: "flux", pre: true, run: fluxCheck},
+	{name: "git", pre: true, run: gitCheck},
+	{name: "kubernetes", pre: true, run: func(ctx context.Context) []checkResult { return kubernetesCheck(ctx, ">=1.16.0-0") }},
+	{name: "rbac", pre: true, run: rbacCheck},
+	{name: "components", run: componentsCheck},
+	{name: "crds", run: crdsCheck},
+	{name: "tenants", run: tenantsCheck},
+}
+
+var checkOutputFormats = []string{"json", "yaml"}
 
 func init() {
+	var names []string
+	for _, c := range checkDefinitions {
+		names = append(names, c.name)
+	}
+
 	checkCmd.Flags().BoolVarP(&checkArgs.pre, "pre", "", false,
 		"only run pre-installation checks")
 	checkCmd.Flags().StringSliceVar(&checkArgs.components, "components", rootArgs.defaults.Components,
 		"list of components, accepts comma-separated values")
 	checkCmd.Flags().StringSliceVar(&checkArgs.extraComponents, "components-extra", nil,
 		"list of components in addition to those supplied or defaulted, accepts comma-separated values")
+	checkCmd.Flags().StringSliceVar(&checkArgs.checks, "check", nil,
+		fmt.Sprintf("only run the given checks, accepts comma-separated values, available options are: (%s)", strings.Join(names, ", ")))
+	checkCmd.Flags().StringVar(&checkArgs.as, "as", "",
+		"username to impersonate for the RBAC check")
+	checkCmd.Flags().StringSliceVar(&checkArgs.asGroups, "as-group", nil,
+		"groups to impersonate for the RBAC check, accepts comma-separated values")
+	checkCmd.Flags().BoolVar(&checkArgs.networkPolicy, "network-policy", rootArgs.defaults.NetworkPolicy,
+		"check the permissions to apply the network policies of the install, for the RBAC check")
+	checkCmd.Flags().BoolVar(&checkArgs.namespaced, "namespaced", false,
+		"check the permissions of a namespaced install, limited to the install namespace and --target-namespaces, for the RBAC check")
+	checkCmd.Flags().StringSliceVar(&checkArgs.targetNamespaces, "target-namespaces", nil,
+		"namespaces the reconcilers of a namespaced install can apply resources to, for the RBAC check")
+	checkCmd.Flags().BoolVar(&checkArgs.failOnWarning, "fail-on-warning", false,
+		fmt.Sprintf("exit with code %d if no check failed but some reported warnings, by default warnings don't change the exit code", checkExitWarning))
+	checkCmd.Flags().StringVarP(&checkArgs.output, "output", "o", "",
+		fmt.Sprintf("print the check results in the given format instead of logging them, available options are: (%s)", strings.Join(checkOutputFormats, ", ")))
 	rootCmd.AddCommand(checkCmd)
 }
 
 func runCheckCmd(cmd *cobra.Command, args []string) error {
+	if checkArgs.output != "" && !utils.ContainsItemString(checkOutputFormats, checkArgs.output) {
+		return fmt.Errorf("unsupported output format '%s', must be one of: %s",
+			checkArgs.output, strings.Join(checkOutputFormats, ", "))
+	}
+	selected, err := selectChecks(checkArgs.checks, checkArgs.pre)
+	if err != nil {
+		return err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
-	logger.Actionf("checking prerequisites")
-	checkFailed := false
+	text := checkArgs.output == ""
+	report := runChecks(ctx, selected, text)
+	if !text {
+		if err := printCheckReport(cmd.OutOrStdout(), report, checkArgs.output); err != nil {
+			return err
+		}
+	}
 
-	fluxCheck()
+	if text && report.Status != checkFailed {
+		if checkArgs.pre {
+			logger.Successf("prerequisites checks passed")
+		} else {
+			logger.Successf("all checks passed")
+		}
+	}
+	if code := checkExitCode(report.Status, checkArgs.failOnWarning); code != 0 {
+		os.Exit(code)
+	}
+	return nil
+}
+
+// runChecks runs the given checks and returns their results, logging
+// them if text is true. The status of the report is the most severe
+// status of the results.
+func runChecks(ctx context.Context, checks []checkDefinition, text bool) checkReport {
+	report := checkReport{Status: checkPassed, Checks: []checkResult{}}
+	actionLogged := map[bool]bool{}
+	for _, c := range checks {
+		if text && !actionLogged[c.pre] {
+			if c.pre {
+				logger.Actionf("checking prerequisites")
+			} else {
+				logger.Actionf("checking controllers")
+			}
+			actionLogged[c.pre] = true
+		}
+		for _, result := range c.run(ctx) {
+			if text {
+				logCheckResult(result)
+			}
+			report.Checks = append(report.Checks, result)
+			if result.Status == checkFailed || (result.Status == checkWarning && report.Status == checkPassed) {
+				report.Status = result.Status
+			}
+		}
+	}
+	return report
+}
 
-	if !kubectlCheck(ctx, ">=1.18.0-0") {
-		checkFailed = true
+// printCheckReport writes the report to w in the given output format.
+func printCheckReport(w io.Writer, report checkReport, format string) error {
+	switch format {
+	case "json":
+		// the messages hold version constraints, keep them readable
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case "yaml":
+		data, err := yaml.Marshal(report)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(w, string(data))
+	default:
+		return fmt.Errorf("unsupported output format '%s', must be one of: %s",
+			format, strings.Join(checkOutputFormats, ", "))
 	}
+	return nil
+}
 
-	if !kubernetesCheck(">=1.16.0-0") {
-		checkFailed = true
+// checkExitCode returns the exit code of the check command for the
+// given report status. Warnings only result in a non-zero exit code if
+// failOnWarning is true.
+func checkExitCode(status checkStatus, failOnWarning bool) int {
+	switch {
+	case status == checkFailed:
+		return checkExitFailure
+	case status == checkWarning && failOnWarning:
+		return checkExitWarning
 	}
+	return 0
+}
 
-	if checkArgs.pre {
-		if checkFailed {
-			os.Exit(1)
+// selectChecks returns the checks matching the given names, or all
+// the checks when names is empty, limited to the pre checks if pre is true.
+func selectChecks(names []string, pre bool) ([]checkDefinition, error) {
+	for _, name := range names {
+		found := false
+		for _, c := range checkDefinitions {
+			if c.name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown check '%s'", name)
 		}
-		logger.Successf("prerequisites checks passed")
-		return nil
 	}
 
-	logger.Actionf("checking controllers")
-	if !componentsCheck() {
-		checkFailed = true
+	var selected []checkDefinition
+	for _, c := range checkDefinitions {
+		if pre && !c.pre {
+			continue
+		}
+		if len(names) > 0 && !utils.ContainsItemString(names, c.name) {
+			continue
+		}
+		selected = append(selected, c)
 	}
-	if checkFailed {
-		os.Exit(1)
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("the selected checks are not pre-installation checks")
 	}
-	logger.Successf("all checks passed")
-	return nil
+	return selected, nil
 }
 
-func fluxCheck() {
+func logCheckResult(result checkResult) {
+	switch result.Status {
+	case checkPassed:
+		logger.Successf("%s", result.Message)
+	case checkWarning:
+		logger.Warningf("%s", result.Message)
+	case checkSkipped:
+		logger.Actionf("%s", result.Message)
+	default:
+		logger.Failuref("%s", result.Message)
+	}
+	for _, detail := range result.Details {
+		logger.Actionf("%s", detail)
+	}
+}
+
+func fluxCheck(ctx context.Context) []checkResult {
 	curSv, err := version.ParseVersion(VERSION)
 	if err != nil {
-		return
+		return nil
 	}
 	// Exclude development builds.
 	if curSv.Prerelease() != "" {
-		return
+		return nil
+	}
+	skipped := func(err error) []checkResult {
+		return []checkResult{{
+			Check:   "flux",
+			Name:    "flux",
+			Status:  checkSkipped,
+			Message: fmt.Sprintf("flux version check skipped: %s", err.Error()),
+		}}
+	}
+	releases, err := releaseSource()
+	if err != nil {
+		return skipped(err)
 	}
-	latest, err := install.GetLatestVersion()
+	// don't hold the other checks when the releases can't be reached
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	latest, err := releases.LatestVersion(ctx)
 	if err != nil {
-		return
+		return skipped(err)
 	}
 	latestSv, err := version.ParseVersion(latest)
 	if err != nil {
-		return
+		return nil
 	}
 	if latestSv.GreaterThan(curSv) {
-		logger.Failuref("flux %s <%s (new version is available, please upgrade)", curSv, latestSv)
+		return []checkResult{{
+			Check:       "flux",
+			Name:        "flux",
+			Status:      checkWarning,
+			Message:     fmt.Sprintf("flux %s <%s (new version is available, please upgrade)", curSv, latestSv),
+			Remediation: "upgrade the flux CLI to the latest version",
+		}}
 	}
+	return []checkResult{{
+		Check:   "flux",
+		Name:    "flux",
+		Status:  checkPassed,
+		Message: fmt.Sprintf("flux %s is the latest version", curSv),
+	}}
 }
 
-func kubectlCheck(ctx context.Context, constraint string) bool {
-	_, err := exec.LookPath("kubectl")
-	if err != nil {
-		logger.Failuref("kubectl not found")
-		return false
+// gitCheck checks the version of the git binary used by bootstrap with
+// --git-client=git. As the default go-git client doesn't need it, a
+// missing or older git is reported as a warning.
+func gitCheck(ctx context.Context) []checkResult {
+	warning := func(message string) []checkResult {
+		return []checkResult{{
+			Check:       "git",
+			Name:        "git",
+			Status:      checkWarning,
+			Message:     message,
+			Remediation: fmt.Sprintf("install git %s or later to bootstrap with --git-client=git", gitcli.MinVersion()),
+		}}
 	}
 
-	kubectlArgs := []string{"version", "--client", "--output", "json"}
-	output, err := utils.ExecKubectlCommand(ctx, utils.ModeCapture, rootArgs.kubeconfig, rootArgs.kubecontext, kubectlArgs...)
+	v, err := gitcli.Version()
 	if err != nil {
-		logger.Failuref("kubectl version can't be determined")
-		return false
+		return warning(err.Error())
 	}
-
-	kv := &kubectlVersion{}
-	if err = json.Unmarshal([]byte(output), kv); err != nil {
-		logger.Failuref("kubectl version output can't be unmarshalled")
-		return false
-	}
-
-	v, err := version.ParseVersion(kv.ClientVersion.GitVersion)
-	if err != nil {
-		logger.Failuref("kubectl version can't be parsed")
-		return false
+	if err := gitcli.CheckVersion(v); err != nil {
+		return warning(err.Error())
 	}
+	return []checkResult{{
+		Check:   "git",
+		Name:    "git",
+		Status:  checkPassed,
+		Message: fmt.Sprintf("%s >=%s", v, gitcli.MinVersion()),
+	}}
+}
 
-	c, _ := semver.NewConstraint(constraint)
-	if !c.Check(v) {
-		logger.Failuref("kubectl version %s < %s", v.Original(), constraint)
-		return false
+func kubernetesCheck(ctx context.Context, constraint string) []checkResult {
+	failure := func(message, remediation string) []checkResult {
+		return []checkResult{{
+			Check:       "kubernetes",
+			Name:        "kubernetes",
+			Status:      checkFailed,
+			Message:     message,
+			Remediation: remediation,
+		}}
 	}
+	const connectRemediation = "check that the kubeconfig context points to a reachable cluster"
 
-	logger.Successf("kubectl %s %s", v.String(), constraint)
-	return true
-}
-
-func kubernetesCheck(constraint string) bool {
 	cfg, err := utils.KubeConfig(rootArgs.kubeconfig, rootArgs.kubecontext)
 	if err != nil {
-		logger.Failuref("Kubernetes client initialization failed: %s", err.Error())
-		return false
+		return failure(fmt.Sprintf("Kubernetes client initialization failed: %s", err.Error()), connectRemediation)
 	}
 
 	clientSet, err := kubernetes.NewForConfig(cfg)
 	if err != nil {
-		logger.Failuref("Kubernetes client initialization failed: %s", err.Error())
-		return false
+		return failure(fmt.Sprintf("Kubernetes client initialization failed: %s", err.Error()), connectRemediation)
 	}
 
 	kv, err := clientSet.Discovery().ServerVersion()
 	if err != nil {
-		logger.Failuref("Kubernetes API call failed: %s", err.Error())
-		return false
+		return failure(fmt.Sprintf("Kubernetes API call failed: %s", err.Error()), connectRemediation)
 	}
 
 	v, err := version.ParseVersion(kv.String())
 	if err != nil {
-		logger.Failuref("Kubernetes version can't be determined")
-		return false
+		return failure("Kubernetes version can't be determined", "")
 	}
 
 	c, _ := semver.NewConstraint(constraint)
 	if !c.Check(v) {
-		logger.Failuref("Kubernetes version %s < %s", v.Original(), constraint)
-		return false
+		return failure(fmt.Sprintf("Kubernetes version %s < %s", v.Original(), constraint),
+			fmt.Sprintf("upgrade the cluster to Kubernetes %s", constraint))
 	}
 
-	logger.Successf("Kubernetes %s %s", v.String(), constraint)
-	return true
+	return []checkResult{{
+		Check:   "kubernetes",
+		Name:    "kubernetes",
+		Status:  checkPassed,
+		Message: fmt.Sprintf("Kubernetes %s %s", v.String(), constraint),
+	}}
 }
 
-func componentsCheck() bool {
-	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
-	defer cancel()
+func componentsCheck(ctx context.Context) []checkResult {
+	failure := func(err error) []checkResult {
+		return []checkResult{{
+			Check:       "components",
+			Name:        "components",
+			Status:      checkFailed,
+			Message:     fmt.Sprintf("failed to list the components: %s", err.Error()),
+			Remediation: "check that the kubeconfig context points to a reachable cluster",
+		}}
+	}
 
 	kubeConfig, err := utils.KubeConfig(rootArgs.kubeconfig, rootArgs.kubecontext)
 	if err != nil {
-		return false
+		return failure(err)
 	}
 
-	statusChecker, err := status.NewStatusChecker(kubeConfig, time.Second, rootArgs.timeout, logger)
+	// the results are reported per deployment instead of being logged
+	statusChecker, err := status.NewStatusChecker(kubeConfig, time.Second, rootArgs.timeout, stderrLogger{stderr: io.Discard})
 	if err != nil {
-		return false
+		return failure(err)
 	}
 
-	kubeClient, err := utils.KubeClient(rootArgs.kubeconfig, rootArgs.kubecontext)
+	kubeClient, err := newKubeClient()
 	if err != nil {
-		return false
+		return failure(err)
 	}
 
-	ok := true
 	selector := client.MatchingLabels{"app.kubernetes.io/instance": rootArgs.namespace}
 	var list v1.DeploymentList
-	if err := kubeClient.List(ctx, &list, client.InNamespace(rootArgs.namespace), selector); err == nil {
-		for _, d := range list.Items {
-			if ref, err := buildComponentObjectRefs(d.Name); err == nil {
-				if err := statusChecker.Assess(ref...); err != nil {
-					ok = false
-				}
+	if err := kubeClient.List(ctx, &list, client.InNamespace(rootArgs.namespace), selector); err != nil {
+		return failure(err)
+	}
+
+	var results []checkResult
+	for _, d := range list.Items {
+		ref, err := buildComponentObjectRefs(d.Name)
+		if err != nil {
+			continue
+		}
+		result := checkResult{
+			Check:   "components",
+			Name:    d.Name,
+			Status:  checkPassed,
+			Message: fmt.Sprintf("%s: deployment ready", d.Name),
+		}
+		if err := statusChecker.Assess(ref...); err != nil {
+			result.Status = checkFailed
+			result.Message = fmt.Sprintf("%s: deployment not ready", d.Name)
+			result.Remediation = fmt.Sprintf("inspect the pods events and logs with 'kubectl -n %s describe deployment %s'", d.Namespace, d.Name)
+		}
+		for _, c := range d.Spec.Template.Spec.Containers {
+			result.Details = append(result.Details, c.Image)
+		}
+		results = append(results, result)
+	}
+	if len(results) == 0 {
+		return []checkResult{{
+			Check:       "components",
+			Name:        "components",
+			Status:      checkFailed,
+			Message:     fmt.Sprintf("no components found in %s namespace", rootArgs.namespace),
+			Remediation: "install Flux with 'flux install' or 'flux bootstrap'",
+		}}
+	}
+	return results
+}
+
+// crdsCheck verifies that the Flux custom resource definitions
+// are established, a single result is reported when they all are.
+func crdsCheck(ctx context.Context) []checkResult {
+	kubeClient, err := newKubeClient()
+	if err != nil {
+		return []checkResult{{
+			Check:   "crds",
+			Name:    "crds",
+			Status:  checkFailed,
+			Message: fmt.Sprintf("Kubernetes client initialization failed: %s", err.Error()),
+		}}
+	}
+
+	var list apiextensionsv1.CustomResourceDefinitionList
+	selector := client.MatchingLabels{"app.kubernetes.io/part-of": "flux"}
+	if err := kubeClient.List(ctx, &list, selector); err != nil {
+		return []checkResult{{
+			Check:   "crds",
+			Name:    "crds",
+			Status:  checkFailed,
+			Message: fmt.Sprintf("failed to list the custom resource definitions: %s", err.Error()),
+		}}
+	}
+	if len(list.Items) == 0 {
+		return []checkResult{{
+			Check:       "crds",
+			Name:        "crds",
+			Status:      checkFailed,
+			Message:     "no Flux custom resource definitions found",
+			Remediation: "install Flux with 'flux install' or 'flux bootstrap'",
+		}}
+	}
+
+	var results []checkResult
+	for _, crd := range list.Items {
+		if !crdEstablished(crd) {
+			results = append(results, checkResult{
+				Check:       "crds",
+				Name:        crd.Name,
+				Status:      checkFailed,
+				Message:     fmt.Sprintf("%s: custom resource definition not established", crd.Name),
+				Remediation: fmt.Sprintf("inspect the conditions with 'kubectl describe crd %s'", crd.Name),
+			})
+		}
+	}
+	if len(results) > 0 {
+		return results
+	}
+	return []checkResult{{
+		Check:   "crds",
+		Name:    "crds",
+		Status:  checkPassed,
+		Message: fmt.Sprintf("%d custom resource definitions established", len(list.Items)),
+	}}
+}
+
+func crdEstablished(crd apiextensionsv1.CustomResourceDefinition) bool {
+	for _, c := range crd.Status.Conditions {
+		if c.Type == apiextensionsv1.Established {
+			return c.Status == apiextensionsv1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// tenantsCheck warns about the Kustomizations and HelmReleases of the
+// tenants that have no service account to impersonate. An object belongs
+// to a tenant when it or its namespace has the tenant label.
+func tenantsCheck(ctx context.Context) []checkResult {
+	kubeClient, err := newKubeClient()
+	if err != nil {
+		return nil
+	}
+
+	var namespaces corev1.NamespaceList
+	if err := kubeClient.List(ctx, &namespaces, client.HasLabels{tenantLabel}); err != nil {
+		return nil
+	}
+	tenantNamespaces := map[string]bool{}
+	for _, ns := range namespaces.Items {
+		tenantNamespaces[ns.Name] = true
+	}
+	isTenant := func(obj client.Object) bool {
+		_, ok := obj.GetLabels()[tenantLabel]
+		return ok || tenantNamespaces[obj.GetNamespace()]
+	}
+
+	var results []checkResult
+	warning := func(kind, namespace, name string) {
+		results = append(results, checkResult{
+			Check:       "tenants",
+			Name:        fmt.Sprintf("%s/%s/%s", kind, namespace, name),
+			Status:      checkWarning,
+			Message:     fmt.Sprintf("tenant %s %s/%s has no serviceAccountName", kind, namespace, name),
+			Remediation: "set spec.serviceAccountName to a service account of the tenant",
+		})
+	}
+
+	var kustomizations kustomizev1.KustomizationList
+	if err := kubeClient.List(ctx, &kustomizations); err == nil {
+		for _, k := range kustomizations.Items {
+			if isTenant(&k) && k.Spec.ServiceAccountName == "" {
+				warning(kustomizev1.KustomizationKind, k.Namespace, k.Name)
 			}
-			for _, c := range d.Spec.Template.Spec.Containers {
-				logger.Actionf(c.Image)
+		}
+	}
+
+	var helmReleases helmv2.HelmReleaseList
+	if err := kubeClient.List(ctx, &helmReleases); err == nil {
+		for _, hr := range helmReleases.Items {
+			if isTenant(&hr) && hr.Spec.ServiceAccountName == "" {
+				warning(helmv2.HelmReleaseKind, hr.Namespace, hr.Name)
 			}
 		}
 	}
-	return ok
+	return results
 }
@@ -0,0 +1,204 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	authorizationv1client "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/client-go/rest"
+
+	"github.com/fluxcd/flux2/internal/utils"
+	"github.com/fluxcd/flux2/pkg/manifestgen/install"
+)
+
+// rbacRequirement is a set of verbs on a resource that flux install
+// or flux bootstrap needs, the namespace is empty for cluster-scoped resources.
+type rbacRequirement struct {
+	group     string
+	resource  string
+	namespace string
+	verbs     []string
+}
+
+func (r rbacRequirement) String() string {
+	resource := r.resource
+	if r.group != "" {
+		resource = fmt.Sprintf("%s.%s", r.resource, r.group)
+	}
+	if r.namespace != "" {
+		return fmt.Sprintf("%s in namespace %s", resource, r.namespace)
+	}
+	return resource
+}
+
+// applyVerbs are the verbs needed to server-side apply an object.
+var applyVerbs = []string{"get", "create", "patch"}
+
+// roleVerbs are the verbs needed to apply roles granting permissions the
+// identity applying them may not hold itself.
+var roleVerbs = append(append([]string{}, applyVerbs...), "bind", "escalate")
+
+// rbacRequirements returns the permissions needed to install the components
+// with the given options, and to bootstrap them from a Git repository. A
+// namespaced install needs Roles and RoleBindings in the install and target
+// namespaces instead of the cluster-scoped objects.
+func rbacRequirements(options install.Options) []rbacRequirement {
+	namespace := options.Namespace
+	var requirements []rbacRequirement
+	if options.Namespaced {
+		namespaces := []string{namespace}
+		for _, ns := range options.TargetNamespaces {
+			if !utils.ContainsItemString(namespaces, ns) {
+				namespaces = append(namespaces, ns)
+			}
+		}
+		for _, ns := range namespaces {
+			requirements = append(requirements,
+				rbacRequirement{group: "rbac.authorization.k8s.io", resource: "roles", namespace: ns, verbs: roleVerbs},
+				rbacRequirement{group: "rbac.authorization.k8s.io", resource: "rolebindings", namespace: ns, verbs: applyVerbs},
+			)
+		}
+	} else {
+		requirements = append(requirements,
+			rbacRequirement{resource: "namespaces", verbs: applyVerbs},
+			rbacRequirement{group: "apiextensions.k8s.io", resource: "customresourcedefinitions", verbs: applyVerbs},
+			rbacRequirement{group: "rbac.authorization.k8s.io", resource: "clusterroles", verbs: roleVerbs},
+			rbacRequirement{group: "rbac.authorization.k8s.io", resource: "clusterrolebindings", verbs: applyVerbs},
+		)
+	}
+	requirements = append(requirements,
+		rbacRequirement{resource: "serviceaccounts", namespace: namespace, verbs: applyVerbs},
+		rbacRequirement{group: "apps", resource: "deployments", namespace: namespace, verbs: append([]string{"list", "watch"}, applyVerbs...)},
+	)
+	if options.NetworkPolicy {
+		requirements = append(requirements,
+			rbacRequirement{group: "networking.k8s.io", resource: "networkpolicies", namespace: namespace, verbs: applyVerbs})
+	}
+
+	components := append(append([]string{}, options.Components...), options.ComponentsExtra...)
+	if utils.ContainsItemString(components, "source-controller") || utils.ContainsItemString(components, "notification-controller") {
+		requirements = append(requirements,
+			rbacRequirement{resource: "services", namespace: namespace, verbs: applyVerbs})
+	}
+	if utils.ContainsItemString(components, "source-controller") && utils.ContainsItemString(components, "kustomize-controller") {
+		// the sync objects and the Git credentials written by bootstrap
+		requirements = append(requirements,
+			rbacRequirement{resource: "secrets", namespace: namespace, verbs: applyVerbs},
+			rbacRequirement{group: "source.toolkit.fluxcd.io", resource: "gitrepositories", namespace: namespace, verbs: applyVerbs},
+			rbacRequirement{group: "kustomize.toolkit.fluxcd.io", resource: "kustomizations", namespace: namespace, verbs: applyVerbs},
+		)
+	}
+	return requirements
+}
+
+// rbacCheck verifies with SelfSubjectAccessReviews that the current
+// identity, or the one impersonated with --as and --as-group, has the
+// permissions needed to install and bootstrap the selected components.
+func rbacCheck(ctx context.Context) []checkResult {
+	failure := func(message string) []checkResult {
+		return []checkResult{{
+			Check:   "rbac",
+			Name:    "rbac",
+			Status:  checkFailed,
+			Message: message,
+		}}
+	}
+
+	cfg, err := utils.KubeConfig(rootArgs.kubeconfig, rootArgs.kubecontext)
+	if err != nil {
+		return failure(fmt.Sprintf("Kubernetes client initialization failed: %s", err.Error()))
+	}
+	if checkArgs.as != "" || len(checkArgs.asGroups) > 0 {
+		cfg.Impersonate = rest.ImpersonationConfig{
+			UserName: checkArgs.as,
+			Groups:   checkArgs.asGroups,
+		}
+	}
+	clientSet, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return failure(fmt.Sprintf("Kubernetes client initialization failed: %s", err.Error()))
+	}
+
+	options := install.MakeDefaultOptions()
+	options.Namespace = rootArgs.namespace
+	options.Components = checkArgs.components
+	options.ComponentsExtra = checkArgs.extraComponents
+	options.NetworkPolicy = checkArgs.networkPolicy
+	options.Namespaced = checkArgs.namespaced
+	options.TargetNamespaces = checkArgs.targetNamespaces
+	results, err := reviewRBACRequirements(ctx, clientSet.AuthorizationV1().SelfSubjectAccessReviews(), rbacRequirements(options))
+	if err != nil {
+		return failure(fmt.Sprintf("access review failed: %s", err.Error()))
+	}
+	return results
+}
+
+// reviewRBACRequirements reviews each verb of the given requirements, and
+// returns a failed result for each requirement missing some verbs, or a
+// single passed result if none is missing.
+func reviewRBACRequirements(ctx context.Context, reviews authorizationv1client.SelfSubjectAccessReviewInterface,
+	requirements []rbacRequirement) ([]checkResult, error) {
+	var results []checkResult
+	count := 0
+	for _, requirement := range requirements {
+		var missing []string
+		for _, verb := range requirement.verbs {
+			count++
+			review := &authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Namespace: requirement.namespace,
+						Verb:      verb,
+						Group:     requirement.group,
+						Resource:  requirement.resource,
+					},
+				},
+			}
+			review, err := reviews.Create(ctx, review, metav1.CreateOptions{})
+			if err != nil {
+				return nil, err
+			}
+			if !review.Status.Allowed {
+				missing = append(missing, verb)
+			}
+		}
+		if len(missing) > 0 {
+			results = append(results, checkResult{
+				Check:       "rbac",
+				Name:        requirement.String(),
+				Status:      checkFailed,
+				Message:     fmt.Sprintf("missing permission to %s %s", strings.Join(missing, ", "), requirement),
+				Remediation: fmt.Sprintf("grant the %s verbs on %s to the identity running flux", strings.Join(missing, ", "), requirement),
+			})
+		}
+	}
+	if len(results) > 0 {
+		return results, nil
+	}
+	return []checkResult{{
+		Check:   "rbac",
+		Name:    "rbac",
+		Status:  checkPassed,
+		Message: fmt.Sprintf("%d permissions needed to install Flux granted", count),
+	}}, nil
+}
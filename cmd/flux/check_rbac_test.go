@@ -0,0 +1,149 @@
+// +build unit
+
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/fluxcd/flux2/pkg/manifestgen/install"
+)
+
+// fakeAccessReviews returns the SelfSubjectAccessReviews of a fake
+// clientset which deny the given "verb resource namespace" attributes,
+// and allow everything else.
+func fakeAccessReviews(denied ...string) *fake.Clientset {
+	clientSet := fake.NewSimpleClientset()
+	clientSet.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		key := strings.TrimSpace(strings.Join([]string{attrs.Verb, attrs.Resource, attrs.Namespace}, " "))
+		review.Status.Allowed = true
+		for _, d := range denied {
+			if d == key {
+				review.Status.Allowed = false
+			}
+		}
+		return true, review, nil
+	})
+	return clientSet
+}
+
+func TestRBACRequirements(t *testing.T) {
+	find := func(requirements []rbacRequirement, resource, namespace string) *rbacRequirement {
+		for _, r := range requirements {
+			if r.resource == resource && r.namespace == namespace {
+				return &r
+			}
+		}
+		return nil
+	}
+
+	options := install.MakeDefaultOptions()
+	requirements := rbacRequirements(options)
+	clusterRoles := find(requirements, "clusterroles", "")
+	if clusterRoles == nil {
+		t.Fatalf("expected clusterroles requirement, got %v", requirements)
+	}
+	if verbs := strings.Join(clusterRoles.verbs, ","); verbs != "get,create,patch,bind,escalate" {
+		t.Errorf("expected bind and escalate on clusterroles, got %s", verbs)
+	}
+	if find(requirements, "networkpolicies", "flux-system") == nil {
+		t.Errorf("expected networkpolicies requirement, got %v", requirements)
+	}
+	if find(requirements, "roles", "flux-system") != nil {
+		t.Errorf("expected no roles requirement, got %v", requirements)
+	}
+
+	options.NetworkPolicy = false
+	options.Namespaced = true
+	options.TargetNamespaces = []string{"apps", "flux-system", "infra"}
+	requirements = rbacRequirements(options)
+	for _, resource := range []string{"namespaces", "customresourcedefinitions", "clusterroles", "clusterrolebindings"} {
+		if find(requirements, resource, "") != nil {
+			t.Errorf("expected no %s requirement for a namespaced install", resource)
+		}
+	}
+	for _, ns := range []string{"flux-system", "apps", "infra"} {
+		roles := find(requirements, "roles", ns)
+		if roles == nil {
+			t.Fatalf("expected roles requirement in namespace %s, got %v", ns, requirements)
+		}
+		if verbs := strings.Join(roles.verbs, ","); verbs != "get,create,patch,bind,escalate" {
+			t.Errorf("expected bind and escalate on roles, got %s", verbs)
+		}
+		if find(requirements, "rolebindings", ns) == nil {
+			t.Errorf("expected rolebindings requirement in namespace %s", ns)
+		}
+	}
+	if find(requirements, "networkpolicies", "flux-system") != nil {
+		t.Errorf("expected no networkpolicies requirement, got %v", requirements)
+	}
+}
+
+func TestReviewRBACRequirements(t *testing.T) {
+	namespaced := install.MakeDefaultOptions()
+	namespaced.Namespaced = true
+	namespaced.TargetNamespaces = []string{"apps"}
+
+	cases := []struct {
+		name     string
+		options  install.Options
+		denied   []string
+		want     checkStatus
+		wantName []string
+	}{
+		{
+			name:     "allowed",
+			options:  install.MakeDefaultOptions(),
+			want:     checkPassed,
+			wantName: []string{"rbac"},
+		},
+		{
+			name:     "denied",
+			options:  install.MakeDefaultOptions(),
+			denied:   []string{"escalate clusterroles", "create secrets flux-system"},
+			want:     checkFailed,
+			wantName: []string{"clusterroles.rbac.authorization.k8s.io", "secrets in namespace flux-system"},
+		},
+		{
+			name:     "namespaced allowed",
+			options:  namespaced,
+			denied:   []string{"create clusterroles", "create customresourcedefinitions"},
+			want:     checkPassed,
+			wantName: []string{"rbac"},
+		},
+		{
+			name:     "namespaced denied",
+			options:  namespaced,
+			denied:   []string{"bind roles apps"},
+			want:     checkFailed,
+			wantName: []string{"roles.rbac.authorization.k8s.io in namespace apps"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reviews := fakeAccessReviews(tc.denied...).AuthorizationV1().SelfSubjectAccessReviews()
+			results, err := reviewRBACRequirements(context.TODO(), reviews, rbacRequirements(tc.options))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, r := range results {
+				if r.Status != tc.want {
+					t.Errorf("expected status %q, got %q: %s", tc.want, r.Status, r.Message)
+				}
+				names = append(names, r.Name)
+			}
+			if strings.Join(names, ";") != strings.Join(tc.wantName, ";") {
+				t.Errorf("expected results %v, got %v", tc.wantName, names)
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/fluxcd/flux2/internal/bootstrap/git/gitcli"
 	"github.com/fluxcd/flux2/internal/utils"
 	"k8s.io/apimachinery/pkg/version"
 )
@@ -23,14 +24,17 @@ func TestCheckPre(t *testing.T) {
 		t.Fatalf("Error unmarshalling: %v", err.Error())
 	}
 
-	clientVersion := strings.TrimPrefix(versions["clientVersion"].GitVersion, "v")
 	serverVersion := strings.TrimPrefix(versions["serverVersion"].GitVersion, "v")
+	gitVersion, err := gitcli.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	cmd := cmdTestCase{
 		args: "check --pre",
 		assert: assertGoldenTemplateFile("testdata/check/check_pre.golden", map[string]string{
-			"clientVersion": clientVersion,
 			"serverVersion": serverVersion,
+			"gitVersion":    gitVersion,
 		}),
 	}
 	cmd.runTestCmd(t)
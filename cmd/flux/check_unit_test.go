@@ -0,0 +1,137 @@
+// +build unit
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSelectChecks(t *testing.T) {
+	cases := []struct {
+		name    string
+		names   []string
+		pre     bool
+		want    []string
+		wantErr string
+	}{
+		{"all", nil, false, []string{"flux", "git", "kubernetes", "rbac", "components", "crds", "tenants"}, ""},
+		{"pre", nil, true, []string{"flux", "git", "kubernetes", "rbac"}, ""},
+		{"selected", []string{"crds", "components"}, false, []string{"components", "crds"}, ""},
+		{"selected pre", []string{"rbac", "crds"}, true, []string{"rbac"}, ""},
+		{"no pre", []string{"crds"}, true, nil, "the selected checks are not pre-installation checks"},
+		{"unknown", []string{"crds", "foo"}, false, nil, "unknown check 'foo'"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			selected, err := selectChecks(tc.names, tc.pre)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, c := range selected {
+				names = append(names, c.name)
+			}
+			if strings.Join(names, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("expected checks %v, got %v", tc.want, names)
+			}
+		})
+	}
+}
+
+func TestRunChecks(t *testing.T) {
+	check := func(name string, statuses ...checkStatus) checkDefinition {
+		return checkDefinition{name: name, run: func(context.Context) []checkResult {
+			var results []checkResult
+			for _, s := range statuses {
+				results = append(results, checkResult{Check: name, Name: name, Status: s})
+			}
+			return results
+		}}
+	}
+	cases := []struct {
+		name   string
+		checks []checkDefinition
+		want   checkStatus
+	}{
+		{"passed", []checkDefinition{check("a", checkPassed), check("b", checkSkipped)}, checkPassed},
+		{"warning", []checkDefinition{check("a", checkPassed), check("b", checkWarning)}, checkWarning},
+		{"failed", []checkDefinition{check("a", checkFailed), check("b", checkWarning)}, checkFailed},
+		{"failed after warning", []checkDefinition{check("a", checkWarning, checkFailed, checkPassed)}, checkFailed},
+		{"no results", []checkDefinition{check("a")}, checkPassed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			report := runChecks(context.TODO(), tc.checks, false)
+			if report.Status != tc.want {
+				t.Errorf("expected status %q, got %q", tc.want, report.Status)
+			}
+		})
+	}
+}
+
+func TestPrintCheckReport(t *testing.T) {
+	report := checkReport{
+		Status: checkWarning,
+		Checks: []checkResult{
+			{
+				Check:   "components",
+				Name:    "source-controller",
+				Status:  checkPassed,
+				Message: "source-controller: deployment ready",
+				Details: []string{"ghcr.io/fluxcd/source-controller:v0.15.3"},
+			},
+			{
+				Check:       "flux",
+				Name:        "flux",
+				Status:      checkWarning,
+				Message:     "flux 0.16.0 <0.17.0 (new version is available, please upgrade)",
+				Remediation: "upgrade the flux CLI to the latest version",
+			},
+		},
+	}
+	cases := []struct {
+		format     string
+		goldenFile string
+	}{
+		{"json", "testdata/check/report_json.golden"},
+		{"yaml", "testdata/check/report_yaml.golden"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := printCheckReport(&buf, report, tc.format)
+			if assertErr := assertGoldenFile(tc.goldenFile)(buf.String(), err); assertErr != nil {
+				t.Error(assertErr)
+			}
+		})
+	}
+}
+
+func TestCheckExitCode(t *testing.T) {
+	cases := []struct {
+		status        checkStatus
+		failOnWarning bool
+		want          int
+	}{
+		{checkPassed, false, 0},
+		{checkPassed, true, 0},
+		{checkWarning, false, 0},
+		{checkWarning, true, checkExitWarning},
+		{checkFailed, false, checkExitFailure},
+		{checkFailed, true, checkExitFailure},
+	}
+	for _, tc := range cases {
+		if got := checkExitCode(tc.status, tc.failOnWarning); got != tc.want {
+			t.Errorf("checkExitCode(%q, %v) = %d, want %d", tc.status, tc.failOnWarning, got, tc.want)
+		}
+	}
+}
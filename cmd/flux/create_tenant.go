@@ -21,7 +21,6 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/fluxcd/flux2/internal/utils"
 	"github.com/spf13/cobra"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
@@ -48,17 +47,27 @@ reconcilers scope to the tenant namespaces.`,
   flux create tenant dev-team \
     --with-namespace=frontend \
     --with-namespace=backend \
-	--export > dev-team.yaml`,
+	--export > dev-team.yaml
+
+  # Create a tenant bound to the restricted flux-tenant cluster role
+  flux create tenant dev-team \
+    --with-namespace=frontend \
+    --multi-tenant`,
 	RunE: createTenantCmdRun,
 }
 
 const (
 	tenantLabel = "toolkit.fluxcd.io/tenant"
+
+	// tenantClusterRole is the restricted cluster role bound
+	// to the tenants in multi-tenant mode.
+	tenantClusterRole = "flux-tenant"
 )
 
 type tenantFlags struct {
 	namespaces  []string
 	clusterRole string
+	multiTenant bool
 }
 
 var tenantArgs tenantFlags
@@ -66,6 +75,8 @@ var tenantArgs tenantFlags
 func init() {
 	createTenantCmd.Flags().StringSliceVar(&tenantArgs.namespaces, "with-namespace", nil, "namespace belonging to this tenant")
 	createTenantCmd.Flags().StringVar(&tenantArgs.clusterRole, "cluster-role", "cluster-admin", "cluster role of the tenant role binding")
+	createTenantCmd.Flags().BoolVar(&tenantArgs.multiTenant, "multi-tenant", false,
+		fmt.Sprintf("bind the tenant to the restricted %s cluster role instead of cluster-admin, unless --cluster-role is set", tenantClusterRole))
 	createCmd.AddCommand(createTenantCmd)
 }
 
@@ -82,6 +93,12 @@ func createTenantCmdRun(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("cluster-role is required")
 	}
 
+	var clusterRole *rbacv1.ClusterRole
+	if tenantArgs.multiTenant && !cmd.Flags().Changed("cluster-role") {
+		tenantArgs.clusterRole = tenantClusterRole
+		clusterRole = newTenantClusterRole()
+	}
+
 	if tenantArgs.namespaces == nil {
 		return fmt.Errorf("with-namespace is required")
 	}
@@ -148,6 +165,11 @@ func createTenantCmdRun(cmd *cobra.Command, args []string) error {
 	}
 
 	if createArgs.export {
+		if clusterRole != nil {
+			if err := exportClusterRole(*clusterRole); err != nil {
+				return err
+			}
+		}
 		for i := range tenantArgs.namespaces {
 			if err := exportTenant(namespaces[i], accounts[i], roleBindings[i]); err != nil {
 				return err
@@ -159,11 +181,18 @@ func createTenantCmdRun(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
-	kubeClient, err := utils.KubeClient(rootArgs.kubeconfig, rootArgs.kubecontext)
+	kubeClient, err := newKubeClient()
 	if err != nil {
 		return err
 	}
 
+	if clusterRole != nil {
+		logger.Actionf("applying cluster role %s", clusterRole.Name)
+		if err := upsertClusterRole(ctx, kubeClient, *clusterRole); err != nil {
+			return err
+		}
+	}
+
 	for i := range tenantArgs.namespaces {
 		logger.Actionf("applying namespace %s", namespaces[i].Name)
 		if err := upsertNamespace(ctx, kubeClient, namespaces[i]); err != nil {
@@ -185,6 +214,69 @@ func createTenantCmdRun(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// newTenantClusterRole returns the cluster role bound to the tenants in
+// multi-tenant mode. Bound by role bindings, it grants access to the common
+// namespaced workloads and Flux objects, and not to resource quotas, limit
+// ranges or any cluster-scoped objects.
+func newTenantClusterRole() *rbacv1.ClusterRole {
+	all := []string{"*"}
+	return &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: tenantClusterRole,
+		},
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{""},
+				Resources: []string{"configmaps", "secrets", "services", "serviceaccounts",
+					"persistentvolumeclaims", "pods", "pods/log", "pods/exec", "pods/portforward",
+					"endpoints", "replicationcontrollers"},
+				Verbs: all,
+			},
+			{
+				APIGroups: []string{""},
+				Resources: []string{"events"},
+				Verbs:     []string{"get", "list", "watch"},
+			},
+			{
+				APIGroups: []string{"apps", "batch", "autoscaling", "policy", "networking.k8s.io"},
+				Resources: all,
+				Verbs:     all,
+			},
+			{
+				APIGroups: []string{"rbac.authorization.k8s.io"},
+				Resources: []string{"roles", "rolebindings"},
+				Verbs:     all,
+			},
+			{
+				APIGroups: []string{"source.toolkit.fluxcd.io", "kustomize.toolkit.fluxcd.io", "helm.toolkit.fluxcd.io",
+					"notification.toolkit.fluxcd.io", "image.toolkit.fluxcd.io"},
+				Resources: all,
+				Verbs:     all,
+			},
+		},
+	}
+}
+
+func upsertClusterRole(ctx context.Context, kubeClient client.Client, clusterRole rbacv1.ClusterRole) error {
+	var existing rbacv1.ClusterRole
+	err := kubeClient.Get(ctx, types.NamespacedName{Name: clusterRole.GetName()}, &existing)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return kubeClient.Create(ctx, &clusterRole)
+		}
+		return err
+	}
+
+	if !equality.Semantic.DeepDerivative(clusterRole.Rules, existing.Rules) {
+		existing.Rules = clusterRole.Rules
+		if err := kubeClient.Update(ctx, &existing); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func upsertNamespace(ctx context.Context, kubeClient client.Client, namespace corev1.Namespace) error {
 	namespacedName := types.NamespacedName{
 		Namespace: namespace.GetNamespace(),
@@ -276,6 +368,22 @@ func upsertRoleBinding(ctx context.Context, kubeClient client.Client, roleBindin
 	return nil
 }
 
+func exportClusterRole(clusterRole rbacv1.ClusterRole) error {
+	clusterRole.TypeMeta = metav1.TypeMeta{
+		APIVersion: "rbac.authorization.k8s.io/v1",
+		Kind:       "ClusterRole",
+	}
+	data, err := yaml.Marshal(clusterRole)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("---")
+	fmt.Println(resourceToString(data))
+
+	return nil
+}
+
 func exportTenant(namespace corev1.Namespace, account corev1.ServiceAccount, roleBinding rbacv1.RoleBinding) error {
 	namespace.TypeMeta = metav1.TypeMeta{
 		APIVersion: "v1",
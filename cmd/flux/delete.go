@@ -19,12 +19,13 @@ package main
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
-
-	"github.com/fluxcd/flux2/internal/utils"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 )
 
 var deleteCmd = &cobra.Command{
@@ -34,7 +35,8 @@ var deleteCmd = &cobra.Command{
 }
 
 type deleteFlags struct {
-	silent bool
+	silent        bool
+	labelSelector string
 }
 
 var deleteArgs deleteFlags
@@ -42,6 +44,8 @@ var deleteArgs deleteFlags
 func init() {
 	deleteCmd.PersistentFlags().BoolVarP(&deleteArgs.silent, "silent", "s", false,
 		"delete resource without asking for confirmation")
+	deleteCmd.PersistentFlags().StringVarP(&deleteArgs.labelSelector, "selector", "l", "",
+		"delete the resources in that namespace matching the label selector, e.g. team=payments")
 
 	rootCmd.AddCommand(deleteCmd)
 }
@@ -52,32 +56,42 @@ type deleteCommand struct {
 }
 
 func (del deleteCommand) run(cmd *cobra.Command, args []string) error {
-	if len(args) < 1 {
+	if len(args) < 1 && deleteArgs.labelSelector == "" {
 		return fmt.Errorf("%s name is required", del.humanKind)
 	}
-	name := args[0]
 
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
-	kubeClient, err := utils.KubeClient(rootArgs.kubeconfig, rootArgs.kubecontext)
+	kubeClient, err := newKubeClient()
 	if err != nil {
 		return err
 	}
 
-	namespacedName := types.NamespacedName{
-		Namespace: rootArgs.namespace,
-		Name:      name,
-	}
-
-	err = kubeClient.Get(ctx, namespacedName, del.object.asClientObject())
+	names, err := objectNames(ctx, kubeClient, del.object.asClientObject(), args, deleteArgs.labelSelector)
 	if err != nil {
 		return err
 	}
 
+	// the selected objects exist, check the named one does before asking for confirmation
+	if deleteArgs.labelSelector == "" {
+		namespacedName := types.NamespacedName{
+			Namespace: rootArgs.namespace,
+			Name:      names[0],
+		}
+		err = kubeClient.Get(ctx, namespacedName, del.object.asClientObject())
+		if err != nil {
+			return err
+		}
+	}
+
 	if !deleteArgs.silent {
+		label := "Are you sure you want to delete this " + del.humanKind
+		if len(names) > 1 {
+			label = fmt.Sprintf("Are you sure you want to delete %d %s objects (%s)", len(names), del.humanKind, strings.Join(names, ", "))
+		}
 		prompt := promptui.Prompt{
-			Label:     "Are you sure you want to delete this " + del.humanKind,
+			Label:     label,
 			IsConfirm: true,
 		}
 		if _, err := prompt.Run(); err != nil {
@@ -85,12 +99,24 @@ func (del deleteCommand) run(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	logger.Actionf("deleting %s %s in %s namespace", del.humanKind, name, rootArgs.namespace)
-	err = kubeClient.Delete(ctx, del.object.asClientObject())
+	gvk, err := apiutil.GVKForObject(del.object.asClientObject(), kubeClient.Scheme())
 	if err != nil {
 		return err
 	}
-	logger.Successf("%s deleted", del.humanKind)
+	for _, name := range names {
+		// only the kind, name and namespace are needed to delete an object
+		obj := &metav1.PartialObjectMetadata{}
+		obj.SetGroupVersionKind(gvk)
+		obj.SetName(name)
+		obj.SetNamespace(rootArgs.namespace)
+
+		logger.Actionf("deleting %s %s in %s namespace", del.humanKind, name, rootArgs.namespace)
+		err = kubeClient.Delete(ctx, obj)
+		if err != nil {
+			return err
+		}
+		logger.Successf("%s deleted", del.humanKind)
+	}
 
 	return nil
 }
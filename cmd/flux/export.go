@@ -24,8 +24,6 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
-
-	"github.com/fluxcd/flux2/internal/utils"
 )
 
 var exportCmd = &cobra.Command{
@@ -35,13 +33,16 @@ var exportCmd = &cobra.Command{
 }
 
 type exportFlags struct {
-	all bool
+	all           bool
+	labelSelector string
 }
 
 var exportArgs exportFlags
 
 func init() {
 	exportCmd.PersistentFlags().BoolVar(&exportArgs.all, "all", false, "select all resources")
+	exportCmd.PersistentFlags().StringVarP(&exportArgs.labelSelector, "selector", "l", "",
+		"select the resources matching the label selector, e.g. team=payments")
 
 	rootCmd.AddCommand(exportCmd)
 }
@@ -66,20 +67,24 @@ type exportCommand struct {
 }
 
 func (export exportCommand) run(cmd *cobra.Command, args []string) error {
-	if !exportArgs.all && len(args) < 1 {
+	if !exportArgs.all && exportArgs.labelSelector == "" && len(args) < 1 {
 		return fmt.Errorf("name is required")
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
-	kubeClient, err := utils.KubeClient(rootArgs.kubeconfig, rootArgs.kubecontext)
+	kubeClient, err := newKubeClient()
 	if err != nil {
 		return err
 	}
 
-	if exportArgs.all {
-		err = kubeClient.List(ctx, export.list.asClientList(), client.InNamespace(rootArgs.namespace))
+	if exportArgs.all || exportArgs.labelSelector != "" {
+		listOpts, err := exportListOptions()
+		if err != nil {
+			return err
+		}
+		err = kubeClient.List(ctx, export.list.asClientList(), listOpts...)
 		if err != nil {
 			return err
 		}
@@ -108,6 +113,20 @@ func (export exportCommand) run(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// exportListOptions returns the options listing the
+// resources selected with --all or --selector.
+func exportListOptions() ([]client.ListOption, error) {
+	listOpts := []client.ListOption{client.InNamespace(rootArgs.namespace)}
+	if exportArgs.labelSelector != "" {
+		opt, err := labelSelectorListOption(exportArgs.labelSelector)
+		if err != nil {
+			return nil, err
+		}
+		listOpts = append(listOpts, opt)
+	}
+	return listOpts, nil
+}
+
 func printExport(export interface{}) error {
 	data, err := yaml.Marshal(export)
 	if err != nil {
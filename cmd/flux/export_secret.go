@@ -24,8 +24,6 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-
-	"github.com/fluxcd/flux2/internal/utils"
 )
 
 // exportableWithSecret represents a type that you can fetch from the Kubernetes
@@ -51,20 +49,24 @@ type exportWithSecretCommand struct {
 }
 
 func (export exportWithSecretCommand) run(cmd *cobra.Command, args []string) error {
-	if !exportArgs.all && len(args) < 1 {
+	if !exportArgs.all && exportArgs.labelSelector == "" && len(args) < 1 {
 		return fmt.Errorf("name is required")
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
-	kubeClient, err := utils.KubeClient(rootArgs.kubeconfig, rootArgs.kubecontext)
+	kubeClient, err := newKubeClient()
 	if err != nil {
 		return err
 	}
 
-	if exportArgs.all {
-		err = kubeClient.List(ctx, export.list.asClientList(), client.InNamespace(rootArgs.namespace))
+	if exportArgs.all || exportArgs.labelSelector != "" {
+		listOpts, err := exportListOptions()
+		if err != nil {
+			return err
+		}
+		err = kubeClient.List(ctx, export.list.asClientList(), listOpts...)
 		if err != nil {
 			return err
 		}
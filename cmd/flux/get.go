@@ -19,8 +19,10 @@ package main
 import (
 	"context"
 	"fmt"
-	"os"
+	"io"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
@@ -66,6 +68,9 @@ type GetFlags struct {
 	noHeader       bool
 	statusSelector string
 	watch          bool
+	output         string
+	labelSelector  string
+	sortBy         string
 }
 
 var getArgs GetFlags
@@ -77,6 +82,12 @@ func init() {
 	getCmd.PersistentFlags().BoolVarP(&getArgs.watch, "watch", "w", false, "After listing/getting the requested object, watch for changes.")
 	getCmd.PersistentFlags().StringVar(&getArgs.statusSelector, "status-selector", "",
 		"specify the status condition name and the desired state to filter the get result, e.g. ready=false")
+	getCmd.PersistentFlags().StringVarP(&getArgs.labelSelector, "selector", "l", "",
+		"list the objects matching the label selector, e.g. team=payments")
+	getCmd.PersistentFlags().StringVar(&getArgs.sortBy, "sort-by", "",
+		fmt.Sprintf("sort the objects of each kind, available options are: (%s), cannot be used with --watch", strings.Join(getSortKeys, ", ")))
+	getCmd.PersistentFlags().StringVarP(&getArgs.output, "output", "o", getOutputTable,
+		fmt.Sprintf("output format, available options are: (%s, %s=...)", strings.Join(getOutputFormats, ", "), getOutputGoTemplate))
 	rootCmd.AddCommand(getCmd)
 }
 
@@ -132,61 +143,197 @@ type getCommand struct {
 }
 
 func (get getCommand) run(cmd *cobra.Command, args []string) error {
+	if err := validateGetFlags(); err != nil {
+		return err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
-	kubeClient, err := utils.KubeClient(rootArgs.kubeconfig, rootArgs.kubecontext)
+	kubeClient, err := newKubeClient()
 	if err != nil {
 		return err
 	}
 
-	var listOpts []client.ListOption
-	if !getArgs.allNamespaces {
-		listOpts = append(listOpts, client.InNamespace(rootArgs.namespace))
-	}
-
-	if len(args) > 0 {
-		listOpts = append(listOpts, client.MatchingFields{"metadata.name": args[0]})
+	listOpts, err := getListOptions(args)
+	if err != nil {
+		return err
 	}
 
 	getAll := cmd.Use == "all"
 
 	if getArgs.watch {
+		if getArgs.output != getOutputTable {
+			return fmt.Errorf("--watch is only supported with the %s output", getOutputTable)
+		}
+		if getArgs.sortBy != "" {
+			return fmt.Errorf("--sort-by cannot be used with --watch")
+		}
 		return get.watch(ctx, kubeClient, cmd, args, listOpts)
 	}
 
-	err = kubeClient.List(ctx, get.list.asClientList(), listOpts...)
+	indexes, err := get.listItems(ctx, kubeClient, listOpts)
 	if err != nil {
 		return err
 	}
 
-	if get.list.len() == 0 {
-		if !getAll {
-			logger.Failuref("no %s objects found in %s namespace", get.kind, rootArgs.namespace)
+	switch getArgs.output {
+	case getOutputTable, getOutputWide:
+		if get.list.len() == 0 {
+			if !getAll {
+				logger.Failuref("no %s objects found in %s namespace", get.kind, rootArgs.namespace)
+			}
+			return nil
+		}
+
+		printTable(cmd.OutOrStderr(), get.list, indexes, getAll, true)
+
+		if getAll {
+			fmt.Println()
+		}
+	default:
+		// a single object is printed as is when requested by name
+		if err := printObjects(cmd.OutOrStdout(), getArgs.output, get.objects(indexes), len(args) > 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runGetAll runs the given get commands for the all sub-commands. The
+// objects of every kind are printed at once by the outputs other than
+// table and wide, e.g. as a single List by json and yaml.
+func runGetAll(cmd *cobra.Command, args []string, commands []getCommand) error {
+	if err := validateWatchOption(cmd, "all"); err != nil {
+		return err
+	}
+
+	if getArgs.output == getOutputTable || getArgs.output == getOutputWide {
+		for _, c := range commands {
+			if err := c.run(cmd, args); err != nil {
+				logError(err)
+			}
 		}
 		return nil
 	}
 
-	var header []string
-	if !getArgs.noHeader {
-		header = get.list.headers(getArgs.allNamespaces)
+	if err := validateGetFlags(); err != nil {
+		return err
 	}
 
-	rows, err := getRowsToPrint(getAll, get.list)
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	kubeClient, err := newKubeClient()
 	if err != nil {
 		return err
 	}
 
-	utils.PrintTable(cmd.OutOrStderr(), header, rows)
+	listOpts, err := getListOptions(args)
+	if err != nil {
+		return err
+	}
 
-	if getAll {
-		fmt.Println()
+	var objects []client.Object
+	for _, c := range commands {
+		indexes, err := c.listItems(ctx, kubeClient, listOpts)
+		if err != nil {
+			logError(err)
+			continue
+		}
+		objects = append(objects, c.objects(indexes)...)
 	}
+	return printObjects(cmd.OutOrStdout(), getArgs.output, objects, false)
+}
 
+// validateGetFlags validates the --output and --sort-by flags.
+func validateGetFlags() error {
+	if err := validateGetOutput(getArgs.output); err != nil {
+		return err
+	}
+	if getArgs.sortBy != "" && !utils.ContainsItemString(getSortKeys, getArgs.sortBy) {
+		return fmt.Errorf("unsupported sort key '%s', must be one of: %s", getArgs.sortBy, strings.Join(getSortKeys, ", "))
+	}
 	return nil
 }
 
-func getRowsToPrint(getAll bool, list summarisable) ([][]string, error) {
+// getListOptions returns the options listing the objects in the namespace
+// or all namespaces, with the name given in args and matching the
+// --selector.
+func getListOptions(args []string) ([]client.ListOption, error) {
+	var listOpts []client.ListOption
+	if !getArgs.allNamespaces {
+		listOpts = append(listOpts, client.InNamespace(rootArgs.namespace))
+	}
+
+	if len(args) > 0 {
+		listOpts = append(listOpts, client.MatchingFields{"metadata.name": args[0]})
+	}
+
+	if getArgs.labelSelector != "" {
+		opt, err := labelSelectorListOption(getArgs.labelSelector)
+		if err != nil {
+			return nil, err
+		}
+		listOpts = append(listOpts, opt)
+	}
+	return listOpts, nil
+}
+
+// listItems lists the objects of the command kind, and returns the indexes
+// of those matching the --status-selector, in the --sort-by order.
+func (get getCommand) listItems(ctx context.Context, kubeClient client.Client, listOpts []client.ListOption) ([]int, error) {
+	if err := kubeClient.List(ctx, get.list.asClientList(), listOpts...); err != nil {
+		return nil, err
+	}
+
+	indexes, err := selectedItems(get.list)
+	if err != nil {
+		return nil, err
+	}
+	if getArgs.sortBy != "" {
+		sortItems(get.list, indexes, getArgs.sortBy)
+	}
+	return indexes, nil
+}
+
+// objects returns the list items at the given indexes.
+func (get getCommand) objects(indexes []int) []client.Object {
+	var objects []client.Object
+	for _, i := range indexes {
+		objects = append(objects, get.list.clientObject(i))
+	}
+	return objects
+}
+
+// printTable prints the list items at the given indexes in the table or
+// wide output, with the header unless omitted or --no-header is set.
+func printTable(w io.Writer, list summarisable, indexes []int, getAll, withHeader bool) {
+	wide := getArgs.output == getOutputWide
+	var header []string
+	if withHeader && !getArgs.noHeader {
+		header = list.headers(getArgs.allNamespaces)
+		if wide {
+			header = append(header, wideHeaders(header)...)
+		}
+	}
+
+	var rows [][]string
+	for _, i := range indexes {
+		row := list.summariseItem(i, getArgs.allNamespaces, getAll)
+		if wide {
+			row = append(row, wideColumns(list.clientObject(i), list.headers(false))...)
+		}
+		rows = append(rows, row)
+	}
+
+	utils.PrintTable(w, header, rows)
+}
+
+// selectedItems returns the indexes of the list items
+// matching the --status-selector.
+func selectedItems(list summarisable) ([]int, error) {
 	noFilter := true
 	var conditionType, conditionStatus string
 	if getArgs.statusSelector != "" {
@@ -198,14 +345,57 @@ func getRowsToPrint(getAll bool, list summarisable) ([][]string, error) {
 		conditionStatus = parts[1]
 		noFilter = false
 	}
-	var rows [][]string
+	var indexes []int
 	for i := 0; i < list.len(); i++ {
 		if noFilter || list.statusSelectorMatches(i, conditionType, conditionStatus) {
-			row := list.summariseItem(i, getArgs.allNamespaces, getAll)
-			rows = append(rows, row)
+			indexes = append(indexes, i)
 		}
 	}
-	return rows, nil
+	return indexes, nil
+}
+
+var getSortKeys = []string{"name", "namespace", "ready", "last-reconcile"}
+
+// sortItems sorts the indexes of the list items by name, by namespace and
+// name, with the not ready items first, or with the most recently
+// reconciled items first.
+func sortItems(list summarisable, indexes []int, key string) {
+	type sortable struct {
+		name, namespace, ready string
+		lastReconcile          time.Time
+	}
+	items := make(map[int]sortable, len(indexes))
+	for _, i := range indexes {
+		obj := list.clientObject(i)
+		ready, lastReconcile := readyAndLastReconcile(obj)
+		items[i] = sortable{
+			name:          obj.GetName(),
+			namespace:     obj.GetNamespace(),
+			ready:         ready,
+			lastReconcile: lastReconcile,
+		}
+	}
+
+	sort.SliceStable(indexes, func(a, b int) bool {
+		x, y := items[indexes[a]], items[indexes[b]]
+		switch key {
+		case "namespace":
+			if x.namespace != y.namespace {
+				return x.namespace < y.namespace
+			}
+		case "ready":
+			// False and Unknown sort before True
+			xReady, yReady := x.ready == string(metav1.ConditionTrue), y.ready == string(metav1.ConditionTrue)
+			if xReady != yReady {
+				return yReady
+			}
+		case "last-reconcile":
+			if !x.lastReconcile.Equal(y.lastReconcile) {
+				return x.lastReconcile.After(y.lastReconcile)
+			}
+		}
+		return x.name < y.name
+	})
 }
 
 //
@@ -216,7 +406,7 @@ func (get *getCommand) watch(ctx context.Context, kubeClient client.WithWatch, c
 		return err
 	}
 
-	_, err = watchUntil(ctx, w, get)
+	_, err = watchUntil(ctx, cmd.OutOrStdout(), w, get)
 	if err != nil {
 		return err
 	}
@@ -224,7 +414,7 @@ func (get *getCommand) watch(ctx context.Context, kubeClient client.WithWatch, c
 	return nil
 }
 
-func watchUntil(ctx context.Context, w watch.Interface, get *getCommand) (bool, error) {
+func watchUntil(ctx context.Context, out io.Writer, w watch.Interface, get *getCommand) (bool, error) {
 	firstIteration := true
 	_, error := watchtools.UntilWithoutRetry(ctx, w, func(e watch.Event) (bool, error) {
 		objToPrint := e.Object
@@ -233,20 +423,12 @@ func watchUntil(ctx context.Context, w watch.Interface, get *getCommand) (bool,
 			return false, err
 		}
 
-		var header []string
-		if !getArgs.noHeader {
-			header = sink.headers(getArgs.allNamespaces)
-		}
-		rows, err := getRowsToPrint(false, sink)
+		indexes, err := selectedItems(sink)
 		if err != nil {
 			return false, err
 		}
-		if firstIteration {
-			utils.PrintTable(os.Stdout, header, rows)
-			firstIteration = false
-		} else {
-			utils.PrintTable(os.Stdout, []string{}, rows)
-		}
+		printTable(out, sink, indexes, false, firstIteration)
+		firstIteration = false
 
 		return false, nil
 	})
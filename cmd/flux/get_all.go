@@ -36,52 +36,31 @@ var getAllCmd = &cobra.Command{
   # List all resources in all namespaces
   flux get all --all-namespaces`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		err := validateWatchOption(cmd, "all")
-		if err != nil {
-			return err
-		}
-
-		err = getSourceAllCmd.RunE(cmd, args)
-		if err != nil {
-			logError(err)
-		}
-
-		// all get command
-		var allCmd = []getCommand{
-			{
+		commands := getSourceAllCommands()
+		commands = append(commands,
+			getCommand{
 				apiType: helmReleaseType,
 				list:    &helmReleaseListAdapter{&helmv2.HelmReleaseList{}},
 			},
-			{
+			getCommand{
 				apiType: kustomizationType,
 				list:    &kustomizationListAdapter{&kustomizev1.KustomizationList{}},
 			},
-			{
+			getCommand{
 				apiType: receiverType,
 				list:    receiverListAdapter{&notificationv1.ReceiverList{}},
 			},
-			{
+			getCommand{
 				apiType: alertProviderType,
 				list:    alertProviderListAdapter{&notificationv1.ProviderList{}},
 			},
-			{
+			getCommand{
 				apiType: alertType,
 				list:    &alertListAdapter{&notificationv1.AlertList{}},
 			},
-		}
-
-		err = getImageAllCmd.RunE(cmd, args)
-		if err != nil {
-			logError(err)
-		}
-
-		for _, c := range allCmd {
-			if err := c.run(cmd, args); err != nil {
-				logError(err)
-			}
-		}
-
-		return nil
+		)
+		commands = append(commands, getImageAllCommands()...)
+		return runGetAll(cmd, args, commands)
 	},
 }
 
@@ -17,8 +17,6 @@ limitations under the License.
 package main
 
 import (
-	"strings"
-
 	"github.com/spf13/cobra"
 
 	autov1 "github.com/fluxcd/image-automation-controller/api/v1beta1"
@@ -35,38 +33,28 @@ var getImageAllCmd = &cobra.Command{
   # List all image objects in all namespaces
   flux get images all --all-namespaces`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		err := validateWatchOption(cmd, "all")
-		if err != nil {
-			return err
-		}
-
-		var allImageCmd = []getCommand{
-			{
-				apiType: imageRepositoryType,
-				list:    imageRepositoryListAdapter{&imagev1.ImageRepositoryList{}},
-			},
-			{
-				apiType: imagePolicyType,
-				list:    &imagePolicyListAdapter{&imagev1.ImagePolicyList{}},
-			},
-			{
-				apiType: imageUpdateAutomationType,
-				list:    &imageUpdateAutomationListAdapter{&autov1.ImageUpdateAutomationList{}},
-			},
-		}
-
-		for _, c := range allImageCmd {
-			if err := c.run(cmd, args); err != nil {
-				if !strings.Contains(err.Error(), "no matches for kind") {
-					logger.Failuref(err.Error())
-				}
-			}
-		}
-
-		return nil
+		return runGetAll(cmd, args, getImageAllCommands())
 	},
 }
 
+// getImageAllCommands returns the get commands of every image kind.
+func getImageAllCommands() []getCommand {
+	return []getCommand{
+		{
+			apiType: imageRepositoryType,
+			list:    imageRepositoryListAdapter{&imagev1.ImageRepositoryList{}},
+		},
+		{
+			apiType: imagePolicyType,
+			list:    &imagePolicyListAdapter{&imagev1.ImagePolicyList{}},
+		},
+		{
+			apiType: imageUpdateAutomationType,
+			list:    &imageUpdateAutomationListAdapter{&autov1.ImageUpdateAutomationList{}},
+		},
+	}
+}
+
 func init() {
 	getImageCmd.AddCommand(getImageAllCmd)
 }
@@ -17,8 +17,6 @@ limitations under the License.
 package main
 
 import (
-	"strings"
-
 	"github.com/spf13/cobra"
 
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta1"
@@ -34,42 +32,32 @@ var getSourceAllCmd = &cobra.Command{
   # List all sources in all namespaces
   flux get sources all --all-namespaces`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		err := validateWatchOption(cmd, "all")
-		if err != nil {
-			return err
-		}
-
-		var allSourceCmd = []getCommand{
-			{
-				apiType: bucketType,
-				list:    &bucketListAdapter{&sourcev1.BucketList{}},
-			},
-			{
-				apiType: gitRepositoryType,
-				list:    &gitRepositoryListAdapter{&sourcev1.GitRepositoryList{}},
-			},
-			{
-				apiType: helmRepositoryType,
-				list:    &helmRepositoryListAdapter{&sourcev1.HelmRepositoryList{}},
-			},
-			{
-				apiType: helmChartType,
-				list:    &helmChartListAdapter{&sourcev1.HelmChartList{}},
-			},
-		}
-
-		for _, c := range allSourceCmd {
-			if err := c.run(cmd, args); err != nil {
-				if !strings.Contains(err.Error(), "no matches for kind") {
-					logger.Failuref(err.Error())
-				}
-			}
-		}
-
-		return nil
+		return runGetAll(cmd, args, getSourceAllCommands())
 	},
 }
 
+// getSourceAllCommands returns the get commands of every source kind.
+func getSourceAllCommands() []getCommand {
+	return []getCommand{
+		{
+			apiType: bucketType,
+			list:    &bucketListAdapter{&sourcev1.BucketList{}},
+		},
+		{
+			apiType: gitRepositoryType,
+			list:    &gitRepositoryListAdapter{&sourcev1.GitRepositoryList{}},
+		},
+		{
+			apiType: helmRepositoryType,
+			list:    &helmRepositoryListAdapter{&sourcev1.HelmRepositoryList{}},
+		},
+		{
+			apiType: helmChartType,
+			list:    &helmChartListAdapter{&sourcev1.HelmChartList{}},
+		},
+	}
+}
+
 func init() {
 	getSourceCmd.AddCommand(getSourceAllCmd)
 }
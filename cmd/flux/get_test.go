@@ -0,0 +1,78 @@
+// +build unit
+
+package main
+
+import (
+	"testing"
+)
+
+func TestGetOutput(t *testing.T) {
+	cases := []struct {
+		name       string
+		args       string
+		goldenFile string
+	}{
+		{
+			"json",
+			"get kustomizations -o json",
+			"testdata/get/kustomizations_json.golden",
+		},
+		{
+			"yaml",
+			"get kustomizations -o yaml",
+			"testdata/get/kustomizations_yaml.golden",
+		},
+		{
+			"name",
+			"get kustomizations -o name",
+			"testdata/get/kustomizations_name.golden",
+		},
+		{
+			"go-template",
+			`get kustomizations -o 'go-template={{.metadata.name}} {{.status.lastAttemptedRevision}}{{"\n"}}'`,
+			"testdata/get/kustomizations_go_template.golden",
+		},
+		{
+			"single object",
+			"get kustomizations apps -o json",
+			"testdata/get/kustomization_json.golden",
+		},
+		{
+			"no matches",
+			"get kustomizations -l team=payments -o json",
+			"testdata/get/empty_json.golden",
+		},
+		{
+			"all json",
+			"get all -o json",
+			"testdata/get/all_json.golden",
+		},
+		{
+			"all yaml",
+			"get all -o yaml",
+			"testdata/get/all_yaml.golden",
+		},
+		{
+			"all no matches",
+			"get all -l team=payments -o yaml",
+			"testdata/get/empty_yaml.golden",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			useFakeKubeClient(t, "testdata/get/objects.yaml")
+			t.Cleanup(resetGetArgs)
+			cmd := cmdTestCase{
+				args:   tc.args + " -n=flux-system",
+				assert: assertGoldenFile(tc.goldenFile),
+			}
+			cmd.runTestCmd(t)
+		})
+	}
+}
+
+// resetGetArgs resets the get flags to their defaults, as the flags set
+// by a command persist across the commands run by the tests.
+func resetGetArgs() {
+	getArgs = GetFlags{output: getOutputTable}
+}
@@ -54,3 +54,7 @@ func (h helmReleaseListAdapter) asClientList() client.ObjectList {
 func (h helmReleaseListAdapter) len() int {
 	return len(h.HelmReleaseList.Items)
 }
+
+func (h helmReleaseListAdapter) clientObject(i int) client.Object {
+	return &h.HelmReleaseList.Items[i]
+}
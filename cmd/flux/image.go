@@ -60,6 +60,10 @@ func (a imageRepositoryListAdapter) len() int {
 	return len(a.ImageRepositoryList.Items)
 }
 
+func (a imageRepositoryListAdapter) clientObject(i int) client.Object {
+	return &a.ImageRepositoryList.Items[i]
+}
+
 // imagev1.ImagePolicy
 
 var imagePolicyType = apiType{
@@ -89,6 +93,10 @@ func (a imagePolicyListAdapter) len() int {
 	return len(a.ImagePolicyList.Items)
 }
 
+func (a imagePolicyListAdapter) clientObject(i int) client.Object {
+	return &a.ImagePolicyList.Items[i]
+}
+
 // autov1.ImageUpdateAutomation
 
 var imageUpdateAutomationType = apiType{
@@ -121,3 +129,7 @@ func (a imageUpdateAutomationListAdapter) asClientList() client.ObjectList {
 func (a imageUpdateAutomationListAdapter) len() int {
 	return len(a.ImageUpdateAutomationList.Items)
 }
+
+func (a imageUpdateAutomationListAdapter) clientObject(i int) client.Object {
+	return &a.ImageUpdateAutomationList.Items[i]
+}
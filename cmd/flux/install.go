@@ -25,8 +25,11 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
 
 	"github.com/fluxcd/flux2/internal/flags"
+	"github.com/fluxcd/flux2/internal/ssa"
 	"github.com/fluxcd/flux2/internal/utils"
 	"github.com/fluxcd/flux2/pkg/manifestgen/install"
 	"github.com/fluxcd/flux2/pkg/status"
@@ -50,7 +53,22 @@ If a previous version is installed, then an in-place upgrade will be performed.`
   flux install --dry-run --verbose
 
   # Write install manifests to file
-  flux install --export > flux-system.yaml`,
+  flux install --export > flux-system.yaml
+
+  # Install with namespaced RBAC on a cluster where the CRDs and the namespace exist
+  flux install --watch-all-namespaces=false --namespaced --target-namespaces=apps
+
+  # Install with the multi-tenancy lockdown profile
+  flux install --multi-tenant
+
+  # Install with resource limits and extra args set by Kustomize patches
+  flux install --patch-file=patches.yaml
+
+  # Write an air-gapped install bundle including the image layers
+  flux install --export-bundle=flux-bundle.tar.gz --bundle-images
+
+  # Install from an air-gapped bundle, pushing the images to a private registry
+  flux install --from-bundle=flux-bundle.tar.gz --registry=registry.internal/fluxcd`,
 	RunE: installCmdRun,
 }
 
@@ -71,6 +89,17 @@ type installFlags struct {
 	tokenAuth          bool
 	clusterDomain      string
 	tolerationKeys     []string
+	exportBundle       string
+	bundleImages       bool
+	fromBundle         string
+	noCache            bool
+	patchFile          string
+	namespaced         bool
+	targetNamespaces   []string
+	multiTenant        bool
+	defaultSA          string
+	imageDigests       bool
+	imageDigestsFile   string
 }
 
 var installArgs = NewInstallFlags()
@@ -100,6 +129,28 @@ func init() {
 	installCmd.Flags().StringVar(&installArgs.clusterDomain, "cluster-domain", rootArgs.defaults.ClusterDomain, "internal cluster domain")
 	installCmd.Flags().StringSliceVar(&installArgs.tolerationKeys, "toleration-keys", nil,
 		"list of toleration keys used to schedule the components pods onto nodes with matching taints")
+	installCmd.Flags().StringVar(&installArgs.exportBundle, "export-bundle", "",
+		"write an air-gapped install bundle with the manifests and the image list to the given file, instead of installing")
+	installCmd.Flags().BoolVar(&installArgs.bundleImages, "bundle-images", false,
+		"include the image layers as an OCI image layout in the bundle written by --export-bundle")
+	installCmd.Flags().StringVar(&installArgs.fromBundle, "from-bundle", "",
+		"path to an air-gapped install bundle, the images are pushed to --registry when the bundle includes them")
+	installCmd.Flags().BoolVar(&installArgs.namespaced, "namespaced", false,
+		"limit the controllers RBAC to the install namespace and --target-namespaces, and leave out the cluster-scoped objects, requires --watch-all-namespaces=false")
+	installCmd.Flags().StringSliceVar(&installArgs.targetNamespaces, "target-namespaces", nil,
+		"namespaces the reconcilers can apply resources to in addition to the install namespace, requires --namespaced")
+	installCmd.Flags().BoolVar(&installArgs.multiTenant, "multi-tenant", false,
+		"deny cross-namespace references and make the reconcilers impersonate --default-service-account when no service account is set")
+	installCmd.Flags().StringVar(&installArgs.defaultSA, "default-service-account", rootArgs.defaults.DefaultServiceAccount,
+		"service account impersonated by the reconcilers by default, requires --multi-tenant")
+	installCmd.Flags().StringVar(&installArgs.patchFile, "patch-file", "",
+		"path to a YAML list of Kustomize patches applied to the components manifests")
+	installCmd.Flags().BoolVar(&installArgs.imageDigests, "image-digests", false,
+		"pin the components images to the digests of their tags, as resolved from the container registry")
+	installCmd.Flags().StringVar(&installArgs.imageDigestsFile, "image-digests-file", "",
+		"path to a YAML map of component names to image digests, the components missing from the map are resolved when --image-digests is set")
+	installCmd.Flags().BoolVar(&installArgs.noCache, "no-cache", false,
+		"download the release manifests even if they are in the local cache")
 	installCmd.Flags().MarkHidden("manifests")
 	installCmd.Flags().MarkDeprecated("arch", "multi-arch container image is now available for AMD64, ARMv7 and ARM64")
 	rootCmd.AddCommand(installCmd)
@@ -121,13 +172,31 @@ func installCmdRun(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	if ver, err := getVersion(installArgs.version); err != nil {
-		return err
-	} else {
-		installArgs.version = ver
+	if installArgs.fromBundle != "" && installArgs.exportBundle != "" {
+		return fmt.Errorf("--from-bundle and --export-bundle are mutually exclusive")
+	}
+	if installArgs.fromBundle != "" && installArgs.version != "" {
+		return fmt.Errorf("--version can't be used with --from-bundle, the version is read from the bundle")
+	}
+	if installArgs.bundleImages && installArgs.exportBundle == "" {
+		return fmt.Errorf("--bundle-images requires --export-bundle")
+	}
+	if installArgs.namespaced && installArgs.watchAllNamespaces {
+		return fmt.Errorf("--namespaced requires --watch-all-namespaces=false")
+	}
+	if len(installArgs.targetNamespaces) > 0 && !installArgs.namespaced {
+		return fmt.Errorf("--target-namespaces requires --namespaced")
+	}
+
+	if installArgs.fromBundle == "" {
+		if ver, err := getVersion(installArgs.version); err != nil {
+			return err
+		} else {
+			installArgs.version = ver
+		}
 	}
 
-	if !installArgs.export {
+	if !installArgs.export && installArgs.exportBundle == "" {
 		logger.Generatef("generating manifests")
 	}
 
@@ -138,13 +207,27 @@ func installCmdRun(cmd *cobra.Command, args []string) error {
 	defer os.RemoveAll(tmpDir)
 
 	manifestsBase := ""
-	if isEmbeddedVersion(installArgs.version) {
+	if installArgs.fromBundle != "" {
+		bundleDir := filepath.Join(tmpDir, "bundle")
+		bundle, err := loadBundle(ctx, installArgs.fromBundle, bundleDir, installArgs.registry, components,
+			!installArgs.export && !installArgs.dryRun)
+		if err != nil {
+			return fmt.Errorf("install failed: %w", err)
+		}
+		installArgs.version = bundle.Version
+		manifestsBase = bundle.ManifestsBase()
+	} else if installArgs.exportBundle == "" && isEmbeddedVersion(installArgs.version) {
 		if err := writeEmbeddedManifests(tmpDir); err != nil {
 			return err
 		}
 		manifestsBase = tmpDir
 	}
 
+	releases, err := releaseSource()
+	if err != nil {
+		return err
+	}
+
 	opts := install.Options{
 		BaseURL:                installArgs.manifestsPath,
 		Version:                installArgs.version,
@@ -160,12 +243,40 @@ func installCmdRun(cmd *cobra.Command, args []string) error {
 		Timeout:                rootArgs.timeout,
 		ClusterDomain:          installArgs.clusterDomain,
 		TolerationKeys:         installArgs.tolerationKeys,
+		CacheDir:               manifestsCacheDir(installArgs.noCache),
+		ReleaseSource:          releases,
+		Namespaced:             installArgs.namespaced,
+		TargetNamespaces:       installArgs.targetNamespaces,
+		MultiTenant:            installArgs.multiTenant,
+		DefaultServiceAccount:  installArgs.defaultSA,
+		ResolveImageDigests:    installArgs.imageDigests,
+	}
+
+	if installArgs.patchFile != "" {
+		if opts.Patches, err = loadPatchFile(installArgs.patchFile); err != nil {
+			return err
+		}
+	}
+
+	if installArgs.imageDigestsFile != "" {
+		if opts.ImageDigests, err = loadImageDigestsFile(installArgs.imageDigestsFile); err != nil {
+			return err
+		}
 	}
 
 	if installArgs.manifestsPath == "" {
 		opts.BaseURL = install.MakeDefaultOptions().BaseURL
 	}
 
+	if installArgs.exportBundle != "" {
+		logger.Generatef("exporting bundle for Flux %s", installArgs.version)
+		if err := exportBundle(ctx, opts, installArgs.exportBundle, installArgs.bundleImages); err != nil {
+			return fmt.Errorf("export bundle failed: %w", err)
+		}
+		logger.Successf("bundle written to %s", installArgs.exportBundle)
+		return nil
+	}
+
 	manifest, err := install.Generate(opts, manifestsBase)
 	if err != nil {
 		return fmt.Errorf("install failed: %w", err)
@@ -188,19 +299,27 @@ func installCmdRun(cmd *cobra.Command, args []string) error {
 
 	logger.Successf("manifests build completed")
 	logger.Actionf("installing components in %s namespace", rootArgs.namespace)
-	applyOutput := utils.ModeStderrOS
-	if rootArgs.verbose {
-		applyOutput = utils.ModeOS
+	objects, err := ssa.ReadPath(filepath.Join(tmpDir, manifest.Path))
+	if err != nil {
+		return fmt.Errorf("install failed: %w", err)
 	}
-
-	kubectlArgs := []string{"apply", "-f", filepath.Join(tmpDir, manifest.Path)}
-	if installArgs.dryRun {
-		kubectlArgs = append(kubectlArgs, "--dry-run=client")
-		applyOutput = utils.ModeOS
+	kubeClient, err := newKubeClient()
+	if err != nil {
+		return fmt.Errorf("install failed: %w", err)
 	}
-	if _, err := utils.ExecKubectlCommand(ctx, applyOutput, rootArgs.kubeconfig, rootArgs.kubecontext, kubectlArgs...); err != nil {
+	resourceManager := ssa.NewResourceManager(kubeClient, ssa.FieldOwner)
+	resourceManager.DryRun = installArgs.dryRun
+	changeSet, err := resourceManager.ApplyAllStaged(ctx, objects, rootArgs.timeout)
+	if err != nil {
 		return fmt.Errorf("install failed: %w", err)
 	}
+	applyOutput := os.Stderr
+	if rootArgs.verbose || installArgs.dryRun {
+		applyOutput = os.Stdout
+	}
+	for _, entry := range changeSet.Entries {
+		fmt.Fprintln(applyOutput, entry.String())
+	}
 
 	if installArgs.dryRun {
 		logger.Successf("install dry-run finished")
@@ -227,3 +346,40 @@ func installCmdRun(cmd *cobra.Command, args []string) error {
 	logger.Successf("install finished")
 	return nil
 }
+
+// manifestsCacheDir returns the directory where the release manifests
+// are cached under the user cache directory, or an empty string when
+// caching is disabled or the user cache directory can't be determined.
+func manifestsCacheDir(noCache bool) string {
+	if noCache {
+		return ""
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "flux", "manifests")
+}
+
+// loadPatchFile reads the Kustomize patches from the given file.
+func loadPatchFile(path string) ([]kustypes.Patch, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read patch file: %w", err)
+	}
+	return install.ParsePatches(data)
+}
+
+// loadImageDigestsFile reads the map of component names to image digests
+// from the given file.
+func loadImageDigestsFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image digests file: %w", err)
+	}
+	var digests map[string]string
+	if err := yaml.UnmarshalStrict(data, &digests); err != nil {
+		return nil, fmt.Errorf("failed to parse image digests file: %w", err)
+	}
+	return digests, nil
+}
@@ -54,3 +54,7 @@ func (a kustomizationListAdapter) asClientList() client.ObjectList {
 func (a kustomizationListAdapter) len() int {
 	return len(a.KustomizationList.Items)
 }
+
+func (a kustomizationListAdapter) clientObject(i int) client.Object {
+	return &a.KustomizationList.Items[i]
+}
@@ -19,6 +19,8 @@ package main
 import (
 	"fmt"
 	"io"
+
+	"github.com/fluxcd/flux2/pkg/log"
 )
 
 type stderrLogger struct {
@@ -48,3 +50,34 @@ func (l stderrLogger) Warningf(format string, a ...interface{}) {
 func (l stderrLogger) Failuref(format string, a ...interface{}) {
 	fmt.Fprintln(l.stderr, `✗`, fmt.Sprintf(format, a...))
 }
+
+// prefixLogger prefixes the messages of the wrapped logger, e.g. with
+// the name of the cluster when bootstrapping several at once.
+type prefixLogger struct {
+	logger log.Logger
+	prefix string
+}
+
+func (l prefixLogger) Actionf(format string, a ...interface{}) {
+	l.logger.Actionf("%s: %s", l.prefix, fmt.Sprintf(format, a...))
+}
+
+func (l prefixLogger) Generatef(format string, a ...interface{}) {
+	l.logger.Generatef("%s: %s", l.prefix, fmt.Sprintf(format, a...))
+}
+
+func (l prefixLogger) Waitingf(format string, a ...interface{}) {
+	l.logger.Waitingf("%s: %s", l.prefix, fmt.Sprintf(format, a...))
+}
+
+func (l prefixLogger) Successf(format string, a ...interface{}) {
+	l.logger.Successf("%s: %s", l.prefix, fmt.Sprintf(format, a...))
+}
+
+func (l prefixLogger) Warningf(format string, a ...interface{}) {
+	l.logger.Warningf("%s: %s", l.prefix, fmt.Sprintf(format, a...))
+}
+
+func (l prefixLogger) Failuref(format string, a ...interface{}) {
+	l.logger.Failuref("%s: %s", l.prefix, fmt.Sprintf(format, a...))
+}
@@ -25,7 +25,9 @@ import (
 	"github.com/spf13/cobra"
 	corev1 "k8s.io/api/core/v1"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/fluxcd/flux2/internal/utils"
 	"github.com/fluxcd/flux2/pkg/manifestgen/install"
 )
 
@@ -102,11 +104,18 @@ type rootFlags struct {
 	timeout      time.Duration
 	verbose      bool
 	pollInterval time.Duration
+	releasesURL  string
 	defaults     install.Options
 }
 
 var rootArgs = NewRootFlags()
 
+// newKubeClient returns a client for the --kubeconfig and --context,
+// tests replace it to run the commands against a fake client.
+var newKubeClient = func() (client.WithWatch, error) {
+	return utils.KubeClient(rootArgs.kubeconfig, rootArgs.kubecontext)
+}
+
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&rootArgs.namespace, "namespace", "n", rootArgs.defaults.Namespace, "the namespace scope for this operation")
 	rootCmd.RegisterFlagCompletionFunc("namespace", resourceNamesCompletionFunc(corev1.SchemeGroupVersion.WithKind("Namespace")))
@@ -119,6 +128,9 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&rootArgs.kubecontext, "context", "", "", "kubernetes context to use")
 	rootCmd.RegisterFlagCompletionFunc("context", contextsCompletionFunc)
 
+	rootCmd.PersistentFlags().StringVar(&rootArgs.releasesURL, "releases-url", "",
+		"URL of the Flux releases discovery, either a GitHub API releases endpoint, an HTTP URL or a local path to a release index, defaults to the GitHub API")
+
 	rootCmd.DisableAutoGenTag = true
 }
 
@@ -6,9 +6,16 @@ import (
 	"context"
 	"fmt"
 	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
 	"os"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"testing"
+
+	"github.com/fluxcd/flux2/internal/utils"
 )
 
 // The test environment is long running process shared between tests, initialized
@@ -46,3 +53,96 @@ func setupTestNamespace(namespace string, t *testing.T) {
 		_ = testEnv.client.Delete(context.Background(), ns)
 	})
 }
+
+// useFakeKubeClient makes the commands run against a fake client holding
+// the objects of the given file, until the end of the test.
+func useFakeKubeClient(t *testing.T, objectFile string) client.WithWatch {
+	t.Helper()
+	f, err := os.Open(objectFile)
+	if err != nil {
+		t.Fatalf("Error reading file '%s': %v", objectFile, err)
+	}
+	defer f.Close()
+	objects, err := readYamlObjects(f)
+	if err != nil {
+		t.Fatalf("Error decoding yaml file '%s': %v", objectFile, err)
+	}
+	scheme := utils.NewScheme()
+	builder := fake.NewClientBuilder().WithScheme(scheme)
+	for i := range objects {
+		obj, err := scheme.New(objects[i].GroupVersionKind())
+		if err != nil {
+			t.Fatalf("Error creating object '%s': %v", objects[i].GetName(), err)
+		}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(objects[i].Object, obj); err != nil {
+			t.Fatalf("Error converting object '%s': %v", objects[i].GetName(), err)
+		}
+		builder = builder.WithRuntimeObjects(obj)
+	}
+	mapper := apimeta.NewDefaultRESTMapper(nil)
+	for gvk := range scheme.AllKnownTypes() {
+		scope := apimeta.RESTScopeNamespace
+		if clusterScopedKinds[gvk.Kind] {
+			scope = apimeta.RESTScopeRoot
+		}
+		mapper.Add(gvk, scope)
+	}
+	kubeClient := fakeKubeClient{builder.Build(), mapper}
+
+	prev := newKubeClient
+	newKubeClient = func() (client.WithWatch, error) {
+		return kubeClient, nil
+	}
+	t.Cleanup(func() {
+		newKubeClient = prev
+	})
+	return kubeClient
+}
+
+// clusterScopedKinds are the kinds of the scheme which are not namespaced.
+var clusterScopedKinds = map[string]bool{
+	"Namespace":                true,
+	"ClusterRole":              true,
+	"ClusterRoleBinding":       true,
+	"CustomResourceDefinition": true,
+}
+
+// fakeKubeClient completes the fake client with the name and namespace
+// field selectors, which it ignores, and a REST mapper, which it lacks.
+type fakeKubeClient struct {
+	client.WithWatch
+	mapper apimeta.RESTMapper
+}
+
+func (c fakeKubeClient) RESTMapper() apimeta.RESTMapper {
+	return c.mapper
+}
+
+func (c fakeKubeClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if err := c.WithWatch.List(ctx, list, opts...); err != nil {
+		return err
+	}
+	listOpts := client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	if listOpts.FieldSelector == nil || listOpts.FieldSelector.Empty() {
+		return nil
+	}
+	items, err := apimeta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	var selected []runtime.Object
+	for _, item := range items {
+		obj, err := apimeta.Accessor(item)
+		if err != nil {
+			return err
+		}
+		if listOpts.FieldSelector.Matches(fields.Set{
+			"metadata.name":      obj.GetName(),
+			"metadata.namespace": obj.GetNamespace(),
+		}) {
+			selected = append(selected, item)
+		}
+	}
+	return apimeta.SetList(list, selected)
+}
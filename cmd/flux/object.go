@@ -52,6 +52,7 @@ type copyable interface {
 type listAdapter interface {
 	asClientList() client.ObjectList
 	len() int
+	clientObject(i int) client.Object
 }
 
 // universalAdapter is an adapter for any client.Object. Use this if
@@ -0,0 +1,210 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+
+	"github.com/fluxcd/pkg/apis/meta"
+
+	"github.com/fluxcd/flux2/internal/utils"
+)
+
+const (
+	getOutputTable      = "table"
+	getOutputWide       = "wide"
+	getOutputJSON       = "json"
+	getOutputYAML       = "yaml"
+	getOutputName       = "name"
+	getOutputGoTemplate = "go-template"
+)
+
+var getOutputFormats = []string{getOutputTable, getOutputWide, getOutputJSON, getOutputYAML, getOutputName}
+
+func validateGetOutput(output string) error {
+	if utils.ContainsItemString(getOutputFormats, output) || strings.HasPrefix(output, getOutputGoTemplate+"=") {
+		return nil
+	}
+	return fmt.Errorf("unsupported output format '%s', must be one of: %s, %s=...",
+		output, strings.Join(getOutputFormats, ", "), getOutputGoTemplate)
+}
+
+// wideHeaders returns the headers of the columns added by the wide
+// output to the given headers.
+func wideHeaders(headers []string) []string {
+	wide := []string{"Source", "Source revision", "Interval", "Last handled reconcile"}
+	if !utils.ContainsItemString(headers, "Suspended") {
+		wide = append(wide, "Suspended")
+	}
+	return wide
+}
+
+// wideColumns returns the values of the wideHeaders columns for the object.
+// The fields are read from the unstructured object, so that the columns
+// are the same for every kind, and empty when a kind has no such field.
+func wideColumns(obj client.Object, headers []string) []string {
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return make([]string, len(wideHeaders(headers)))
+	}
+
+	sourceRef, found, _ := unstructured.NestedMap(u, "spec", "sourceRef")
+	if !found {
+		sourceRef, _, _ = unstructured.NestedMap(u, "spec", "chart", "spec", "sourceRef")
+	}
+	var source string
+	if name, ok := sourceRef["name"].(string); ok {
+		source = name
+		if kind, ok := sourceRef["kind"].(string); ok {
+			source = fmt.Sprintf("%s/%s", kind, name)
+		}
+	}
+
+	revision, found, _ := unstructured.NestedString(u, "status", "lastAttemptedRevision")
+	if !found {
+		revision, _, _ = unstructured.NestedString(u, "status", "artifact", "revision")
+	}
+
+	interval, _, _ := unstructured.NestedString(u, "spec", "interval")
+
+	var lastHandled string
+	if s, _, _ := unstructured.NestedString(u, "status", "lastHandledReconcileAt"); s != "" {
+		lastHandled = s
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			lastHandled = t.Format(time.RFC3339)
+		}
+	}
+
+	columns := []string{source, revision, interval, lastHandled}
+	if !utils.ContainsItemString(headers, "Suspended") {
+		suspend, _, _ := unstructured.NestedBool(u, "spec", "suspend")
+		columns = append(columns, strings.Title(strconv.FormatBool(suspend)))
+	}
+	return columns
+}
+
+// readyAndLastReconcile returns the status of the Ready condition of the
+// object, and the time of its last handled reconcile request, or of the
+// last Ready transition if no reconcile was requested.
+func readyAndLastReconcile(obj client.Object) (string, time.Time) {
+	ready := string(metav1.ConditionFalse)
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return ready, time.Time{}
+	}
+
+	var readyTransition string
+	conditions, _, _ := unstructured.NestedSlice(u, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != meta.ReadyCondition {
+			continue
+		}
+		if status, ok := condition["status"].(string); ok {
+			ready = status
+		}
+		readyTransition, _ = condition["lastTransitionTime"].(string)
+	}
+
+	lastReconcile, _, _ := unstructured.NestedString(u, "status", "lastHandledReconcileAt")
+	if lastReconcile == "" {
+		lastReconcile = readyTransition
+	}
+	t, _ := time.Parse(time.RFC3339Nano, lastReconcile)
+	return ready, t
+}
+
+// printObjects writes the objects to w in the given output format, json
+// and yaml print a List of the objects, or the object itself if single is
+// true and there is exactly one, go-template executes the template for
+// each object.
+func printObjects(w io.Writer, output string, objects []client.Object, single bool) error {
+	scheme := utils.NewScheme()
+	items := []map[string]interface{}{}
+	for _, obj := range objects {
+		gvk, err := apiutil.GVKForObject(obj, scheme)
+		if err != nil {
+			return err
+		}
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return err
+		}
+		u["apiVersion"], u["kind"] = gvk.GroupVersion().String(), gvk.Kind
+		items = append(items, u)
+	}
+
+	switch {
+	case output == getOutputJSON || output == getOutputYAML:
+		var doc interface{} = map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "List",
+			"items":      items,
+		}
+		if single && len(items) == 1 {
+			doc = items[0]
+		}
+		if output == getOutputJSON {
+			data, err := json.MarshalIndent(doc, "", "  ")
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(w, string(data))
+			return err
+		}
+		data, err := yaml.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "---\n%s", data)
+		return err
+	case output == getOutputName:
+		for _, item := range items {
+			if _, err := fmt.Fprintf(w, "%s/%s\n", strings.ToLower(item["kind"].(string)),
+				item["metadata"].(map[string]interface{})["name"]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case strings.HasPrefix(output, getOutputGoTemplate+"="):
+		tmpl, err := template.New("output").Parse(strings.TrimPrefix(output, getOutputGoTemplate+"="))
+		if err != nil {
+			return fmt.Errorf("invalid go-template: %w", err)
+		}
+		for _, item := range items {
+			if err := tmpl.Execute(w, item); err != nil {
+				return fmt.Errorf("go-template execution failed: %w", err)
+			}
+		}
+		return nil
+	default:
+		return validateGetOutput(output)
+	}
+}
@@ -54,3 +54,7 @@ func (a receiverListAdapter) asClientList() client.ObjectList {
 func (a receiverListAdapter) len() int {
 	return len(a.ReceiverList.Items)
 }
+
+func (a receiverListAdapter) clientObject(i int) client.Object {
+	return &a.ReceiverList.Items[i]
+}
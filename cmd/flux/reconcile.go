@@ -31,8 +31,6 @@ import (
 
 	"github.com/fluxcd/notification-controller/api/v1beta1"
 	"github.com/fluxcd/pkg/apis/meta"
-
-	"github.com/fluxcd/flux2/internal/utils"
 )
 
 var reconcileCmd = &cobra.Command{
@@ -41,7 +39,15 @@ var reconcileCmd = &cobra.Command{
 	Long:  "The reconcile sub-commands trigger a reconciliation of sources and resources.",
 }
 
+type reconcileFlags struct {
+	labelSelector string
+}
+
+var reconcileArgs reconcileFlags
+
 func init() {
+	reconcileCmd.PersistentFlags().StringVarP(&reconcileArgs.labelSelector, "selector", "l", "",
+		"reconcile the resources in that namespace matching the label selector, e.g. team=payments")
 	rootCmd.AddCommand(reconcileCmd)
 }
 
@@ -64,28 +70,44 @@ type reconcilable interface {
 
 	lastHandledReconcileRequest() string // what was the last handled reconcile request?
 	successMessage() string              // what do you want to tell people when successfully reconciled?
+	newReconcilable() reconcilable       // a new, empty value to load another object from the cluster
 }
 
 func (reconcile reconcileCommand) run(cmd *cobra.Command, args []string) error {
-	if len(args) < 1 {
+	if len(args) < 1 && reconcileArgs.labelSelector == "" {
 		return fmt.Errorf("%s name is required", reconcile.kind)
 	}
-	name := args[0]
 
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
-	kubeClient, err := utils.KubeClient(rootArgs.kubeconfig, rootArgs.kubecontext)
+	kubeClient, err := newKubeClient()
 	if err != nil {
 		return err
 	}
 
+	names, err := objectNames(ctx, kubeClient, reconcile.object.asClientObject(), args, reconcileArgs.labelSelector)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		reconcile.object = reconcile.object.newReconcilable()
+		if err := reconcile.reconcile(ctx, kubeClient, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reconcile requests the reconciliation of the named object
+// and waits for it to be handled.
+func (reconcile reconcileCommand) reconcile(ctx context.Context, kubeClient client.Client, name string) error {
 	namespacedName := types.NamespacedName{
 		Namespace: rootArgs.namespace,
 		Name:      name,
 	}
 
-	err = kubeClient.Get(ctx, namespacedName, reconcile.object.asClientObject())
+	err := kubeClient.Get(ctx, namespacedName, reconcile.object.asClientObject())
 	if err != nil {
 		return err
 	}
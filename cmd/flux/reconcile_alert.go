@@ -42,3 +42,7 @@ func init() {
 func (obj alertAdapter) lastHandledReconcileRequest() string {
 	return ""
 }
+
+func (obj alertAdapter) newReconcilable() reconcilable {
+	return alertAdapter{&notificationv1.Alert{}}
+}
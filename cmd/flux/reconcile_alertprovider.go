@@ -24,11 +24,10 @@ import (
 	"github.com/spf13/cobra"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	notificationv1 "github.com/fluxcd/notification-controller/api/v1beta1"
 	"github.com/fluxcd/pkg/apis/meta"
-
-	"github.com/fluxcd/flux2/internal/utils"
 )
 
 var reconcileAlertProviderCmd = &cobra.Command{
@@ -46,19 +45,31 @@ func init() {
 }
 
 func reconcileAlertProviderCmdRun(cmd *cobra.Command, args []string) error {
-	if len(args) < 1 {
+	if len(args) < 1 && reconcileArgs.labelSelector == "" {
 		return fmt.Errorf("Provider name is required")
 	}
-	name := args[0]
 
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
-	kubeClient, err := utils.KubeClient(rootArgs.kubeconfig, rootArgs.kubecontext)
+	kubeClient, err := newKubeClient()
+	if err != nil {
+		return err
+	}
+
+	names, err := objectNames(ctx, kubeClient, &notificationv1.Provider{}, args, reconcileArgs.labelSelector)
 	if err != nil {
 		return err
 	}
+	for _, name := range names {
+		if err := reconcileAlertProvider(ctx, kubeClient, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+func reconcileAlertProvider(ctx context.Context, kubeClient client.Client, name string) error {
 	namespacedName := types.NamespacedName{
 		Namespace: rootArgs.namespace,
 		Name:      name,
@@ -66,7 +77,7 @@ func reconcileAlertProviderCmdRun(cmd *cobra.Command, args []string) error {
 
 	logger.Actionf("annotating Provider %s in %s namespace", name, rootArgs.namespace)
 	var alertProvider notificationv1.Provider
-	err = kubeClient.Get(ctx, namespacedName, &alertProvider)
+	err := kubeClient.Get(ctx, namespacedName, &alertProvider)
 	if err != nil {
 		return err
 	}
@@ -58,6 +58,14 @@ func (obj helmReleaseAdapter) lastHandledReconcileRequest() string {
 	return obj.Status.GetLastHandledReconcileRequest()
 }
 
+func (obj helmReleaseAdapter) newReconcilable() reconcilable {
+	return helmReleaseAdapter{&helmv2.HelmRelease{}}
+}
+
+func (obj helmReleaseAdapter) newReconcileWithSource() reconcileWithSource {
+	return helmReleaseAdapter{&helmv2.HelmRelease{}}
+}
+
 func (obj helmReleaseAdapter) reconcileSource() bool {
 	return rhrArgs.syncHrWithSource
 }
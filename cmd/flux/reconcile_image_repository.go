@@ -45,6 +45,10 @@ func (obj imageRepositoryAdapter) lastHandledReconcileRequest() string {
 	return obj.Status.GetLastHandledReconcileRequest()
 }
 
+func (obj imageRepositoryAdapter) newReconcilable() reconcilable {
+	return imageRepositoryAdapter{&imagev1.ImageRepository{}}
+}
+
 func (obj imageRepositoryAdapter) successMessage() string {
 	return fmt.Sprintf("scan fetched %d tags", obj.Status.LastScanResult.TagCount)
 }
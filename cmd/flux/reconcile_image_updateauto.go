@@ -51,6 +51,10 @@ func (obj imageUpdateAutomationAdapter) lastHandledReconcileRequest() string {
 	return obj.Status.GetLastHandledReconcileRequest()
 }
 
+func (obj imageUpdateAutomationAdapter) newReconcilable() reconcilable {
+	return imageUpdateAutomationAdapter{&autov1.ImageUpdateAutomation{}}
+}
+
 func (obj imageUpdateAutomationAdapter) successMessage() string {
 	if rc := apimeta.FindStatusCondition(obj.Status.Conditions, meta.ReadyCondition); rc != nil {
 		return rc.Message
@@ -58,6 +58,14 @@ func (obj kustomizationAdapter) lastHandledReconcileRequest() string {
 	return obj.Status.GetLastHandledReconcileRequest()
 }
 
+func (obj kustomizationAdapter) newReconcilable() reconcilable {
+	return kustomizationAdapter{&kustomizev1.Kustomization{}}
+}
+
+func (obj kustomizationAdapter) newReconcileWithSource() reconcileWithSource {
+	return kustomizationAdapter{&kustomizev1.Kustomization{}}
+}
+
 func (obj kustomizationAdapter) reconcileSource() bool {
 	return rksArgs.syncKsWithSource
 }
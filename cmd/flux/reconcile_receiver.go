@@ -24,11 +24,10 @@ import (
 	"github.com/spf13/cobra"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	notificationv1 "github.com/fluxcd/notification-controller/api/v1beta1"
 	"github.com/fluxcd/pkg/apis/meta"
-
-	"github.com/fluxcd/flux2/internal/utils"
 )
 
 var reconcileReceiverCmd = &cobra.Command{
@@ -46,26 +45,38 @@ func init() {
 }
 
 func reconcileReceiverCmdRun(cmd *cobra.Command, args []string) error {
-	if len(args) < 1 {
+	if len(args) < 1 && reconcileArgs.labelSelector == "" {
 		return fmt.Errorf("receiver name is required")
 	}
-	name := args[0]
 
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
-	kubeClient, err := utils.KubeClient(rootArgs.kubeconfig, rootArgs.kubecontext)
+	kubeClient, err := newKubeClient()
+	if err != nil {
+		return err
+	}
+
+	names, err := objectNames(ctx, kubeClient, &notificationv1.Receiver{}, args, reconcileArgs.labelSelector)
 	if err != nil {
 		return err
 	}
+	for _, name := range names {
+		if err := reconcileReceiver(ctx, kubeClient, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+func reconcileReceiver(ctx context.Context, kubeClient client.Client, name string) error {
 	namespacedName := types.NamespacedName{
 		Namespace: rootArgs.namespace,
 		Name:      name,
 	}
 
 	var receiver notificationv1.Receiver
-	err = kubeClient.Get(ctx, namespacedName, &receiver)
+	err := kubeClient.Get(ctx, namespacedName, &receiver)
 	if err != nil {
 		return err
 	}
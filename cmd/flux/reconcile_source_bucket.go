@@ -77,6 +77,10 @@ func (obj bucketAdapter) lastHandledReconcileRequest() string {
 	return obj.Status.GetLastHandledReconcileRequest()
 }
 
+func (obj bucketAdapter) newReconcilable() reconcilable {
+	return bucketAdapter{&sourcev1.Bucket{}}
+}
+
 func (obj bucketAdapter) successMessage() string {
 	return fmt.Sprintf("fetched revision %s", obj.Status.Artifact.Revision)
 }
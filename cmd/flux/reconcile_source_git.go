@@ -45,6 +45,10 @@ func (obj gitRepositoryAdapter) lastHandledReconcileRequest() string {
 	return obj.Status.GetLastHandledReconcileRequest()
 }
 
+func (obj gitRepositoryAdapter) newReconcilable() reconcilable {
+	return gitRepositoryAdapter{&sourcev1.GitRepository{}}
+}
+
 func (obj gitRepositoryAdapter) successMessage() string {
 	return fmt.Sprintf("fetched revision %s", obj.Status.Artifact.Revision)
 }
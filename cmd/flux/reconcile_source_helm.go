@@ -45,6 +45,10 @@ func (obj helmRepositoryAdapter) lastHandledReconcileRequest() string {
 	return obj.Status.GetLastHandledReconcileRequest()
 }
 
+func (obj helmRepositoryAdapter) newReconcilable() reconcilable {
+	return helmRepositoryAdapter{&sourcev1.HelmRepository{}}
+}
+
 func (obj helmRepositoryAdapter) successMessage() string {
 	return fmt.Sprintf("fetched revision %s", obj.Status.Artifact.Revision)
 }
@@ -8,10 +8,9 @@ import (
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/fluxcd/pkg/apis/meta"
-
-	"github.com/fluxcd/flux2/internal/utils"
 )
 
 type reconcileWithSource interface {
@@ -19,6 +18,7 @@ type reconcileWithSource interface {
 	reconcilable
 	reconcileSource() bool
 	getSource() (reconcileCommand, types.NamespacedName)
+	newReconcileWithSource() reconcileWithSource
 }
 
 type reconcileWithSourceCommand struct {
@@ -27,25 +27,40 @@ type reconcileWithSourceCommand struct {
 }
 
 func (reconcile reconcileWithSourceCommand) run(cmd *cobra.Command, args []string) error {
-	if len(args) < 1 {
+	if len(args) < 1 && reconcileArgs.labelSelector == "" {
 		return fmt.Errorf("%s name is required", reconcile.kind)
 	}
-	name := args[0]
 
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
-	kubeClient, err := utils.KubeClient(rootArgs.kubeconfig, rootArgs.kubecontext)
+	kubeClient, err := newKubeClient()
+	if err != nil {
+		return err
+	}
+
+	names, err := objectNames(ctx, kubeClient, reconcile.object.asClientObject(), args, reconcileArgs.labelSelector)
 	if err != nil {
 		return err
 	}
+	for _, name := range names {
+		reconcile.object = reconcile.object.newReconcileWithSource()
+		if err := reconcile.reconcile(ctx, kubeClient, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+// reconcile requests the reconciliation of the named object, and of its
+// source first if requested, and waits for it to be handled.
+func (reconcile reconcileWithSourceCommand) reconcile(ctx context.Context, kubeClient client.Client, name string) error {
 	namespacedName := types.NamespacedName{
 		Namespace: rootArgs.namespace,
 		Name:      name,
 	}
 
-	err = kubeClient.Get(ctx, namespacedName, reconcile.object.asClientObject())
+	err := kubeClient.Get(ctx, namespacedName, reconcile.object.asClientObject())
 	if err != nil {
 		return err
 	}
@@ -61,7 +76,7 @@ func (reconcile reconcileWithSourceCommand) run(cmd *cobra.Command, args []strin
 			rootArgs.namespace = nsName.Namespace
 		}
 
-		err := reconcileCmd.run(nil, []string{nsName.Name})
+		err := reconcileCmd.reconcile(ctx, kubeClient, nsName.Name)
 		if err != nil {
 			return err
 		}
@@ -24,8 +24,6 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-
-	"github.com/fluxcd/flux2/internal/utils"
 )
 
 var resumeCmd = &cobra.Command{
@@ -35,7 +33,8 @@ var resumeCmd = &cobra.Command{
 }
 
 type ResumeFlags struct {
-	all bool
+	all           bool
+	labelSelector string
 }
 
 var resumeArgs ResumeFlags
@@ -43,6 +42,8 @@ var resumeArgs ResumeFlags
 func init() {
 	resumeCmd.PersistentFlags().BoolVarP(&resumeArgs.all, "all", "", false,
 		"suspend all resources in that namespace")
+	resumeCmd.PersistentFlags().StringVarP(&resumeArgs.labelSelector, "selector", "l", "",
+		"resume the resources in that namespace matching the label selector, e.g. team=payments")
 	rootCmd.AddCommand(resumeCmd)
 }
 
@@ -65,14 +66,14 @@ type listResumable interface {
 }
 
 func (resume resumeCommand) run(cmd *cobra.Command, args []string) error {
-	if len(args) < 1 && !resumeArgs.all {
+	if len(args) < 1 && !resumeArgs.all && resumeArgs.labelSelector == "" {
 		return fmt.Errorf("%s name is required", resume.humanKind)
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
-	kubeClient, err := utils.KubeClient(rootArgs.kubeconfig, rootArgs.kubecontext)
+	kubeClient, err := newKubeClient()
 	if err != nil {
 		return err
 	}
@@ -85,6 +86,14 @@ func (resume resumeCommand) run(cmd *cobra.Command, args []string) error {
 		})
 	}
 
+	if resumeArgs.labelSelector != "" {
+		opt, err := labelSelectorListOption(resumeArgs.labelSelector)
+		if err != nil {
+			return err
+		}
+		listOpts = append(listOpts, opt)
+	}
+
 	err = kubeClient.List(ctx, resume.list.asClientList(), listOpts...)
 	if err != nil {
 		return err
@@ -0,0 +1,159 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fluxcd/flux2/internal/bootstrap"
+	"github.com/fluxcd/flux2/internal/bootstrap/provider"
+	"github.com/fluxcd/flux2/internal/flags"
+	"github.com/fluxcd/flux2/pkg/manifestgen/sourcesecret"
+	"github.com/fluxcd/flux2/pkg/manifestgen/sync"
+)
+
+var rotateDeployKeyCmd = &cobra.Command{
+	Use:   "deploy-key",
+	Short: "Rotate the SSH deploy key of a bootstrapped repository",
+	Long: `The rotate deploy-key command generates a new SSH key pair and registers its public key
+with the Git provider, next to the current deploy key. It then updates the source secret on the cluster,
+and waits for the GitRepository to reconcile with the new key before deleting the previous deploy key.
+If the GitRepository fails to reconcile, the previous key is restored.`,
+	Example: `  # Rotate the deploy key of a repository bootstrapped with flux bootstrap github
+  export GITHUB_TOKEN=<my-token>
+  flux rotate deploy-key --provider=github --owner=<organization> --repository=<repository name>
+
+  # Rotate the deploy key of a cluster path bootstrapped with flux bootstrap gitlab
+  export GITLAB_TOKEN=<my-token>
+  flux rotate deploy-key --provider=gitlab --owner=<group> --repository=<repository name> --path=dev-cluster`,
+	RunE: rotateDeployKeyCmdRun,
+}
+
+type rotateDeployKeyFlags struct {
+	provider      flags.DeployKeyProvider
+	owner         string
+	repository    string
+	personal      bool
+	hostname      string
+	path          flags.SafeRelativePath
+	branch        string
+	secretName    string
+	readWriteKey  bool
+	keyAlgorithm  flags.PublicKeyAlgorithm
+	keyRSABits    flags.RSAKeyBits
+	keyECDSACurve flags.ECDSACurve
+	sshHostname   string
+}
+
+var rotateDeployKeyArgs = rotateDeployKeyFlags{
+	provider:     flags.DeployKeyProvider(provider.GitProviderGitHub),
+	keyAlgorithm: flags.PublicKeyAlgorithm(sourcesecret.RSAPrivateKeyAlgorithm),
+	keyRSABits:   2048,
+}
+
+func init() {
+	rotateDeployKeyCmd.Flags().Var(&rotateDeployKeyArgs.provider, "provider", rotateDeployKeyArgs.provider.Description())
+	rotateDeployKeyCmd.Flags().StringVar(&rotateDeployKeyArgs.owner, "owner", "", "user, organization or group name of the repository")
+	rotateDeployKeyCmd.Flags().StringVar(&rotateDeployKeyArgs.repository, "repository", "", "repository name")
+	rotateDeployKeyCmd.Flags().BoolVar(&rotateDeployKeyArgs.personal, "personal", false, "if true, the owner is assumed to be a user; otherwise an organization or group")
+	rotateDeployKeyCmd.Flags().StringVar(&rotateDeployKeyArgs.hostname, "hostname", "", "Git provider hostname, defaults to the public hostname of the provider")
+	rotateDeployKeyCmd.Flags().Var(&rotateDeployKeyArgs.path, "path", "path relative to the repository root the cluster was bootstrapped with")
+	rotateDeployKeyCmd.Flags().StringVar(&rotateDeployKeyArgs.branch, "branch", bootstrapDefaultBranch, "Git branch the cluster was bootstrapped with")
+	rotateDeployKeyCmd.Flags().StringVar(&rotateDeployKeyArgs.secretName, "secret-name", rootArgs.defaults.Namespace, "name of the secret the sync credentials are stored in")
+	rotateDeployKeyCmd.Flags().BoolVar(&rotateDeployKeyArgs.readWriteKey, "read-write-key", false, "if true, the deploy key is configured with read/write permissions")
+	rotateDeployKeyCmd.Flags().Var(&rotateDeployKeyArgs.keyAlgorithm, "ssh-key-algorithm", rotateDeployKeyArgs.keyAlgorithm.Description())
+	rotateDeployKeyCmd.Flags().Var(&rotateDeployKeyArgs.keyRSABits, "ssh-rsa-bits", rotateDeployKeyArgs.keyRSABits.Description())
+	rotateDeployKeyCmd.Flags().Var(&rotateDeployKeyArgs.keyECDSACurve, "ssh-ecdsa-curve", rotateDeployKeyArgs.keyECDSACurve.Description())
+	rotateDeployKeyCmd.Flags().StringVar(&rotateDeployKeyArgs.sshHostname, "ssh-hostname", "", "SSH hostname, to be used when the SSH host differs from the HTTPS one")
+
+	rotateCmd.AddCommand(rotateDeployKeyCmd)
+}
+
+func rotateDeployKeyCmdRun(cmd *cobra.Command, args []string) error {
+	if rotateDeployKeyArgs.owner == "" || rotateDeployKeyArgs.repository == "" {
+		return fmt.Errorf("--owner and --repository are required")
+	}
+
+	providerCfg := provider.Config{
+		Provider: provider.GitProvider(rotateDeployKeyArgs.provider),
+		Hostname: rotateDeployKeyArgs.hostname,
+	}
+	tokenEnvVar := ghTokenEnvVar
+	if providerCfg.Provider == provider.GitProviderGitLab {
+		tokenEnvVar = glTokenEnvVar
+	}
+	if providerCfg.Token = os.Getenv(tokenEnvVar); providerCfg.Token == "" {
+		return fmt.Errorf("%s environment variable not found", tokenEnvVar)
+	}
+	if providerCfg.Hostname == "" {
+		providerCfg.Hostname = ghDefaultDomain
+		if providerCfg.Provider == provider.GitProviderGitLab {
+			providerCfg.Hostname = glDefaultDomain
+		}
+	}
+	providerClient, err := provider.BuildGitProvider(providerCfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	kubeClient, err := newKubeClient()
+	if err != nil {
+		return err
+	}
+
+	secretOpts := sourcesecret.Options{
+		Name:                rotateDeployKeyArgs.secretName,
+		Namespace:           rootArgs.namespace,
+		TargetPath:          rotateDeployKeyArgs.path.ToSlash(),
+		ManifestFile:        sourcesecret.MakeDefaultOptions().ManifestFile,
+		PrivateKeyAlgorithm: sourcesecret.PrivateKeyAlgorithm(rotateDeployKeyArgs.keyAlgorithm),
+		RSAKeyBits:          int(rotateDeployKeyArgs.keyRSABits),
+		ECDSACurve:          rotateDeployKeyArgs.keyECDSACurve.Curve,
+		SSHHostname:         providerCfg.Hostname,
+	}
+	if rotateDeployKeyArgs.sshHostname != "" {
+		secretOpts.SSHHostname = rotateDeployKeyArgs.sshHostname
+	}
+
+	syncOpts := sync.Options{
+		Name:      rootArgs.namespace,
+		Namespace: rootArgs.namespace,
+	}
+
+	b, err := bootstrap.NewGitProviderBootstrapper(nil, providerClient, kubeClient,
+		bootstrap.WithProviderRepository(rotateDeployKeyArgs.owner, rotateDeployKeyArgs.repository, rotateDeployKeyArgs.personal),
+		bootstrap.WithBranch(rotateDeployKeyArgs.branch),
+		bootstrap.WithReadWriteKeyPermissions(rotateDeployKeyArgs.readWriteKey),
+		bootstrap.WithLogger(logger),
+	)
+	if err != nil {
+		return err
+	}
+
+	if err = b.RotateDeployKey(ctx, secretOpts, syncOpts, rootArgs.pollInterval, rootArgs.timeout); err != nil {
+		return err
+	}
+	logger.Successf("deploy key rotated")
+	return nil
+}
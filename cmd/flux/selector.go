@@ -0,0 +1,75 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// labelSelectorListOption returns the list option matching
+// the objects with the given label selector, e.g. team=payments.
+func labelSelectorListOption(selector string) (client.ListOption, error) {
+	s, err := labels.Parse(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid label selector '%s': %w", selector, err)
+	}
+	return client.MatchingLabelsSelector{Selector: s}, nil
+}
+
+// objectNames returns the names of the objects a command operates on: the
+// ones of the kind of obj matching the label selector in the namespace if
+// the selector is set, otherwise the name given as first argument.
+func objectNames(ctx context.Context, kubeClient client.Client, obj client.Object, args []string, selector string) ([]string, error) {
+	if selector == "" {
+		if len(args) < 1 {
+			return nil, fmt.Errorf("name is required")
+		}
+		return []string{args[0]}, nil
+	}
+	if len(args) > 0 {
+		return nil, fmt.Errorf("a name can't be used with a label selector")
+	}
+
+	opt, err := labelSelectorListOption(selector)
+	if err != nil {
+		return nil, err
+	}
+	gvk, err := apiutil.GVKForObject(obj, kubeClient.Scheme())
+	if err != nil {
+		return nil, err
+	}
+	// only the metadata is needed to get the names
+	list := &metav1.PartialObjectMetadataList{}
+	list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if err := kubeClient.List(ctx, list, client.InNamespace(rootArgs.namespace), opt); err != nil {
+		return nil, err
+	}
+	if len(list.Items) == 0 {
+		return nil, fmt.Errorf("no %s objects matching '%s' found in %s namespace", gvk.Kind, selector, rootArgs.namespace)
+	}
+	var names []string
+	for _, item := range list.Items {
+		names = append(names, item.Name)
+	}
+	return names, nil
+}
@@ -0,0 +1,204 @@
+// +build unit
+
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+func TestObjectNames(t *testing.T) {
+	kubeClient := useFakeKubeClient(t, "testdata/selector/objects.yaml")
+	namespace := rootArgs.namespace
+	rootArgs.namespace = "flux-system"
+	t.Cleanup(func() {
+		rootArgs.namespace = namespace
+	})
+
+	cases := []struct {
+		name     string
+		args     []string
+		selector string
+		want     []string
+		wantErr  string
+	}{
+		{
+			name: "name",
+			args: []string{"backend"},
+			want: []string{"backend"},
+		},
+		{
+			name:     "selector",
+			selector: "team=payments",
+			want:     []string{"backend", "frontend"},
+		},
+		{
+			name:     "set based selector",
+			selector: "team in (payments,platform)",
+			want:     []string{"backend", "frontend", "monitoring"},
+		},
+		{
+			name:    "no name",
+			wantErr: "name is required",
+		},
+		{
+			name:     "name and selector",
+			args:     []string{"backend"},
+			selector: "team=payments",
+			wantErr:  "a name can't be used with a label selector",
+		},
+		{
+			name:     "no match",
+			selector: "team=search",
+			wantErr:  "no Kustomization objects matching 'team=search' found in flux-system namespace",
+		},
+		{
+			name:     "invalid selector",
+			selector: "team in payments",
+			wantErr:  "invalid label selector 'team in payments': ",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			names, err := objectNames(context.TODO(), kubeClient, &kustomizev1.Kustomization{}, tc.args, tc.selector)
+			if tc.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error starting with '%s', got: %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, names); diff != "" {
+				t.Errorf("Mismatch from expected names (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetSelectorAndSortBy(t *testing.T) {
+	cases := []struct {
+		name     string
+		args     string
+		expected string
+	}{
+		{
+			"selector",
+			"get kustomizations -n flux-system -l team=payments -o name",
+			"kustomization/backend\nkustomization/frontend\n",
+		},
+		{
+			"sort by name",
+			"get kustomizations -A --sort-by name -o name",
+			"kustomization/ads\nkustomization/backend\nkustomization/frontend\nkustomization/monitoring\n",
+		},
+		{
+			"sort by namespace",
+			"get kustomizations -A --sort-by namespace -o 'go-template={{.metadata.namespace}}/{{.metadata.name}}{{\"\\n\"}}'",
+			"apps/ads\nflux-system/backend\nflux-system/frontend\nflux-system/monitoring\n",
+		},
+		{
+			"sort by ready",
+			"get kustomizations -A --sort-by ready -o name",
+			"kustomization/ads\nkustomization/frontend\nkustomization/backend\nkustomization/monitoring\n",
+		},
+		{
+			"sort by last reconcile",
+			"get kustomizations -A --sort-by last-reconcile -o name",
+			"kustomization/ads\nkustomization/frontend\nkustomization/backend\nkustomization/monitoring\n",
+		},
+		{
+			"selector and sort by",
+			"get kustomizations -A -l team=payments --sort-by ready -o name",
+			"kustomization/ads\nkustomization/frontend\nkustomization/backend\n",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			useFakeKubeClient(t, "testdata/selector/objects.yaml")
+			t.Cleanup(resetGetArgs)
+			cmd := cmdTestCase{
+				args:   tc.args,
+				assert: assertGoldenValue(tc.expected),
+			}
+			cmd.runTestCmd(t)
+		})
+	}
+}
+
+func TestGetSortByInvalidKey(t *testing.T) {
+	useFakeKubeClient(t, "testdata/selector/objects.yaml")
+	t.Cleanup(resetGetArgs)
+	cmd := cmdTestCase{
+		args:   "get kustomizations -n flux-system --sort-by age",
+		assert: assertError("unsupported sort key 'age', must be one of: name, namespace, ready, last-reconcile"),
+	}
+	cmd.runTestCmd(t)
+}
+
+func TestGetSortByWatch(t *testing.T) {
+	useFakeKubeClient(t, "testdata/selector/objects.yaml")
+	t.Cleanup(resetGetArgs)
+	cmd := cmdTestCase{
+		args:   "get kustomizations -n flux-system --sort-by name --watch",
+		assert: assertError("--sort-by cannot be used with --watch"),
+	}
+	cmd.runTestCmd(t)
+}
+
+func TestDeleteSelector(t *testing.T) {
+	kubeClient := useFakeKubeClient(t, "testdata/selector/objects.yaml")
+	t.Cleanup(func() {
+		deleteArgs = deleteFlags{}
+	})
+	cmd := cmdTestCase{
+		args: "delete kustomization -n flux-system -l team=payments --silent",
+		assert: assertGoldenValue(`► deleting kustomizations backend in flux-system namespace
+✔ kustomizations deleted
+► deleting kustomizations frontend in flux-system namespace
+✔ kustomizations deleted
+`),
+	}
+	cmd.runTestCmd(t)
+
+	var list kustomizev1.KustomizationList
+	if err := kubeClient.List(context.TODO(), &list); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, item := range list.Items {
+		names = append(names, item.Namespace+"/"+item.Name)
+	}
+	if diff := cmp.Diff([]string{"apps/ads", "flux-system/monitoring"}, names); diff != "" {
+		t.Errorf("Mismatch from expected remaining objects (-want +got):\n%s", diff)
+	}
+}
+
+func TestSuspendSelector(t *testing.T) {
+	kubeClient := useFakeKubeClient(t, "testdata/selector/objects.yaml")
+	t.Cleanup(func() {
+		suspendArgs = SuspendFlags{}
+	})
+	cmd := cmdTestCase{
+		args:   "suspend kustomization -n flux-system -l team=payments",
+		assert: assertSuccess(),
+	}
+	cmd.runTestCmd(t)
+
+	for name, want := range map[string]bool{"backend": true, "frontend": true, "monitoring": false} {
+		var ks kustomizev1.Kustomization
+		if err := kubeClient.Get(context.TODO(), client.ObjectKey{Namespace: "flux-system", Name: name}, &ks); err != nil {
+			t.Fatal(err)
+		}
+		if ks.Spec.Suspend != want {
+			t.Errorf("expected %s suspend to be %v, got %v", name, want, ks.Spec.Suspend)
+		}
+	}
+}
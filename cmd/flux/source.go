@@ -59,6 +59,10 @@ func (a bucketListAdapter) len() int {
 	return len(a.BucketList.Items)
 }
 
+func (a bucketListAdapter) clientObject(i int) client.Object {
+	return &a.BucketList.Items[i]
+}
+
 // sourcev1.HelmChart
 
 var helmChartType = apiType{
@@ -92,6 +96,10 @@ func (a helmChartListAdapter) len() int {
 	return len(a.HelmChartList.Items)
 }
 
+func (a helmChartListAdapter) clientObject(i int) client.Object {
+	return &a.HelmChartList.Items[i]
+}
+
 // sourcev1.GitRepository
 
 var gitRepositoryType = apiType{
@@ -125,6 +133,10 @@ func (a gitRepositoryListAdapter) len() int {
 	return len(a.GitRepositoryList.Items)
 }
 
+func (a gitRepositoryListAdapter) clientObject(i int) client.Object {
+	return &a.GitRepositoryList.Items[i]
+}
+
 // sourcev1.HelmRepository
 
 var helmRepositoryType = apiType{
@@ -157,3 +169,7 @@ func (a helmRepositoryListAdapter) asClientList() client.ObjectList {
 func (a helmRepositoryListAdapter) len() int {
 	return len(a.HelmRepositoryList.Items)
 }
+
+func (a helmRepositoryListAdapter) clientObject(i int) client.Object {
+	return &a.HelmRepositoryList.Items[i]
+}
@@ -22,8 +22,6 @@ import (
 
 	"github.com/spf13/cobra"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-
-	"github.com/fluxcd/flux2/internal/utils"
 )
 
 var suspendCmd = &cobra.Command{
@@ -33,7 +31,8 @@ var suspendCmd = &cobra.Command{
 }
 
 type SuspendFlags struct {
-	all bool
+	all           bool
+	labelSelector string
 }
 
 var suspendArgs SuspendFlags
@@ -41,6 +40,8 @@ var suspendArgs SuspendFlags
 func init() {
 	suspendCmd.PersistentFlags().BoolVarP(&suspendArgs.all, "all", "", false,
 		"suspend all resources in that namespace")
+	suspendCmd.PersistentFlags().StringVarP(&suspendArgs.labelSelector, "selector", "l", "",
+		"suspend the resources in that namespace matching the label selector, e.g. team=payments")
 	rootCmd.AddCommand(suspendCmd)
 }
 
@@ -62,14 +63,14 @@ type listSuspendable interface {
 }
 
 func (suspend suspendCommand) run(cmd *cobra.Command, args []string) error {
-	if len(args) < 1 && !suspendArgs.all {
+	if len(args) < 1 && !suspendArgs.all && suspendArgs.labelSelector == "" {
 		return fmt.Errorf("%s name is required", suspend.humanKind)
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
-	kubeClient, err := utils.KubeClient(rootArgs.kubeconfig, rootArgs.kubecontext)
+	kubeClient, err := newKubeClient()
 	if err != nil {
 		return err
 	}
@@ -82,6 +83,14 @@ func (suspend suspendCommand) run(cmd *cobra.Command, args []string) error {
 		})
 	}
 
+	if suspendArgs.labelSelector != "" {
+		opt, err := labelSelectorListOption(suspendArgs.labelSelector)
+		if err != nil {
+			return err
+		}
+		listOpts = append(listOpts, opt)
+	}
+
 	err = kubeClient.List(ctx, suspend.list.asClientList(), listOpts...)
 	if err != nil {
 		return err
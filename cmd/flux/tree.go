@@ -0,0 +1,225 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	helmv2 "github.com/fluxcd/helm-controller/api/v2beta1"
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+var treeCmd = &cobra.Command{
+	Use:   "tree",
+	Short: "Print the resources managed by a Flux object",
+	Long: `The tree sub-commands print a hierarchical tree of the Kubernetes objects
+managed by a Kustomization or HelmRelease, expanding nested Kustomizations and HelmReleases.`,
+}
+
+type treeFlags struct {
+	maxDepth int
+	output   string
+}
+
+var treeArgs treeFlags
+
+func init() {
+	treeCmd.PersistentFlags().IntVar(&treeArgs.maxDepth, "max-depth", 0,
+		"the maximum depth of the tree, 0 means unlimited")
+	treeCmd.PersistentFlags().StringVarP(&treeArgs.output, "output", "o", "",
+		"the format in which the tree should be printed, can be 'json'")
+	rootCmd.AddCommand(treeCmd)
+}
+
+// treeNode is a Kubernetes object together with the objects it manages.
+type treeNode struct {
+	Kind       string      `json:"kind"`
+	APIVersion string      `json:"apiVersion,omitempty"`
+	Namespace  string      `json:"namespace,omitempty"`
+	Name       string      `json:"name"`
+	Children   []*treeNode `json:"children,omitempty"`
+}
+
+func (n *treeNode) String() string {
+	if n.Namespace == "" {
+		return fmt.Sprintf("%s/%s", n.Kind, n.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", n.Kind, n.Namespace, n.Name)
+}
+
+// treeBuilder expands Flux objects into their managed objects,
+// recursing into nested Kustomizations and HelmReleases.
+type treeBuilder struct {
+	kubeClient client.Client
+	mapper     meta.RESTMapper
+	maxDepth   int
+	visited    map[string]bool
+}
+
+func newTreeBuilder() (*treeBuilder, error) {
+	kubeClient, err := newKubeClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return &treeBuilder{
+		kubeClient: kubeClient,
+		mapper:     kubeClient.RESTMapper(),
+		maxDepth:   treeArgs.maxDepth,
+		visited:    make(map[string]bool),
+	}, nil
+}
+
+// expand populates the children of the given node if it is a Kustomization
+// or HelmRelease and the maximum depth has not been reached.
+func (b *treeBuilder) expand(ctx context.Context, node *treeNode, depth int) error {
+	if b.maxDepth > 0 && depth >= b.maxDepth {
+		return nil
+	}
+
+	gv, err := schema.ParseGroupVersion(node.APIVersion)
+	if err != nil {
+		return err
+	}
+
+	kustomization := gv.Group == kustomizev1.GroupVersion.Group && node.Kind == kustomizev1.KustomizationKind
+	helmRelease := gv.Group == helmv2.GroupVersion.Group && node.Kind == helmv2.HelmReleaseKind
+	if !kustomization && !helmRelease {
+		return nil
+	}
+
+	// guard against objects managing each other
+	key := node.String()
+	if b.visited[key] {
+		return nil
+	}
+	b.visited[key] = true
+
+	var children []*treeNode
+	if kustomization {
+		children, err = b.kustomizationChildren(ctx, node)
+	} else {
+		children, err = b.helmReleaseChildren(ctx, node)
+	}
+	if err != nil {
+		// nested objects may be rendered without having been created yet
+		if depth > 0 && apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	sortTreeNodes(children)
+	for _, child := range children {
+		if err := b.expand(ctx, child, depth+1); err != nil {
+			return err
+		}
+	}
+	node.Children = children
+	return nil
+}
+
+// namespaced reports whether the given kind is namespace scoped,
+// defaulting to true when the kind is unknown to the API server.
+func (b *treeBuilder) namespaced(gvk schema.GroupVersionKind) bool {
+	mapping, err := b.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return true
+	}
+	return mapping.Scope.Name() == meta.RESTScopeNameNamespace
+}
+
+func sortTreeNodes(nodes []*treeNode) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].Kind != nodes[j].Kind {
+			return nodes[i].Kind < nodes[j].Kind
+		}
+		if nodes[i].Namespace != nodes[j].Namespace {
+			return nodes[i].Namespace < nodes[j].Namespace
+		}
+		return nodes[i].Name < nodes[j].Name
+	})
+}
+
+func printManagedTree(ctx context.Context, w io.Writer, root *treeNode) error {
+	switch treeArgs.output {
+	case "":
+	case "json":
+	default:
+		return fmt.Errorf("unsupported output format '%s', can be 'json'", treeArgs.output)
+	}
+	if treeArgs.maxDepth < 0 {
+		return fmt.Errorf("max depth must be greater than or equal to 0")
+	}
+
+	builder, err := newTreeBuilder()
+	if err != nil {
+		return err
+	}
+	if err := builder.expand(ctx, root, 0); err != nil {
+		return err
+	}
+
+	if treeArgs.output == "json" {
+		data, err := json.MarshalIndent(root, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(data))
+		return nil
+	}
+
+	printTree(w, root)
+	return nil
+}
+
+// printTree writes the node and its descendants using box-drawing connectors.
+func printTree(w io.Writer, root *treeNode) {
+	fmt.Fprintln(w, root.String())
+	printTreeChildren(w, root.Children, "")
+}
+
+func printTreeChildren(w io.Writer, nodes []*treeNode, prefix string) {
+	for i, node := range nodes {
+		connector, indent := "├── ", "│   "
+		if i == len(nodes)-1 {
+			connector, indent = "└── ", "    "
+		}
+		fmt.Fprintf(w, "%s%s%s\n", prefix, connector, node.String())
+		printTreeChildren(w, node.Children, prefix+indent)
+	}
+}
+
+// treeNodeFromGVK returns a leaf node for an object of the given kind.
+func treeNodeFromGVK(gvk schema.GroupVersionKind, namespace, name string) *treeNode {
+	return &treeNode{
+		Kind:       gvk.Kind,
+		APIVersion: gvk.GroupVersion().String(),
+		Namespace:  namespace,
+		Name:       name,
+	}
+}
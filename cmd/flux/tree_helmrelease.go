@@ -0,0 +1,161 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	helmv2 "github.com/fluxcd/helm-controller/api/v2beta1"
+
+	"github.com/fluxcd/flux2/internal/ssa"
+)
+
+var treeHrCmd = &cobra.Command{
+	Use:     "helmrelease [name]",
+	Aliases: []string{"hr", "helmreleases"},
+	Short:   "Print the resources managed by a HelmRelease",
+	Long: `The tree helmrelease command prints a hierarchical tree of the objects installed by a HelmRelease,
+as recorded in the Helm storage of the latest deployed release.
+Nested Kustomizations and HelmReleases are expanded recursively.`,
+	Example: `  # Print the resources managed by a HelmRelease
+  flux tree helmrelease podinfo -n apps
+
+  # Print the resources managed by a HelmRelease in JSON format
+  flux tree helmrelease podinfo -n apps -o json`,
+	ValidArgsFunction: resourceNamesCompletionFunc(helmv2.GroupVersion.WithKind(helmv2.HelmReleaseKind)),
+	RunE:              treeHrCmdRun,
+}
+
+func init() {
+	treeCmd.AddCommand(treeHrCmd)
+}
+
+func treeHrCmdRun(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("HelmRelease name is required")
+	}
+	name := args[0]
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	root := treeNodeFromGVK(helmv2.GroupVersion.WithKind(helmv2.HelmReleaseKind), rootArgs.namespace, name)
+	return printManagedTree(ctx, cmd.OutOrStdout(), root)
+}
+
+// helmReleaseChildren returns the objects rendered in the manifest
+// of the latest deployed Helm release of the HelmRelease.
+func (b *treeBuilder) helmReleaseChildren(ctx context.Context, node *treeNode) ([]*treeNode, error) {
+	var hr helmv2.HelmRelease
+	if err := b.kubeClient.Get(ctx, client.ObjectKey{Namespace: node.Namespace, Name: node.Name}, &hr); err != nil {
+		return nil, err
+	}
+
+	manifest, err := b.helmReleaseManifest(ctx, hr.GetStorageNamespace(), hr.GetReleaseName())
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := ssa.ReadObjects(strings.NewReader(manifest))
+	if err != nil {
+		return nil, fmt.Errorf("parsing manifest of release '%s' failed: %w", hr.GetReleaseName(), err)
+	}
+
+	var children []*treeNode
+	for _, obj := range objects {
+		gvk := obj.GroupVersionKind()
+		namespace := obj.GetNamespace()
+		if namespace == "" && b.namespaced(gvk) {
+			namespace = hr.GetReleaseNamespace()
+		}
+		children = append(children, treeNodeFromGVK(gvk, namespace, obj.GetName()))
+	}
+	return children, nil
+}
+
+// helmReleaseManifest reads the manifest of the latest deployed release
+// from the Helm secrets storage. It returns an empty manifest if the
+// release has not been deployed.
+func (b *treeBuilder) helmReleaseManifest(ctx context.Context, namespace, name string) (string, error) {
+	var secrets corev1.SecretList
+	if err := b.kubeClient.List(ctx, &secrets, client.InNamespace(namespace), client.MatchingLabels{
+		"owner":  "helm",
+		"name":   name,
+		"status": "deployed",
+	}); err != nil {
+		return "", fmt.Errorf("listing Helm storage secrets failed: %w", err)
+	}
+
+	var latest *corev1.Secret
+	latestVersion := -1
+	for i, secret := range secrets.Items {
+		version, err := strconv.Atoi(secret.GetLabels()["version"])
+		if err != nil {
+			continue
+		}
+		if version > latestVersion {
+			latest, latestVersion = &secrets.Items[i], version
+		}
+	}
+	if latest == nil {
+		return "", nil
+	}
+
+	data, err := decodeHelmRelease(latest.Data["release"])
+	if err != nil {
+		return "", fmt.Errorf("decoding Helm release '%s' failed: %w", latest.GetName(), err)
+	}
+
+	var release struct {
+		Manifest string `json:"manifest"`
+	}
+	if err := json.Unmarshal(data, &release); err != nil {
+		return "", fmt.Errorf("decoding Helm release '%s' failed: %w", latest.GetName(), err)
+	}
+	return release.Manifest, nil
+}
+
+// decodeHelmRelease reverses the Helm storage encoding, which is
+// base64 encoded and, for recent Helm versions, gzip compressed JSON.
+func decodeHelmRelease(data []byte) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) > 3 && bytes.Equal(b[0:3], []byte{0x1f, 0x8b, 0x08}) {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	}
+	return b, nil
+}
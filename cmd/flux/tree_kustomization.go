@@ -0,0 +1,110 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+var treeKsCmd = &cobra.Command{
+	Use:     "kustomization [name]",
+	Aliases: []string{"ks", "kustomizations"},
+	Short:   "Print the resources managed by a Kustomization",
+	Long: `The tree kustomization command prints a hierarchical tree of the objects applied by a Kustomization.
+Nested Kustomizations and HelmReleases are expanded recursively.`,
+	Example: `  # Print the resources managed by the root Kustomization
+  flux tree kustomization flux-system
+
+  # Print the resources managed by a Kustomization up to the second level
+  flux tree kustomization my-app --max-depth=2
+
+  # Print the resources managed by a Kustomization in JSON format
+  flux tree kustomization my-app -o json`,
+	ValidArgsFunction: resourceNamesCompletionFunc(kustomizev1.GroupVersion.WithKind(kustomizev1.KustomizationKind)),
+	RunE:              treeKsCmdRun,
+}
+
+func init() {
+	treeCmd.AddCommand(treeKsCmd)
+}
+
+func treeKsCmdRun(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("Kustomization name is required")
+	}
+	name := args[0]
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	root := treeNodeFromGVK(kustomizev1.GroupVersion.WithKind(kustomizev1.KustomizationKind), rootArgs.namespace, name)
+	return printManagedTree(ctx, cmd.OutOrStdout(), root)
+}
+
+// kustomizationChildren returns the objects applied by the Kustomization,
+// looking up the kinds recorded in its snapshot and selecting the objects
+// by the labels set by kustomize-controller.
+func (b *treeBuilder) kustomizationChildren(ctx context.Context, node *treeNode) ([]*treeNode, error) {
+	var kustomization kustomizev1.Kustomization
+	if err := b.kubeClient.Get(ctx, client.ObjectKey{Namespace: node.Namespace, Name: node.Name}, &kustomization); err != nil {
+		return nil, err
+	}
+	if kustomization.Status.Snapshot == nil {
+		return nil, nil
+	}
+
+	selector := client.MatchingLabels{
+		fmt.Sprintf("%s/name", kustomizev1.GroupVersion.Group):      kustomization.GetName(),
+		fmt.Sprintf("%s/namespace", kustomizev1.GroupVersion.Group): kustomization.GetNamespace(),
+	}
+
+	var children []*treeNode
+	for _, entry := range kustomization.Status.Snapshot.Entries {
+		for key, kind := range entry.Kinds {
+			// the snapshot keys are in the schema.GroupVersionKind string format
+			gv, err := schema.ParseGroupVersion(strings.Split(key, ",")[0])
+			if err != nil {
+				continue
+			}
+			gvk := gv.WithKind(kind)
+
+			list := &metav1.PartialObjectMetadataList{}
+			list.SetGroupVersionKind(gv.WithKind(kind + "List"))
+			opts := []client.ListOption{selector}
+			if entry.Namespace != "" {
+				opts = append(opts, client.InNamespace(entry.Namespace))
+			}
+			if err := b.kubeClient.List(ctx, list, opts...); err != nil {
+				return nil, fmt.Errorf("listing %s objects failed: %w", gvk.Kind, err)
+			}
+
+			for _, item := range list.Items {
+				children = append(children, treeNodeFromGVK(gvk, item.GetNamespace(), item.GetName()))
+			}
+		}
+	}
+	return children, nil
+}
@@ -0,0 +1,64 @@
+// +build unit
+
+package main
+
+import (
+	"testing"
+)
+
+func TestTree(t *testing.T) {
+	cases := []struct {
+		name   string
+		args   string
+		assert assertFunc
+	}{
+		{
+			"kustomization",
+			"tree kustomization flux-system -n flux-system",
+			assertGoldenFile("testdata/tree/kustomization.golden"),
+		},
+		{
+			"kustomization max depth",
+			"tree kustomization flux-system -n flux-system --max-depth 1",
+			assertGoldenFile("testdata/tree/kustomization_max_depth.golden"),
+		},
+		{
+			"helmrelease",
+			"tree helmrelease redis -n apps",
+			assertGoldenFile("testdata/tree/helmrelease.golden"),
+		},
+		{
+			"helmrelease json",
+			"tree helmrelease redis -n apps -o json",
+			assertGoldenFile("testdata/tree/helmrelease_json.golden"),
+		},
+		{
+			"not found",
+			"tree kustomization podinfo -n flux-system",
+			assertError(`kustomizations.kustomize.toolkit.fluxcd.io "podinfo" not found`),
+		},
+		{
+			"invalid max depth",
+			"tree kustomization flux-system -n flux-system --max-depth -1",
+			assertError("max depth must be greater than or equal to 0"),
+		},
+		{
+			"invalid output",
+			"tree kustomization flux-system -n flux-system -o yaml",
+			assertError("unsupported output format 'yaml', can be 'json'"),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			useFakeKubeClient(t, "testdata/tree/objects.yaml")
+			t.Cleanup(func() {
+				treeArgs = treeFlags{}
+			})
+			cmd := cmdTestCase{
+				args:   tc.args,
+				assert: tc.assert,
+			}
+			cmd.runTestCmd(t)
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
 
 	"github.com/fluxcd/flux2/internal/utils"
 	"github.com/fluxcd/flux2/pkg/manifestgen/install"
@@ -16,14 +18,20 @@ func getVersion(input string) (string, error) {
 		return input, nil
 	}
 
-	var err error
+	releases, err := releaseSource()
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
 	if input == install.MakeDefaultOptions().Version {
-		input, err = install.GetLatestVersion()
+		input, err = releases.LatestVersion(ctx)
 		if err != nil {
 			return "", err
 		}
 	} else {
-		if ok, err := install.ExistingVersion(input); err != nil || !ok {
+		if ok, err := releases.VersionExists(ctx, input); err != nil || !ok {
 			if err == nil {
 				err = fmt.Errorf("targeted version '%s' does not exist", input)
 			}
@@ -40,3 +48,9 @@ func getVersion(input string) (string, error) {
 func isEmbeddedVersion(input string) bool {
 	return input == rootArgs.defaults.Version
 }
+
+// releaseSource returns the release discovery backend for --releases-url,
+// the GitHub API calls are authenticated with the GITHUB_TOKEN if set.
+func releaseSource() (install.ReleaseSource, error) {
+	return install.NewReleaseSource(rootArgs.releasesURL, os.Getenv(ghTokenEnvVar))
+}
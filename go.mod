@@ -4,6 +4,7 @@ go 1.16
 
 require (
 	github.com/Masterminds/semver/v3 v3.1.0
+	github.com/ProtonMail/go-crypto v0.0.0-20210428141323-04723f9f07d7
 	github.com/cyphar/filepath-securejoin v0.2.2
 	github.com/fluxcd/go-git-providers v0.1.1
 	github.com/fluxcd/helm-controller/api v0.11.2
@@ -20,11 +21,14 @@ require (
 	github.com/go-git/go-git/v5 v5.4.2
 	github.com/google/go-cmp v0.5.5
 	github.com/google/go-containerregistry v0.2.0
+	github.com/google/go-github/v32 v32.1.0
 	github.com/manifoldco/promptui v0.7.0
 	github.com/mattn/go-shellwords v1.0.12
 	github.com/olekukonko/tablewriter v0.0.4
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/spf13/cobra v1.1.3
 	github.com/spf13/pflag v1.0.5
+	github.com/xanzy/go-gitlab v0.43.0
 	golang.org/x/crypto v0.0.0-20210421170649-83a5a9bb288b
 	k8s.io/api v0.21.3
 	k8s.io/apiextensions-apiserver v0.21.3
@@ -32,6 +32,7 @@ import (
 
 	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
 	"github.com/fluxcd/pkg/apis/meta"
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta1"
 
 	"github.com/fluxcd/flux2/pkg/manifestgen/install"
 	"github.com/fluxcd/flux2/pkg/manifestgen/sourcesecret"
@@ -40,6 +41,11 @@ import (
 
 var (
 	ErrReconciledWithWarning = errors.New("reconciled with warning")
+
+	// ErrPullRequestPending is returned by a Reconciler when the
+	// changes have been proposed through a pull request which has not
+	// been merged yet, in which case the cluster is left untouched.
+	ErrPullRequestPending = errors.New("pull request pending")
 )
 
 type Reconciler interface {
@@ -74,6 +80,13 @@ type RepositoryReconciler interface {
 	ReconcileRepository(ctx context.Context) error
 }
 
+type RollbackReconciler interface {
+	// Rollback reverts the changes committed to Git by the Reconciler,
+	// and reports about the health of the Kustomization synchronizing
+	// the reverted revision.
+	Rollback(ctx context.Context, options sync.Options, pollInterval, timeout time.Duration) error
+}
+
 type PostGenerateSecretFunc func(ctx context.Context, secret corev1.Secret, options sourcesecret.Options) error
 
 func Run(ctx context.Context, reconciler Reconciler, manifestsBase string,
@@ -94,6 +107,9 @@ func Run(ctx context.Context, reconciler Reconciler, manifestsBase string,
 		return err
 	}
 	if err := reconciler.ReconcileSyncConfig(ctx, syncOpts); err != nil {
+		if errors.Is(err, ErrPullRequestPending) {
+			return nil
+		}
 		return err
 	}
 
@@ -109,6 +125,12 @@ func Run(ctx context.Context, reconciler Reconciler, manifestsBase string,
 		// errors does not result in any useful information for the
 		// user, as both methods log the failures they run into.
 		err = fmt.Errorf("bootstrap failed with %d health check failure(s)", healthErrCount)
+
+		if r, ok := reconciler.(RollbackReconciler); ok {
+			if rollbackErr := r.Rollback(ctx, syncOpts, pollInterval, timeout); rollbackErr != nil {
+				err = fmt.Errorf("%w, and rollback failed: %s", err, rollbackErr.Error())
+			}
+		}
 	}
 
 	return err
@@ -203,6 +225,42 @@ func kustomizationReconciled(ctx context.Context, kube client.Client, objKey cli
 	}
 }
 
+func gitRepositoryReconciled(ctx context.Context, kube client.Client, objKey client.ObjectKey,
+	repository *sourcev1.GitRepository, requestedAt string) func() (bool, error) {
+
+	return func() (bool, error) {
+		if err := kube.Get(ctx, objKey, repository); err != nil {
+			return false, err
+		}
+
+		// Detect suspended GitRepository, as this would result in an endless wait
+		if repository.Spec.Suspend {
+			return false, fmt.Errorf("GitRepository is suspended")
+		}
+
+		// Confirm the state we are observing is for the current generation
+		if repository.Generation != repository.Status.ObservedGeneration {
+			return false, nil
+		}
+
+		// Confirm the requested reconciliation has been handled by the controller
+		if repository.Status.LastHandledReconcileAt != requestedAt {
+			return false, nil
+		}
+
+		// Confirm the resource is healthy
+		if c := apimeta.FindStatusCondition(repository.Status.Conditions, meta.ReadyCondition); c != nil {
+			switch c.Status {
+			case metav1.ConditionTrue:
+				return true, nil
+			case metav1.ConditionFalse:
+				return false, fmt.Errorf(c.Message)
+			}
+		}
+		return false, nil
+	}
+}
+
 func retry(retries int, wait time.Duration, fn func() error) (err error) {
 	for i := 0; ; i++ {
 		err = fn()
@@ -0,0 +1,80 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fluxcd/go-git-providers/gitprovider"
+
+	"github.com/fluxcd/flux2/pkg/manifestgen/commitstatus"
+	"github.com/fluxcd/flux2/pkg/manifestgen/sync"
+)
+
+func WithCommitStatus(token string) GitProviderOption {
+	return commitStatusOption(token)
+}
+
+type commitStatusOption string
+
+func (o commitStatusOption) applyGitProvider(b *GitProviderBootstrapper) {
+	b.commitStatusToken = string(o)
+}
+
+// prepareCommitStatus generates the notification Provider and Alert
+// manifest to be written next to the sync manifests, and returns the
+// secret holding the token of the Provider, to be applied to the cluster
+// with the sync manifests. The token is not committed to Git.
+func (b *GitProviderBootstrapper) prepareCommitStatus(repo gitprovider.UserRepository, options sync.Options) (tokenSecret, error) {
+	notificationType, err := b.notificationType()
+	if err != nil {
+		return tokenSecret{}, err
+	}
+	address, err := b.getCloneURL(repo, gitprovider.TransportTypeHTTPS)
+	if err != nil {
+		return tokenSecret{}, err
+	}
+
+	opts := commitstatus.MakeDefaultOptions()
+	opts.Name = options.Name
+	opts.Namespace = options.Namespace
+	opts.Type = notificationType
+	opts.Address = strings.TrimSuffix(address, ".git")
+	opts.Secret = options.Name + "-commit-status"
+	opts.KustomizationName = options.Name
+	opts.TargetPath = options.TargetPath
+	manifest, err := commitstatus.Generate(opts)
+	if err != nil {
+		return tokenSecret{}, fmt.Errorf("commit status manifest generation failed: %w", err)
+	}
+	b.syncExtraManifests = append(b.syncExtraManifests, manifest)
+
+	return tokenSecret{
+		description: "commit status token secret",
+		secret: corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      opts.Secret,
+				Namespace: opts.Namespace,
+			},
+			StringData: map[string]string{"token": b.commitStatusToken},
+		},
+	}, nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/fluxcd/go-git-providers/github"
+	corev1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluxcd/flux2/internal/utils"
+	"github.com/fluxcd/flux2/pkg/manifestgen/sync"
+)
+
+func TestGitProviderBootstrapper_prepareCommitStatus(t *testing.T) {
+	provider, err := github.NewClient(github.WithOAuth2Token("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kube := fake.NewClientBuilder().WithScheme(utils.NewScheme()).Build()
+	b := &GitProviderBootstrapper{
+		PlainGitBootstrapper: &PlainGitBootstrapper{kube: kube, logger: nopLogger{}},
+		owner:                "org",
+		repository:           "repo",
+		commitStatusToken:    "token",
+		provider:             provider,
+	}
+
+	options := sync.MakeDefaultOptions()
+	options.TargetPath = "clusters/production"
+	secret, err := b.prepareCommitStatus(nil, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(b.syncExtraManifests) != 1 {
+		t.Fatalf("expected 1 extra sync manifest, got %d", len(b.syncExtraManifests))
+	}
+	m := b.syncExtraManifests[0]
+	if want := "clusters/production/flux-system/gotk-commit-status.yaml"; m.Path != want {
+		t.Errorf("Path = %q, want %q", m.Path, want)
+	}
+	for _, s := range []string{"type: github", "address: https://github.com/org/repo\n", "name: flux-system-commit-status"} {
+		if !strings.Contains(m.Content, s) {
+			t.Errorf("%q not found in:\n%s", s, m.Content)
+		}
+	}
+
+	// The secret is only applied with the sync manifests
+	secretKey := client.ObjectKey{Name: "flux-system-commit-status", Namespace: "flux-system"}
+	if err := kube.Get(context.TODO(), secretKey, &corev1.Secret{}); !apierr.IsNotFound(err) {
+		t.Fatalf("expected the secret not to be applied, got: %v", err)
+	}
+	if token := secret.secret.StringData["token"]; token != "token" {
+		t.Errorf("secret token = %q, want %q", token, "token")
+	}
+}
+
+func TestGitProviderBootstrapper_applyTokenSecrets(t *testing.T) {
+	kube := fake.NewClientBuilder().WithScheme(utils.NewScheme()).Build()
+	b := &GitProviderBootstrapper{
+		PlainGitBootstrapper: &PlainGitBootstrapper{kube: kube, logger: nopLogger{}, plan: &Plan{}},
+	}
+	secrets := []tokenSecret{{
+		description: "commit status token secret",
+		secret: corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "flux-system-commit-status", Namespace: "flux-system"},
+			StringData: map[string]string{"token": "token"},
+		},
+	}}
+
+	// Planned but not applied in dry-run mode
+	b.dryRun = true
+	if err := b.applyTokenSecrets(context.TODO(), secrets); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.plan.Changes) != 1 || b.plan.Changes[0].Action != PlanActionCreate {
+		t.Fatalf("expected the secret creation to be planned, got %v", b.plan.Changes)
+	}
+	secretKey := client.ObjectKey{Name: "flux-system-commit-status", Namespace: "flux-system"}
+	if err := kube.Get(context.TODO(), secretKey, &corev1.Secret{}); !apierr.IsNotFound(err) {
+		t.Fatalf("expected the secret not to be applied, got: %v", err)
+	}
+
+	b.dryRun = false
+	if err := b.applyTokenSecrets(context.TODO(), secrets); err != nil {
+		t.Fatal(err)
+	}
+	var secret corev1.Secret
+	if err := kube.Get(context.TODO(), secretKey, &secret); err != nil {
+		t.Fatal(err)
+	}
+	if token := secret.StringData["token"]; token != "token" {
+		t.Errorf("secret token = %q, want %q", token, "token")
+	}
+}
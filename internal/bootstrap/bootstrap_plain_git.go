@@ -22,22 +22,28 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	gosync "sync"
 	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/cli-utils/pkg/object"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/kustomize/api/filesys"
 	"sigs.k8s.io/kustomize/api/konfig"
+	kustypes "sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/yaml"
 
 	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
 
 	"github.com/fluxcd/flux2/internal/bootstrap/git"
+	"github.com/fluxcd/flux2/internal/ssa"
 	"github.com/fluxcd/flux2/internal/utils"
 	"github.com/fluxcd/flux2/pkg/log"
+	"github.com/fluxcd/flux2/pkg/manifestgen"
 	"github.com/fluxcd/flux2/pkg/manifestgen/install"
 	"github.com/fluxcd/flux2/pkg/manifestgen/kustomization"
 	"github.com/fluxcd/flux2/pkg/manifestgen/sourcesecret"
@@ -45,6 +51,11 @@ import (
 	"github.com/fluxcd/flux2/pkg/status"
 )
 
+const (
+	// ssaTimeout is the time to wait for the applied CRDs to be established.
+	ssaTimeout = time.Minute
+)
+
 type PlainGitBootstrapper struct {
 	url      string
 	branch   string
@@ -53,11 +64,49 @@ type PlainGitBootstrapper struct {
 	author                git.Author
 	commitMessageAppendix string
 
+	gpgKeyRingPath string
+	gpgPassphrase  string
+	gpgKeyID       string
+	signingKey     *openpgp.Entity
+
 	kubeconfig  string
 	kubecontext string
 
 	postGenerateSecret []PostGenerateSecretFunc
 
+	dryRun bool
+	plan   *Plan
+
+	// singleCommit configures the component and sync manifests to be
+	// committed and pushed at once by ReconcileSyncConfig, in which case
+	// components holds the generated component manifests, and afterPush
+	// the changes to the cluster applied once the commit has been pushed.
+	singleCommit      bool
+	components        *manifestgen.Manifest
+	componentsVersion string
+	afterPush         []func(ctx context.Context) error
+
+	rollbackOnFailure bool
+	// originals holds the content of the written manifests before they
+	// were first written by path, or nil if the file did not exist.
+	originals map[string]*string
+	pushed    bool
+
+	// syncExtraManifests are written next to the sync manifests, and
+	// included in the kustomization.yaml synchronizing them.
+	syncExtraManifests []*manifestgen.Manifest
+
+	// componentPatches are the install patches, written to the
+	// kustomization.yaml synchronizing the components instead of
+	// being applied to the component manifests. When not nil, they
+	// replace the patches written by a previous bootstrap, an empty
+	// list removing them all.
+	componentPatches []kustypes.Patch
+
+	// gitLock is held while writing to the Git repository, if set by
+	// the Fleet sharing the repository with other bootstrappers.
+	gitLock gosync.Locker
+
 	git    git.Git
 	kube   client.Client
 	logger log.Logger
@@ -108,251 +157,529 @@ func NewPlainGitProvider(git git.Git, kube client.Client, opts ...GitOption) (*P
 	return b, nil
 }
 
+// Plan returns the changes recorded in dry-run mode, or nil if not
+// configured to run in dry-run mode.
+func (b *PlainGitBootstrapper) Plan() *Plan {
+	return b.plan
+}
+
 func (b *PlainGitBootstrapper) ReconcileComponents(ctx context.Context, manifestsBase string, options install.Options, secretOpts sourcesecret.Options) error {
+	objects, err := b.commitComponents(ctx, manifestsBase, options)
+	if err != nil {
+		return err
+	}
+
+	err = b.applyAfterPush(ctx, func(ctx context.Context) error {
+		// Conditionally install manifests
+		if mustInstallManifests(ctx, b.kube, options.Namespace) {
+			return b.installComponents(ctx, objects, options.Namespace)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	b.logger.Successf("reconciled components")
+	return nil
+}
+
+// commitComponents writes the component manifests to the Git repository,
+// commits and pushes them, and returns the objects to install.
+func (b *PlainGitBootstrapper) commitComponents(ctx context.Context, manifestsBase string, options install.Options) ([]*unstructured.Unstructured, error) {
+	defer b.lockGit()()
+
 	// Clone if not already
-	if _, err := b.git.Status(); err != nil {
-		if err != git.ErrNoGitRepository {
+	if err := b.cloneBranch(ctx); err != nil {
+		return nil, err
+	}
+
+	// Generate component manifests and write to Git repository
+	manifests, err := b.writeComponents(manifestsBase, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// Git commit generated, unless committed together with the sync
+	// manifests
+	if b.singleCommit {
+		b.components = manifests
+		b.componentsVersion = options.Version
+	} else {
+		committed, err := b.commitManifests("component manifests", fmt.Sprintf("Add Flux %s component manifests", options.Version), b.branch)
+		if err != nil {
+			return nil, err
+		}
+		if committed {
+			b.logger.Actionf("pushing component manifests to %q", b.url)
+			if err = b.git.Push(ctx, b.caBundle); err != nil {
+				return nil, fmt.Errorf("failed to push manifests: %w", err)
+			}
+			b.pushed = true
+		}
+	}
+
+	return b.componentObjects(manifests.Path)
+}
+
+func (b *PlainGitBootstrapper) ReconcileSourceSecret(ctx context.Context, options sourcesecret.Options) error {
+	secret, err := b.generateSourceSecret(ctx, options)
+	if err != nil || secret == nil {
+		return err
+	}
+	return b.applyAfterPush(ctx, func(ctx context.Context) error {
+		if err := b.postGenerateSourceSecret(ctx, *secret, options); err != nil {
 			return err
 		}
+		return b.applySourceSecret(ctx, *secret)
+	})
+}
+
+func (b *PlainGitBootstrapper) ReconcileSyncConfig(ctx context.Context, options sync.Options) error {
+	// Confirm that sync configuration does not overwrite existing config
+	if err := b.confirmSyncPath(ctx, options); err != nil {
+		return err
+	}
+
+	objects, err := b.commitSyncConfig(ctx, options)
+	if err != nil {
+		return err
+	}
 
-		b.logger.Actionf("cloning branch %q from Git repository %q", b.branch, b.url)
-		var cloned bool
-		if err = retry(1, 2*time.Second, func() (err error) {
-			cloned, err = b.git.Clone(ctx, b.url, b.branch, b.caBundle)
-			return
-		}); err != nil {
-			return fmt.Errorf("failed to clone repository: %w", err)
+	// Apply the changes held back until the single commit was pushed
+	for _, apply := range b.afterPush {
+		if err := apply(ctx); err != nil {
+			return err
 		}
-		if cloned {
-			b.logger.Successf("cloned repository")
+	}
+	b.afterPush = nil
+
+	// Apply to cluster
+	return b.applySyncConfig(ctx, objects)
+}
+
+// applyAfterPush applies the given changes to the cluster right away, or
+// holds them back until ReconcileSyncConfig has pushed the single commit
+// of the component and sync manifests, so that the cluster is left
+// untouched when the push fails.
+func (b *PlainGitBootstrapper) applyAfterPush(ctx context.Context, apply func(ctx context.Context) error) error {
+	if b.singleCommit {
+		b.afterPush = append(b.afterPush, apply)
+		return nil
+	}
+	return apply(ctx)
+}
+
+// commitSyncConfig writes the sync manifests to the Git repository,
+// commits and pushes them, and returns the objects to apply.
+func (b *PlainGitBootstrapper) commitSyncConfig(ctx context.Context, options sync.Options) ([]*unstructured.Unstructured, error) {
+	defer b.lockGit()()
+
+	// Clone if not already
+	if err := b.cloneBranch(ctx); err != nil {
+		return nil, err
+	}
+
+	// Generate sync manifests and write to Git repository
+	_, kusManifests, err := b.writeSyncConfig(options)
+	if err != nil {
+		return nil, err
+	}
+
+	// Git commit generated
+	if b.singleCommit {
+		if err = b.pushSingleCommit(ctx, options); err != nil {
+			return nil, err
+		}
+	} else {
+		committed, err := b.commitManifests("sync manifests", "Add Flux sync manifests", b.branch)
+		if err != nil {
+			return nil, err
 		}
+		if committed {
+			b.logger.Actionf("pushing sync manifests to %q", b.url)
+			if err = b.git.Push(ctx, b.caBundle); err != nil {
+				return nil, fmt.Errorf("failed to push sync manifests: %w", err)
+			}
+			b.pushed = true
+		}
+	}
+
+	return b.syncObjects(kusManifests.Path)
+}
+
+// lockGit takes the lock guarding the Git repository, if any, and
+// returns the function releasing it.
+func (b *PlainGitBootstrapper) lockGit() func() {
+	if b.gitLock == nil {
+		return func() {}
+	}
+	b.gitLock.Lock()
+	return b.gitLock.Unlock
+}
+
+func (b *PlainGitBootstrapper) setGitLock(l gosync.Locker) {
+	b.gitLock = l
+}
+
+// cloneBranch clones the configured branch of the Git repository, unless
+// it has already been cloned.
+func (b *PlainGitBootstrapper) cloneBranch(ctx context.Context) error {
+	_, err := b.git.Status()
+	if err == nil || err != git.ErrNoGitRepository {
+		return err
 	}
 
-	// Generate component manifests
+	b.logger.Actionf("cloning branch %q from Git repository %q", b.branch, b.url)
+	var cloned bool
+	if err = retry(1, 2*time.Second, func() (err error) {
+		cloned, err = b.git.Clone(ctx, b.url, b.branch, b.caBundle)
+		return
+	}); err != nil {
+		return fmt.Errorf("failed to clone repository: %w", err)
+	}
+	if cloned {
+		b.logger.Successf("cloned repository")
+	}
+	return nil
+}
+
+// writeComponents generates the component manifests and writes them to
+// the Git repository.
+func (b *PlainGitBootstrapper) writeComponents(manifestsBase string, options install.Options) (*manifestgen.Manifest, error) {
 	b.logger.Actionf("generating component manifests")
+	b.componentPatches = options.Patches
+	options.Patches = nil
 	manifests, err := install.Generate(options, manifestsBase)
 	if err != nil {
-		return fmt.Errorf("component manifest generation failed: %w", err)
+		return nil, fmt.Errorf("component manifest generation failed: %w", err)
 	}
 	b.logger.Successf("generated component manifests")
 
-	// Write manifest to Git repository
-	if err = b.git.Write(manifests.Path, strings.NewReader(manifests.Content)); err != nil {
-		return fmt.Errorf("failed to write manifest %q: %w", manifests.Path, err)
+	if err = b.writeManifest(manifests); err != nil {
+		return nil, err
 	}
+	return manifests, nil
+}
 
-	// Git commit generated
-	commitMsg := fmt.Sprintf("Add Flux %s component manifests", options.Version)
-	if b.commitMessageAppendix != "" {
-		commitMsg = commitMsg + "\n\n" + b.commitMessageAppendix
+// writeSyncConfig generates the sync manifests and the kustomization.yaml
+// including them, and writes them to the Git repository. It returns the
+// sync and kustomization.yaml manifests.
+func (b *PlainGitBootstrapper) writeSyncConfig(options sync.Options) (*manifestgen.Manifest, *manifestgen.Manifest, error) {
+	b.logger.Actionf("generating sync manifests")
+	manifests, err := sync.Generate(options)
+	if err != nil {
+		return nil, nil, fmt.Errorf("sync manifests generation failed: %w", err)
 	}
-	commit, err := b.git.Commit(git.Commit{
-		Author:  b.author,
-		Message: commitMsg,
+	if err = b.writeManifest(manifests); err != nil {
+		return nil, nil, err
+	}
+	for _, m := range b.syncExtraManifests {
+		if err = b.writeManifest(m); err != nil {
+			return nil, nil, err
+		}
+	}
+	kusManifests, err := kustomization.Generate(kustomization.Options{
+		FileSystem: filesys.MakeFsOnDisk(),
+		BaseDir:    b.git.Path(),
+		TargetPath: filepath.Dir(manifests.Path),
 	})
-	if err != nil && err != git.ErrNoStagedFiles {
-		return fmt.Errorf("failed to commit sync manifests: %w", err)
+	if err != nil {
+		return nil, nil, fmt.Errorf("kustomization.yaml generation failed: %w", err)
 	}
-	if err == nil {
-		b.logger.Successf("committed sync manifests to %q (%q)", b.branch, commit)
-		b.logger.Actionf("pushing component manifests to %q", b.url)
-		if err = b.git.Push(ctx, b.caBundle); err != nil {
-			return fmt.Errorf("failed to push manifests: %w", err)
+	// An existing kustomization.yaml is not regenerated, and may not
+	// include the extra manifests yet
+	for _, m := range b.syncExtraManifests {
+		if kusManifests.Content, err = addKustomizationResource(kusManifests.Content, filepath.Base(m.Path)); err != nil {
+			return nil, nil, fmt.Errorf("failed to add %q to kustomization.yaml: %w", m.Path, err)
 		}
-	} else {
-		b.logger.Successf("component manifests are up to date")
 	}
+	if b.componentPatches != nil {
+		content, err := install.SetPatches([]byte(kusManifests.Content), b.componentPatches)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to set patches in kustomization.yaml: %w", err)
+		}
+		kusManifests.Content = string(content)
+	}
+	if err = b.writeManifest(kusManifests); err != nil {
+		return nil, nil, err
+	}
+	b.logger.Successf("generated sync manifests")
+	return manifests, kusManifests, nil
+}
 
-	// Conditionally install manifests
-	if mustInstallManifests(ctx, b.kube, options.Namespace) {
-		componentsYAML := filepath.Join(b.git.Path(), manifests.Path)
-
-		// Apply components using any existing customisations
-		kfile := filepath.Join(filepath.Dir(componentsYAML), konfig.DefaultKustomizationFileName())
-		if _, err := os.Stat(kfile); err == nil {
-			tmpDir, err := os.MkdirTemp("", "gotk-crds")
-			defer os.RemoveAll(tmpDir)
+// writeManifest writes the given manifest to the Git repository. In
+// dry-run mode, the change to the file is recorded in the plan first.
+func (b *PlainGitBootstrapper) writeManifest(manifest *manifestgen.Manifest) error {
+	if b.dryRun {
+		if err := b.planFile(manifest.Path, manifest.Content); err != nil {
+			return fmt.Errorf("failed to plan manifest %q: %w", manifest.Path, err)
+		}
+	} else if err := b.recordOriginal(manifest.Path); err != nil {
+		return fmt.Errorf("failed to read manifest %q: %w", manifest.Path, err)
+	}
+	if err := b.git.Write(manifest.Path, strings.NewReader(manifest.Content)); err != nil {
+		return fmt.Errorf("failed to write manifest %q: %w", manifest.Path, err)
+	}
+	return nil
+}
 
-			// Extract the CRDs from the components manifest
-			crdsYAML := filepath.Join(tmpDir, "gotk-crds.yaml")
-			if err := utils.ExtractCRDs(componentsYAML, crdsYAML); err != nil {
-				return err
-			}
+// commitManifests commits the staged changes to the current branch with
+// the given message, and the commit message appendix. It returns false
+// if there were no changes to commit, which is always the case in
+// dry-run mode.
+func (b *PlainGitBootstrapper) commitManifests(kind, message, branch string) (bool, error) {
+	if b.dryRun {
+		clean, err := b.git.Status()
+		if err != nil {
+			return false, fmt.Errorf("failed to determine status of %s: %w", kind, err)
+		}
+		if clean {
+			b.logger.Successf("%s are up to date", kind)
+		} else {
+			b.logger.Successf("%s would be committed to %q", kind, branch)
+		}
+		return false, nil
+	}
+	if b.commitMessageAppendix != "" {
+		message = message + "\n\n" + b.commitMessageAppendix
+	}
+	var opts []git.CommitOption
+	if b.gpgKeyRingPath != "" {
+		signingKey, err := b.loadSigningKey()
+		if err != nil {
+			return false, err
+		}
+		opts = append(opts, git.WithSigningKey(signingKey))
+	}
+	commit, err := b.git.Commit(git.Commit{
+		Author:  b.author,
+		Message: message,
+	}, opts...)
+	if err != nil && err != git.ErrNoStagedFiles {
+		return false, fmt.Errorf("failed to commit %s: %w", kind, err)
+	}
+	if err == git.ErrNoStagedFiles {
+		b.logger.Successf("%s are up to date", kind)
+		return false, nil
+	}
+	b.logger.Successf("committed %s to %q (%q)", kind, branch, commit)
+	return true, nil
+}
 
-			// Apply the CRDs
-			b.logger.Actionf("installing toolkit.fluxcd.io CRDs")
-			kubectlArgs := []string{"apply", "-f", crdsYAML}
-			if _, err = utils.ExecKubectlCommand(ctx, utils.ModeStderrOS, b.kubeconfig, b.kubecontext, kubectlArgs...); err != nil {
-				return err
-			}
+// loadSigningKey reads the OpenPGP signing key from the configured key
+// ring the first time it is called, and returns the cached key after.
+func (b *PlainGitBootstrapper) loadSigningKey() (*openpgp.Entity, error) {
+	if b.signingKey != nil {
+		return b.signingKey, nil
+	}
+	f, err := os.Open(b.gpgKeyRingPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open OpenPGP key ring: %w", err)
+	}
+	defer f.Close()
+	signingKey, err := git.ReadSigningKey(f, b.gpgPassphrase, b.gpgKeyID)
+	if err != nil {
+		return nil, err
+	}
+	b.logger.Actionf("signing commits with OpenPGP key %s", git.SigningKeyID(signingKey))
+	b.signingKey = signingKey
+	return signingKey, nil
+}
 
-			// Wait for CRDs to be established
-			b.logger.Waitingf("waiting for CRDs to be reconciled")
-			kubectlArgs = []string{"wait", "--for", "condition=established", "-f", crdsYAML}
-			if _, err = utils.ExecKubectlCommand(ctx, utils.ModeStderrOS, b.kubeconfig, b.kubecontext, kubectlArgs...); err != nil {
-				return err
-			}
-			b.logger.Successf("CRDs reconciled successfully")
+// componentObjects reads the objects of the components manifest at the
+// given path relative to the Git repository, built with any existing
+// customisations in the kustomization.yaml next to it. The install
+// patches are only written to the kustomization.yaml together with the
+// sync manifests, and are set in it in memory, so that the components
+// are installed with them on the first bootstrap as well.
+func (b *PlainGitBootstrapper) componentObjects(path string) ([]*unstructured.Unstructured, error) {
+	dir := filepath.Join(b.git.Path(), filepath.Dir(path))
+	kfile := filepath.Join(dir, konfig.DefaultKustomizationFileName())
+	_, err := os.Stat(kfile)
+	exists := err == nil
+	if b.componentPatches == nil {
+		if exists {
+			return ssa.ReadPath(dir)
+		}
+		return ssa.ReadPath(filepath.Join(b.git.Path(), path))
+	}
 
-			// Apply the components and their patches
-			b.logger.Actionf("installing components in %q namespace", options.Namespace)
-			kubectlArgs = []string{"apply", "-k", filepath.Dir(componentsYAML)}
-			if _, err = utils.ExecKubectlCommand(ctx, utils.ModeStderrOS, b.kubeconfig, b.kubecontext, kubectlArgs...); err != nil {
-				return err
-			}
-		} else {
-			// Apply the CRDs and controllers
-			b.logger.Actionf("installing components in %q namespace", options.Namespace)
-			kubectlArgs := []string{"apply", "-f", componentsYAML}
-			if _, err = utils.ExecKubectlCommand(ctx, utils.ModeStderrOS, b.kubeconfig, b.kubecontext, kubectlArgs...); err != nil {
-				return err
-			}
+	fs := filesys.MakeFsInMemory()
+	if err = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
 		}
-		b.logger.Successf("installed components")
+		return fs.WriteFile(p, content)
+	}); err != nil {
+		return nil, fmt.Errorf("failed to read components: %w", err)
+	}
+	var content []byte
+	if exists {
+		content, err = os.ReadFile(kfile)
+	} else {
+		content, err = yaml.Marshal(kustypes.Kustomization{
+			TypeMeta: kustypes.TypeMeta{
+				APIVersion: kustypes.KustomizationVersion,
+				Kind:       kustypes.KustomizationKind,
+			},
+			Resources: []string{filepath.Base(path)},
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if content, err = install.SetPatches(content, b.componentPatches); err != nil {
+		return nil, fmt.Errorf("failed to set patches in kustomization.yaml: %w", err)
 	}
+	if err = fs.WriteFile(kfile, content); err != nil {
+		return nil, err
+	}
+	return ssa.ReadKustomization(fs, dir)
+}
 
-	b.logger.Successf("reconciled components")
+// installComponents applies the given component objects to the cluster.
+func (b *PlainGitBootstrapper) installComponents(ctx context.Context, objects []*unstructured.Unstructured, namespace string) error {
+	if b.dryRun {
+		return b.planObjects(ctx, objects)
+	}
+
+	b.logger.Actionf("installing components in %q namespace", namespace)
+	if err := b.applyObjects(ctx, objects); err != nil {
+		return err
+	}
+	b.logger.Successf("installed components")
 	return nil
 }
 
-func (b *PlainGitBootstrapper) ReconcileSourceSecret(ctx context.Context, options sourcesecret.Options) error {
+// applyObjects applies the given objects to the cluster, the CRDs first,
+// and logs the result for every object.
+func (b *PlainGitBootstrapper) applyObjects(ctx context.Context, objects []*unstructured.Unstructured) error {
+	changeSet, err := ssa.NewResourceManager(b.kube, ssa.FieldOwner).ApplyAllStaged(ctx, objects, ssaTimeout)
+	for _, entry := range changeSet.Entries {
+		b.logger.Actionf("%s", entry)
+	}
+	return err
+}
+
+// generateSourceSecret generates the source secret. It returns nil if
+// the secret already exists on the cluster and no custom configuration
+// is passed.
+func (b *PlainGitBootstrapper) generateSourceSecret(ctx context.Context, options sourcesecret.Options) (*corev1.Secret, error) {
 	// Determine if there is an existing secret
 	secretKey := client.ObjectKey{Name: options.Name, Namespace: options.Namespace}
 	b.logger.Actionf("determining if source secret %q exists", secretKey)
 	ok, err := secretExists(ctx, b.kube, secretKey)
 	if err != nil {
-		return fmt.Errorf("failed to determine if deploy key secret exists: %w", err)
+		return nil, fmt.Errorf("failed to determine if deploy key secret exists: %w", err)
 	}
 
 	// Return early if exists and no custom config is passed
 	if ok && len(options.CAFilePath+options.PrivateKeyPath+options.Username+options.Password) == 0 {
 		b.logger.Successf("source secret up to date")
-		return nil
+		return nil, nil
 	}
 
 	// Generate source secret
 	b.logger.Actionf("generating source secret")
 	manifest, err := sourcesecret.Generate(options)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	var secret corev1.Secret
 	if err := yaml.Unmarshal([]byte(manifest.Content), &secret); err != nil {
-		return fmt.Errorf("failed to unmarshal generated source secret manifest: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal generated source secret manifest: %w", err)
 	}
+	if b.dryRun {
+		action := PlanActionCreate
+		if ok {
+			action = PlanActionReplace
+		}
+		b.plan.add("source secret", secretKey.String(), action, "")
+	}
+	return &secret, nil
+}
 
+// postGenerateSourceSecret runs the callbacks configured to be run
+// after the source secret has been generated, e.g. to register the
+// deploy key with the Git provider.
+func (b *PlainGitBootstrapper) postGenerateSourceSecret(ctx context.Context, secret corev1.Secret, options sourcesecret.Options) error {
 	for _, callback := range b.postGenerateSecret {
-		if err = callback(ctx, secret, options); err != nil {
+		if err := callback(ctx, secret, options); err != nil {
 			return err
 		}
 	}
+	return nil
+}
 
-	// Apply source secret
-	b.logger.Actionf("applying source secret %q", secretKey)
-	if err = reconcileSecret(ctx, b.kube, secret); err != nil {
+// applySourceSecret applies the given source secret to the cluster.
+func (b *PlainGitBootstrapper) applySourceSecret(ctx context.Context, secret corev1.Secret) error {
+	if b.dryRun {
+		return nil
+	}
+	b.logger.Actionf("applying source secret %q", client.ObjectKeyFromObject(&secret))
+	if err := reconcileSecret(ctx, b.kube, secret); err != nil {
 		return err
 	}
 	b.logger.Successf("reconciled source secret")
-
 	return nil
 }
 
-func (b *PlainGitBootstrapper) ReconcileSyncConfig(ctx context.Context, options sync.Options) error {
-	// Confirm that sync configuration does not overwrite existing config
+// confirmSyncPath confirms the sync configuration does not overwrite the
+// path of an existing Kustomization.
+func (b *PlainGitBootstrapper) confirmSyncPath(ctx context.Context, options sync.Options) error {
 	if curPath, err := kustomizationPathDiffers(ctx, b.kube, client.ObjectKey{Name: options.Name, Namespace: options.Namespace}, options.TargetPath); err != nil {
 		return fmt.Errorf("failed to determine if sync configuration would overwrite existing Kustomization: %w", err)
 	} else if curPath != "" {
 		return fmt.Errorf("sync path configuration (%q) would overwrite path (%q) of existing Kustomization", options.TargetPath, curPath)
 	}
+	return nil
+}
 
-	// Clone if not already
-	if _, err := b.git.Status(); err != nil {
-		if err == git.ErrNoGitRepository {
-			b.logger.Actionf("cloning branch %q from Git repository %q", b.branch, b.url)
-			var cloned bool
-			if err = retry(1, 2*time.Second, func() (err error) {
-				cloned, err = b.git.Clone(ctx, b.url, b.branch, b.caBundle)
-				return
-			}); err != nil {
-				return fmt.Errorf("failed to clone repository: %w", err)
-			}
-			if cloned {
-				b.logger.Successf("cloned repository")
-			}
-		}
-		return err
-	}
-
-	// Generate sync manifests and write to Git repository
-	b.logger.Actionf("generating sync manifests")
-	manifests, err := sync.Generate(options)
-	if err != nil {
-		return fmt.Errorf("sync manifests generation failed: %w", err)
-	}
-	if err = b.git.Write(manifests.Path, strings.NewReader(manifests.Content)); err != nil {
-		return fmt.Errorf("failed to write manifest %q: %w", manifests.Path, err)
-	}
-	kusManifests, err := kustomization.Generate(kustomization.Options{
-		FileSystem: filesys.MakeFsOnDisk(),
-		BaseDir:    b.git.Path(),
-		TargetPath: filepath.Dir(manifests.Path),
-	})
-	if err != nil {
-		return fmt.Errorf("kustomization.yaml generation failed: %w", err)
-	}
-	if err = b.git.Write(kusManifests.Path, strings.NewReader(kusManifests.Content)); err != nil {
-		return fmt.Errorf("failed to write manifest %q: %w", kusManifests.Path, err)
-	}
-	b.logger.Successf("generated sync manifests")
+// syncObjects reads the objects of the kustomization.yaml at the given
+// path relative to the Git repository.
+func (b *PlainGitBootstrapper) syncObjects(path string) ([]*unstructured.Unstructured, error) {
+	return ssa.ReadPath(filepath.Join(b.git.Path(), filepath.Dir(path)))
+}
 
-	// Git commit generated
-	commitMsg := fmt.Sprintf("Add Flux sync manifests")
-	if b.commitMessageAppendix != "" {
-		commitMsg = commitMsg + "\n\n" + b.commitMessageAppendix
-	}
-	commit, err := b.git.Commit(git.Commit{
-		Author:  b.author,
-		Message: commitMsg,
-	})
-	if err != nil && err != git.ErrNoStagedFiles {
-		return fmt.Errorf("failed to commit sync manifests: %w", err)
+// applySyncConfig applies the given sync objects to the cluster.
+func (b *PlainGitBootstrapper) applySyncConfig(ctx context.Context, objects []*unstructured.Unstructured) error {
+	if b.dryRun {
+		return b.planObjects(ctx, objects)
 	}
-	if err == nil {
-		b.logger.Successf("committed sync manifests to %q (%q)", b.branch, commit)
-		b.logger.Actionf("pushing sync manifests to %q", b.url)
-		if err = b.git.Push(ctx, b.caBundle); err != nil {
-			return fmt.Errorf("failed to push sync manifests: %w", err)
-		}
-	} else {
-		b.logger.Successf("sync manifests are up to date")
-	}
-
-	// Apply to cluster
 	b.logger.Actionf("applying sync manifests")
-	kubectlArgs := []string{"apply", "-k", filepath.Join(b.git.Path(), filepath.Dir(kusManifests.Path))}
-	if _, err = utils.ExecKubectlCommand(ctx, utils.ModeStderrOS, b.kubeconfig, b.kubecontext, kubectlArgs...); err != nil {
+	if err := b.applyObjects(ctx, objects); err != nil {
 		return err
 	}
 	b.logger.Successf("reconciled sync configuration")
-
 	return nil
 }
 
 func (b *PlainGitBootstrapper) ReportKustomizationHealth(ctx context.Context, options sync.Options, pollInterval, timeout time.Duration) error {
-	head, err := b.git.Head()
-	if err != nil {
-		return err
+	if b.dryRun {
+		return nil
 	}
 
 	objKey := client.ObjectKey{Name: options.Name, Namespace: options.Namespace}
 
 	b.logger.Waitingf("waiting for Kustomization %q to be reconciled", objKey.String())
 
-	expectRevision := fmt.Sprintf("%s/%s", options.Branch, head)
 	var k kustomizev1.Kustomization
-	if err := wait.PollImmediate(pollInterval, timeout, kustomizationReconciled(
-		ctx, b.kube, objKey, &k, expectRevision),
-	); err != nil {
+	if err := wait.PollImmediate(pollInterval, timeout, func() (bool, error) {
+		// The head is determined on every poll, as the branch may move
+		// on while waiting when it is shared with the bootstrappers of
+		// other clusters
+		head, err := b.git.Head()
+		if err != nil {
+			return false, err
+		}
+		expectRevision := fmt.Sprintf("%s/%s", options.Branch, head)
+		return kustomizationReconciled(ctx, b.kube, objKey, &k, expectRevision)()
+	}); err != nil {
 		b.logger.Failuref(err.Error())
 		return err
 	}
@@ -362,6 +689,10 @@ func (b *PlainGitBootstrapper) ReportKustomizationHealth(ctx context.Context, op
 }
 
 func (b *PlainGitBootstrapper) ReportComponentsHealth(ctx context.Context, install install.Options, timeout time.Duration) error {
+	if b.dryRun {
+		return nil
+	}
+
 	cfg, err := utils.KubeConfig(b.kubeconfig, b.kubecontext)
 	if err != nil {
 		return err
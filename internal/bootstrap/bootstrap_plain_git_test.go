@@ -0,0 +1,191 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+
+	"github.com/fluxcd/flux2/pkg/manifestgen"
+	"github.com/fluxcd/flux2/pkg/manifestgen/install"
+	"github.com/fluxcd/flux2/pkg/manifestgen/sync"
+)
+
+func TestPlainGitBootstrapper_writeSyncConfigPatches(t *testing.T) {
+	b, remote := newRollbackBootstrapper(t)
+	commitAndPush(t, b, &manifestgen.Manifest{Path: "clusters/test/flux-system/gotk-components.yaml", Content: "---\n"})
+
+	patches, err := install.ParsePatches([]byte(`
+- target:
+    kind: Deployment
+  patch: |
+    - op: add
+      path: /spec/template/spec/containers/0/args/-
+      value: --concurrent=10
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.componentPatches = patches
+	options := sync.MakeDefaultOptions()
+	options.URL = remote
+	options.TargetPath = "clusters/test"
+
+	// Writing the sync config again must not duplicate the patches
+	for i := 0; i < 2; i++ {
+		_, kusManifests, err := b.writeSyncConfig(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var kus kustypes.Kustomization
+		if err := kus.Unmarshal([]byte(kusManifests.Content)); err != nil {
+			t.Fatal(err)
+		}
+		if len(kus.Patches) != 1 || !kus.Patches[0].Equals(b.componentPatches[0]) {
+			t.Errorf("expected the component patch in kustomization.yaml, got:\n%s", kusManifests.Content)
+		}
+		if len(kus.Resources) != 2 {
+			t.Errorf("expected the components and sync manifests in kustomization.yaml, got %v", kus.Resources)
+		}
+	}
+}
+
+func TestPlainGitBootstrapper_writeSyncConfigPatchesReplaced(t *testing.T) {
+	b, remote := newRollbackBootstrapper(t)
+	commitAndPush(t, b, &manifestgen.Manifest{Path: "clusters/test/flux-system/gotk-components.yaml", Content: "---\n"})
+	options := sync.MakeDefaultOptions()
+	options.URL = remote
+	options.TargetPath = "clusters/test"
+
+	patchFile := func(value string) []kustypes.Patch {
+		patches, err := install.ParsePatches([]byte(`
+- target:
+    kind: Deployment
+  patch: |
+    - op: add
+      path: /spec/template/spec/containers/0/args/-
+      value: ` + value + `
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return patches
+	}
+
+	// Every bootstrap writes the patches of its patch file, none
+	// being left when the patch file is empty, and the patches are
+	// left untouched without a patch file
+	for _, run := range []struct {
+		patches []kustypes.Patch
+		want    []kustypes.Patch
+	}{
+		{patches: patchFile("--concurrent=10"), want: patchFile("--concurrent=10")},
+		{patches: patchFile("--concurrent=20"), want: patchFile("--concurrent=20")},
+		{patches: nil, want: patchFile("--concurrent=20")},
+		{patches: []kustypes.Patch{}, want: nil},
+	} {
+		b.componentPatches = run.patches
+		_, kusManifests, err := b.writeSyncConfig(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.commitManifests("sync manifests", "Update sync manifests", b.branch); err != nil {
+			t.Fatal(err)
+		}
+		b.originals = nil
+
+		var kus kustypes.Kustomization
+		if err := kus.Unmarshal([]byte(kusManifests.Content)); err != nil {
+			t.Fatal(err)
+		}
+		if len(kus.Patches) != len(run.want) || (len(run.want) > 0 && !kus.Patches[0].Equals(run.want[0])) {
+			t.Errorf("expected patches %v in kustomization.yaml, got:\n%s", run.want, kusManifests.Content)
+		}
+	}
+}
+
+func TestPlainGitBootstrapper_componentObjectsPatches(t *testing.T) {
+	const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: source-controller
+  namespace: flux-system
+spec:
+  template:
+    spec:
+      containers:
+      - name: manager
+        args:
+        - --log-level=info
+`
+	patches, err := install.ParsePatches([]byte(`
+- target:
+    kind: Deployment
+  patch: |
+    - op: add
+      path: /spec/template/spec/containers/0/args/-
+      value: --concurrent=10
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		kustomization string
+	}{
+		{name: "first bootstrap"},
+		{name: "existing kustomization.yaml", kustomization: "resources:\n- gotk-components.yaml\n"},
+		{name: "existing patches", kustomization: "resources:\n- gotk-components.yaml\npatches:\n- target:\n    kind: Deployment\n  patch: |\n    - op: add\n      path: /spec/template/spec/containers/0/args/-\n      value: --concurrent=10\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := newRollbackBootstrapper(t)
+			b.componentPatches = patches
+			dir := filepath.Join(b.git.Path(), "clusters/test/flux-system")
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "gotk-components.yaml"), []byte(deployment), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.kustomization != "" {
+				if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte(tt.kustomization), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			objects, err := b.componentObjects("clusters/test/flux-system/gotk-components.yaml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(objects) != 1 {
+				t.Fatalf("expected 1 object, got %d", len(objects))
+			}
+			containers, _, _ := unstructured.NestedSlice(objects[0].Object, "spec", "template", "spec", "containers")
+			args, _, _ := unstructured.NestedStringSlice(containers[0].(map[string]interface{}), "args")
+			want := []string{"--log-level=info", "--concurrent=10"}
+			if len(args) != len(want) || args[0] != want[0] || args[1] != want[1] {
+				t.Errorf("expected args %v, got %v", want, args)
+			}
+		})
+	}
+}
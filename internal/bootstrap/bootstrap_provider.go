@@ -25,11 +25,13 @@ import (
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/fluxcd/go-git-providers/gitprovider"
 
 	"github.com/fluxcd/flux2/internal/bootstrap/git"
+	"github.com/fluxcd/flux2/pkg/manifestgen/receiver"
 	"github.com/fluxcd/flux2/pkg/manifestgen/sourcesecret"
 	"github.com/fluxcd/flux2/pkg/manifestgen/sync"
 )
@@ -56,6 +58,18 @@ type GitProviderBootstrapper struct {
 
 	sshHostname string
 
+	pullRequest  bool
+	waitForMerge bool
+	pending      *pendingPullRequest
+
+	// webhookReceiverURL is the external URL the notification-controller
+	// receivers are exposed at, a webhook receiver is configured if set.
+	webhookReceiverURL string
+
+	// commitStatusToken is the token of the notification Provider
+	// setting the commit status, which is configured if set.
+	commitStatusToken string
+
 	provider gitprovider.Client
 }
 
@@ -180,10 +194,27 @@ func (o reconcileOption) applyGitProvider(b *GitProviderBootstrapper) {
 	b.reconcile = true
 }
 
+func WithPullRequest(waitForMerge bool) GitProviderOption {
+	return pullRequestOption{waitForMerge: waitForMerge}
+}
+
+type pullRequestOption struct {
+	waitForMerge bool
+}
+
+func (o pullRequestOption) applyGitProvider(b *GitProviderBootstrapper) {
+	b.pullRequest = true
+	b.waitForMerge = o.waitForMerge
+}
+
 func (b *GitProviderBootstrapper) ReconcileSyncConfig(ctx context.Context, options sync.Options) error {
 	repo, err := b.getRepository(ctx)
 	if err != nil {
-		return err
+		// In dry-run mode, the repository may not have been created
+		if !b.dryRun || !errors.Is(err, gitprovider.ErrNotFound) {
+			return err
+		}
+		repo = nil
 	}
 	if b.url == "" {
 		bootstrapURL, err := b.getCloneURL(repo, gitprovider.TransportType(b.bootstrapTransportType))
@@ -199,7 +230,100 @@ func (b *GitProviderBootstrapper) ReconcileSyncConfig(ctx context.Context, optio
 		}
 		options.URL = syncURL
 	}
-	return b.PlainGitBootstrapper.ReconcileSyncConfig(ctx, options)
+
+	// The token secrets are applied with the sync manifests, which
+	// happens once merged when opening a pull request
+	var secrets []tokenSecret
+	if b.commitStatusToken != "" {
+		secret, err := b.prepareCommitStatus(repo, options)
+		if err != nil {
+			return err
+		}
+		secrets = append(secrets, secret)
+	}
+
+	var receiverOpts receiver.Options
+	var webhookToken string
+	if b.webhookReceiverURL != "" {
+		if receiverOpts, err = b.receiverOptions(options); err != nil {
+			return err
+		}
+		var secret *tokenSecret
+		if webhookToken, secret, err = b.prepareWebhookReceiver(ctx, receiverOpts); err != nil {
+			return err
+		}
+		if secret != nil {
+			secrets = append(secrets, *secret)
+		}
+	}
+
+	if b.pullRequest && !b.dryRun {
+		err = b.reconcileSyncConfigPullRequest(ctx, repo, options, secrets)
+	} else {
+		if b.pullRequest {
+			b.plan.add("pull request against branch", b.branch, PlanActionCreate, "")
+		}
+		err = b.applyAfterPush(ctx, func(ctx context.Context) error {
+			return b.applyTokenSecrets(ctx, secrets)
+		})
+		if err != nil {
+			return err
+		}
+		err = b.PlainGitBootstrapper.ReconcileSyncConfig(ctx, options)
+	}
+	if err != nil || b.webhookReceiverURL == "" {
+		return err
+	}
+	return b.reconcileWebhook(ctx, receiverOpts, webhookToken)
+}
+
+// tokenSecret is a secret holding a token which is applied to the
+// cluster but not committed to Git.
+type tokenSecret struct {
+	description string
+	secret      corev1.Secret
+}
+
+// applyTokenSecrets applies the given secrets to the cluster, or adds the
+// ones to create or update to the plan in dry-run mode.
+func (b *GitProviderBootstrapper) applyTokenSecrets(ctx context.Context, secrets []tokenSecret) error {
+	for _, s := range secrets {
+		secretKey := client.ObjectKeyFromObject(&s.secret)
+		if b.dryRun {
+			action := PlanActionUpdate
+			var existing corev1.Secret
+			if err := b.kube.Get(ctx, secretKey, &existing); err != nil {
+				if !apierr.IsNotFound(err) {
+					return fmt.Errorf("failed to determine if %s %q exists: %w", s.description, secretKey, err)
+				}
+				action = PlanActionCreate
+			} else if secretDataEqual(existing, s.secret) {
+				continue
+			}
+			b.plan.add(s.description, secretKey.String(), action, "")
+			continue
+		}
+
+		b.logger.Actionf("applying %s %q", s.description, secretKey)
+		if err := reconcileSecret(ctx, b.kube, s.secret); err != nil {
+			return fmt.Errorf("failed to apply %s %q: %w", s.description, secretKey, err)
+		}
+	}
+	return nil
+}
+
+// secretDataEqual returns true if the data of the existing secret
+// equals the string data of the desired one.
+func secretDataEqual(existing, desired corev1.Secret) bool {
+	if len(existing.Data) != len(desired.StringData) {
+		return false
+	}
+	for k, v := range desired.StringData {
+		if string(existing.Data[k]) != v {
+			return false
+		}
+	}
+	return true
 }
 
 // ReconcileRepository reconciles an organization or user repository with the
@@ -209,6 +333,10 @@ func (b *GitProviderBootstrapper) ReconcileSyncConfig(ctx context.Context, optio
 // When part of the reconciliation fails with a warning without aborting, an
 // ErrReconciledWithWarning error is returned.
 func (b *GitProviderBootstrapper) ReconcileRepository(ctx context.Context) error {
+	if b.dryRun {
+		return b.planRepository(ctx)
+	}
+
 	var repo gitprovider.UserRepository
 	var err error
 
@@ -238,13 +366,16 @@ func (b *GitProviderBootstrapper) reconcileDeployKey(ctx context.Context, secret
 	}
 	b.logger.Successf("public key: %s", strings.TrimSpace(ppk))
 
+	name := deployKeyName(options.Namespace, b.branch, options.Name, options.TargetPath)
+	deployKeyInfo := newDeployKeyInfo(name, ppk, b.readWriteKey)
+	if b.dryRun {
+		return b.planDeployKey(ctx, deployKeyInfo)
+	}
+
 	repo, err := b.getRepository(ctx)
 	if err != nil {
 		return err
 	}
-
-	name := deployKeyName(options.Namespace, b.branch, options.Name, options.TargetPath)
-	deployKeyInfo := newDeployKeyInfo(name, ppk, b.readWriteKey)
 	var changed bool
 	if _, changed, err = repo.DeployKeys().Reconcile(ctx, deployKeyInfo); err != nil {
 		return err
@@ -399,8 +530,12 @@ func (b *GitProviderBootstrapper) getRepository(ctx context.Context) (gitprovide
 // getCloneURL returns the Git clone URL for the given
 // gitprovider.UserRepository. If the given transport type is
 // gitprovider.TransportTypeSSH and a custom SSH hostname is configured,
-// the hostname of the URL will be modified to this hostname.
+// the hostname of the URL will be modified to this hostname. If the
+// repository is nil, the planned clone URL is returned.
 func (b *GitProviderBootstrapper) getCloneURL(repository gitprovider.UserRepository, transport gitprovider.TransportType) (string, error) {
+	if repository == nil {
+		return b.plannedCloneURL(transport), nil
+	}
 	u := repository.Repository().GetCloneURL(transport)
 	// TODO(hidde): https://github.com/fluxcd/go-git-providers/issues/55
 	if strings.HasPrefix(u, "https://https://") {
@@ -0,0 +1,303 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/xanzy/go-gitlab"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/fluxcd/go-git-providers/gitprovider"
+
+	"github.com/fluxcd/flux2/pkg/manifestgen/install"
+	"github.com/fluxcd/flux2/pkg/manifestgen/sourcesecret"
+	"github.com/fluxcd/flux2/pkg/manifestgen/sync"
+)
+
+// pullRequestPollInterval is the interval at which the Git provider is
+// queried while waiting for the pull request to be merged.
+var pullRequestPollInterval = 10 * time.Second
+
+// pendingPullRequest holds the changes committed to the pull request
+// branch, which are applied to the cluster once the pull request has
+// been merged.
+type pendingPullRequest struct {
+	branch  string
+	version string
+
+	namespace         string
+	componentsPath    string
+	kustomizationPath string
+	secret            *corev1.Secret
+	secretOptions     sourcesecret.Options
+	tokenSecrets      []tokenSecret
+
+	committed bool
+}
+
+// pullRequestMergeChecker is implemented by the pull request clients of
+// the Git providers in this module, which report whether the pull
+// request opened from a branch has been merged.
+type pullRequestMergeChecker interface {
+	Merged(ctx context.Context, branch string) (bool, error)
+}
+
+// ReconcileComponents reconciles the components. When configured to
+// open a pull request, the component manifests are committed to a new
+// branch and the installation on the cluster is postponed until the
+// pull request has been merged.
+func (b *GitProviderBootstrapper) ReconcileComponents(ctx context.Context, manifestsBase string, options install.Options, secretOpts sourcesecret.Options) error {
+	if !b.pullRequest || b.dryRun {
+		return b.PlainGitBootstrapper.ReconcileComponents(ctx, manifestsBase, options, secretOpts)
+	}
+	defer b.lockGit()()
+
+	// Clone if not already
+	if err := b.cloneBranch(ctx); err != nil {
+		return err
+	}
+	if _, err := b.git.Head(); err != nil {
+		return fmt.Errorf("branch %q must exist to open a pull request against it: %w", b.branch, err)
+	}
+
+	// Switch to the pull request branch
+	branch := fmt.Sprintf("flux-bootstrap-%d", time.Now().Unix())
+	if err := b.git.SwitchBranch(branch); err != nil {
+		return fmt.Errorf("failed to switch to branch %q: %w", branch, err)
+	}
+	b.pending = &pendingPullRequest{
+		branch:    branch,
+		version:   options.Version,
+		namespace: options.Namespace,
+	}
+
+	// Generate component manifests and write to Git repository
+	manifests, err := b.writeComponents(manifestsBase, options)
+	if err != nil {
+		return err
+	}
+	b.pending.componentsPath = manifests.Path
+
+	// Git commit generated
+	committed, err := b.commitManifests("component manifests", fmt.Sprintf("Add Flux %s component manifests", options.Version), branch)
+	if err != nil {
+		return err
+	}
+	b.pending.committed = committed
+	return nil
+}
+
+// ReconcileSourceSecret reconciles the source secret. When configured
+// to open a pull request, the secret is generated but only applied to
+// the cluster, and its deploy key registered, once the pull request has
+// been merged.
+func (b *GitProviderBootstrapper) ReconcileSourceSecret(ctx context.Context, options sourcesecret.Options) error {
+	if !b.pullRequest || b.dryRun {
+		return b.PlainGitBootstrapper.ReconcileSourceSecret(ctx, options)
+	}
+
+	secret, err := b.generateSourceSecret(ctx, options)
+	if err != nil {
+		return err
+	}
+	b.pending.secret = secret
+	b.pending.secretOptions = options
+	return nil
+}
+
+// reconcileSyncConfigPullRequest commits the sync manifests to the pull
+// request branch, pushes the branch and opens a pull request against
+// the configured branch. If configured to wait for the merge, it
+// applies the pending changes, including the given token secrets, to the
+// cluster once merged. Otherwise, ErrPullRequestPending is returned.
+func (b *GitProviderBootstrapper) reconcileSyncConfigPullRequest(ctx context.Context, repo gitprovider.UserRepository, options sync.Options, secrets []tokenSecret) error {
+	if b.pending == nil {
+		return fmt.Errorf("components must be reconciled before the sync configuration")
+	}
+	b.pending.tokenSecrets = secrets
+
+	// Confirm that sync configuration does not overwrite existing config
+	if err := b.confirmSyncPath(ctx, options); err != nil {
+		return err
+	}
+
+	pushed, err := b.pushPullRequestBranch(ctx, options)
+	if err != nil {
+		return err
+	}
+	if !pushed {
+		b.logger.Successf("no changes to propose in a pull request")
+		return b.applyPending(ctx)
+	}
+
+	// Open the pull request
+	title := fmt.Sprintf("Add Flux %s manifests", b.pending.version)
+	description := "Add the Flux component and sync manifests generated by `flux bootstrap`."
+	if b.commitMessageAppendix != "" {
+		description = description + "\n\n" + b.commitMessageAppendix
+	}
+	if err = repo.PullRequests().Create(ctx, title, b.pending.branch, b.branch, description); err != nil {
+		return fmt.Errorf("failed to open pull request: %w", err)
+	}
+	b.logger.Successf("opened pull request to merge %q into %q", b.pending.branch, b.branch)
+
+	if !b.waitForMerge {
+		b.logger.Warningf("the cluster and the deploy keys are left untouched, run bootstrap again after merging the pull request")
+		return ErrPullRequestPending
+	}
+	if err = b.waitForPullRequestMerge(ctx, repo); err != nil {
+		return err
+	}
+	return b.applyPending(ctx)
+}
+
+// pushPullRequestBranch writes the sync manifests to the Git repository,
+// commits them to the pull request branch and pushes the branch. It
+// returns false if neither the component nor the sync manifests changed,
+// in which case nothing is pushed.
+func (b *GitProviderBootstrapper) pushPullRequestBranch(ctx context.Context, options sync.Options) (bool, error) {
+	defer b.lockGit()()
+
+	// Generate sync manifests and write to Git repository
+	_, kusManifests, err := b.writeSyncConfig(options)
+	if err != nil {
+		return false, err
+	}
+	b.pending.kustomizationPath = kusManifests.Path
+
+	// Git commit generated
+	committed, err := b.commitManifests("sync manifests", "Add Flux sync manifests", b.pending.branch)
+	if err != nil {
+		return false, err
+	}
+	if !b.pending.committed && !committed {
+		return false, nil
+	}
+
+	b.logger.Actionf("pushing manifests to branch %q", b.pending.branch)
+	if err = b.git.Push(ctx, b.caBundle); err != nil {
+		return false, fmt.Errorf("failed to push manifests: %w", err)
+	}
+	return true, nil
+}
+
+// waitForPullRequestMerge polls the Git provider until the pull request
+// has been merged, and then pulls the configured branch.
+func (b *GitProviderBootstrapper) waitForPullRequestMerge(ctx context.Context, repo gitprovider.UserRepository) error {
+	b.logger.Waitingf("waiting for pull request to be merged into %q", b.branch)
+	if err := wait.PollImmediateUntil(pullRequestPollInterval, func() (bool, error) {
+		return b.pullRequestMerged(ctx, repo, b.pending.branch)
+	}, ctx.Done()); err != nil {
+		return fmt.Errorf("failed to wait for pull request to be merged: %w", err)
+	}
+	b.logger.Successf("pull request merged")
+
+	defer b.lockGit()()
+	if err := b.git.SwitchBranch(b.branch); err != nil {
+		return fmt.Errorf("failed to switch to branch %q: %w", b.branch, err)
+	}
+	if err := b.git.Pull(ctx, b.caBundle); err != nil {
+		return fmt.Errorf("failed to pull branch %q: %w", b.branch, err)
+	}
+	return nil
+}
+
+// pullRequestMerged returns true if the Git provider reports the pull
+// request opened from the given branch as merged.
+func (b *GitProviderBootstrapper) pullRequestMerged(ctx context.Context, repo gitprovider.UserRepository, branch string) (bool, error) {
+	if c, ok := repo.PullRequests().(pullRequestMergeChecker); ok {
+		return c.Merged(ctx, branch)
+	}
+
+	switch c := b.provider.Raw().(type) {
+	case *github.Client:
+		prs, _, err := c.PullRequests.List(ctx, b.owner, b.repository, &github.PullRequestListOptions{
+			State: "closed",
+			Head:  b.owner + ":" + branch,
+		})
+		if err != nil {
+			return false, err
+		}
+		for _, pr := range prs {
+			if pr.MergedAt != nil {
+				return true, nil
+			}
+		}
+		return false, nil
+	case *gitlab.Client:
+		mrs, _, err := c.MergeRequests.ListProjectMergeRequests(b.owner+"/"+b.repository, &gitlab.ListProjectMergeRequestsOptions{
+			State:        gitlab.String("merged"),
+			SourceBranch: gitlab.String(branch),
+		}, gitlab.WithContext(ctx))
+		if err != nil {
+			return false, err
+		}
+		return len(mrs) > 0, nil
+	}
+	return false, fmt.Errorf("pull request status is not supported for Git provider %q", b.provider.ProviderID())
+}
+
+// applyPending installs the components, registers the deploy key, and
+// applies the source secret, the token secrets and the sync manifests
+// to the cluster.
+func (b *GitProviderBootstrapper) applyPending(ctx context.Context) error {
+	components, syncObjects, err := b.pendingObjects()
+	if err != nil {
+		return err
+	}
+
+	if mustInstallManifests(ctx, b.kube, b.pending.namespace) {
+		if err := b.installComponents(ctx, components, b.pending.namespace); err != nil {
+			return err
+		}
+	}
+	b.logger.Successf("reconciled components")
+
+	if b.pending.secret != nil {
+		if err := b.postGenerateSourceSecret(ctx, *b.pending.secret, b.pending.secretOptions); err != nil {
+			return err
+		}
+		if err := b.applySourceSecret(ctx, *b.pending.secret); err != nil {
+			return err
+		}
+	}
+	if err := b.applyTokenSecrets(ctx, b.pending.tokenSecrets); err != nil {
+		return err
+	}
+	return b.applySyncConfig(ctx, syncObjects)
+}
+
+// pendingObjects reads the component and sync objects of the merged
+// pull request from the Git repository.
+func (b *GitProviderBootstrapper) pendingObjects() ([]*unstructured.Unstructured, []*unstructured.Unstructured, error) {
+	defer b.lockGit()()
+	components, err := b.componentObjects(b.pending.componentsPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	syncObjects, err := b.syncObjects(b.pending.kustomizationPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return components, syncObjects, nil
+}
@@ -0,0 +1,255 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fluxcd/go-git-providers/gitprovider"
+	gogitv5 "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/google/go-github/v32/github"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fluxcd/flux2/pkg/manifestgen"
+	"github.com/fluxcd/flux2/pkg/manifestgen/sourcesecret"
+	"github.com/fluxcd/flux2/pkg/manifestgen/sync"
+)
+
+// fakePullRequests records the pull requests opened against it, and
+// reports the branches in merged as merged.
+type fakePullRequests struct {
+	created []string
+	merged  map[string]bool
+}
+
+func (c *fakePullRequests) Create(_ context.Context, _, branch, baseBranch, _ string) error {
+	c.created = append(c.created, branch+":"+baseBranch)
+	return nil
+}
+
+func (c *fakePullRequests) Merged(_ context.Context, branch string) (bool, error) {
+	return c.merged[branch], nil
+}
+
+type fakeRepository struct {
+	gitprovider.UserRepository
+	pullRequests gitprovider.PullRequestClient
+}
+
+func (r fakeRepository) PullRequests() gitprovider.PullRequestClient {
+	return r.pullRequests
+}
+
+type fakeProvider struct {
+	gitprovider.Client
+	raw interface{}
+}
+
+func (p fakeProvider) Raw() interface{} {
+	return p.raw
+}
+
+func (p fakeProvider) ProviderID() gitprovider.ProviderID {
+	return "fake"
+}
+
+// newPullRequestBootstrapper returns a GitProviderBootstrapper with a
+// pending pull request, of which the components have been committed to
+// the pull request branch.
+func newPullRequestBootstrapper(t *testing.T) (*GitProviderBootstrapper, string) {
+	t.Helper()
+	pb, remote := newRollbackBootstrapper(t)
+	pb.rollbackOnFailure = false
+	commitAndPush(t, pb, &manifestgen.Manifest{Path: "README.md", Content: "fleet"})
+	pb.pushed = false
+
+	b := &GitProviderBootstrapper{
+		PlainGitBootstrapper: pb,
+		owner:                "org",
+		repository:           "fleet",
+		pullRequest:          true,
+		provider:             fakeProvider{},
+	}
+	b.pending = &pendingPullRequest{
+		branch:         "flux-bootstrap-1",
+		version:        "v0.17.0",
+		namespace:      "flux-system",
+		componentsPath: "clusters/test/flux-system/gotk-components.yaml",
+	}
+	if err := b.git.SwitchBranch(b.pending.branch); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.writeManifest(&manifestgen.Manifest{Path: b.pending.componentsPath, Content: "---\n"}); err != nil {
+		t.Fatal(err)
+	}
+	committed, err := b.commitManifests("component manifests", "Add Flux component manifests", b.pending.branch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.pending.committed = committed
+	return b, remote
+}
+
+func TestGitProviderBootstrapper_reconcileSyncConfigPullRequest(t *testing.T) {
+	b, remote := newPullRequestBootstrapper(t)
+	prs := &fakePullRequests{}
+	options := sync.MakeDefaultOptions()
+	options.URL = remote
+	options.TargetPath = "clusters/test"
+
+	// The deploy key is only registered once the pull request is merged
+	var deployKeys int
+	b.postGenerateSecret = append(b.postGenerateSecret, func(context.Context, corev1.Secret, sourcesecret.Options) error {
+		deployKeys++
+		return nil
+	})
+	b.pending.secret = &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "flux-system", Namespace: "flux-system"}}
+	secrets := []tokenSecret{{
+		description: "webhook token secret",
+		secret: corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "flux-system-webhook-token", Namespace: "flux-system"},
+			StringData: map[string]string{webhookTokenSecretKey: "token"},
+		},
+	}}
+
+	err := b.reconcileSyncConfigPullRequest(context.TODO(), fakeRepository{pullRequests: prs}, options, secrets)
+	if !errors.Is(err, ErrPullRequestPending) {
+		t.Fatalf("reconcileSyncConfigPullRequest() error = %v, want %v", err, ErrPullRequestPending)
+	}
+	if want := []string{"flux-bootstrap-1:main"}; len(prs.created) != 1 || prs.created[0] != want[0] {
+		t.Errorf("created pull requests = %v, want %v", prs.created, want)
+	}
+
+	// The branch is pushed, while the base branch is left untouched
+	r, err := gogitv5.PlainOpen(remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := r.Reference(plumbing.NewBranchReferenceName(b.pending.branch), true)
+	if err != nil {
+		t.Fatalf("expected branch %q to be pushed: %v", b.pending.branch, err)
+	}
+	head, err := b.git.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.Hash().String() != head {
+		t.Errorf("remote %s = %s, want %s", b.pending.branch, ref.Hash(), head)
+	}
+	main, err := r.Reference(plumbing.NewBranchReferenceName("main"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if main.Hash() == ref.Hash() {
+		t.Error("expected main not to contain the pull request commits")
+	}
+	if b.pushed {
+		t.Error("expected the base branch not to be marked as pushed")
+	}
+
+	// The cluster and the deploy keys are left untouched
+	var clusterSecrets corev1.SecretList
+	if err := b.kube.List(context.TODO(), &clusterSecrets); err != nil {
+		t.Fatal(err)
+	}
+	if len(clusterSecrets.Items) != 0 {
+		t.Errorf("expected no secrets to be applied, got %d", len(clusterSecrets.Items))
+	}
+	if deployKeys != 0 {
+		t.Errorf("expected no deploy key to be registered, got %d", deployKeys)
+	}
+	if len(b.pending.tokenSecrets) != 1 {
+		t.Errorf("expected the token secret to be pending, got %v", b.pending.tokenSecrets)
+	}
+}
+
+func TestGitProviderBootstrapper_waitForPullRequestMerge(t *testing.T) {
+	b, remote := newPullRequestBootstrapper(t)
+	prs := &fakePullRequests{merged: map[string]bool{b.pending.branch: true}}
+
+	if err := b.waitForPullRequestMerge(context.TODO(), fakeRepository{pullRequests: prs}); err != nil {
+		t.Fatalf("waitForPullRequestMerge() error = %v", err)
+	}
+
+	// The worktree is switched back to the base branch
+	r, err := gogitv5.PlainOpen(remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	main, err := r.Reference(plumbing.NewBranchReferenceName("main"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head, err := b.git.Head(); err != nil || head != main.Hash().String() {
+		t.Errorf("Head() = %s, %v, want %s", head, err, main.Hash())
+	}
+}
+
+func TestGitProviderBootstrapper_waitForPullRequestMergeTimeout(t *testing.T) {
+	b, _ := newPullRequestBootstrapper(t)
+	prs := &fakePullRequests{}
+
+	interval := pullRequestPollInterval
+	pullRequestPollInterval = time.Millisecond
+	defer func() { pullRequestPollInterval = interval }()
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 20*time.Millisecond)
+	defer cancel()
+	err := b.waitForPullRequestMerge(ctx, fakeRepository{pullRequests: prs})
+	if err == nil || !strings.Contains(err.Error(), "failed to wait for pull request to be merged") {
+		t.Fatalf("waitForPullRequestMerge() error = %v, expected timeout", err)
+	}
+}
+
+func TestGitProviderBootstrapper_pullRequestMergedGitHub(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/org/fleet/pulls", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("head") == "org:merged" {
+			w.Write([]byte(`[{"number": 1, "merged_at": "2021-08-01T00:00:00Z"}]`))
+			return
+		}
+		w.Write([]byte(`[{"number": 2}]`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := github.NewClient(nil)
+	c.BaseURL, _ = url.Parse(server.URL + "/")
+	b := &GitProviderBootstrapper{
+		PlainGitBootstrapper: &PlainGitBootstrapper{logger: nopLogger{}},
+		owner:                "org",
+		repository:           "fleet",
+		provider:             fakeProvider{raw: c},
+	}
+	repo := fakeRepository{}
+
+	if merged, err := b.pullRequestMerged(context.TODO(), repo, "merged"); err != nil || !merged {
+		t.Errorf("pullRequestMerged() = %v, %v, want merged", merged, err)
+	}
+	if merged, err := b.pullRequestMerged(context.TODO(), repo, "closed"); err != nil || merged {
+		t.Errorf("pullRequestMerged() = %v, %v, want not merged", merged, err)
+	}
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fluxcd/flux2/pkg/manifestgen/sync"
+)
+
+// recordOriginal records the current content of the file at the given
+// path relative to the Git repository, unless it has been recorded
+// before.
+func (b *PlainGitBootstrapper) recordOriginal(path string) error {
+	if _, ok := b.originals[path]; ok {
+		return nil
+	}
+	if b.originals == nil {
+		b.originals = map[string]*string{}
+	}
+	content, err := os.ReadFile(filepath.Join(b.git.Path(), path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			b.originals[path] = nil
+			return nil
+		}
+		return err
+	}
+	s := string(content)
+	b.originals[path] = &s
+	return nil
+}
+
+// Rollback reverts the manifests written by the bootstrapper to their
+// original content in a new commit, pushes it, and waits for the
+// Kustomization to become healthy again. It does nothing unless
+// configured to roll back on failure and changes have been pushed.
+//
+// An error is returned if there was no previous bootstrap to roll back
+// to, as removing the manifests would leave the cluster unmanaged.
+func (b *PlainGitBootstrapper) Rollback(ctx context.Context, options sync.Options, pollInterval, timeout time.Duration) error {
+	if !b.rollbackOnFailure || !b.pushed {
+		return nil
+	}
+
+	var paths []string
+	var previous bool
+	for path, content := range b.originals {
+		paths = append(paths, path)
+		previous = previous || content != nil
+	}
+	if !previous {
+		b.logger.Failuref("no previous revision of the manifests to roll back to")
+		return fmt.Errorf("no previous revision to roll back to")
+	}
+	sort.Strings(paths)
+
+	b.logger.Actionf("rolling back manifests on branch %q", b.branch)
+	for _, path := range paths {
+		content := b.originals[path]
+		if content == nil {
+			if err := os.Remove(filepath.Join(b.git.Path(), path)); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove manifest %q: %w", path, err)
+			}
+			continue
+		}
+		if err := b.git.Write(path, strings.NewReader(*content)); err != nil {
+			return fmt.Errorf("failed to restore manifest %q: %w", path, err)
+		}
+	}
+
+	committed, err := b.commitManifests("reverted manifests", "Revert Flux bootstrap changes", b.branch)
+	if err != nil {
+		return err
+	}
+	if committed {
+		b.logger.Actionf("pushing reverted manifests to %q", b.url)
+		if err = b.git.Push(ctx, b.caBundle); err != nil {
+			return fmt.Errorf("failed to push reverted manifests: %w", err)
+		}
+	}
+
+	if err = b.ReportKustomizationHealth(ctx, options, pollInterval, timeout); err != nil {
+		return fmt.Errorf("previous revision did not become healthy: %w", err)
+	}
+	b.logger.Successf("rolled back to the previous revision")
+	return nil
+}
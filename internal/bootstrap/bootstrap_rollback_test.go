@@ -0,0 +1,159 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gogitv5 "github.com/go-git/go-git/v5"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluxcd/flux2/internal/bootstrap/git"
+	"github.com/fluxcd/flux2/internal/bootstrap/git/gogit"
+	"github.com/fluxcd/flux2/internal/utils"
+	"github.com/fluxcd/flux2/pkg/manifestgen"
+	"github.com/fluxcd/flux2/pkg/manifestgen/sync"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Actionf(string, ...interface{})   {}
+func (nopLogger) Generatef(string, ...interface{}) {}
+func (nopLogger) Waitingf(string, ...interface{})  {}
+func (nopLogger) Successf(string, ...interface{})  {}
+func (nopLogger) Warningf(string, ...interface{})  {}
+func (nopLogger) Failuref(string, ...interface{})  {}
+
+func newRollbackBootstrapper(t *testing.T) (*PlainGitBootstrapper, string) {
+	t.Helper()
+	remote := t.TempDir()
+	if _, err := gogitv5.PlainInit(remote, true); err != nil {
+		t.Fatal(err)
+	}
+	g := gogit.New(t.TempDir(), nil)
+	if _, err := g.Clone(context.TODO(), remote, "main", nil); err != nil {
+		t.Fatal(err)
+	}
+	return &PlainGitBootstrapper{
+		url:               remote,
+		branch:            "main",
+		author:            git.Author{Name: "Flux"},
+		rollbackOnFailure: true,
+		git:               g,
+		kube:              fake.NewClientBuilder().WithScheme(utils.NewScheme()).Build(),
+		logger:            nopLogger{},
+	}, remote
+}
+
+func commitAndPush(t *testing.T, b *PlainGitBootstrapper, manifests ...*manifestgen.Manifest) {
+	t.Helper()
+	for _, m := range manifests {
+		if err := b.writeManifest(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := b.commitManifests("manifests", "Update manifests", b.branch); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.git.Push(context.TODO(), nil); err != nil {
+		t.Fatal(err)
+	}
+	b.pushed = true
+}
+
+func TestPlainGitBootstrapper_Rollback(t *testing.T) {
+	b, remote := newRollbackBootstrapper(t)
+
+	// Previous bootstrap, which should not be rolled back
+	commitAndPush(t, b, &manifestgen.Manifest{Path: "flux-system/gotk-sync.yaml", Content: "previous"})
+	b.originals = nil
+	previous, err := b.git.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commitAndPush(t, b,
+		&manifestgen.Manifest{Path: "flux-system/gotk-sync.yaml", Content: "new"},
+		&manifestgen.Manifest{Path: "flux-system/gotk-components.yaml", Content: "new"},
+	)
+
+	// The health check fails as there is no Kustomization on the cluster
+	err = b.Rollback(context.TODO(), sync.Options{Name: "flux-system", Namespace: "flux-system", Branch: "main"}, time.Millisecond, 10*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "previous revision did not become healthy") {
+		t.Fatalf("Rollback() error = %v, expected health check failure", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(b.git.Path(), "flux-system/gotk-sync.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "previous" {
+		t.Errorf("gotk-sync.yaml = %q, want %q", content, "previous")
+	}
+	if _, err := os.Stat(filepath.Join(b.git.Path(), "flux-system/gotk-components.yaml")); !os.IsNotExist(err) {
+		t.Errorf("gotk-components.yaml was not removed: %v", err)
+	}
+	if clean, err := b.git.Status(); err != nil || !clean {
+		t.Errorf("Status() = %v, %v, expected clean worktree", clean, err)
+	}
+
+	// The revert is a new commit on top of the pushed ones
+	head, err := b.git.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head == previous {
+		t.Error("expected a new revert commit")
+	}
+	r, err := gogitv5.PlainOpen(remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := r.Reference("refs/heads/main", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.Hash().String() != head {
+		t.Errorf("remote main = %s, want %s", ref.Hash(), head)
+	}
+}
+
+func TestPlainGitBootstrapper_RollbackWithoutPrevious(t *testing.T) {
+	b, _ := newRollbackBootstrapper(t)
+	commitAndPush(t, b, &manifestgen.Manifest{Path: "flux-system/gotk-sync.yaml", Content: "new"})
+	head, err := b.git.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Rollback(context.TODO(), sync.Options{}, time.Millisecond, time.Millisecond); err == nil {
+		t.Fatal("Rollback() expected error without previous revision")
+	}
+	if h, _ := b.git.Head(); h != head {
+		t.Errorf("Head() = %s, want %s", h, head)
+	}
+
+	b.rollbackOnFailure = false
+	if err := b.Rollback(context.TODO(), sync.Options{}, time.Millisecond, time.Millisecond); err != nil {
+		t.Errorf("Rollback() error = %v, expected no-op when not enabled", err)
+	}
+}
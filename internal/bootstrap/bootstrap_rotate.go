@@ -0,0 +1,172 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/fluxcd/go-git-providers/gitprovider"
+	"github.com/fluxcd/pkg/apis/meta"
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta1"
+
+	"github.com/fluxcd/flux2/pkg/manifestgen/sourcesecret"
+	"github.com/fluxcd/flux2/pkg/manifestgen/sync"
+)
+
+// RotateDeployKey replaces the deploy key of the source secret with a
+// newly generated key pair. The new public key is registered with the
+// provider next to the old one before the secret is updated, and the
+// old keys are only deleted once the GitRepository has reconciled with
+// the new key. If the GitRepository fails to do so, the secret is
+// restored and the new key is deleted again.
+func (b *GitProviderBootstrapper) RotateDeployKey(ctx context.Context, options sourcesecret.Options, syncOptions sync.Options, pollInterval, timeout time.Duration) error {
+	secretKey := client.ObjectKey{Name: options.Name, Namespace: options.Namespace}
+	var existing corev1.Secret
+	if err := b.kube.Get(ctx, secretKey, &existing); err != nil {
+		if apierr.IsNotFound(err) {
+			return fmt.Errorf("source secret %q not found, run bootstrap first", secretKey)
+		}
+		return fmt.Errorf("failed to get source secret %q: %w", secretKey, err)
+	}
+	if _, ok := existing.Data[sourcesecret.PrivateKeySecretKey]; !ok {
+		return fmt.Errorf("source secret %q does not contain an SSH private key", secretKey)
+	}
+
+	b.logger.Actionf("generating new deploy key")
+	manifest, err := sourcesecret.Generate(options)
+	if err != nil {
+		return err
+	}
+	var secret corev1.Secret
+	if err := yaml.Unmarshal([]byte(manifest.Content), &secret); err != nil {
+		return fmt.Errorf("failed to unmarshal generated source secret manifest: %w", err)
+	}
+	ppk, ok := secret.StringData[sourcesecret.PublicKeySecretKey]
+	if !ok {
+		return fmt.Errorf("generated source secret does not contain a public key")
+	}
+	b.logger.Successf("public key: %s", strings.TrimSpace(ppk))
+
+	repo, err := b.getRepository(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get Git repository: %w", err)
+	}
+	name := deployKeyName(options.Namespace, b.branch, options.Name, options.TargetPath)
+	key, err := repo.DeployKeys().Create(ctx, newDeployKeyInfo(name, ppk, b.readWriteKey))
+	if err != nil {
+		return fmt.Errorf("failed to add deploy key %q: %w", name, err)
+	}
+	b.logger.Successf("added deploy key %q to %q", name, repo.Repository().String())
+
+	b.logger.Actionf("updating source secret %q", secretKey)
+	if err = reconcileSecret(ctx, b.kube, secret); err != nil {
+		return fmt.Errorf("failed to update source secret %q: %w", secretKey, err)
+	}
+
+	if err = b.waitForGitRepository(ctx, syncOptions, pollInterval, timeout); err != nil {
+		b.logger.Failuref("GitRepository did not reconcile with the new deploy key, restoring the previous key")
+		restore := corev1.Secret{ObjectMeta: secret.ObjectMeta, StringData: map[string]string{}}
+		for k, v := range existing.Data {
+			restore.StringData[k] = string(v)
+		}
+		if rErr := reconcileSecret(ctx, b.kube, restore); rErr != nil {
+			return fmt.Errorf("%w, and restoring source secret failed: %s", err, rErr)
+		}
+		if dErr := key.Delete(ctx); dErr != nil {
+			return fmt.Errorf("%w, and deleting new deploy key failed: %s", err, dErr)
+		}
+		return err
+	}
+
+	keys, err := repo.DeployKeys().List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list deploy keys: %w", err)
+	}
+	for _, k := range staleDeployKeys(keys, name, []byte(ppk)) {
+		if err = k.Delete(ctx); err != nil {
+			return fmt.Errorf("failed to delete previous deploy key %q: %w", name, err)
+		}
+		b.logger.Successf("deleted previous deploy key %q", name)
+	}
+	return nil
+}
+
+// waitForGitRepository requests a reconciliation of the GitRepository
+// and waits for it to be handled successfully.
+func (b *GitProviderBootstrapper) waitForGitRepository(ctx context.Context, options sync.Options, pollInterval, timeout time.Duration) error {
+	objKey := client.ObjectKey{Name: options.Name, Namespace: options.Namespace}
+	requestedAt := time.Now().Format(time.RFC3339Nano)
+	var repository sourcev1.GitRepository
+	if err := b.kube.Get(ctx, objKey, &repository); err != nil {
+		return fmt.Errorf("failed to get GitRepository %q: %w", objKey, err)
+	}
+	patch := client.MergeFrom(repository.DeepCopy())
+	annotations := repository.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[meta.ReconcileRequestAnnotation] = requestedAt
+	repository.SetAnnotations(annotations)
+	if err := b.kube.Patch(ctx, &repository, patch); err != nil {
+		return fmt.Errorf("failed to request reconciliation of GitRepository %q: %w", objKey, err)
+	}
+
+	b.logger.Waitingf("waiting for GitRepository %q to be reconciled", objKey.String())
+	if err := wait.PollImmediate(pollInterval, timeout, gitRepositoryReconciled(
+		ctx, b.kube, objKey, &repository, requestedAt),
+	); err != nil {
+		b.logger.Failuref(err.Error())
+		return err
+	}
+	b.logger.Successf("GitRepository reconciled successfully")
+	return nil
+}
+
+// staleDeployKeys returns the deploy keys with the given name which do
+// not match the given public key.
+func staleDeployKeys(keys []gitprovider.DeployKey, name string, publicKey []byte) []gitprovider.DeployKey {
+	var stale []gitprovider.DeployKey
+	for _, k := range keys {
+		info := k.Get()
+		if info.Name != name || sameAuthorizedKey(info.Key, publicKey) {
+			continue
+		}
+		stale = append(stale, k)
+	}
+	return stale
+}
+
+// sameAuthorizedKey compares the type and data of the given public keys
+// in authorized_keys format, ignoring any comments which providers may
+// strip.
+func sameAuthorizedKey(a, b []byte) bool {
+	fa, fb := bytes.Fields(a), bytes.Fields(b)
+	if len(fa) < 2 || len(fb) < 2 {
+		return bytes.Equal(bytes.TrimSpace(a), bytes.TrimSpace(b))
+	}
+	return bytes.Equal(fa[0], fb[0]) && bytes.Equal(fa[1], fb[1])
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluxcd/go-git-providers/gitprovider"
+	"github.com/fluxcd/pkg/apis/meta"
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta1"
+
+	"github.com/fluxcd/flux2/internal/utils"
+	"github.com/fluxcd/flux2/pkg/manifestgen/sync"
+)
+
+type fakeDeployKey struct {
+	gitprovider.DeployKey
+	info gitprovider.DeployKeyInfo
+}
+
+func (k fakeDeployKey) Get() gitprovider.DeployKeyInfo {
+	return k.info
+}
+
+func TestStaleDeployKeys(t *testing.T) {
+	newKey := "ssh-ed25519 AAAAnew flux"
+	keys := []gitprovider.DeployKey{
+		fakeDeployKey{info: gitprovider.DeployKeyInfo{Name: "flux-system-main", Key: []byte("ssh-ed25519 AAAAold")}},
+		fakeDeployKey{info: gitprovider.DeployKeyInfo{Name: "flux-system-main", Key: []byte("ssh-ed25519 AAAAnew")}},
+		fakeDeployKey{info: gitprovider.DeployKeyInfo{Name: "other", Key: []byte("ssh-ed25519 AAAAother")}},
+	}
+	stale := staleDeployKeys(keys, "flux-system-main", []byte(newKey))
+	if len(stale) != 1 || string(stale[0].Get().Key) != "ssh-ed25519 AAAAold" {
+		t.Errorf("staleDeployKeys() = %v, expected only the old key", stale)
+	}
+}
+
+func TestGitProviderBootstrapper_waitForGitRepository(t *testing.T) {
+	objKey := client.ObjectKey{Name: "flux-system", Namespace: "flux-system"}
+	kube := fake.NewClientBuilder().WithScheme(utils.NewScheme()).WithObjects(&sourcev1.GitRepository{
+		ObjectMeta: metav1.ObjectMeta{Name: objKey.Name, Namespace: objKey.Namespace},
+	}).Build()
+	b := &GitProviderBootstrapper{PlainGitBootstrapper: &PlainGitBootstrapper{kube: kube, logger: nopLogger{}}}
+	options := sync.Options{Name: objKey.Name, Namespace: objKey.Namespace}
+	ctx := context.TODO()
+
+	// The request is not handled without a controller
+	if err := b.waitForGitRepository(ctx, options, time.Millisecond, 10*time.Millisecond); err == nil {
+		t.Fatal("waitForGitRepository() expected timeout error")
+	}
+
+	var repository sourcev1.GitRepository
+	if err := kube.Get(ctx, objKey, &repository); err != nil {
+		t.Fatal(err)
+	}
+	requestedAt := repository.GetAnnotations()[meta.ReconcileRequestAnnotation]
+	if requestedAt == "" {
+		t.Fatal("expected reconcile request annotation")
+	}
+
+	repository.Status.ObservedGeneration = repository.Generation
+	repository.Status.LastHandledReconcileAt = requestedAt
+	apimeta.SetStatusCondition(&repository.Status.Conditions, metav1.Condition{
+		Type:    meta.ReadyCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  "GitOperationFailed",
+		Message: "unable to clone",
+	})
+	if err := kube.Update(ctx, &repository); err != nil {
+		t.Fatal(err)
+	}
+	ok, err := gitRepositoryReconciled(ctx, kube, objKey, &repository, requestedAt)()
+	if ok || err == nil || err.Error() != "unable to clone" {
+		t.Errorf("gitRepositoryReconciled() = %v, %v, expected Ready condition error", ok, err)
+	}
+
+	apimeta.SetStatusCondition(&repository.Status.Conditions, metav1.Condition{
+		Type:   meta.ReadyCondition,
+		Status: metav1.ConditionTrue,
+		Reason: "GitOperationSucceed",
+	})
+	if err := kube.Update(ctx, &repository); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := gitRepositoryReconciled(ctx, kube, objKey, &repository, requestedAt)(); !ok || err != nil {
+		t.Errorf("gitRepositoryReconciled() = %v, %v, expected reconciled", ok, err)
+	}
+	if ok, _ := gitRepositoryReconciled(ctx, kube, objKey, &repository, "other")(); ok {
+		t.Error("gitRepositoryReconciled() expected false for unhandled request")
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/fluxcd/flux2/internal/bootstrap/git"
+	"github.com/fluxcd/flux2/pkg/manifestgen/sync"
+)
+
+// singleCommitPushRetries is the number of times the push of the single
+// commit is retried after being rejected as a non-fast-forward update.
+const singleCommitPushRetries = 3
+
+// pushSingleCommit commits the component and sync manifests in a single
+// commit, and pushes it. When the push is rejected as a non-fast-forward
+// update, the branch is reset to the remote, the manifests are written
+// again on top of it, and the push is retried.
+func (b *PlainGitBootstrapper) pushSingleCommit(ctx context.Context, options sync.Options) error {
+	message := fmt.Sprintf("Add Flux %s component and sync manifests", b.componentsVersion)
+	for i := 0; ; i++ {
+		committed, err := b.commitManifests("component and sync manifests", message, b.branch)
+		if err != nil || !committed {
+			return err
+		}
+
+		b.logger.Actionf("pushing component and sync manifests to %q", b.url)
+		err = b.git.Push(ctx, b.caBundle)
+		if err == nil {
+			b.pushed = true
+			return nil
+		}
+		if !errors.Is(err, git.ErrNonFastForward) || i >= singleCommitPushRetries {
+			return fmt.Errorf("failed to push manifests: %w", err)
+		}
+
+		b.logger.Warningf("branch %q has been updated on the remote, writing manifests on top of it", b.branch)
+		if err = b.git.ResetToRemote(ctx, b.caBundle); err != nil {
+			return fmt.Errorf("failed to reset to remote branch %q: %w", b.branch, err)
+		}
+		// The original manifests are those on the remote branch now
+		b.originals = nil
+		if b.components != nil {
+			if err = b.writeManifest(b.components); err != nil {
+				return err
+			}
+		}
+		if _, _, err = b.writeSyncConfig(options); err != nil {
+			return err
+		}
+	}
+}
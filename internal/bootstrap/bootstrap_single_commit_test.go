@@ -0,0 +1,161 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gogitv5 "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+
+	"github.com/fluxcd/flux2/internal/bootstrap/git"
+	"github.com/fluxcd/flux2/internal/bootstrap/git/gogit"
+	"github.com/fluxcd/flux2/pkg/manifestgen"
+	"github.com/fluxcd/flux2/pkg/manifestgen/sync"
+)
+
+func TestPlainGitBootstrapper_pushSingleCommit(t *testing.T) {
+	b, remote := newRollbackBootstrapper(t)
+	ctx := context.TODO()
+	b.singleCommit = true
+	b.componentsVersion = "v0.0.0"
+
+	commitAndPush(t, b, &manifestgen.Manifest{Path: "README.md", Content: "initial"})
+	b.originals = nil
+
+	// Concurrent change to the branch after the bootstrapper cloned it
+	other := gogit.New(t.TempDir(), nil)
+	if _, err := other.Clone(ctx, remote, "main", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Write("README.md", strings.NewReader("concurrent")); err != nil {
+		t.Fatal(err)
+	}
+	concurrent, err := other.Commit(git.Commit{Author: git.Author{Name: "Other"}, Message: "Update README.md"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Push(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	b.components = &manifestgen.Manifest{Path: "clusters/test/flux-system/gotk-components.yaml", Content: "components"}
+	if err := b.writeManifest(b.components); err != nil {
+		t.Fatal(err)
+	}
+	options := sync.MakeDefaultOptions()
+	options.URL = remote
+	options.TargetPath = "clusters/test"
+	if _, _, err := b.writeSyncConfig(options); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.pushSingleCommit(ctx, options); err != nil {
+		t.Fatalf("pushSingleCommit() error = %v", err)
+	}
+	if !b.pushed {
+		t.Error("expected pushed to be true")
+	}
+
+	r, err := gogitv5.PlainOpen(remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := r.Reference(plumbing.NewBranchReferenceName("main"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := r.CommitObject(ref.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Add Flux v0.0.0 component and sync manifests"; commit.Message != want {
+		t.Errorf("commit message = %q, want %q", commit.Message, want)
+	}
+	if commit.NumParents() != 1 || commit.ParentHashes[0].String() != concurrent {
+		t.Errorf("commit parents = %v, want %s", commit.ParentHashes, concurrent)
+	}
+	stats, err := commit.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 3 {
+		t.Errorf("expected the components, sync and kustomization manifests in a single commit, got %v", stats)
+	}
+
+	// The concurrent change is kept
+	content, err := os.ReadFile(filepath.Join(b.git.Path(), "README.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "concurrent" {
+		t.Errorf("README.md = %q, want %q", content, "concurrent")
+	}
+}
+
+func TestPlainGitBootstrapper_applyAfterPush(t *testing.T) {
+	b, remote := newRollbackBootstrapper(t)
+	ctx := context.TODO()
+	b.singleCommit = true
+	b.componentsVersion = "v0.0.0"
+
+	var applied []string
+	for _, name := range []string{"components", "source secret"} {
+		name := name
+		if err := b.applyAfterPush(ctx, func(context.Context) error {
+			applied = append(applied, name)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(applied) != 0 {
+		t.Fatalf("expected the changes to be held back until pushed, got %v applied", applied)
+	}
+
+	// The push of the single commit fails
+	if err := os.RemoveAll(remote); err != nil {
+		t.Fatal(err)
+	}
+	b.components = &manifestgen.Manifest{Path: "clusters/test/flux-system/gotk-components.yaml", Content: "components"}
+	if err := b.writeManifest(b.components); err != nil {
+		t.Fatal(err)
+	}
+	options := sync.MakeDefaultOptions()
+	options.URL = remote
+	options.TargetPath = "clusters/test"
+	if err := b.ReconcileSyncConfig(ctx, options); err == nil {
+		t.Fatal("expected push error")
+	}
+	if len(applied) != 0 {
+		t.Errorf("expected the cluster to be left untouched after a failed push, got %v applied", applied)
+	}
+
+	b.singleCommit = false
+	if err := b.applyAfterPush(ctx, func(context.Context) error {
+		applied = append(applied, "sync")
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 1 || applied[0] != "sync" {
+		t.Errorf("expected the change to be applied right away, got %v", applied)
+	}
+}
@@ -0,0 +1,264 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/xanzy/go-gitlab"
+	corev1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+
+	notificationv1 "github.com/fluxcd/notification-controller/api/v1beta1"
+
+	"github.com/fluxcd/flux2/pkg/manifestgen/receiver"
+	"github.com/fluxcd/flux2/pkg/manifestgen/sync"
+)
+
+const webhookTokenSecretKey = "token"
+
+func WithWebhookReceiver(externalURL string) GitProviderOption {
+	return webhookReceiverOption(externalURL)
+}
+
+type webhookReceiverOption string
+
+func (o webhookReceiverOption) applyGitProvider(b *GitProviderBootstrapper) {
+	b.webhookReceiverURL = string(o)
+}
+
+// receiverOptions returns the receiver.Options for the Receiver of the
+// GitRepository configured by the given sync.Options.
+func (b *GitProviderBootstrapper) receiverOptions(options sync.Options) (receiver.Options, error) {
+	opts := receiver.MakeDefaultOptions()
+	opts.Name = options.Name
+	opts.Namespace = options.Namespace
+	opts.Secret = options.Name + "-webhook-token"
+	opts.SourceName = options.Name
+	opts.TargetPath = options.TargetPath
+	notificationType, err := b.notificationType()
+	if err != nil {
+		return opts, err
+	}
+	opts.Type = notificationType
+	if notificationType == notificationv1.GitLabReceiver {
+		opts.Events = []string{"Push Hook"}
+	} else {
+		opts.Events = []string{"ping", "push"}
+	}
+	return opts, nil
+}
+
+// notificationType returns the type of the notification-controller
+// Receiver and Provider for the Git provider, which are the same for
+// both kinds.
+func (b *GitProviderBootstrapper) notificationType() (string, error) {
+	switch b.provider.Raw().(type) {
+	case *github.Client:
+		return notificationv1.GitHubReceiver, nil
+	case *gitlab.Client:
+		return notificationv1.GitLabReceiver, nil
+	}
+	return "", fmt.Errorf("notifications are not supported for Git provider %q", b.provider.ProviderID())
+}
+
+// prepareWebhookReceiver generates the Receiver manifest to be written
+// next to the sync manifests, and returns the webhook token. If the
+// secret holding the token does not exist yet, a new token is generated
+// and the secret is returned, to be applied to the cluster with the sync
+// manifests. The token is not committed to Git.
+func (b *GitProviderBootstrapper) prepareWebhookReceiver(ctx context.Context, options receiver.Options) (string, *tokenSecret, error) {
+	manifest, err := receiver.Generate(options)
+	if err != nil {
+		return "", nil, fmt.Errorf("receiver manifest generation failed: %w", err)
+	}
+	b.syncExtraManifests = append(b.syncExtraManifests, manifest)
+
+	secretKey := client.ObjectKey{Name: options.Secret, Namespace: options.Namespace}
+	var existing corev1.Secret
+	err = b.kube.Get(ctx, secretKey, &existing)
+	if err == nil {
+		if token := string(existing.Data[webhookTokenSecretKey]); token != "" {
+			return token, nil, nil
+		}
+	} else if !apierr.IsNotFound(err) {
+		return "", nil, fmt.Errorf("failed to get webhook token secret %q: %w", secretKey, err)
+	}
+
+	token, err := generateWebhookToken()
+	if err != nil {
+		return "", nil, err
+	}
+	return token, &tokenSecret{
+		description: "webhook token secret",
+		secret: corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      options.Secret,
+				Namespace: options.Namespace,
+			},
+			StringData: map[string]string{webhookTokenSecretKey: token},
+		},
+	}, nil
+}
+
+// reconcileWebhook waits for the Receiver to be assigned its URL by the
+// notification-controller, and registers it as push webhook of the
+// repository at the configured external URL.
+func (b *GitProviderBootstrapper) reconcileWebhook(ctx context.Context, options receiver.Options, token string) error {
+	if b.dryRun {
+		b.plan.add("repository webhook", b.owner+"/"+b.repository, PlanActionCreate, "")
+		return nil
+	}
+
+	objKey := client.ObjectKey{Name: options.Name, Namespace: options.Namespace}
+	b.logger.Waitingf("waiting for Receiver %q to be assigned a URL", objKey.String())
+	var r notificationv1.Receiver
+	if err := wait.PollImmediateUntil(2*time.Second, func() (bool, error) {
+		if err := b.kube.Get(ctx, objKey, &r); err != nil {
+			if apierr.IsNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return r.Status.URL != "", nil
+	}, ctx.Done()); err != nil {
+		return fmt.Errorf("failed to get URL of Receiver %q: %w", objKey.String(), err)
+	}
+
+	url := strings.TrimSuffix(b.webhookReceiverURL, "/") + r.Status.URL
+	b.logger.Actionf("registering webhook %q", url)
+	var err error
+	switch c := b.provider.Raw().(type) {
+	case *github.Client:
+		err = reconcileGitHubWebhook(ctx, c, b.owner, b.repository, url, token)
+	case *gitlab.Client:
+		err = reconcileGitLabWebhook(ctx, c, b.owner+"/"+b.repository, url, token)
+	default:
+		err = fmt.Errorf("webhooks are not supported for Git provider %q", b.provider.ProviderID())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to register webhook: %w", err)
+	}
+	b.logger.Successf("registered webhook for %q", b.owner+"/"+b.repository)
+	return nil
+}
+
+// reconcileGitHubWebhook creates the push webhook with the given URL,
+// or updates it if it exists.
+func reconcileGitHubWebhook(ctx context.Context, c *github.Client, owner, repository, url, token string) error {
+	hook := &github.Hook{
+		Config: map[string]interface{}{
+			"url":          url,
+			"content_type": "json",
+			"secret":       token,
+		},
+		Events: []string{"push"},
+		Active: github.Bool(true),
+	}
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		hooks, resp, err := c.Repositories.ListHooks(ctx, owner, repository, opts)
+		if err != nil {
+			return err
+		}
+		for _, h := range hooks {
+			if u, ok := h.Config["url"].(string); ok && u == url {
+				_, _, err = c.Repositories.EditHook(ctx, owner, repository, h.GetID(), hook)
+				return err
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	_, _, err := c.Repositories.CreateHook(ctx, owner, repository, hook)
+	return err
+}
+
+// reconcileGitLabWebhook creates the push webhook with the given URL,
+// or updates it if it exists.
+func reconcileGitLabWebhook(ctx context.Context, c *gitlab.Client, project, url, token string) error {
+	opts := &gitlab.ListProjectHooksOptions{PerPage: 100}
+	for {
+		hooks, resp, err := c.Projects.ListProjectHooks(project, opts, gitlab.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		for _, h := range hooks {
+			if h.URL == url {
+				_, _, err = c.Projects.EditProjectHook(project, h.ID, &gitlab.EditProjectHookOptions{
+					URL:        gitlab.String(url),
+					PushEvents: gitlab.Bool(true),
+					Token:      gitlab.String(token),
+				}, gitlab.WithContext(ctx))
+				return err
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	_, _, err := c.Projects.AddProjectHook(project, &gitlab.AddProjectHookOptions{
+		URL:        gitlab.String(url),
+		PushEvents: gitlab.Bool(true),
+		Token:      gitlab.String(token),
+	}, gitlab.WithContext(ctx))
+	return err
+}
+
+// generateWebhookToken returns a random token for authenticating the
+// webhook requests.
+func generateWebhookToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// addKustomizationResource adds the given resource to the given
+// kustomization.yaml content, unless it is already listed.
+func addKustomizationResource(content, resource string) (string, error) {
+	var kus kustypes.Kustomization
+	if err := yaml.Unmarshal([]byte(content), &kus); err != nil {
+		return "", err
+	}
+	for _, r := range kus.Resources {
+		if r == resource {
+			return content, nil
+		}
+	}
+	kus.Resources = append(kus.Resources, resource)
+	data, err := yaml.Marshal(kus)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
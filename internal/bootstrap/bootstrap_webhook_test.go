@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v32/github"
+)
+
+func TestAddKustomizationResource(t *testing.T) {
+	content := "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n- gotk-components.yaml\n- gotk-sync.yaml\n"
+
+	got, err := addKustomizationResource(content, "gotk-receiver.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n- gotk-components.yaml\n- gotk-sync.yaml\n- gotk-receiver.yaml\n"
+	if got != want {
+		t.Errorf("addKustomizationResource() = %q, want %q", got, want)
+	}
+
+	// Listed resources leave the content untouched
+	if got, err = addKustomizationResource(content, "gotk-sync.yaml"); err != nil || got != content {
+		t.Errorf("addKustomizationResource() = %q, %v, want unchanged content", got, err)
+	}
+}
+
+func TestReconcileGitHubWebhook(t *testing.T) {
+	var hooks []*github.Hook
+	var edits int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/org/repo/hooks", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(hooks)
+		case http.MethodPost:
+			var h github.Hook
+			if err := json.NewDecoder(r.Body).Decode(&h); err != nil {
+				t.Fatal(err)
+			}
+			h.ID = github.Int64(int64(len(hooks) + 1))
+			hooks = append(hooks, &h)
+			json.NewEncoder(w).Encode(h)
+		}
+	})
+	mux.HandleFunc("/repos/org/repo/hooks/1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch {
+			t.Errorf("unexpected %s request", r.Method)
+		}
+		edits++
+		json.NewEncoder(w).Encode(hooks[0])
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := github.NewClient(nil)
+	c.BaseURL, _ = url.Parse(server.URL + "/")
+	ctx := context.TODO()
+	hookURL := "https://flux.example.com/hook/abc"
+
+	if err := reconcileGitHubWebhook(ctx, c, "org", "repo", hookURL, "token"); err != nil {
+		t.Fatal(err)
+	}
+	if len(hooks) != 1 {
+		t.Fatalf("expected 1 webhook, got %d", len(hooks))
+	}
+	if u := hooks[0].Config["url"]; u != hookURL {
+		t.Errorf("webhook url = %v, want %q", u, hookURL)
+	}
+	if s := hooks[0].Config["secret"]; s != "token" {
+		t.Errorf("webhook secret = %v, want %q", s, "token")
+	}
+
+	// The existing webhook is updated
+	if err := reconcileGitHubWebhook(ctx, c, "org", "repo", hookURL, "token"); err != nil {
+		t.Fatal(err)
+	}
+	if len(hooks) != 1 || edits != 1 {
+		t.Errorf("expected the webhook to be updated, got %d webhooks and %d edits", len(hooks), edits)
+	}
+}
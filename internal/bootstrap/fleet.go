@@ -0,0 +1,244 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"context"
+	"io"
+	gosync "sync"
+	"time"
+
+	"github.com/fluxcd/flux2/internal/bootstrap/git"
+	"github.com/fluxcd/flux2/pkg/manifestgen/install"
+	"github.com/fluxcd/flux2/pkg/manifestgen/sourcesecret"
+	"github.com/fluxcd/flux2/pkg/manifestgen/sync"
+)
+
+// FleetCluster holds the Reconciler and options to bootstrap a single
+// cluster of a Fleet with.
+type FleetCluster struct {
+	// Name identifies the cluster in the FleetResult.
+	Name string
+
+	// Reconciler must be constructed with the Git repository returned
+	// by Fleet.Git.
+	Reconciler Reconciler
+
+	InstallOptions install.Options
+	SecretOptions  sourcesecret.Options
+	SyncOptions    sync.Options
+}
+
+// FleetResult is the outcome of bootstrapping a single cluster of a
+// Fleet.
+type FleetResult struct {
+	Name string
+	Err  error
+}
+
+// Fleet bootstraps a set of clusters from a single clone of a shared
+// Git repository. The clusters are bootstrapped concurrently, but the
+// steps writing to the Git repository are run for one cluster at a
+// time, so that the commits of the clusters do not interleave.
+type Fleet struct {
+	git         *lockedGit
+	concurrency int
+
+	// mu serializes the reconciliation steps writing to the Git
+	// repository
+	mu gosync.Mutex
+}
+
+// NewFleet returns a Fleet which bootstraps at most the given number of
+// clusters at once, using the given Git repository as shared clone.
+func NewFleet(g git.Git, concurrency int) *Fleet {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Fleet{
+		git:         &lockedGit{Git: g},
+		concurrency: concurrency,
+	}
+}
+
+// Git returns the shared Git repository the Reconciler of each
+// FleetCluster must be constructed with.
+func (f *Fleet) Git() git.Git {
+	return f.git
+}
+
+// Run runs the bootstrap pipeline for each of the given clusters. Each
+// cluster is given its own context, which expires after the timeout
+// counting from the moment the bootstrap of the cluster starts. It keeps
+// going past the failure of individual clusters, and returns the result
+// of each cluster in the order the clusters were given.
+func (f *Fleet) Run(ctx context.Context, clusters []FleetCluster, manifestsBase string, pollInterval, timeout time.Duration) []FleetResult {
+	results := make([]FleetResult, len(clusters))
+	sem := make(chan struct{}, f.concurrency)
+	var wg gosync.WaitGroup
+	for i := range clusters {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			c := clusters[i]
+			r := &fleetReconciler{Reconciler: c.Reconciler, mu: &f.mu}
+			if l, ok := c.Reconciler.(gitLocker); ok {
+				l.setGitLock(&f.mu)
+				r.gitLocked = true
+			}
+			results[i] = FleetResult{
+				Name: c.Name,
+				Err:  Run(ctx, r, manifestsBase, c.InstallOptions, c.SecretOptions, c.SyncOptions, pollInterval, timeout),
+			}
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+// gitLocker is implemented by the Reconcilers which take the lock of the
+// Fleet themselves, only while writing to the shared Git repository.
+type gitLocker interface {
+	setGitLock(l gosync.Locker)
+}
+
+// fleetReconciler wraps the Reconciler of a FleetCluster to run the
+// steps writing to the shared Git repository while holding the lock of
+// the Fleet. The health reports are run without it, as these make up
+// most of the time a bootstrap takes. The components and sync steps of a
+// gitLocker are run without it as well, as these take the lock only
+// around the Git write, commit and push, and not while applying the
+// manifests to the cluster.
+type fleetReconciler struct {
+	Reconciler
+	mu        *gosync.Mutex
+	gitLocked bool
+}
+
+func (r *fleetReconciler) ReconcileRepository(ctx context.Context) error {
+	rr, ok := r.Reconciler.(RepositoryReconciler)
+	if !ok {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return rr.ReconcileRepository(ctx)
+}
+
+func (r *fleetReconciler) ReconcileComponents(ctx context.Context, manifestsBase string, options install.Options, secretOpts sourcesecret.Options) error {
+	if r.gitLocked {
+		return r.Reconciler.ReconcileComponents(ctx, manifestsBase, options, secretOpts)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.Reconciler.ReconcileComponents(ctx, manifestsBase, options, secretOpts)
+}
+
+func (r *fleetReconciler) ReconcileSyncConfig(ctx context.Context, options sync.Options) error {
+	if r.gitLocked {
+		return r.Reconciler.ReconcileSyncConfig(ctx, options)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.Reconciler.ReconcileSyncConfig(ctx, options)
+}
+
+func (r *fleetReconciler) Rollback(ctx context.Context, options sync.Options, pollInterval, timeout time.Duration) error {
+	rr, ok := r.Reconciler.(RollbackReconciler)
+	if !ok {
+		return nil
+	}
+	// The lock is held while waiting for the previous revision to become
+	// healthy as well, as the revert commit is made in the same call
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return rr.Rollback(ctx, options, pollInterval, timeout)
+}
+
+// lockedGit guards a git.Git shared by the bootstrappers of a Fleet, as
+// the head of the repository is read concurrently to the reconciliation
+// steps of other clusters while reporting the Kustomization health.
+type lockedGit struct {
+	git.Git
+	mu gosync.Mutex
+}
+
+func (g *lockedGit) Init(url, branch string) (bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.Git.Init(url, branch)
+}
+
+func (g *lockedGit) Clone(ctx context.Context, url, branch string, caBundle []byte) (bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.Git.Clone(ctx, url, branch, caBundle)
+}
+
+func (g *lockedGit) Write(path string, reader io.Reader) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.Git.Write(path, reader)
+}
+
+func (g *lockedGit) Commit(message git.Commit, opts ...git.CommitOption) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.Git.Commit(message, opts...)
+}
+
+func (g *lockedGit) Push(ctx context.Context, caBundle []byte) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.Git.Push(ctx, caBundle)
+}
+
+func (g *lockedGit) SwitchBranch(branch string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.Git.SwitchBranch(branch)
+}
+
+func (g *lockedGit) Pull(ctx context.Context, caBundle []byte) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.Git.Pull(ctx, caBundle)
+}
+
+func (g *lockedGit) ResetToRemote(ctx context.Context, caBundle []byte) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.Git.ResetToRemote(ctx, caBundle)
+}
+
+func (g *lockedGit) Status() (bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.Git.Status()
+}
+
+func (g *lockedGit) Head() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.Git.Head()
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	gosync "sync"
+	"testing"
+	"time"
+
+	"github.com/fluxcd/flux2/internal/bootstrap/git/gogit"
+	"github.com/fluxcd/flux2/pkg/manifestgen/install"
+	"github.com/fluxcd/flux2/pkg/manifestgen/sourcesecret"
+	"github.com/fluxcd/flux2/pkg/manifestgen/sync"
+)
+
+// fakeFleetReconciler records the number of clusters reconciling the
+// Git repository and reporting health at the same time.
+type fakeFleetReconciler struct {
+	counts *fleetCounts
+	err    error
+}
+
+type fleetCounts struct {
+	mu                      gosync.Mutex
+	writing, maxWriting     int
+	reporting, maxReporting int
+}
+
+func (c *fleetCounts) enter(n, max *int) {
+	c.mu.Lock()
+	*n++
+	if *n > *max {
+		*max = *n
+	}
+	c.mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	c.mu.Lock()
+	*n--
+	c.mu.Unlock()
+}
+
+func (r fakeFleetReconciler) ReconcileComponents(context.Context, string, install.Options, sourcesecret.Options) error {
+	r.counts.enter(&r.counts.writing, &r.counts.maxWriting)
+	return nil
+}
+
+func (r fakeFleetReconciler) ReconcileSourceSecret(context.Context, sourcesecret.Options) error {
+	return nil
+}
+
+func (r fakeFleetReconciler) ReconcileSyncConfig(context.Context, sync.Options) error {
+	r.counts.enter(&r.counts.writing, &r.counts.maxWriting)
+	return r.err
+}
+
+func (r fakeFleetReconciler) ReportKustomizationHealth(context.Context, sync.Options, time.Duration, time.Duration) error {
+	r.counts.enter(&r.counts.reporting, &r.counts.maxReporting)
+	return nil
+}
+
+func (r fakeFleetReconciler) ReportComponentsHealth(context.Context, install.Options, time.Duration) error {
+	return nil
+}
+
+func TestFleet_Run(t *testing.T) {
+	counts := &fleetCounts{}
+	failure := errors.New("failed to push sync manifests")
+	var clusters []FleetCluster
+	for _, name := range []string{"dev", "staging", "prod-eu", "prod-us"} {
+		r := fakeFleetReconciler{counts: counts}
+		if name == "staging" {
+			r.err = failure
+		}
+		clusters = append(clusters, FleetCluster{Name: name, Reconciler: r})
+	}
+
+	f := NewFleet(gogit.New(t.TempDir(), nil), 2)
+	results := f.Run(context.TODO(), clusters, "", time.Millisecond, time.Second)
+
+	if len(results) != len(clusters) {
+		t.Fatalf("expected %d results, got %d", len(clusters), len(results))
+	}
+	for i, r := range results {
+		if r.Name != clusters[i].Name {
+			t.Errorf("results[%d].Name = %q, want %q", i, r.Name, clusters[i].Name)
+		}
+		if wantErr := r.Name == "staging"; (r.Err != nil) != wantErr {
+			t.Errorf("results[%d].Err = %v, expected error %v", i, r.Err, wantErr)
+		}
+	}
+	if counts.maxWriting != 1 {
+		t.Errorf("expected Git repository to be reconciled for one cluster at a time, got %d", counts.maxWriting)
+	}
+	if counts.maxReporting > 2 {
+		t.Errorf("expected at most 2 clusters at once, got %d", counts.maxReporting)
+	}
+}
+
+// lockingFleetReconciler takes the lock of the Fleet itself while
+// reconciling the components and the sync configuration.
+type lockingFleetReconciler struct {
+	fakeFleetReconciler
+	lock gosync.Locker
+}
+
+func (r *lockingFleetReconciler) setGitLock(l gosync.Locker) {
+	r.lock = l
+}
+
+func (r *lockingFleetReconciler) ReconcileComponents(ctx context.Context, manifestsBase string, options install.Options, secretOpts sourcesecret.Options) error {
+	// Would deadlock if the Fleet held the lock around this step
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.fakeFleetReconciler.ReconcileComponents(ctx, manifestsBase, options, secretOpts)
+}
+
+func (r *lockingFleetReconciler) ReconcileSyncConfig(ctx context.Context, options sync.Options) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.fakeFleetReconciler.ReconcileSyncConfig(ctx, options)
+}
+
+func TestFleet_RunGitLocker(t *testing.T) {
+	counts := &fleetCounts{}
+	var clusters []FleetCluster
+	for _, name := range []string{"dev", "staging", "prod"} {
+		r := &lockingFleetReconciler{fakeFleetReconciler: fakeFleetReconciler{counts: counts}}
+		clusters = append(clusters, FleetCluster{Name: name, Reconciler: r})
+	}
+
+	f := NewFleet(gogit.New(t.TempDir(), nil), 3)
+	results := f.Run(context.TODO(), clusters, "", time.Millisecond, time.Second)
+
+	for i, r := range results {
+		if r.Err != nil {
+			t.Errorf("results[%d].Err = %v", i, r.Err)
+		}
+	}
+	if counts.maxWriting != 1 {
+		t.Errorf("expected Git repository to be reconciled for one cluster at a time, got %d", counts.maxWriting)
+	}
+}
+
+func TestFleet_RunTimeoutPerCluster(t *testing.T) {
+	var deadlines []time.Time
+	var mu gosync.Mutex
+	var clusters []FleetCluster
+	for _, name := range []string{"dev", "prod"} {
+		clusters = append(clusters, FleetCluster{Name: name, Reconciler: deadlineFleetReconciler{
+			fakeFleetReconciler: fakeFleetReconciler{counts: &fleetCounts{}},
+			record: func(d time.Time) {
+				mu.Lock()
+				defer mu.Unlock()
+				deadlines = append(deadlines, d)
+			},
+		}})
+	}
+
+	f := NewFleet(gogit.New(t.TempDir(), nil), 1)
+	f.Run(context.TODO(), clusters, "", time.Millisecond, time.Second)
+
+	if len(deadlines) != 2 {
+		t.Fatalf("expected a deadline for each cluster, got %v", deadlines)
+	}
+	if !deadlines[1].After(deadlines[0]) {
+		t.Errorf("expected the clusters to be given their own deadline, got %v", deadlines)
+	}
+}
+
+// deadlineFleetReconciler records the deadline of the context the
+// components are reconciled with.
+type deadlineFleetReconciler struct {
+	fakeFleetReconciler
+	record func(time.Time)
+}
+
+func (r deadlineFleetReconciler) ReconcileComponents(ctx context.Context, manifestsBase string, options install.Options, secretOpts sourcesecret.Options) error {
+	if d, ok := ctx.Deadline(); ok {
+		r.record(d)
+	}
+	return r.fakeFleetReconciler.ReconcileComponents(ctx, manifestsBase, options, secretOpts)
+}
@@ -20,11 +20,14 @@ import (
 	"context"
 	"errors"
 	"io"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
 )
 
 var (
 	ErrNoGitRepository = errors.New("no git repository")
 	ErrNoStagedFiles   = errors.New("no staged files")
+	ErrNonFastForward  = errors.New("non-fast-forward update")
 )
 
 type Author struct {
@@ -38,14 +41,34 @@ type Commit struct {
 	Message string
 }
 
+// CommitOptions holds the optional settings for a commit.
+type CommitOptions struct {
+	// SigningKey is the OpenPGP entity used to sign the commit, the
+	// commit is not signed if nil.
+	SigningKey *openpgp.Entity
+}
+
+// CommitOption configures a commit.
+type CommitOption func(*CommitOptions)
+
+// WithSigningKey signs the commit with the given OpenPGP entity.
+func WithSigningKey(key *openpgp.Entity) CommitOption {
+	return func(o *CommitOptions) {
+		o.SigningKey = key
+	}
+}
+
 // Git is an interface for basic Git operations on a single branch of a
 // remote repository.
 type Git interface {
 	Init(url, branch string) (bool, error)
 	Clone(ctx context.Context, url, branch string, caBundle []byte) (bool, error)
 	Write(path string, reader io.Reader) error
-	Commit(message Commit) (string, error)
+	Commit(message Commit, opts ...CommitOption) (string, error)
 	Push(ctx context.Context, caBundle []byte) error
+	SwitchBranch(branch string) error
+	Pull(ctx context.Context, caBundle []byte) error
+	ResetToRemote(ctx context.Context, caBundle []byte) error
 	Status() (bool, error)
 	Head() (string, error)
 	Path() string
@@ -0,0 +1,483 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitcli
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/fluxcd/flux2/internal/bootstrap/git"
+)
+
+const remoteName = "origin"
+
+// minGitVersion is the minimum version of the git binary, which is the
+// first to support passing configuration through GIT_CONFIG_COUNT.
+var minGitVersion = [2]int{2, 31}
+
+var gitVersionRe = regexp.MustCompile(`^git version (\d+)\.(\d+)`)
+
+// Auth holds the credentials used to authenticate against the remote.
+// When empty, the configuration of the git binary is used as is, e.g.
+// credential helpers and the SSH agent.
+type Auth struct {
+	// Username and Password are used for HTTP basic authentication.
+	Username string
+	Password string
+	// PrivateKeyFile is the path to an unencrypted private key used for
+	// SSH authentication. Encrypted keys are rejected by New, as the
+	// passphrase can't be passed to ssh.
+	PrivateKeyFile string
+}
+
+// GitCLI implements git.Git by running the git binary, which means it
+// honours the Git configuration of the user, including credential
+// helpers, URL rewrites and proxy settings.
+type GitCLI struct {
+	path        string
+	auth        *Auth
+	initialized bool
+}
+
+// New returns a GitCLI for the repository at the given path. It returns
+// an error if the git binary is not found or older than minGitVersion,
+// or if the private key of auth is encrypted.
+func New(path string, auth *Auth) (*GitCLI, error) {
+	g := &GitCLI{
+		path: path,
+		auth: auth,
+	}
+	if auth != nil && auth.PrivateKeyFile != "" {
+		if err := checkPrivateKey(auth.PrivateKeyFile); err != nil {
+			return nil, err
+		}
+	}
+	version, err := Version()
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckVersion(version); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Version returns the output of 'git version', e.g. "git version 2.31.0".
+func Version() (string, error) {
+	out, err := exec.Command("git", "version").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine git version: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// MinVersion returns the minimum version of the git binary.
+func MinVersion() string {
+	return fmt.Sprintf("%d.%d", minGitVersion[0], minGitVersion[1])
+}
+
+// checkPrivateKey returns an error if the private key at the given path
+// can't be parsed or is encrypted, in which case ssh would prompt for
+// the passphrase.
+func checkPrivateKey(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read private key: %w", err)
+	}
+	if _, err := ssh.ParsePrivateKey(data); err != nil {
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return fmt.Errorf("private key %q is encrypted, which the git client doesn't support: "+
+				"use the go-git client, or a key without passphrase", path)
+		}
+		return fmt.Errorf("failed to parse private key %q: %w", path, err)
+	}
+	return nil
+}
+
+// CheckVersion returns an error if the given output of 'git version'
+// reports a version older than minGitVersion.
+func CheckVersion(version string) error {
+	m := gitVersionRe.FindStringSubmatch(version)
+	if m == nil {
+		return fmt.Errorf("failed to parse git version %q", version)
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	if major < minGitVersion[0] || (major == minGitVersion[0] && minor < minGitVersion[1]) {
+		return fmt.Errorf("%s is not supported, the git client requires git %s or later", version, MinVersion())
+	}
+	return nil
+}
+
+func (g *GitCLI) Init(url, branch string) (bool, error) {
+	if g.initialized {
+		return false, nil
+	}
+
+	ctx := context.Background()
+	if err := os.MkdirAll(g.path, 0o755); err != nil {
+		return false, err
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"symbolic-ref", "HEAD", "refs/heads/" + branch},
+		{"remote", "add", remoteName, url},
+		{"config", "branch." + branch + ".remote", remoteName},
+		{"config", "branch." + branch + ".merge", "refs/heads/" + branch},
+	} {
+		if _, err := g.run(ctx, nil, nil, args...); err != nil {
+			return false, err
+		}
+	}
+
+	g.initialized = true
+	return true, nil
+}
+
+func (g *GitCLI) Clone(ctx context.Context, url, branch string, caBundle []byte) (bool, error) {
+	if err := os.MkdirAll(g.path, 0o755); err != nil {
+		return false, err
+	}
+	env, cleanup, err := g.remoteEnv(caBundle)
+	if err != nil {
+		return false, err
+	}
+	defer cleanup()
+
+	// An empty remote or a missing branch is initialized locally, to
+	// match the behaviour of go-git
+	refs, err := g.run(ctx, env, nil, "ls-remote", "--heads", url, "refs/heads/"+branch)
+	if err != nil {
+		return false, err
+	}
+	if refs == "" {
+		return g.Init(url, branch)
+	}
+
+	if _, err = g.run(ctx, env, nil, "clone", "--quiet", "--origin", remoteName,
+		"--branch", branch, "--single-branch", "--no-tags", url, "."); err != nil {
+		return false, err
+	}
+
+	g.initialized = true
+	return true, nil
+}
+
+func (g *GitCLI) Write(path string, reader io.Reader) error {
+	if !g.initialized {
+		return git.ErrNoGitRepository
+	}
+
+	abspath := filepath.Join(g.path, path)
+	if err := os.MkdirAll(filepath.Dir(abspath), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(abspath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, reader)
+	return err
+}
+
+func (g *GitCLI) Commit(message git.Commit, opts ...git.CommitOption) (string, error) {
+	if !g.initialized {
+		return "", git.ErrNoGitRepository
+	}
+
+	ctx := context.Background()
+	if _, err := g.run(ctx, nil, nil, "add", "--all"); err != nil {
+		return "", err
+	}
+	if clean, err := g.Status(); err != nil {
+		return "", err
+	} else if clean {
+		head, err := g.Head()
+		if err != nil {
+			return "", err
+		}
+		return head, git.ErrNoStagedFiles
+	}
+
+	var options git.CommitOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.SigningKey != nil {
+		return g.commitSigned(ctx, message, options.SigningKey)
+	}
+
+	env := []string{
+		"GIT_AUTHOR_NAME=" + message.Name,
+		"GIT_AUTHOR_EMAIL=" + message.Email,
+		"GIT_COMMITTER_NAME=" + message.Name,
+		"GIT_COMMITTER_EMAIL=" + message.Email,
+	}
+	if _, err := g.run(ctx, env, strings.NewReader(message.Message), "commit", "--quiet", "--cleanup=whitespace", "--file=-"); err != nil {
+		return "", err
+	}
+	return g.Head()
+}
+
+// commitSigned writes a commit object for the staged tree signed with
+// the given OpenPGP entity, and moves the current branch to it. The
+// git binary can only sign using GnuPG, which has no access to the key.
+func (g *GitCLI) commitSigned(ctx context.Context, message git.Commit, key *openpgp.Entity) (string, error) {
+	tree, err := g.run(ctx, nil, nil, "write-tree")
+	if err != nil {
+		return "", err
+	}
+
+	var headers bytes.Buffer
+	fmt.Fprintf(&headers, "tree %s\n", tree)
+	if parent, err := g.Head(); err == nil {
+		fmt.Fprintf(&headers, "parent %s\n", parent)
+	}
+	now := time.Now()
+	signature := fmt.Sprintf("%s <%s> %d %s", message.Name, message.Email, now.Unix(), now.Format("-0700"))
+	fmt.Fprintf(&headers, "author %s\n", signature)
+	fmt.Fprintf(&headers, "committer %s\n", signature)
+	// Clean up the message like the unsigned commit does with
+	// --cleanup=whitespace
+	cleaned, err := g.run(ctx, nil, strings.NewReader(message.Message), "stripspace")
+	if err != nil {
+		return "", err
+	}
+	if cleaned == "" {
+		return "", fmt.Errorf("git commit failed: empty commit message")
+	}
+	body := "\n" + cleaned + "\n"
+
+	var sig bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&sig, key, strings.NewReader(headers.String()+body), nil); err != nil {
+		return "", fmt.Errorf("failed to sign commit: %w", err)
+	}
+	gpgsig := strings.ReplaceAll(strings.TrimSuffix(sig.String(), "\n"), "\n", "\n ")
+	object := headers.String() + "gpgsig " + gpgsig + "\n" + body
+
+	hash, err := g.run(ctx, nil, strings.NewReader(object), "hash-object", "-t", "commit", "-w", "--stdin")
+	if err != nil {
+		return "", err
+	}
+	if _, err = g.run(ctx, nil, nil, "update-ref", "HEAD", hash); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+func (g *GitCLI) Push(ctx context.Context, caBundle []byte) error {
+	if !g.initialized {
+		return git.ErrNoGitRepository
+	}
+
+	// Only push the current branch, as other local branches may
+	// contain commits that are not meant to be pushed
+	branch, err := g.run(ctx, nil, nil, "symbolic-ref", "HEAD")
+	if err != nil {
+		return err
+	}
+	env, cleanup, err := g.remoteEnv(caBundle)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	_, err = g.run(ctx, env, nil, "push", "--quiet", remoteName, branch+":"+branch)
+	if err != nil && isNonFastForwardErr(err) {
+		return git.ErrNonFastForward
+	}
+	return err
+}
+
+// SwitchBranch checks out the given branch, creating it from the
+// current HEAD if it does not exist locally. Pushes made after
+// switching are made to the branch with the same name on the remote.
+func (g *GitCLI) SwitchBranch(branch string) error {
+	if !g.initialized {
+		return git.ErrNoGitRepository
+	}
+
+	ctx := context.Background()
+	if _, err := g.run(ctx, nil, nil, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err == nil {
+		_, err = g.run(ctx, nil, nil, "checkout", "--quiet", branch)
+		return err
+	}
+	_, err := g.run(ctx, nil, nil, "checkout", "--quiet", "-b", branch)
+	return err
+}
+
+// Pull fetches the current branch from the remote and fast-forwards the
+// worktree to it.
+func (g *GitCLI) Pull(ctx context.Context, caBundle []byte) error {
+	if !g.initialized {
+		return git.ErrNoGitRepository
+	}
+
+	branch, err := g.run(ctx, nil, nil, "symbolic-ref", "HEAD")
+	if err != nil {
+		return err
+	}
+	env, cleanup, err := g.remoteEnv(caBundle)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	_, err = g.run(ctx, env, nil, "pull", "--quiet", "--ff-only", "--no-tags", remoteName, branch)
+	return err
+}
+
+// ResetToRemote fetches the current branch from the remote and hard
+// resets the worktree to it, discarding any local commits and changes.
+func (g *GitCLI) ResetToRemote(ctx context.Context, caBundle []byte) error {
+	if !g.initialized {
+		return git.ErrNoGitRepository
+	}
+
+	branch, err := g.run(ctx, nil, nil, "symbolic-ref", "HEAD")
+	if err != nil {
+		return err
+	}
+	env, cleanup, err := g.remoteEnv(caBundle)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if _, err = g.run(ctx, env, nil, "fetch", "--quiet", "--no-tags", remoteName, branch); err != nil {
+		return err
+	}
+	_, err = g.run(ctx, nil, nil, "reset", "--quiet", "--hard", "FETCH_HEAD")
+	return err
+}
+
+func (g *GitCLI) Status() (bool, error) {
+	if !g.initialized {
+		return false, git.ErrNoGitRepository
+	}
+	status, err := g.run(context.Background(), nil, nil, "status", "--porcelain")
+	if err != nil {
+		return false, err
+	}
+	return status == "", nil
+}
+
+func (g *GitCLI) Head() (string, error) {
+	if !g.initialized {
+		return "", git.ErrNoGitRepository
+	}
+	return g.run(context.Background(), nil, nil, "rev-parse", "--verify", "HEAD")
+}
+
+func (g *GitCLI) Path() string {
+	return g.path
+}
+
+// remoteEnv returns the environment for commands which interact with
+// the remote, configuring the credentials and the given CA bundle. The
+// returned cleanup function removes any temporary files.
+func (g *GitCLI) remoteEnv(caBundle []byte) ([]string, func(), error) {
+	var env []string
+	var config [][2]string
+	cleanup := func() {}
+
+	if len(caBundle) > 0 {
+		f, err := os.CreateTemp("", "flux-ca-")
+		if err != nil {
+			return nil, cleanup, err
+		}
+		cleanup = func() { os.Remove(f.Name()) }
+		_, err = f.Write(caBundle)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			cleanup()
+			return nil, func() {}, err
+		}
+		config = append(config, [2]string{"http.sslCAInfo", f.Name()})
+	}
+
+	if g.auth != nil {
+		if g.auth.Username != "" || g.auth.Password != "" {
+			credentials := base64.StdEncoding.EncodeToString([]byte(g.auth.Username + ":" + g.auth.Password))
+			config = append(config, [2]string{"http.extraHeader", "Authorization: Basic " + credentials})
+		}
+		if g.auth.PrivateKeyFile != "" {
+			env = append(env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -i %s -o IdentitiesOnly=yes -o BatchMode=yes", shellQuote(g.auth.PrivateKeyFile)))
+		}
+	}
+
+	// Configuration is passed through the environment rather than the
+	// command line, to keep credentials out of the process list
+	if len(config) > 0 {
+		env = append(env, "GIT_CONFIG_COUNT="+strconv.Itoa(len(config)))
+		for i, kv := range config {
+			env = append(env, fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", i, kv[0]), fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", i, kv[1]))
+		}
+	}
+	return env, cleanup, nil
+}
+
+// run runs git with the given arguments in the repository directory,
+// and returns the trimmed standard output.
+func (g *GitCLI) run(ctx context.Context, env []string, stdin io.Reader, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = g.path
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	cmd.Env = append(cmd.Env, env...)
+	cmd.Stdin = stdin
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s failed: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s failed: %w", args[0], err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// isNonFastForwardErr returns true if the given push error reports the
+// update was rejected as the remote contains commits which are not
+// present locally.
+func isNonFastForwardErr(err error) bool {
+	return strings.Contains(err.Error(), "(non-fast-forward)") || strings.Contains(err.Error(), "(fetch first)")
+}
@@ -0,0 +1,467 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitcli
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+
+	"github.com/fluxcd/flux2/internal/bootstrap/git"
+)
+
+var author = git.Author{Name: "Flux", Email: "flux@example.com"}
+
+func initBareRepository(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not found")
+	}
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "init", "--bare", "--quiet", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %s", out)
+	}
+	return dir
+}
+
+func newGitCLI(t *testing.T, auth *Auth) *GitCLI {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not found")
+	}
+	g, err := New(t.TempDir(), auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func remoteRef(t *testing.T, bare, ref string) string {
+	t.Helper()
+	out, err := exec.Command("git", "--git-dir", bare, "rev-parse", "--verify", "--quiet", ref).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func commitFile(t *testing.T, g *GitCLI, path, content string, opts ...git.CommitOption) string {
+	t.Helper()
+	if err := g.Write(path, strings.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := g.Commit(git.Commit{Author: author, Message: "Update " + path}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestGitCLI_CloneEmptyRepository(t *testing.T) {
+	bare := initBareRepository(t)
+	ctx := context.TODO()
+
+	g := newGitCLI(t, nil)
+	if err := g.Write("file", strings.NewReader("content")); err != git.ErrNoGitRepository {
+		t.Fatalf("Write() before Clone() error = %v, want %v", err, git.ErrNoGitRepository)
+	}
+	if ok, err := g.Clone(ctx, bare, "main", nil); err != nil || !ok {
+		t.Fatalf("Clone() = %v, %v", ok, err)
+	}
+	if ok, err := g.Init(bare, "main"); err != nil || ok {
+		t.Fatalf("Init() after Clone() = %v, %v", ok, err)
+	}
+	if _, err := g.Head(); err == nil {
+		t.Fatal("Head() expected error for empty repository")
+	}
+
+	hash := commitFile(t, g, "clusters/file.yaml", "content")
+	if err := g.Push(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := remoteRef(t, bare, "refs/heads/main"); got != hash {
+		t.Errorf("remote main = %q, want %q", got, hash)
+	}
+}
+
+func TestGitCLI_CloneBranch(t *testing.T) {
+	bare := initBareRepository(t)
+	ctx := context.TODO()
+
+	upstream := newGitCLI(t, nil)
+	if _, err := upstream.Clone(ctx, bare, "main", nil); err != nil {
+		t.Fatal(err)
+	}
+	hash := commitFile(t, upstream, "file", "content")
+	if err := upstream.Push(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	g := newGitCLI(t, nil)
+	if ok, err := g.Clone(ctx, bare, "main", nil); err != nil || !ok {
+		t.Fatalf("Clone() = %v, %v", ok, err)
+	}
+	head, err := g.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head != hash {
+		t.Errorf("Head() = %q, want %q", head, hash)
+	}
+	b, err := os.ReadFile(filepath.Join(g.Path(), "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "content" {
+		t.Errorf("file content = %q, want %q", b, "content")
+	}
+
+	// Cloning a branch which does not exist initializes it
+	other := newGitCLI(t, nil)
+	if _, err := other.Clone(ctx, bare, "other", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Head(); err == nil {
+		t.Error("Head() expected error for new branch")
+	}
+}
+
+func TestGitCLI_Commit(t *testing.T) {
+	bare := initBareRepository(t)
+
+	g := newGitCLI(t, nil)
+	if _, err := g.Init(bare, "main"); err != nil {
+		t.Fatal(err)
+	}
+	if clean, err := g.Status(); err != nil || !clean {
+		t.Fatalf("Status() = %v, %v", clean, err)
+	}
+
+	if err := g.Write("file", strings.NewReader("content")); err != nil {
+		t.Fatal(err)
+	}
+	if clean, err := g.Status(); err != nil || clean {
+		t.Fatalf("Status() after Write() = %v, %v", clean, err)
+	}
+	hash, err := g.Commit(git.Commit{Author: author, Message: "Add file\n\n[ci skip]"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command("git", "-C", g.Path(), "log", "-1", "--format=%an <%ae>%n%cn <%ce>%n%B").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Flux <flux@example.com>\nFlux <flux@example.com>\nAdd file\n\n[ci skip]"
+	if got := strings.TrimSpace(string(out)); got != want {
+		t.Errorf("commit = %q, want %q", got, want)
+	}
+
+	// Writing the same content again results in no changes
+	if err := g.Write("file", strings.NewReader("content")); err != nil {
+		t.Fatal(err)
+	}
+	head, err := g.Commit(git.Commit{Author: author, Message: "Update file"})
+	if err != git.ErrNoStagedFiles {
+		t.Fatalf("Commit() error = %v, want %v", err, git.ErrNoStagedFiles)
+	}
+	if head != hash {
+		t.Errorf("Commit() = %q, want %q", head, hash)
+	}
+}
+
+func TestGitCLI_CommitSigned(t *testing.T) {
+	bare := initBareRepository(t)
+
+	entity, err := openpgp.NewEntity("Flux", "", "flux@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := newGitCLI(t, nil)
+	if _, err := g.Init(bare, "main"); err != nil {
+		t.Fatal(err)
+	}
+	first := commitFile(t, g, "first", "content", git.WithSigningKey(entity))
+	second := commitFile(t, g, "second", "content", git.WithSigningKey(entity))
+
+	out, err := exec.Command("git", "-C", g.Path(), "cat-file", "commit", second).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := string(out)
+	if !strings.Contains(raw, "parent "+first+"\n") {
+		t.Errorf("commit %s does not have parent %s", second, first)
+	}
+
+	// Verify the signature over the commit without the gpgsig header
+	headers, body := raw[:strings.Index(raw, "\n\n")+1], raw[strings.Index(raw, "\n\n")+1:]
+	var payload, signature strings.Builder
+	inSig := false
+	for _, line := range strings.SplitAfter(headers, "\n") {
+		switch {
+		case strings.HasPrefix(line, "gpgsig "):
+			inSig = true
+			signature.WriteString(strings.TrimPrefix(line, "gpgsig "))
+		case inSig && strings.HasPrefix(line, " "):
+			signature.WriteString(strings.TrimPrefix(line, " "))
+		default:
+			inSig = false
+			payload.WriteString(line)
+		}
+	}
+	if _, err := openpgp.CheckArmoredDetachedSignature(openpgp.EntityList{entity},
+		strings.NewReader(payload.String()+body), strings.NewReader(signature.String()), nil); err != nil {
+		t.Errorf("invalid commit signature: %v", err)
+	}
+
+	out, err = exec.Command("git", "-C", g.Path(), "fsck", "--strict").CombinedOutput()
+	if err != nil {
+		t.Errorf("git fsck: %s", out)
+	}
+}
+
+func TestGitCLI_CommitMessageCleanup(t *testing.T) {
+	bare := initBareRepository(t)
+
+	entity, err := openpgp.NewEntity("Flux", "", "flux@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := newGitCLI(t, nil)
+	if _, err := g.Init(bare, "main"); err != nil {
+		t.Fatal(err)
+	}
+	message := "\nAdd file  \n\n\n\n# [ci skip]\n\n"
+	var messages []string
+	for i, opts := range [][]git.CommitOption{nil, {git.WithSigningKey(entity)}} {
+		if err := g.Write("file", strings.NewReader(strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := g.Commit(git.Commit{Author: author, Message: message}, opts...); err != nil {
+			t.Fatal(err)
+		}
+		out, err := exec.Command("git", "-C", g.Path(), "cat-file", "commit", "HEAD").Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, string(out[strings.Index(string(out), "\n\n")+2:]))
+	}
+	if want := "Add file\n\n# [ci skip]\n"; messages[0] != want {
+		t.Errorf("unsigned commit message = %q, want %q", messages[0], want)
+	}
+	if messages[1] != messages[0] {
+		t.Errorf("signed commit message = %q, want %q", messages[1], messages[0])
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		wantErr bool
+	}{
+		{"git version 2.31.0", false},
+		{"git version 2.39.2 (Apple Git-143)", false},
+		{"git version 2.40.0.windows.1", false},
+		{"git version 3.0.0", false},
+		{"git version 2.30.2", true},
+		{"git version 1.8.3.1", true},
+		{"unknown", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			if err := CheckVersion(tt.version); (err != nil) != tt.wantErr {
+				t.Errorf("CheckVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGitCLI_SwitchBranchAndPull(t *testing.T) {
+	bare := initBareRepository(t)
+	ctx := context.TODO()
+
+	g := newGitCLI(t, nil)
+	if _, err := g.Clone(ctx, bare, "main", nil); err != nil {
+		t.Fatal(err)
+	}
+	base := commitFile(t, g, "file", "content")
+	if err := g.Push(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the current branch is pushed
+	if err := g.SwitchBranch("feature"); err != nil {
+		t.Fatal(err)
+	}
+	feature := commitFile(t, g, "file", "feature")
+	if err := g.Push(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := remoteRef(t, bare, "refs/heads/feature"); got != feature {
+		t.Errorf("remote feature = %q, want %q", got, feature)
+	}
+	if got := remoteRef(t, bare, "refs/heads/main"); got != base {
+		t.Errorf("remote main = %q, want %q", got, base)
+	}
+
+	// Merge the feature branch upstream and pull it
+	if out, err := exec.Command("git", "--git-dir", bare, "update-ref", "refs/heads/main", feature).CombinedOutput(); err != nil {
+		t.Fatalf("git update-ref: %s", out)
+	}
+	if err := g.SwitchBranch("main"); err != nil {
+		t.Fatal(err)
+	}
+	if head, _ := g.Head(); head != base {
+		t.Errorf("Head() after SwitchBranch() = %q, want %q", head, base)
+	}
+	if err := g.Pull(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if head, _ := g.Head(); head != feature {
+		t.Errorf("Head() after Pull() = %q, want %q", head, feature)
+	}
+}
+
+// writePrivateKey writes a private key to the given path, encrypted
+// with the passphrase if not empty.
+func writePrivateKey(t *testing.T, path, passphrase string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+	if passphrase != "" {
+		if block, err = x509.EncryptPEMBlock(rand.Reader, block.Type, der, []byte(passphrase), x509.PEMCipherAES256); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNew_privateKey(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not found")
+	}
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "id_ecdsa")
+	writePrivateKey(t, plain, "")
+	encrypted := filepath.Join(dir, "id_ecdsa_encrypted")
+	writePrivateKey(t, encrypted, "passphrase")
+
+	if _, err := New(t.TempDir(), &Auth{PrivateKeyFile: plain}); err != nil {
+		t.Errorf("New() with an unencrypted key error = %v", err)
+	}
+	_, err := New(t.TempDir(), &Auth{PrivateKeyFile: encrypted})
+	if err == nil || !strings.Contains(err.Error(), "is encrypted") {
+		t.Errorf("New() with an encrypted key error = %v, want encrypted key error", err)
+	}
+	if _, err := New(t.TempDir(), &Auth{PrivateKeyFile: filepath.Join(dir, "missing")}); err == nil {
+		t.Error("New() with a missing key: expected error")
+	}
+}
+
+func TestGitCLI_RemoteEnv(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "it's")
+	writePrivateKey(t, keyFile, "")
+	g := newGitCLI(t, &Auth{Username: "git", Password: "token", PrivateKeyFile: keyFile})
+	env, cleanup, err := g.remoteEnv([]byte("ca"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vars := map[string]string{}
+	for _, kv := range env {
+		parts := strings.SplitN(kv, "=", 2)
+		vars[parts[0]] = parts[1]
+	}
+	if got := vars["GIT_CONFIG_COUNT"]; got != "2" {
+		t.Errorf("GIT_CONFIG_COUNT = %q, want %q", got, "2")
+	}
+	caFile := vars["GIT_CONFIG_VALUE_0"]
+	if b, err := os.ReadFile(caFile); err != nil || string(b) != "ca" {
+		t.Errorf("CA file content = %q, %v", b, err)
+	}
+	if got, want := vars["GIT_CONFIG_VALUE_1"], "Authorization: Basic Z2l0OnRva2Vu"; got != want {
+		t.Errorf("GIT_CONFIG_VALUE_1 = %q, want %q", got, want)
+	}
+	if got, want := vars["GIT_SSH_COMMAND"], "ssh -i '"+filepath.Dir(keyFile)+`/it'\''s' -o IdentitiesOnly=yes -o BatchMode=yes`; got != want {
+		t.Errorf("GIT_SSH_COMMAND = %q, want %q", got, want)
+	}
+
+	cleanup()
+	if _, err := os.Stat(caFile); !os.IsNotExist(err) {
+		t.Errorf("CA file %q not removed", caFile)
+	}
+}
+
+func TestGitCLI_ResetToRemote(t *testing.T) {
+	bare := initBareRepository(t)
+	ctx := context.TODO()
+
+	g := newGitCLI(t, nil)
+	if _, err := g.Clone(ctx, bare, "main", nil); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, g, "file", "content")
+	if err := g.Push(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	other := newGitCLI(t, nil)
+	if _, err := other.Clone(ctx, bare, "main", nil); err != nil {
+		t.Fatal(err)
+	}
+	upstream := commitFile(t, other, "file", "upstream")
+	if err := other.Push(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	commitFile(t, g, "file", "local")
+	if err := g.Push(ctx, nil); !errors.Is(err, git.ErrNonFastForward) {
+		t.Fatalf("Push() error = %v, want %v", err, git.ErrNonFastForward)
+	}
+	if err := g.ResetToRemote(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if head, _ := g.Head(); head != upstream {
+		t.Errorf("Head() after ResetToRemote() = %q, want %q", head, upstream)
+	}
+	if b, err := os.ReadFile(filepath.Join(g.Path(), "file")); err != nil || string(b) != "upstream" {
+		t.Errorf("file content = %q, %v", b, err)
+	}
+}
@@ -127,7 +127,7 @@ func (g *GoGit) Write(path string, reader io.Reader) error {
 	return err
 }
 
-func (g *GoGit) Commit(message git.Commit) (string, error) {
+func (g *GoGit) Commit(message git.Commit, opts ...git.CommitOption) (string, error) {
 	if g.repository == nil {
 		return "", git.ErrNoGitRepository
 	}
@@ -150,6 +150,14 @@ func (g *GoGit) Commit(message git.Commit) (string, error) {
 	for file, _ := range status {
 		abspath := filepath.Join(g.path, file)
 		info, err := os.Lstat(abspath)
+		if os.IsNotExist(err) {
+			// stage the removal of the file
+			if _, err = wt.Add(file); err != nil {
+				return "", fmt.Errorf("staging removal of %s: %w", file, err)
+			}
+			changed = true
+			continue
+		}
 		if err != nil {
 			return "", fmt.Errorf("checking if %s is a symlink: %w", file, err)
 		}
@@ -173,12 +181,18 @@ func (g *GoGit) Commit(message git.Commit) (string, error) {
 		return head.Hash().String(), git.ErrNoStagedFiles
 	}
 
+	var options git.CommitOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	commit, err := wt.Commit(message.Message, &gogit.CommitOptions{
 		Author: &object.Signature{
 			Name:  message.Name,
 			Email: message.Email,
 			When:  time.Now(),
 		},
+		SignKey: options.SigningKey,
 	})
 	if err != nil {
 		return "", err
@@ -191,12 +205,117 @@ func (g *GoGit) Push(ctx context.Context, caBundle []byte) error {
 		return git.ErrNoGitRepository
 	}
 
-	return g.repository.PushContext(ctx, &gogit.PushOptions{
+	// Only push the current branch, as other local branches may
+	// contain commits that are not meant to be pushed
+	head, err := g.repository.Head()
+	if err != nil {
+		return err
+	}
+	refSpec := config.RefSpec(fmt.Sprintf("%s:%s", head.Name(), head.Name()))
+
+	err = g.repository.PushContext(ctx, &gogit.PushOptions{
+		RemoteName: gogit.DefaultRemoteName,
+		RefSpecs:   []config.RefSpec{refSpec},
+		Auth:       g.auth,
+		Progress:   nil,
+		CABundle:   caBundle,
+	})
+	if err != nil && isNonFastForwardErr(err) {
+		return git.ErrNonFastForward
+	}
+	return err
+}
+
+// SwitchBranch checks out the given branch, creating it from the
+// current HEAD if it does not exist locally. Pushes made after
+// switching are made to the branch with the same name on the remote.
+func (g *GoGit) SwitchBranch(branch string) error {
+	if g.repository == nil {
+		return git.ErrNoGitRepository
+	}
+
+	wt, err := g.repository.Worktree()
+	if err != nil {
+		return err
+	}
+
+	branchRef := plumbing.NewBranchReferenceName(branch)
+	_, err = g.repository.Reference(branchRef, false)
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return err
+	}
+	return wt.Checkout(&gogit.CheckoutOptions{
+		Branch: branchRef,
+		Create: err == plumbing.ErrReferenceNotFound,
+	})
+}
+
+// Pull fetches the current branch from the remote and fast-forwards the
+// worktree to it.
+func (g *GoGit) Pull(ctx context.Context, caBundle []byte) error {
+	if g.repository == nil {
+		return git.ErrNoGitRepository
+	}
+
+	head, err := g.repository.Head()
+	if err != nil {
+		return err
+	}
+	wt, err := g.repository.Worktree()
+	if err != nil {
+		return err
+	}
+
+	err = wt.PullContext(ctx, &gogit.PullOptions{
+		RemoteName:    gogit.DefaultRemoteName,
+		ReferenceName: head.Name(),
+		SingleBranch:  true,
+		Auth:          g.auth,
+		Progress:      nil,
+		CABundle:      caBundle,
+	})
+	if err == gogit.NoErrAlreadyUpToDate {
+		return nil
+	}
+	return err
+}
+
+// ResetToRemote fetches the current branch from the remote and hard
+// resets the worktree to it, discarding any local commits and changes.
+func (g *GoGit) ResetToRemote(ctx context.Context, caBundle []byte) error {
+	if g.repository == nil {
+		return git.ErrNoGitRepository
+	}
+
+	head, err := g.repository.Head()
+	if err != nil {
+		return err
+	}
+	remoteRef := plumbing.NewRemoteReferenceName(gogit.DefaultRemoteName, head.Name().Short())
+	err = g.repository.FetchContext(ctx, &gogit.FetchOptions{
 		RemoteName: gogit.DefaultRemoteName,
+		RefSpecs:   []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", head.Name(), remoteRef))},
 		Auth:       g.auth,
 		Progress:   nil,
+		Tags:       gogit.NoTags,
 		CABundle:   caBundle,
 	})
+	if err != nil && err != gogit.NoErrAlreadyUpToDate {
+		return err
+	}
+	ref, err := g.repository.Reference(remoteRef, true)
+	if err != nil {
+		return err
+	}
+
+	wt, err := g.repository.Worktree()
+	if err != nil {
+		return err
+	}
+	return wt.Reset(&gogit.ResetOptions{
+		Commit: ref.Hash(),
+		Mode:   gogit.HardReset,
+	})
 }
 
 func (g *GoGit) Status() (bool, error) {
@@ -232,3 +351,7 @@ func (g *GoGit) Path() string {
 func isRemoteBranchNotFoundErr(err error, ref string) bool {
 	return strings.Contains(err.Error(), fmt.Sprintf("couldn't find remote ref %q", ref))
 }
+
+func isNonFastForwardErr(err error) bool {
+	return strings.HasPrefix(err.Error(), "non-fast-forward update")
+}
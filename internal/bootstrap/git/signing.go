@@ -0,0 +1,96 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// ReadSigningKey reads the armored OpenPGP key ring from the given
+// reader, and returns the entity with the given key ID, decrypted with
+// the given passphrase. The key ID may be the long or short ID of the
+// primary key or one of its subkeys. If no key ID is given, the first
+// entity with a private key is returned.
+func ReadSigningKey(keyRing io.Reader, passphrase, keyID string) (*openpgp.Entity, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(keyRing)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read OpenPGP key ring: %w", err)
+	}
+
+	keyID = strings.ToUpper(strings.TrimPrefix(strings.ToLower(keyID), "0x"))
+	for _, entity := range entities {
+		if entity.PrivateKey == nil {
+			continue
+		}
+		if keyID != "" && !matchesKeyID(entity, keyID) {
+			continue
+		}
+		if err := decryptEntity(entity, []byte(passphrase)); err != nil {
+			return nil, fmt.Errorf("unable to decrypt OpenPGP key %s: %w", entity.PrimaryKey.KeyIdString(), err)
+		}
+		return entity, nil
+	}
+
+	if keyID != "" {
+		return nil, fmt.Errorf("no OpenPGP private key with ID %s found in key ring", keyID)
+	}
+	return nil, fmt.Errorf("no OpenPGP private key found in key ring")
+}
+
+// SigningKeyID returns the ID of the key used to sign with the given
+// entity, which is either a subkey or the primary key.
+func SigningKeyID(entity *openpgp.Entity) string {
+	if key, ok := entity.SigningKey(time.Now()); ok {
+		return key.PublicKey.KeyIdString()
+	}
+	return entity.PrimaryKey.KeyIdString()
+}
+
+func matchesKeyID(entity *openpgp.Entity, keyID string) bool {
+	keys := []*packet.PublicKey{entity.PrimaryKey}
+	for _, subkey := range entity.Subkeys {
+		keys = append(keys, subkey.PublicKey)
+	}
+	for _, key := range keys {
+		if keyID == key.KeyIdString() || keyID == key.KeyIdShortString() {
+			return true
+		}
+	}
+	return false
+}
+
+func decryptEntity(entity *openpgp.Entity, passphrase []byte) error {
+	if entity.PrivateKey.Encrypted {
+		if err := entity.PrivateKey.Decrypt(passphrase); err != nil {
+			return err
+		}
+	}
+	for _, subkey := range entity.Subkeys {
+		if subkey.PrivateKey != nil && subkey.PrivateKey.Encrypted {
+			if err := subkey.PrivateKey.Decrypt(passphrase); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
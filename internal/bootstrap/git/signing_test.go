@@ -0,0 +1,100 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+func armoredKeyRing(t *testing.T, passphrase string, entities ...*openpgp.Entity) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entities {
+		if passphrase != "" {
+			if err := e.PrivateKey.Encrypt([]byte(passphrase)); err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range e.Subkeys {
+				if err := s.PrivateKey.Encrypt([]byte(passphrase)); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if err := e.SerializePrivateWithoutSigning(w, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadSigningKey(t *testing.T) {
+	first, err := openpgp.NewEntity("Flux", "", "first@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := openpgp.NewEntity("Flux", "", "second@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstID := first.PrimaryKey.KeyIdString()
+	secondID := second.PrimaryKey.KeyIdString()
+	secondSubkeyID := second.Subkeys[0].PublicKey.KeyIdShortString()
+	keyRing := armoredKeyRing(t, "secret", first, second)
+
+	tests := []struct {
+		name       string
+		passphrase string
+		keyID      string
+		wantID     string
+		wantErr    bool
+	}{
+		{name: "first key", passphrase: "secret", wantID: firstID},
+		{name: "long key ID", passphrase: "secret", keyID: secondID, wantID: secondID},
+		{name: "prefixed key ID", passphrase: "secret", keyID: "0x" + secondID, wantID: secondID},
+		{name: "short subkey ID", passphrase: "secret", keyID: secondSubkeyID, wantID: secondID},
+		{name: "unknown key ID", passphrase: "secret", keyID: "DEADBEEF", wantErr: true},
+		{name: "wrong passphrase", passphrase: "wrong", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entity, err := ReadSigningKey(bytes.NewReader(keyRing), tt.passphrase, tt.keyID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadSigningKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := entity.PrimaryKey.KeyIdString(); got != tt.wantID {
+				t.Errorf("ReadSigningKey() key ID = %s, want %s", got, tt.wantID)
+			}
+			if entity.PrivateKey.Encrypted {
+				t.Error("ReadSigningKey() returned an encrypted key")
+			}
+		})
+	}
+}
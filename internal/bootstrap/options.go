@@ -112,3 +112,70 @@ func (o loggerOption) applyGit(b *PlainGitBootstrapper) {
 func (o loggerOption) applyGitProvider(b *GitProviderBootstrapper) {
 	b.logger = o.logger
 }
+
+func WithGitCommitSigning(keyRingPath, passphrase, keyID string) Option {
+	return gitCommitSigningOption{
+		keyRingPath: keyRingPath,
+		passphrase:  passphrase,
+		keyID:       keyID,
+	}
+}
+
+type gitCommitSigningOption struct {
+	keyRingPath string
+	passphrase  string
+	keyID       string
+}
+
+func (o gitCommitSigningOption) applyGit(b *PlainGitBootstrapper) {
+	b.gpgKeyRingPath = o.keyRingPath
+	b.gpgPassphrase = o.passphrase
+	b.gpgKeyID = o.keyID
+}
+
+func (o gitCommitSigningOption) applyGitProvider(b *GitProviderBootstrapper) {
+	o.applyGit(b.PlainGitBootstrapper)
+}
+
+func WithDryRun() Option {
+	return dryRunOption(true)
+}
+
+type dryRunOption bool
+
+func (o dryRunOption) applyGit(b *PlainGitBootstrapper) {
+	b.dryRun = bool(o)
+	b.plan = &Plan{}
+}
+
+func (o dryRunOption) applyGitProvider(b *GitProviderBootstrapper) {
+	o.applyGit(b.PlainGitBootstrapper)
+}
+
+func WithRollbackOnFailure() Option {
+	return rollbackOnFailureOption(true)
+}
+
+type rollbackOnFailureOption bool
+
+func (o rollbackOnFailureOption) applyGit(b *PlainGitBootstrapper) {
+	b.rollbackOnFailure = bool(o)
+}
+
+func (o rollbackOnFailureOption) applyGitProvider(b *GitProviderBootstrapper) {
+	o.applyGit(b.PlainGitBootstrapper)
+}
+
+func WithSingleCommit() Option {
+	return singleCommitOption(true)
+}
+
+type singleCommitOption bool
+
+func (o singleCommitOption) applyGit(b *PlainGitBootstrapper) {
+	b.singleCommit = bool(o)
+}
+
+func (o singleCommitOption) applyGitProvider(b *GitProviderBootstrapper) {
+	o.applyGit(b.PlainGitBootstrapper)
+}
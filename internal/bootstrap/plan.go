@@ -0,0 +1,285 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/fluxcd/go-git-providers/gitprovider"
+
+	"github.com/fluxcd/flux2/internal/ssa"
+)
+
+// PlanAction is the action a dry-run bootstrap would take on a resource.
+type PlanAction string
+
+const (
+	PlanActionCreate  PlanAction = "create"
+	PlanActionUpdate  PlanAction = "update"
+	PlanActionReplace PlanAction = "replace"
+)
+
+// PlanChange describes a single change a dry-run bootstrap would make.
+type PlanChange struct {
+	// Kind is the kind of resource, e.g. "file", "deploy key" or the kind
+	// of a cluster object.
+	Kind string
+	// Name identifies the resource of the given kind.
+	Name   string
+	Action PlanAction
+	// Diff is the unified diff of the change, if available.
+	Diff string
+}
+
+// Plan holds the changes a dry-run bootstrap would make to the Git
+// provider, the Git repository and the cluster, in the order they would
+// be made.
+type Plan struct {
+	Changes []PlanChange
+}
+
+func (p *Plan) add(kind, name string, action PlanAction, diff string) {
+	// Objects may be planned more than once, as the components and sync
+	// manifests share a kustomization.yaml
+	for _, c := range p.Changes {
+		if c.Kind == kind && c.Name == name {
+			return
+		}
+	}
+	p.Changes = append(p.Changes, PlanChange{
+		Kind:   kind,
+		Name:   name,
+		Action: action,
+		Diff:   diff,
+	})
+}
+
+// Print writes the plan in a human readable format to the given writer.
+func (p *Plan) Print(w io.Writer) error {
+	var buf bytes.Buffer
+	if len(p.Changes) == 0 {
+		buf.WriteString("no changes, the bootstrap configuration is up to date\n")
+	}
+	for _, c := range p.Changes {
+		symbol := "~"
+		switch c.Action {
+		case PlanActionCreate:
+			symbol = "+"
+		case PlanActionReplace:
+			symbol = "-/+"
+		}
+		fmt.Fprintf(&buf, "%s %s %s %q\n", symbol, c.Action, c.Kind, c.Name)
+		if c.Diff != "" {
+			for _, line := range strings.SplitAfter(strings.TrimSuffix(c.Diff, "\n"), "\n") {
+				fmt.Fprintf(&buf, "    %s", line)
+			}
+			buf.WriteString("\n")
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// planFile records the change writing the given content to the file at
+// the given path relative to the Git repository would make.
+func (b *PlainGitBootstrapper) planFile(path, content string) error {
+	current, err := os.ReadFile(filepath.Join(b.git.Path(), path))
+	if os.IsNotExist(err) {
+		b.plan.add("file", path, PlanActionCreate, "")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if string(current) == content {
+		return nil
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(string(current)),
+		B:        splitLines(content),
+		FromFile: "a/" + path,
+		ToFile:   "b/" + path,
+		Context:  3,
+	})
+	if err != nil {
+		return err
+	}
+	b.plan.add("file", path, PlanActionUpdate, diff)
+	return nil
+}
+
+// splitLines splits the given string into lines for diffing, each
+// ending with a newline.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(s, "\n"), "\n")
+	lines[len(lines)-1] += "\n"
+	return lines
+}
+
+// planObjects records the changes applying the given objects would make
+// to the cluster. The changes are determined with a server-side apply
+// dry-run, objects which would be left unchanged are not recorded.
+func (b *PlainGitBootstrapper) planObjects(ctx context.Context, objects []*unstructured.Unstructured) error {
+	manager := ssa.NewResourceManager(b.kube, ssa.FieldOwner)
+	manager.DryRun = true
+	changeSet, err := manager.ApplyAllStaged(ctx, objects, ssaTimeout)
+	if err != nil {
+		return err
+	}
+	for _, entry := range changeSet.Entries {
+		var action PlanAction
+		switch entry.Action {
+		case ssa.CreatedAction:
+			action = PlanActionCreate
+		case ssa.ConfiguredAction:
+			action = PlanActionUpdate
+		default:
+			continue
+		}
+		// The subject is in the Kind/namespace/name format
+		parts := strings.SplitN(entry.Subject, "/", 2)
+		b.plan.add(parts[0], parts[1], action, "")
+	}
+	return nil
+}
+
+// planRepository records the changes reconciling the repository would
+// make. If the repository does not exist yet, an empty local repository
+// is initialized in place of a clone.
+func (b *GitProviderBootstrapper) planRepository(ctx context.Context) error {
+	b.logger.Actionf("connecting to %s", b.provider.SupportedDomain())
+
+	teamAccessInfo, err := buildTeamAccessInfo(b.teams, gitprovider.RepositoryPermissionVar(gitprovider.RepositoryPermissionMaintain))
+	if err != nil {
+		return fmt.Errorf("failed to plan repository team access: %w", err)
+	}
+
+	name := b.owner + "/" + b.repository
+	repo, err := b.getRepository(ctx)
+	if err != nil {
+		if !errors.Is(err, gitprovider.ErrNotFound) {
+			return fmt.Errorf("failed to get Git repository %q: %w", name, err)
+		}
+		b.plan.add("Git repository", name, PlanActionCreate, "")
+		if !b.personal {
+			for _, i := range teamAccessInfo {
+				b.plan.add(fmt.Sprintf("%s permission for team", *i.Permission), i.Name, PlanActionCreate, "")
+			}
+		}
+
+		url := b.plannedCloneURL(gitprovider.TransportType(b.bootstrapTransportType))
+		WithRepositoryURL(url).applyGit(b.PlainGitBootstrapper)
+		if _, err = b.git.Init(url, b.branch); err != nil {
+			return fmt.Errorf("failed to initialize Git repository: %w", err)
+		}
+		return nil
+	}
+
+	if b.reconcile && repositoryInfoDiffers(repo.Get(), newRepositoryInfo(b.description, b.defaultBranch, b.visibility)) {
+		b.plan.add("Git repository", name, PlanActionUpdate, "")
+	}
+	if orgRepo, ok := repo.(gitprovider.OrgRepository); ok && !b.personal {
+		for _, i := range teamAccessInfo {
+			action := PlanActionUpdate
+			ta, err := orgRepo.TeamAccess().Get(ctx, i.Name)
+			if err != nil {
+				if !errors.Is(err, gitprovider.ErrNotFound) {
+					return fmt.Errorf("failed to get permissions of team %q: %w", i.Name, err)
+				}
+				action = PlanActionCreate
+			} else if p := ta.Get().Permission; p != nil && *p == *i.Permission {
+				continue
+			}
+			b.plan.add(fmt.Sprintf("%s permission for team", *i.Permission), i.Name, action, "")
+		}
+	}
+
+	url, err := b.getCloneURL(repo, gitprovider.TransportType(b.bootstrapTransportType))
+	if err != nil {
+		return err
+	}
+	WithRepositoryURL(url).applyGit(b.PlainGitBootstrapper)
+	return nil
+}
+
+// planDeployKey records the change reconciling the given deploy key
+// would make.
+func (b *GitProviderBootstrapper) planDeployKey(ctx context.Context, info gitprovider.DeployKeyInfo) error {
+	repo, err := b.getRepository(ctx)
+	if err != nil {
+		if errors.Is(err, gitprovider.ErrNotFound) {
+			b.plan.add("deploy key", info.Name, PlanActionCreate, "")
+			return nil
+		}
+		return err
+	}
+	key, err := repo.DeployKeys().Get(ctx, info.Name)
+	if err != nil {
+		if errors.Is(err, gitprovider.ErrNotFound) {
+			b.plan.add("deploy key", info.Name, PlanActionCreate, "")
+			return nil
+		}
+		return err
+	}
+	if !bytes.Equal(bytes.TrimSpace(key.Get().Key), bytes.TrimSpace(info.Key)) {
+		b.plan.add("deploy key", info.Name, PlanActionReplace, "")
+	}
+	return nil
+}
+
+// plannedCloneURL returns the clone URL for the given transport type of
+// a repository which does not exist yet. It is only used to plan the
+// changes to the repository, and never connected to.
+func (b *GitProviderBootstrapper) plannedCloneURL(transport gitprovider.TransportType) string {
+	host := b.provider.SupportedDomain()
+	if u, err := url.Parse(host); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	if transport == gitprovider.TransportTypeSSH {
+		if b.sshHostname != "" {
+			host = b.sshHostname
+		}
+		return fmt.Sprintf("ssh://git@%s/%s/%s", host, b.owner, b.repository)
+	}
+	return fmt.Sprintf("https://%s/%s/%s", host, b.owner, b.repository)
+}
+
+// repositoryInfoDiffers returns true if reconciling the repository with
+// the desired gitprovider.RepositoryInfo would change the actual one.
+func repositoryInfoDiffers(actual, desired gitprovider.RepositoryInfo) bool {
+	if desired.Description != nil && (actual.Description == nil || *actual.Description != *desired.Description) {
+		return true
+	}
+	if desired.Visibility != nil && (actual.Visibility == nil || *actual.Visibility != *desired.Visibility) {
+		return true
+	}
+	return false
+}
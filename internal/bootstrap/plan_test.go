@@ -0,0 +1,189 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluxcd/flux2/internal/bootstrap/git/gogit"
+	"github.com/fluxcd/flux2/internal/ssa"
+	"github.com/fluxcd/flux2/internal/utils"
+)
+
+// dryRunApplyClient emulates a server-side apply dry-run on top of the
+// fake client, which does not support it, by returning the applied
+// object with the server set fields of the existing one.
+type dryRunApplyClient struct {
+	client.Client
+}
+
+func (c dryRunApplyClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	po := &client.PatchOptions{}
+	po.ApplyOptions(opts)
+	if patch.Type() != types.ApplyPatchType || len(po.DryRun) == 0 {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+
+	desired := obj.(*unstructured.Unstructured)
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(desired.GroupVersionKind())
+	if err := c.Get(ctx, client.ObjectKeyFromObject(desired), existing); err != nil {
+		if apierr.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	for _, field := range []string{"resourceVersion", "creationTimestamp", "uid"} {
+		if v, ok, _ := unstructured.NestedFieldCopy(existing.Object, "metadata", field); ok {
+			_ = unstructured.SetNestedField(desired.Object, v, "metadata", field)
+		}
+	}
+	return nil
+}
+
+func TestPlainGitBootstrapper_planFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "existing.yaml"), []byte("a: 1\nb: 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	b := &PlainGitBootstrapper{git: gogit.New(dir, nil), plan: &Plan{}}
+	for path, content := range map[string]string{
+		"new.yaml":      "a: 1\n",
+		"existing.yaml": "a: 1\nb: 3\n",
+	} {
+		if err := b.planFile(path, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.planFile("existing.yaml", "a: 1\nb: 2\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := map[string]PlanChange{}
+	for _, c := range b.plan.Changes {
+		changes[c.Name] = c
+	}
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %v", b.plan.Changes)
+	}
+	if c := changes["new.yaml"]; c.Action != PlanActionCreate || c.Diff != "" {
+		t.Errorf("new.yaml = %+v, expected create without diff", c)
+	}
+	wantDiff := "--- a/existing.yaml\n+++ b/existing.yaml\n@@ -1,2 +1,2 @@\n a: 1\n-b: 2\n+b: 3\n"
+	if c := changes["existing.yaml"]; c.Action != PlanActionUpdate || c.Diff != wantDiff {
+		t.Errorf("existing.yaml = %+v, expected update with diff %q", c, wantDiff)
+	}
+}
+
+func TestPlainGitBootstrapper_planObjects(t *testing.T) {
+	manifest := func(data string) string {
+		return "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n  namespace: flux-system\ndata:\n  key: " + data + "\n"
+	}
+	existing, err := ssa.ReadObjects(strings.NewReader(manifest("value")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		existing bool
+		manifest string
+		want     []PlanChange
+	}{
+		{"create", false, manifest("value"), []PlanChange{{Kind: "ConfigMap", Name: "flux-system/config", Action: PlanActionCreate}}},
+		{"update", true, manifest("changed"), []PlanChange{{Kind: "ConfigMap", Name: "flux-system/config", Action: PlanActionUpdate}}},
+		{"unchanged", true, manifest("value"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := ssa.ReadObjects(strings.NewReader(tt.manifest))
+			if err != nil {
+				t.Fatal(err)
+			}
+			builder := fake.NewClientBuilder().WithScheme(utils.NewScheme())
+			if tt.existing {
+				builder = builder.WithObjects(existing[0].DeepCopy())
+			}
+			b := &PlainGitBootstrapper{
+				kube: dryRunApplyClient{builder.Build()},
+				plan: &Plan{},
+			}
+
+			if err := b.planObjects(context.TODO(), objects); err != nil {
+				t.Fatal(err)
+			}
+			if len(b.plan.Changes) != len(tt.want) {
+				t.Fatalf("planObjects() changes = %+v, want %+v", b.plan.Changes, tt.want)
+			}
+			for i := range tt.want {
+				if b.plan.Changes[i] != tt.want[i] {
+					t.Errorf("planObjects() change = %+v, want %+v", b.plan.Changes[i], tt.want[i])
+				}
+			}
+
+			var buf bytes.Buffer
+			if err := b.plan.Print(&buf); err != nil {
+				t.Fatal(err)
+			}
+			upToDate := strings.Contains(buf.String(), "no changes, the bootstrap configuration is up to date")
+			if upToDate != (len(tt.want) == 0) {
+				t.Errorf("Print() = %q", buf.String())
+			}
+		})
+	}
+}
+
+func TestPlan_Print(t *testing.T) {
+	p := &Plan{}
+	var buf bytes.Buffer
+	if err := p.Print(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "no changes, the bootstrap configuration is up to date\n"; buf.String() != want {
+		t.Errorf("Print() = %q, want %q", buf.String(), want)
+	}
+
+	p.add("Git repository", "org/repo", PlanActionCreate, "")
+	p.add("file", "gotk-sync.yaml", PlanActionUpdate, "-a\n+b\n")
+	p.add("deploy key", "flux-system", PlanActionReplace, "")
+	p.add("Git repository", "org/repo", PlanActionUpdate, "")
+	buf.Reset()
+	if err := p.Print(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `+ create Git repository "org/repo"
+~ update file "gotk-sync.yaml"
+    -a
+    +b
+-/+ replace deploy key "flux-system"
+`
+	if buf.String() != want {
+		t.Errorf("Print() = %q, want %q", buf.String(), want)
+	}
+}
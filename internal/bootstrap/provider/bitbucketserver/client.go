@@ -0,0 +1,274 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package bitbucketserver implements the gitprovider.Client interface
+// for Bitbucket Server and Bitbucket Data Center, using the REST API
+// 1.0.
+//
+// Bitbucket projects are mapped to organizations, repositories in the
+// personal project of a user to user repositories, groups to teams and
+// repository access keys to deploy keys.
+package bitbucketserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/fluxcd/go-git-providers/gitprovider"
+)
+
+const (
+	// ProviderID is the provider ID for Bitbucket Server.
+	ProviderID = gitprovider.ProviderID("bitbucket-server")
+
+	// pageSize is the number of items requested per page for paginated
+	// API calls.
+	pageSize = 100
+
+	apiPath  = "/rest/api/1.0"
+	keysPath = "/rest/keys/1.0"
+)
+
+// ClientOption configures the Client returned by NewClient.
+type ClientOption func(*Client)
+
+// WithHTTPClient sets the http.Client used to talk to the Bitbucket
+// Server API.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// Client implements the gitprovider.Client interface.
+var _ gitprovider.Client = &Client{}
+
+// Client allows talking to a Bitbucket Server instance.
+type Client struct {
+	domain     string
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient returns a new Client for the Bitbucket Server instance at
+// the given domain, authenticating with the given HTTP access token.
+// The domain may be prefixed with a "http://" or "https://" scheme,
+// when omitted HTTPS is assumed.
+func NewClient(domain, token string, opts ...ClientOption) (*Client, error) {
+	if domain == "" {
+		return nil, fmt.Errorf("%w: a domain is required", gitprovider.ErrInvalidClientOptions)
+	}
+	if _, err := url.Parse(gitprovider.GetDomainURL(domain)); err != nil {
+		return nil, fmt.Errorf("invalid domain %q: %w", domain, err)
+	}
+	c := &Client{
+		domain:     domain,
+		token:      token,
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// SupportedDomain returns the domain endpoint for this client.
+func (c *Client) SupportedDomain() string {
+	return c.domain
+}
+
+// ProviderID returns the provider ID "bitbucket-server".
+func (c *Client) ProviderID() gitprovider.ProviderID {
+	return ProviderID
+}
+
+// Raw returns the http.Client used under the hood for accessing
+// Bitbucket Server.
+func (c *Client) Raw() interface{} {
+	return c.httpClient
+}
+
+// HasTokenPermission returns true if the token is valid. The scope of
+// HTTP access tokens can not be inspected through the API, which means
+// this only confirms the token is accepted.
+func (c *Client) HasTokenPermission(ctx context.Context, permission gitprovider.TokenPermission) (bool, error) {
+	if permission != gitprovider.TokenPermissionRWRepository {
+		return false, gitprovider.ErrNoProviderSupport
+	}
+	if err := c.do(ctx, http.MethodGet, apiPath+"/projects?limit=1", nil, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Organizations returns the OrganizationsClient handling Bitbucket
+// projects.
+func (c *Client) Organizations() gitprovider.OrganizationsClient {
+	return &OrganizationsClient{c}
+}
+
+// OrgRepositories returns the OrgRepositoriesClient handling sets of
+// repositories in a project.
+func (c *Client) OrgRepositories() gitprovider.OrgRepositoriesClient {
+	return &OrgRepositoriesClient{c}
+}
+
+// UserRepositories returns the UserRepositoriesClient handling sets of
+// repositories in the personal project of a user.
+func (c *Client) UserRepositories() gitprovider.UserRepositoriesClient {
+	return &UserRepositoriesClient{c}
+}
+
+// apiErrors is the error object returned by the Bitbucket Server API.
+type apiErrors struct {
+	Errors []struct {
+		Message       string `json:"message"`
+		ExceptionName string `json:"exceptionName"`
+	} `json:"errors"`
+}
+
+// apiPage is a page of a paginated API response.
+type apiPage struct {
+	Values        json.RawMessage `json:"values"`
+	IsLastPage    bool            `json:"isLastPage"`
+	NextPageStart int             `json:"nextPageStart"`
+}
+
+// do performs an API request with the given method against the given
+// path, which is relative to the server root. When in is not nil, it
+// is encoded to JSON and sent as the request body. When out is not nil,
+// the response body is decoded into it.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	u := strings.TrimSuffix(gitprovider.GetDomainURL(c.domain), "/") + path
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := errorFromResponse(resp); err != nil {
+		return err
+	}
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("%w: %s", gitprovider.ErrInvalidServerData, err.Error())
+		}
+	}
+	return nil
+}
+
+// list retrieves all pages of the given path, and calls fn with the
+// values of each page.
+func (c *Client) list(ctx context.Context, path string, fn func(values json.RawMessage) error) error {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	start := 0
+	for {
+		var page apiPage
+		if err := c.do(ctx, http.MethodGet, fmt.Sprintf("%s%sstart=%d&limit=%d", path, sep, start, pageSize), nil, &page); err != nil {
+			return err
+		}
+		if err := fn(page.Values); err != nil {
+			return fmt.Errorf("%w: %s", gitprovider.ErrInvalidServerData, err.Error())
+		}
+		if page.IsLastPage || page.NextPageStart <= start {
+			return nil
+		}
+		start = page.NextPageStart
+	}
+}
+
+// errorFromResponse maps non-2xx responses to gitprovider errors.
+func errorFromResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	var apiErr apiErrors
+	b, _ := io.ReadAll(resp.Body)
+	_ = json.Unmarshal(b, &apiErr)
+	var messages []string
+	for _, e := range apiErr.Errors {
+		messages = append(messages, e.Message)
+	}
+	message := strings.Join(messages, "; ")
+	if message == "" {
+		message = strings.TrimSpace(string(b))
+	}
+
+	httpErr := gitprovider.HTTPError{
+		Response:     resp,
+		ErrorMessage: fmt.Sprintf("%s %s: %d %s", resp.Request.Method, resp.Request.URL, resp.StatusCode, message),
+		Message:      message,
+	}
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return gitprovider.ErrNotFound
+	case http.StatusConflict:
+		return gitprovider.ErrAlreadyExists
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return &gitprovider.InvalidCredentialsError{HTTPError: httpErr}
+	case http.StatusBadRequest:
+		return &gitprovider.ValidationError{HTTPError: httpErr}
+	default:
+		return &httpErr
+	}
+}
+
+// projectKey returns the key of the Bitbucket project the given
+// identity refers to. Personal projects of users are prefixed with a
+// "~".
+func projectKey(ref gitprovider.IdentityRef) string {
+	if ref.GetType() == gitprovider.IdentityTypeUser {
+		return "~" + ref.GetIdentity()
+	}
+	return ref.GetIdentity()
+}
+
+// repoPath returns the API path of the given repository, with the given
+// prefix.
+func repoPath(prefix string, ref gitprovider.RepositoryRef) string {
+	return fmt.Sprintf("%s/projects/%s/repos/%s", prefix, url.PathEscape(projectKey(ref)), url.PathEscape(ref.GetRepository()))
+}
@@ -0,0 +1,484 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitbucketserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fluxcd/go-git-providers/gitprovider"
+)
+
+// fakeBitbucket is a minimal in-memory stand-in for the Bitbucket Server
+// API, serving the endpoints used during bootstrap.
+type fakeBitbucket struct {
+	mu             sync.Mutex
+	token          string
+	repos          map[string]*apiRepository
+	defaultBranch  map[string]string
+	keys           map[string][]apiAccessKey
+	groups         map[string]bool
+	repoGroupPerms map[string]map[string]string
+	pulls          map[string][]fakePullRequest
+	nextID         int64
+}
+
+// fakePullRequest is a pull request known to the fake, by the branch it
+// was opened from.
+type fakePullRequest struct {
+	FromRef string `json:"-"`
+	Merged  bool   `json:"-"`
+	Title   string `json:"title"`
+}
+
+func newFakeBitbucket(t *testing.T, token string) (*fakeBitbucket, *Client) {
+	f := &fakeBitbucket{
+		token:          token,
+		repos:          map[string]*apiRepository{},
+		defaultBranch:  map[string]string{},
+		keys:           map[string][]apiAccessKey{},
+		groups:         map[string]bool{"devs": true},
+		repoGroupPerms: map[string]map[string]string{},
+		pulls:          map[string][]fakePullRequest{},
+	}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	c, err := NewClient(srv.URL, token)
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+	return f, c
+}
+
+func (f *fakeBitbucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer "+f.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var path []string
+	var keys bool
+	switch {
+	case strings.HasPrefix(r.URL.Path, apiPath):
+		path = strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, apiPath), "/"), "/")
+	case strings.HasPrefix(r.URL.Path, keysPath):
+		path = strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, keysPath), "/"), "/")
+		keys = true
+	}
+
+	switch {
+	case len(path) == 1 && path[0] == "projects" && r.Method == http.MethodGet:
+		writePage(w, []apiProject{})
+	case len(path) == 3 && path[0] == "projects" && path[2] == "repos" && r.Method == http.MethodPost:
+		var opts createRepositoryOptions
+		_ = json.NewDecoder(r.Body).Decode(&opts)
+		slug := strings.ToLower(opts.Name)
+		key := path[1] + "/" + slug
+		if _, ok := f.repos[key]; ok {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.nextID++
+		repo := &apiRepository{
+			ID:          f.nextID,
+			Slug:        slug,
+			Name:        opts.Name,
+			Description: opts.Description,
+			Public:      opts.Public,
+			Project:     apiProject{Key: path[1]},
+		}
+		f.repos[key] = repo
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(repo)
+	case len(path) >= 4 && path[0] == "projects" && path[2] == "repos":
+		key := path[1] + "/" + path[3]
+		repo, ok := f.repos[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if keys {
+			f.serveKeys(w, r, key, path[4:])
+			return
+		}
+		f.serveRepo(w, r, key, repo, path[4:])
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeBitbucket) serveRepo(w http.ResponseWriter, r *http.Request, key string, repo *apiRepository, path []string) {
+	switch {
+	case len(path) == 0 && r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode(repo)
+	case len(path) == 0 && r.Method == http.MethodPut:
+		var opts editRepositoryOptions
+		_ = json.NewDecoder(r.Body).Decode(&opts)
+		if opts.Description != nil {
+			repo.Description = *opts.Description
+		}
+		if opts.Public != nil {
+			repo.Public = *opts.Public
+		}
+		_ = json.NewEncoder(w).Encode(repo)
+	case len(path) == 2 && path[0] == "branches" && path[1] == "default":
+		switch r.Method {
+		case http.MethodGet:
+			b, ok := f.defaultBranch[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(apiBranch{ID: "refs/heads/" + b})
+		case http.MethodPut:
+			var b apiBranch
+			_ = json.NewDecoder(r.Body).Decode(&b)
+			f.defaultBranch[key] = strings.TrimPrefix(b.ID, "refs/heads/")
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case len(path) == 1 && path[0] == "pull-requests" && r.Method == http.MethodPost:
+		var opts createPullRequestOptions
+		_ = json.NewDecoder(r.Body).Decode(&opts)
+		pr := fakePullRequest{FromRef: opts.FromRef.ID, Title: opts.Title}
+		f.pulls[key] = append(f.pulls[key], pr)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(pr)
+	case len(path) == 1 && path[0] == "pull-requests" && r.Method == http.MethodGet:
+		pulls := []fakePullRequest{}
+		for _, pr := range f.pulls[key] {
+			if pr.FromRef == r.URL.Query().Get("at") && pr.Merged == (r.URL.Query().Get("state") == "MERGED") {
+				pulls = append(pulls, pr)
+			}
+		}
+		writePage(w, pulls)
+	case len(path) == 2 && path[0] == "permissions" && path[1] == "groups":
+		switch r.Method {
+		case http.MethodGet:
+			filter := r.URL.Query().Get("filter")
+			perms := []apiGroupPermission{}
+			for name, perm := range f.repoGroupPerms[key] {
+				if strings.Contains(name, filter) {
+					p := apiGroupPermission{Permission: perm}
+					p.Group.Name = name
+					perms = append(perms, p)
+				}
+			}
+			writePage(w, perms)
+		case http.MethodPut:
+			name := r.URL.Query().Get("name")
+			if !f.groups[name] {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if f.repoGroupPerms[key] == nil {
+				f.repoGroupPerms[key] = map[string]string{}
+			}
+			f.repoGroupPerms[key][name] = r.URL.Query().Get("permission")
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeBitbucket) serveKeys(w http.ResponseWriter, r *http.Request, key string, path []string) {
+	switch {
+	case len(path) == 1 && path[0] == "ssh" && r.Method == http.MethodGet:
+		keys := f.keys[key]
+		if keys == nil {
+			keys = []apiAccessKey{}
+		}
+		writePage(w, keys)
+	case len(path) == 1 && path[0] == "ssh" && r.Method == http.MethodPost:
+		var k apiAccessKey
+		_ = json.NewDecoder(r.Body).Decode(&k)
+		f.nextID++
+		k.Key.ID = f.nextID
+		f.keys[key] = append(f.keys[key], k)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(k)
+	case len(path) == 2 && path[0] == "ssh" && r.Method == http.MethodDelete:
+		id, _ := strconv.ParseInt(path[1], 10, 64)
+		var keys []apiAccessKey
+		for _, k := range f.keys[key] {
+			if k.Key.ID != id {
+				keys = append(keys, k)
+			}
+		}
+		f.keys[key] = keys
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func writePage(w http.ResponseWriter, values interface{}) {
+	b, _ := json.Marshal(values)
+	_ = json.NewEncoder(w).Encode(apiPage{Values: b, IsLastPage: true})
+}
+
+func TestOrgRepositoriesClient(t *testing.T) {
+	f, c := newFakeBitbucket(t, "token")
+	ctx := context.TODO()
+
+	ref := gitprovider.OrgRepositoryRef{
+		OrganizationRef: gitprovider.OrganizationRef{Domain: c.SupportedDomain(), Organization: "PRJ"},
+		RepositoryName:  "fleet",
+	}
+
+	if _, err := c.OrgRepositories().Get(ctx, ref); !errors.Is(err, gitprovider.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	repo, err := c.OrgRepositories().Create(ctx, ref, gitprovider.RepositoryInfo{
+		DefaultBranch: gitprovider.StringVar("main"),
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if got := *repo.Get().Visibility; got != gitprovider.RepositoryVisibilityPrivate {
+		t.Errorf("expected repository to default to private, got %q", got)
+	}
+	if got := f.defaultBranch["PRJ/fleet"]; got != "main" {
+		t.Errorf("expected default branch to be main, got %q", got)
+	}
+
+	if _, err := c.OrgRepositories().Create(ctx, ref, gitprovider.RepositoryInfo{}); !errors.Is(err, gitprovider.ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	}
+
+	_, changed, err := c.OrgRepositories().Reconcile(ctx, ref, gitprovider.RepositoryInfo{
+		DefaultBranch: gitprovider.StringVar("main"),
+		Visibility:    gitprovider.RepositoryVisibilityVar(gitprovider.RepositoryVisibilityPrivate),
+	})
+	if err != nil || changed {
+		t.Errorf("Reconcile() = %v, %v, expected no changes", changed, err)
+	}
+
+	_, changed, err = c.OrgRepositories().Reconcile(ctx, ref, gitprovider.RepositoryInfo{
+		DefaultBranch: gitprovider.StringVar("dev"),
+		Visibility:    gitprovider.RepositoryVisibilityVar(gitprovider.RepositoryVisibilityPublic),
+	})
+	if err != nil || !changed {
+		t.Errorf("Reconcile() = %v, %v, expected changes", changed, err)
+	}
+	if !f.repos["PRJ/fleet"].Public {
+		t.Errorf("expected repository to be public")
+	}
+	if got := f.defaultBranch["PRJ/fleet"]; got != "dev" {
+		t.Errorf("expected default branch to be dev, got %q", got)
+	}
+}
+
+func TestRepositoryRef_GetCloneURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		ref       gitprovider.RepositoryRef
+		https     string
+		ssh       string
+		transport gitprovider.TransportType
+		want      string
+	}{
+		{
+			name: "https from API",
+			ref: gitprovider.OrgRepositoryRef{
+				OrganizationRef: gitprovider.OrganizationRef{Domain: "bitbucket.example.com", Organization: "PRJ"},
+				RepositoryName:  "fleet",
+			},
+			https:     "https://user@bitbucket.example.com/scm/prj/fleet.git",
+			transport: gitprovider.TransportTypeHTTPS,
+			want:      "https://bitbucket.example.com/scm/prj/fleet.git",
+		},
+		{
+			name: "ssh from API",
+			ref: gitprovider.OrgRepositoryRef{
+				OrganizationRef: gitprovider.OrganizationRef{Domain: "bitbucket.example.com", Organization: "PRJ"},
+				RepositoryName:  "fleet",
+			},
+			ssh:       "ssh://git@bitbucket.example.com:7999/prj/fleet.git",
+			transport: gitprovider.TransportTypeSSH,
+			want:      "ssh://git@bitbucket.example.com:7999/prj/fleet.git",
+		},
+		{
+			name: "https fallback",
+			ref: gitprovider.UserRepositoryRef{
+				UserRef:        gitprovider.UserRef{Domain: "bitbucket.example.com", UserLogin: "jdoe"},
+				RepositoryName: "fleet",
+			},
+			transport: gitprovider.TransportTypeHTTPS,
+			want:      "https://bitbucket.example.com/scm/~jdoe/fleet.git",
+		},
+		{
+			name: "ssh fallback",
+			ref: gitprovider.OrgRepositoryRef{
+				OrganizationRef: gitprovider.OrganizationRef{Domain: "https://bitbucket.example.com", Organization: "PRJ"},
+				RepositoryName:  "fleet",
+			},
+			transport: gitprovider.TransportTypeSSH,
+			want:      "ssh://git@bitbucket.example.com:7999/prj/fleet.git",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref := repositoryRef{RepositoryRef: tt.ref, httpsURL: withoutUser(tt.https), sshURL: tt.ssh}
+			if got := ref.GetCloneURL(tt.transport); got != tt.want {
+				t.Errorf("GetCloneURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeployKeyClient_Reconcile(t *testing.T) {
+	f, c := newFakeBitbucket(t, "token")
+	ctx := context.TODO()
+
+	ref := gitprovider.UserRepositoryRef{
+		UserRef:        gitprovider.UserRef{Domain: c.SupportedDomain(), UserLogin: "jdoe"},
+		RepositoryName: "fleet",
+	}
+	repo, err := c.UserRepositories().Create(ctx, ref, gitprovider.RepositoryInfo{})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		key            string
+		readOnly       *bool
+		wantChanged    bool
+		wantPermission string
+	}{
+		{"create", "ssh-ed25519 AAAAC3 flux\n", nil, true, accessKeyRead},
+		{"unchanged", "ssh-ed25519 AAAAC3 other-comment", nil, false, accessKeyRead},
+		{"read-write", "ssh-ed25519 AAAAC3", gitprovider.BoolVar(false), true, accessKeyWrite},
+		{"rotated", "ssh-ed25519 AAAAC4", gitprovider.BoolVar(false), true, accessKeyWrite},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, changed, err := repo.DeployKeys().Reconcile(ctx, gitprovider.DeployKeyInfo{
+				Name:     "flux-system-main",
+				Key:      []byte(tt.key),
+				ReadOnly: tt.readOnly,
+			})
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("Reconcile() changed = %v, want %v", changed, tt.wantChanged)
+			}
+			keys := f.keys["~jdoe/fleet"]
+			if n := len(keys); n != 1 {
+				t.Fatalf("expected exactly one access key, got %d", n)
+			}
+			if got := keys[0].Permission; got != tt.wantPermission {
+				t.Errorf("expected access key permission %q, got %q", tt.wantPermission, got)
+			}
+		})
+	}
+}
+
+func TestTeamAccessClient_Reconcile(t *testing.T) {
+	f, c := newFakeBitbucket(t, "token")
+	ctx := context.TODO()
+
+	ref := gitprovider.OrgRepositoryRef{
+		OrganizationRef: gitprovider.OrganizationRef{Domain: c.SupportedDomain(), Organization: "PRJ"},
+		RepositoryName:  "fleet",
+	}
+	repo, err := c.OrgRepositories().Create(ctx, ref, gitprovider.RepositoryInfo{})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	info := gitprovider.TeamAccessInfo{
+		Name:       "devs",
+		Permission: gitprovider.RepositoryPermissionVar(gitprovider.RepositoryPermissionMaintain),
+	}
+	if _, changed, err := repo.TeamAccess().Reconcile(ctx, info); err != nil || !changed {
+		t.Fatalf("Reconcile() = %v, %v, expected changes", changed, err)
+	}
+	if got := f.repoGroupPerms["PRJ/fleet"]["devs"]; got != "REPO_WRITE" {
+		t.Errorf("expected group permission to be REPO_WRITE, got %q", got)
+	}
+	if _, changed, err := repo.TeamAccess().Reconcile(ctx, info); err != nil || changed {
+		t.Errorf("Reconcile() = %v, %v, expected no changes", changed, err)
+	}
+
+	info.Permission = gitprovider.RepositoryPermissionVar(gitprovider.RepositoryPermissionAdmin)
+	if _, changed, err := repo.TeamAccess().Reconcile(ctx, info); err != nil || !changed {
+		t.Errorf("Reconcile() = %v, %v, expected changes", changed, err)
+	}
+	if got := f.repoGroupPerms["PRJ/fleet"]["devs"]; got != "REPO_ADMIN" {
+		t.Errorf("expected group permission to be REPO_ADMIN, got %q", got)
+	}
+
+	if _, _, err = repo.TeamAccess().Reconcile(ctx, gitprovider.TeamAccessInfo{Name: "unknown"}); !errors.Is(err, gitprovider.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for unknown group, got %v", err)
+	}
+}
+
+func TestPullRequestClient_Merged(t *testing.T) {
+	f, c := newFakeBitbucket(t, "token")
+	ctx := context.TODO()
+
+	ref := gitprovider.UserRepositoryRef{
+		UserRef:        gitprovider.UserRef{Domain: c.SupportedDomain(), UserLogin: "jdoe"},
+		RepositoryName: "fleet",
+	}
+	repo, err := c.UserRepositories().Create(ctx, ref, gitprovider.RepositoryInfo{})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := repo.PullRequests().Create(ctx, "Add Flux", "flux-bootstrap", "main", ""); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	prs := repo.PullRequests().(*PullRequestClient)
+	if merged, err := prs.Merged(ctx, "flux-bootstrap"); err != nil || merged {
+		t.Errorf("Merged() = %v, %v, expected open pull request", merged, err)
+	}
+	for key := range f.pulls {
+		f.pulls[key][0].Merged = true
+	}
+	if merged, err := prs.Merged(ctx, "flux-bootstrap"); err != nil || !merged {
+		t.Errorf("Merged() = %v, %v, expected merged pull request", merged, err)
+	}
+	if merged, err := prs.Merged(ctx, "other"); err != nil || merged {
+		t.Errorf("Merged() = %v, %v, expected no pull request for other branch", merged, err)
+	}
+}
+
+func TestClient_InvalidToken(t *testing.T) {
+	_, c := newFakeBitbucket(t, "token")
+	c.token = "invalid"
+
+	var credErr *gitprovider.InvalidCredentialsError
+	if _, err := c.HasTokenPermission(context.TODO(), gitprovider.TokenPermissionRWRepository); !errors.As(err, &credErr) {
+		t.Errorf("expected InvalidCredentialsError, got %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitbucketserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/fluxcd/go-git-providers/gitprovider"
+)
+
+// apiCommit is the commit object returned by the Bitbucket Server API.
+type apiCommit struct {
+	ID string `json:"id"`
+}
+
+// apiRef is a reference to a branch in a repository, as used by pull
+// requests.
+type apiRef struct {
+	ID         string `json:"id"`
+	Repository struct {
+		Slug    string `json:"slug"`
+		Project struct {
+			Key string `json:"key"`
+		} `json:"project"`
+	} `json:"repository"`
+}
+
+// createBranchOptions is the request body for creating a branch.
+type createBranchOptions struct {
+	Name       string `json:"name"`
+	StartPoint string `json:"startPoint"`
+}
+
+// createPullRequestOptions is the request body for creating a pull
+// request.
+type createPullRequestOptions struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	FromRef     apiRef `json:"fromRef"`
+	ToRef       apiRef `json:"toRef"`
+}
+
+// CommitClient implements the gitprovider.CommitClient interface.
+var _ gitprovider.CommitClient = &CommitClient{}
+
+// CommitClient operates on the commits of a specific repository.
+type CommitClient struct {
+	*Client
+	ref gitprovider.RepositoryRef
+}
+
+// ListPage lists the commits of the given branch, for the given page
+// and page size. Pages start at 1.
+func (c *CommitClient) ListPage(ctx context.Context, branch string, perPage int, page int) ([]gitprovider.Commit, error) {
+	if page < 1 {
+		page = 1
+	}
+	path := fmt.Sprintf("%s/commits?until=%s&start=%d&limit=%d",
+		repoPath(apiPath, c.ref), url.QueryEscape(branch), (page-1)*perPage, perPage)
+	var p apiPage
+	if err := c.do(ctx, http.MethodGet, path, nil, &p); err != nil {
+		return nil, err
+	}
+	var apiObjs []apiCommit
+	if err := json.Unmarshal(p.Values, &apiObjs); err != nil {
+		return nil, fmt.Errorf("%w: %s", gitprovider.ErrInvalidServerData, err.Error())
+	}
+	commits := make([]gitprovider.Commit, 0, len(apiObjs))
+	for _, apiObj := range apiObjs {
+		commits = append(commits, &commit{apiObj})
+	}
+	return commits, nil
+}
+
+// Create is not supported, as the Bitbucket Server API does not allow
+// creating a single commit for multiple files.
+func (c *CommitClient) Create(_ context.Context, _ string, _ string, _ []gitprovider.CommitFile) (gitprovider.Commit, error) {
+	return nil, gitprovider.ErrNoProviderSupport
+}
+
+var _ gitprovider.Commit = &commit{}
+
+type commit struct {
+	c apiCommit
+}
+
+// Get returns the commit information. The tree SHA is not available
+// through the Bitbucket Server API.
+func (c *commit) Get() gitprovider.CommitInfo {
+	return gitprovider.CommitInfo{
+		Sha: c.c.ID,
+	}
+}
+
+func (c *commit) APIObject() interface{} {
+	return &c.c
+}
+
+// BranchClient implements the gitprovider.BranchClient interface.
+var _ gitprovider.BranchClient = &BranchClient{}
+
+// BranchClient operates on the branches of a specific repository.
+type BranchClient struct {
+	*Client
+	ref gitprovider.RepositoryRef
+}
+
+// Create creates a branch with the given name, starting at the given
+// commit SHA.
+func (c *BranchClient) Create(ctx context.Context, branch, sha string) error {
+	body := createBranchOptions{
+		Name:       branch,
+		StartPoint: sha,
+	}
+	return c.do(ctx, http.MethodPost, repoPath(apiPath, c.ref)+"/branches", body, nil)
+}
+
+// PullRequestClient implements the gitprovider.PullRequestClient
+// interface.
+var _ gitprovider.PullRequestClient = &PullRequestClient{}
+
+// PullRequestClient operates on the pull requests of a specific
+// repository.
+type PullRequestClient struct {
+	*Client
+	ref gitprovider.RepositoryRef
+}
+
+// Create opens a pull request to merge branch into baseBranch.
+func (c *PullRequestClient) Create(ctx context.Context, title, branch, baseBranch, description string) error {
+	body := createPullRequestOptions{
+		Title:       title,
+		Description: description,
+		FromRef:     c.branchRef(branch),
+		ToRef:       c.branchRef(baseBranch),
+	}
+	return c.do(ctx, http.MethodPost, repoPath(apiPath, c.ref)+"/pull-requests", body, nil)
+}
+
+// Merged returns true if a pull request opened from branch has been
+// merged.
+func (c *PullRequestClient) Merged(ctx context.Context, branch string) (bool, error) {
+	path := fmt.Sprintf("%s/pull-requests?state=MERGED&direction=OUTGOING&at=%s",
+		repoPath(apiPath, c.ref), url.QueryEscape("refs/heads/"+branch))
+	var merged bool
+	err := c.list(ctx, path, func(values json.RawMessage) error {
+		var prs []json.RawMessage
+		if err := json.Unmarshal(values, &prs); err != nil {
+			return err
+		}
+		if len(prs) > 0 {
+			merged = true
+		}
+		return nil
+	})
+	return merged, err
+}
+
+func (c *PullRequestClient) branchRef(branch string) apiRef {
+	r := apiRef{ID: "refs/heads/" + branch}
+	r.Repository.Slug = c.ref.GetRepository()
+	r.Repository.Project.Key = projectKey(c.ref)
+	return r
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitbucketserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/fluxcd/go-git-providers/gitprovider"
+)
+
+const (
+	accessKeyRead  = "REPO_READ"
+	accessKeyWrite = "REPO_WRITE"
+)
+
+// apiAccessKey is the repository access key object returned by the
+// Bitbucket Server API.
+type apiAccessKey struct {
+	Key struct {
+		ID    int64  `json:"id,omitempty"`
+		Text  string `json:"text"`
+		Label string `json:"label"`
+	} `json:"key"`
+	Permission string `json:"permission"`
+}
+
+// DeployKeyClient implements the gitprovider.DeployKeyClient interface.
+var _ gitprovider.DeployKeyClient = &DeployKeyClient{}
+
+// DeployKeyClient operates on the access keys of a specific repository.
+// The name of a deploy key maps to the label of the access key.
+type DeployKeyClient struct {
+	*Client
+	ref gitprovider.RepositoryRef
+}
+
+// Get returns the access key with the given label.
+//
+// ErrNotFound is returned if the resource does not exist.
+func (c *DeployKeyClient) Get(ctx context.Context, name string) (gitprovider.DeployKey, error) {
+	keys, err := c.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		if k.k.Key.Label == name {
+			return k, nil
+		}
+	}
+	return nil, gitprovider.ErrNotFound
+}
+
+// List returns all access keys of the repository.
+func (c *DeployKeyClient) List(ctx context.Context) ([]gitprovider.DeployKey, error) {
+	keys, err := c.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]gitprovider.DeployKey, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, k)
+	}
+	return result, nil
+}
+
+func (c *DeployKeyClient) list(ctx context.Context) ([]*deployKey, error) {
+	var keys []*deployKey
+	err := c.Client.list(ctx, repoPath(keysPath, c.ref)+"/ssh", func(values json.RawMessage) error {
+		var apiObjs []apiAccessKey
+		if err := json.Unmarshal(values, &apiObjs); err != nil {
+			return err
+		}
+		for _, apiObj := range apiObjs {
+			keys = append(keys, &deployKey{k: apiObj, c: c})
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// Create creates an access key with the given specifications.
+//
+// ErrAlreadyExists will be returned if the resource already exists.
+func (c *DeployKeyClient) Create(ctx context.Context, req gitprovider.DeployKeyInfo) (gitprovider.DeployKey, error) {
+	k := &deployKey{c: c}
+	if err := k.Set(req); err != nil {
+		return nil, err
+	}
+	if err := k.create(ctx); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Reconcile makes sure the given desired state (req) becomes the actual
+// state in Bitbucket Server.
+func (c *DeployKeyClient) Reconcile(ctx context.Context, req gitprovider.DeployKeyInfo) (gitprovider.DeployKey, bool, error) {
+	if err := gitprovider.ValidateAndDefaultInfo(&req); err != nil {
+		return nil, false, err
+	}
+
+	actual, err := c.Get(ctx, req.Name)
+	if err != nil {
+		if errors.Is(err, gitprovider.ErrNotFound) {
+			resp, err := c.Create(ctx, req)
+			return resp, true, err
+		}
+		return nil, false, err
+	}
+
+	if deployKeyInfoEquals(req, actual.Get()) {
+		return actual, false, nil
+	}
+	if err := actual.Set(req); err != nil {
+		return actual, false, err
+	}
+	return actual, true, actual.Update(ctx)
+}
+
+var _ gitprovider.DeployKey = &deployKey{}
+
+type deployKey struct {
+	k apiAccessKey
+	c *DeployKeyClient
+}
+
+func (k *deployKey) Get() gitprovider.DeployKeyInfo {
+	return gitprovider.DeployKeyInfo{
+		Name:     k.k.Key.Label,
+		Key:      []byte(k.k.Key.Text),
+		ReadOnly: gitprovider.BoolVar(k.k.Permission != accessKeyWrite),
+	}
+}
+
+func (k *deployKey) Set(info gitprovider.DeployKeyInfo) error {
+	if err := gitprovider.ValidateAndDefaultInfo(&info); err != nil {
+		return err
+	}
+	k.k.Key.Label = info.Name
+	k.k.Key.Text = string(info.Key)
+	k.k.Permission = accessKeyRead
+	if !*info.ReadOnly {
+		k.k.Permission = accessKeyWrite
+	}
+	return nil
+}
+
+func (k *deployKey) APIObject() interface{} {
+	return &k.k
+}
+
+func (k *deployKey) Repository() gitprovider.RepositoryRef {
+	return k.c.ref
+}
+
+// Update deletes the access key and creates it again with the desired
+// state, as Bitbucket Server does not support changing the public key of
+// an access key.
+func (k *deployKey) Update(ctx context.Context) error {
+	if err := k.Delete(ctx); err != nil {
+		return err
+	}
+	return k.create(ctx)
+}
+
+// Reconcile makes sure the desired state of the access key becomes the
+// actual state in Bitbucket Server.
+func (k *deployKey) Reconcile(ctx context.Context) (bool, error) {
+	_, changed, err := k.c.Reconcile(ctx, k.Get())
+	return changed, err
+}
+
+// Delete revokes the access of the key to the repository.
+//
+// ErrNotFound is returned if the resource doesn't exist anymore.
+func (k *deployKey) Delete(ctx context.Context) error {
+	path := fmt.Sprintf("%s/ssh/%d", repoPath(keysPath, k.c.ref), k.k.Key.ID)
+	return k.c.do(ctx, http.MethodDelete, path, nil, nil)
+}
+
+func (k *deployKey) create(ctx context.Context) error {
+	body := k.k
+	body.Key.ID = 0
+	var apiObj apiAccessKey
+	if err := k.c.do(ctx, http.MethodPost, repoPath(keysPath, k.c.ref)+"/ssh", body, &apiObj); err != nil {
+		return err
+	}
+	k.k = apiObj
+	return nil
+}
+
+// deployKeyInfoEquals compares the given deploy keys, ignoring any
+// trailing whitespace or comments in the public keys.
+func deployKeyInfoEquals(desired, actual gitprovider.DeployKeyInfo) bool {
+	if desired.Name != actual.Name {
+		return false
+	}
+	if desired.ReadOnly != nil && actual.ReadOnly != nil && *desired.ReadOnly != *actual.ReadOnly {
+		return false
+	}
+	return bytes.Equal(keyData(desired.Key), keyData(actual.Key))
+}
+
+// keyData returns the type and base64 encoded data of the given
+// authorized key formatted public key.
+func keyData(key []byte) []byte {
+	fields := bytes.Fields(key)
+	if len(fields) < 2 {
+		return bytes.TrimSpace(key)
+	}
+	return bytes.Join(fields[:2], []byte(" "))
+}
@@ -0,0 +1,266 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitbucketserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/fluxcd/go-git-providers/gitprovider"
+)
+
+// apiProject is the project object returned by the Bitbucket Server API.
+type apiProject struct {
+	ID          int64  `json:"id"`
+	Key         string `json:"key"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// apiGroupPermission is the group permission object returned by the
+// Bitbucket Server API.
+type apiGroupPermission struct {
+	Group struct {
+		Name string `json:"name"`
+	} `json:"group"`
+	Permission string `json:"permission"`
+}
+
+// apiUser is the user object returned by the Bitbucket Server API.
+type apiUser struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// OrganizationsClient implements the gitprovider.OrganizationsClient
+// interface.
+var _ gitprovider.OrganizationsClient = &OrganizationsClient{}
+
+// OrganizationsClient operates on the projects the user has access to.
+type OrganizationsClient struct {
+	*Client
+}
+
+// Get returns the project with the key of the given reference.
+// Bitbucket Server does not support nested projects.
+//
+// ErrNotFound is returned if the resource does not exist.
+func (c *OrganizationsClient) Get(ctx context.Context, ref gitprovider.OrganizationRef) (gitprovider.Organization, error) {
+	if len(ref.SubOrganizations) > 0 {
+		return nil, gitprovider.ErrNoProviderSupport
+	}
+	var apiObj apiProject
+	if err := c.do(ctx, http.MethodGet, apiPath+"/projects/"+url.PathEscape(ref.Organization), nil, &apiObj); err != nil {
+		return nil, err
+	}
+	return newOrganization(c.Client, apiObj, ref), nil
+}
+
+// List returns all projects the user has access to.
+func (c *OrganizationsClient) List(ctx context.Context) ([]gitprovider.Organization, error) {
+	var orgs []gitprovider.Organization
+	err := c.list(ctx, apiPath+"/projects", func(values json.RawMessage) error {
+		var apiObjs []apiProject
+		if err := json.Unmarshal(values, &apiObjs); err != nil {
+			return err
+		}
+		for _, apiObj := range apiObjs {
+			ref := gitprovider.OrganizationRef{
+				Domain:       c.domain,
+				Organization: apiObj.Key,
+			}
+			orgs = append(orgs, newOrganization(c.Client, apiObj, ref))
+		}
+		return nil
+	})
+	return orgs, err
+}
+
+// Children is not supported by Bitbucket Server, as it does not have a
+// concept of nested projects.
+func (c *OrganizationsClient) Children(_ context.Context, _ gitprovider.OrganizationRef) ([]gitprovider.Organization, error) {
+	return nil, gitprovider.ErrNoProviderSupport
+}
+
+func newOrganization(c *Client, apiObj apiProject, ref gitprovider.OrganizationRef) *organization {
+	return &organization{
+		p:   apiObj,
+		ref: ref,
+		teams: &TeamsClient{
+			Client: c,
+			ref:    ref,
+		},
+	}
+}
+
+var _ gitprovider.Organization = &organization{}
+
+type organization struct {
+	p     apiProject
+	ref   gitprovider.OrganizationRef
+	teams *TeamsClient
+}
+
+func (o *organization) Get() gitprovider.OrganizationInfo {
+	info := gitprovider.OrganizationInfo{}
+	if o.p.Name != "" {
+		info.Name = gitprovider.StringVar(o.p.Name)
+	}
+	if o.p.Description != "" {
+		info.Description = gitprovider.StringVar(o.p.Description)
+	}
+	return info
+}
+
+func (o *organization) APIObject() interface{} {
+	return &o.p
+}
+
+func (o *organization) Organization() gitprovider.OrganizationRef {
+	return o.ref
+}
+
+func (o *organization) Teams() gitprovider.TeamsClient {
+	return o.teams
+}
+
+// TeamsClient implements the gitprovider.TeamsClient interface.
+var _ gitprovider.TeamsClient = &TeamsClient{}
+
+// TeamsClient allows reading the groups with access to a specific
+// project. Listing the members of a group requires the token to have
+// admin permissions.
+type TeamsClient struct {
+	*Client
+	ref gitprovider.OrganizationRef
+}
+
+// Get returns the group with the given name, including its members.
+//
+// ErrNotFound is returned if the resource does not exist.
+func (c *TeamsClient) Get(ctx context.Context, name string) (gitprovider.Team, error) {
+	return c.newTeam(ctx, name)
+}
+
+// List returns all groups with access to the project, including their
+// members.
+func (c *TeamsClient) List(ctx context.Context) ([]gitprovider.Team, error) {
+	var names []string
+	path := apiPath + "/projects/" + url.PathEscape(c.ref.Organization) + "/permissions/groups"
+	err := c.list(ctx, path, func(values json.RawMessage) error {
+		var apiObjs []apiGroupPermission
+		if err := json.Unmarshal(values, &apiObjs); err != nil {
+			return err
+		}
+		for _, apiObj := range apiObjs {
+			names = append(names, apiObj.Group.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	teams := make([]gitprovider.Team, 0, len(names))
+	for _, name := range names {
+		t, err := c.newTeam(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		teams = append(teams, t)
+	}
+	return teams, nil
+}
+
+func (c *TeamsClient) newTeam(ctx context.Context, name string) (*team, error) {
+	var members []string
+	path := apiPath + "/admin/groups/more-members?context=" + url.QueryEscape(name)
+	err := c.list(ctx, path, func(values json.RawMessage) error {
+		var apiObjs []apiUser
+		if err := json.Unmarshal(values, &apiObjs); err != nil {
+			return err
+		}
+		for _, u := range apiObjs {
+			members = append(members, u.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &team{
+		name:    name,
+		members: members,
+		ref:     c.ref,
+	}, nil
+}
+
+var _ gitprovider.Team = &team{}
+
+type team struct {
+	name    string
+	members []string
+	ref     gitprovider.OrganizationRef
+}
+
+func (t *team) Get() gitprovider.TeamInfo {
+	return gitprovider.TeamInfo{
+		Name:    t.name,
+		Members: t.members,
+	}
+}
+
+func (t *team) APIObject() interface{} {
+	return t.name
+}
+
+func (t *team) Organization() gitprovider.OrganizationRef {
+	return t.ref
+}
+
+// permissionToAPI maps a gitprovider.RepositoryPermission to a
+// Bitbucket Server repository permission. Bitbucket Server does not
+// distinguish triage from pull, and maintain from push.
+func permissionToAPI(permission gitprovider.RepositoryPermission) string {
+	switch permission {
+	case gitprovider.RepositoryPermissionPull, gitprovider.RepositoryPermissionTriage:
+		return "REPO_READ"
+	case gitprovider.RepositoryPermissionPush, gitprovider.RepositoryPermissionMaintain:
+		return "REPO_WRITE"
+	case gitprovider.RepositoryPermissionAdmin:
+		return "REPO_ADMIN"
+	default:
+		return ""
+	}
+}
+
+// permissionFromAPI maps a Bitbucket Server repository permission to a
+// gitprovider.RepositoryPermission.
+func permissionFromAPI(permission string) *gitprovider.RepositoryPermission {
+	switch permission {
+	case "REPO_READ":
+		return gitprovider.RepositoryPermissionVar(gitprovider.RepositoryPermissionPull)
+	case "REPO_WRITE":
+		return gitprovider.RepositoryPermissionVar(gitprovider.RepositoryPermissionPush)
+	case "REPO_ADMIN":
+		return gitprovider.RepositoryPermissionVar(gitprovider.RepositoryPermissionAdmin)
+	default:
+		return nil
+	}
+}
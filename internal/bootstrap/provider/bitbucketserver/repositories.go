@@ -0,0 +1,495 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitbucketserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/fluxcd/go-git-providers/gitprovider"
+)
+
+// apiRepository is the repository object returned by the Bitbucket
+// Server API.
+type apiRepository struct {
+	ID          int64      `json:"id"`
+	Slug        string     `json:"slug"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Public      bool       `json:"public"`
+	Project     apiProject `json:"project"`
+	Links       struct {
+		Clone []struct {
+			Href string `json:"href"`
+			Name string `json:"name"`
+		} `json:"clone"`
+	} `json:"links"`
+
+	// defaultBranch is retrieved with a separate request, as it is not
+	// part of the repository object.
+	defaultBranch string
+}
+
+// apiBranch is the branch object returned by the Bitbucket Server API.
+type apiBranch struct {
+	ID        string `json:"id"`
+	DisplayID string `json:"displayId"`
+}
+
+// createRepositoryOptions is the request body for creating a
+// repository.
+type createRepositoryOptions struct {
+	Name          string `json:"name"`
+	ScmID         string `json:"scmId"`
+	Description   string `json:"description,omitempty"`
+	Public        bool   `json:"public"`
+	DefaultBranch string `json:"defaultBranch,omitempty"`
+}
+
+// editRepositoryOptions is the request body for updating a repository.
+type editRepositoryOptions struct {
+	Description *string `json:"description,omitempty"`
+	Public      *bool   `json:"public,omitempty"`
+}
+
+// OrgRepositoriesClient implements the gitprovider.OrgRepositoriesClient
+// interface.
+var _ gitprovider.OrgRepositoriesClient = &OrgRepositoriesClient{}
+
+// OrgRepositoriesClient operates on repositories in projects.
+type OrgRepositoriesClient struct {
+	*Client
+}
+
+// Get returns the repository for the given reference. The repository
+// name of the reference is expected to be the repository slug.
+//
+// ErrNotFound is returned if the resource does not exist.
+func (c *OrgRepositoriesClient) Get(ctx context.Context, ref gitprovider.OrgRepositoryRef) (gitprovider.OrgRepository, error) {
+	if len(ref.SubOrganizations) > 0 {
+		return nil, gitprovider.ErrNoProviderSupport
+	}
+	apiObj, err := getRepository(ctx, c.Client, ref)
+	if err != nil {
+		return nil, err
+	}
+	return newOrgRepository(c.Client, *apiObj, ref), nil
+}
+
+// List returns all repositories in the given project.
+func (c *OrgRepositoriesClient) List(ctx context.Context, ref gitprovider.OrganizationRef) ([]gitprovider.OrgRepository, error) {
+	apiObjs, err := listRepositories(ctx, c.Client, ref)
+	if err != nil {
+		return nil, err
+	}
+	repos := make([]gitprovider.OrgRepository, 0, len(apiObjs))
+	for _, apiObj := range apiObjs {
+		repos = append(repos, newOrgRepository(c.Client, apiObj, gitprovider.OrgRepositoryRef{
+			OrganizationRef: ref,
+			RepositoryName:  apiObj.Slug,
+		}))
+	}
+	return repos, nil
+}
+
+// Create creates a repository in the given project, with the data and
+// options. The create options are ignored, as Bitbucket Server does not
+// support initializing a repository on creation.
+//
+// ErrAlreadyExists will be returned if the resource already exists.
+func (c *OrgRepositoriesClient) Create(ctx context.Context, ref gitprovider.OrgRepositoryRef, req gitprovider.RepositoryInfo, _ ...gitprovider.RepositoryCreateOption) (gitprovider.OrgRepository, error) {
+	if len(ref.SubOrganizations) > 0 {
+		return nil, gitprovider.ErrNoProviderSupport
+	}
+	apiObj, err := createRepository(ctx, c.Client, ref, req)
+	if err != nil {
+		return nil, err
+	}
+	return newOrgRepository(c.Client, *apiObj, ref), nil
+}
+
+// Reconcile makes sure the given desired state (req) becomes the actual
+// state in Bitbucket Server.
+func (c *OrgRepositoriesClient) Reconcile(ctx context.Context, ref gitprovider.OrgRepositoryRef, req gitprovider.RepositoryInfo, _ ...gitprovider.RepositoryReconcileOption) (gitprovider.OrgRepository, bool, error) {
+	actual, err := c.Get(ctx, ref)
+	if err != nil {
+		if errors.Is(err, gitprovider.ErrNotFound) {
+			resp, err := c.Create(ctx, ref, req)
+			return resp, true, err
+		}
+		return nil, false, err
+	}
+	changed, err := reconcileRepository(ctx, actual, req)
+	return actual, changed, err
+}
+
+// UserRepositoriesClient implements the
+// gitprovider.UserRepositoriesClient interface.
+var _ gitprovider.UserRepositoriesClient = &UserRepositoriesClient{}
+
+// UserRepositoriesClient operates on repositories in the personal
+// projects of users.
+type UserRepositoriesClient struct {
+	*Client
+}
+
+// Get returns the repository for the given reference. The repository
+// name of the reference is expected to be the repository slug.
+//
+// ErrNotFound is returned if the resource does not exist.
+func (c *UserRepositoriesClient) Get(ctx context.Context, ref gitprovider.UserRepositoryRef) (gitprovider.UserRepository, error) {
+	apiObj, err := getRepository(ctx, c.Client, ref)
+	if err != nil {
+		return nil, err
+	}
+	return newUserRepository(c.Client, *apiObj, ref), nil
+}
+
+// List returns all repositories in the personal project of the given
+// user.
+func (c *UserRepositoriesClient) List(ctx context.Context, ref gitprovider.UserRef) ([]gitprovider.UserRepository, error) {
+	apiObjs, err := listRepositories(ctx, c.Client, ref)
+	if err != nil {
+		return nil, err
+	}
+	repos := make([]gitprovider.UserRepository, 0, len(apiObjs))
+	for _, apiObj := range apiObjs {
+		repos = append(repos, newUserRepository(c.Client, apiObj, gitprovider.UserRepositoryRef{
+			UserRef:        ref,
+			RepositoryName: apiObj.Slug,
+		}))
+	}
+	return repos, nil
+}
+
+// Create creates a repository in the personal project of the user, with
+// the data and options. The create options are ignored, as Bitbucket
+// Server does not support initializing a repository on creation.
+//
+// ErrAlreadyExists will be returned if the resource already exists.
+func (c *UserRepositoriesClient) Create(ctx context.Context, ref gitprovider.UserRepositoryRef, req gitprovider.RepositoryInfo, _ ...gitprovider.RepositoryCreateOption) (gitprovider.UserRepository, error) {
+	apiObj, err := createRepository(ctx, c.Client, ref, req)
+	if err != nil {
+		return nil, err
+	}
+	return newUserRepository(c.Client, *apiObj, ref), nil
+}
+
+// Reconcile makes sure the given desired state (req) becomes the actual
+// state in Bitbucket Server.
+func (c *UserRepositoriesClient) Reconcile(ctx context.Context, ref gitprovider.UserRepositoryRef, req gitprovider.RepositoryInfo, _ ...gitprovider.RepositoryReconcileOption) (gitprovider.UserRepository, bool, error) {
+	actual, err := c.Get(ctx, ref)
+	if err != nil {
+		if errors.Is(err, gitprovider.ErrNotFound) {
+			resp, err := c.Create(ctx, ref, req)
+			return resp, true, err
+		}
+		return nil, false, err
+	}
+	changed, err := reconcileRepository(ctx, actual, req)
+	return actual, changed, err
+}
+
+func getRepository(ctx context.Context, c *Client, ref gitprovider.RepositoryRef) (*apiRepository, error) {
+	var apiObj apiRepository
+	if err := c.do(ctx, http.MethodGet, repoPath(apiPath, ref), nil, &apiObj); err != nil {
+		return nil, err
+	}
+	branch, err := getDefaultBranch(ctx, c, ref)
+	if err != nil {
+		return nil, err
+	}
+	apiObj.defaultBranch = branch
+	return &apiObj, nil
+}
+
+// getDefaultBranch returns the default branch of the repository, or an
+// empty string if the repository does not have any branches yet.
+func getDefaultBranch(ctx context.Context, c *Client, ref gitprovider.RepositoryRef) (string, error) {
+	var apiObj apiBranch
+	if err := c.do(ctx, http.MethodGet, repoPath(apiPath, ref)+"/branches/default", nil, &apiObj); err != nil {
+		if errors.Is(err, gitprovider.ErrNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimPrefix(apiObj.ID, "refs/heads/"), nil
+}
+
+func setDefaultBranch(ctx context.Context, c *Client, ref gitprovider.RepositoryRef, branch string) error {
+	body := apiBranch{ID: "refs/heads/" + branch}
+	return c.do(ctx, http.MethodPut, repoPath(apiPath, ref)+"/branches/default", body, nil)
+}
+
+func listRepositories(ctx context.Context, c *Client, ref gitprovider.IdentityRef) ([]apiRepository, error) {
+	var repos []apiRepository
+	path := apiPath + "/projects/" + url.PathEscape(projectKey(ref)) + "/repos"
+	err := c.list(ctx, path, func(values json.RawMessage) error {
+		var apiObjs []apiRepository
+		if err := json.Unmarshal(values, &apiObjs); err != nil {
+			return err
+		}
+		repos = append(repos, apiObjs...)
+		return nil
+	})
+	return repos, err
+}
+
+func createRepository(ctx context.Context, c *Client, ref gitprovider.RepositoryRef, req gitprovider.RepositoryInfo) (*apiRepository, error) {
+	if err := gitprovider.ValidateAndDefaultInfo(&req); err != nil {
+		return nil, err
+	}
+
+	body := createRepositoryOptions{
+		Name:          ref.GetRepository(),
+		ScmID:         "git",
+		Public:        *req.Visibility == gitprovider.RepositoryVisibilityPublic,
+		DefaultBranch: *req.DefaultBranch,
+	}
+	if req.Description != nil {
+		body.Description = *req.Description
+	}
+
+	var apiObj apiRepository
+	path := apiPath + "/projects/" + url.PathEscape(projectKey(ref)) + "/repos"
+	if err := c.do(ctx, http.MethodPost, path, body, &apiObj); err != nil {
+		return nil, err
+	}
+	// Older versions of Bitbucket Server ignore the default branch
+	// given on creation, configure it explicitly to be sure.
+	if err := setDefaultBranch(ctx, c, ref, *req.DefaultBranch); err != nil {
+		return nil, err
+	}
+	apiObj.defaultBranch = *req.DefaultBranch
+	return &apiObj, nil
+}
+
+// reconcileRepository updates the actual repository to match the
+// desired state, comparing only the fields set in req.
+func reconcileRepository(ctx context.Context, actual gitprovider.UserRepository, req gitprovider.RepositoryInfo) (bool, error) {
+	if err := req.ValidateInfo(); err != nil {
+		return false, err
+	}
+	info := actual.Get()
+	if (req.Description == nil || *req.Description == *info.Description) &&
+		(req.DefaultBranch == nil || *req.DefaultBranch == *info.DefaultBranch) &&
+		(req.Visibility == nil || *req.Visibility == *info.Visibility) {
+		return false, nil
+	}
+	if err := actual.Set(req); err != nil {
+		return false, err
+	}
+	return true, actual.Update(ctx)
+}
+
+func newUserRepository(c *Client, apiObj apiRepository, ref gitprovider.RepositoryRef) *userRepository {
+	return &userRepository{
+		Client: c,
+		r:      apiObj,
+		ref:    ref,
+	}
+}
+
+var _ gitprovider.UserRepository = &userRepository{}
+
+type userRepository struct {
+	*Client
+
+	r   apiRepository
+	ref gitprovider.RepositoryRef
+
+	// edit and defaultBranch hold the changes made with Set, to be
+	// applied with Update.
+	edit          editRepositoryOptions
+	defaultBranch *string
+}
+
+func (r *userRepository) Get() gitprovider.RepositoryInfo {
+	visibility := gitprovider.RepositoryVisibilityPrivate
+	if r.r.Public {
+		visibility = gitprovider.RepositoryVisibilityPublic
+	}
+	return gitprovider.RepositoryInfo{
+		Description:   gitprovider.StringVar(r.r.Description),
+		DefaultBranch: gitprovider.StringVar(r.r.defaultBranch),
+		Visibility:    gitprovider.RepositoryVisibilityVar(visibility),
+	}
+}
+
+// Set sets the desired state for the repository. Bitbucket Server does
+// not have a concept of internal repositories, which means the internal
+// visibility is handled as private.
+func (r *userRepository) Set(info gitprovider.RepositoryInfo) error {
+	if err := info.ValidateInfo(); err != nil {
+		return err
+	}
+	if info.Description != nil {
+		r.edit.Description = info.Description
+	}
+	if info.DefaultBranch != nil {
+		r.defaultBranch = info.DefaultBranch
+	}
+	if info.Visibility != nil {
+		r.edit.Public = gitprovider.BoolVar(*info.Visibility == gitprovider.RepositoryVisibilityPublic)
+	}
+	return nil
+}
+
+func (r *userRepository) APIObject() interface{} {
+	return &r.r
+}
+
+// Repository returns a reference to the repository, of which the clone
+// URLs are the ones reported by Bitbucket Server.
+func (r *userRepository) Repository() gitprovider.RepositoryRef {
+	ref := repositoryRef{RepositoryRef: r.ref}
+	for _, l := range r.r.Links.Clone {
+		switch l.Name {
+		case "http":
+			ref.httpsURL = withoutUser(l.Href)
+		case "ssh":
+			ref.sshURL = l.Href
+		}
+	}
+	return ref
+}
+
+func (r *userRepository) DeployKeys() gitprovider.DeployKeyClient {
+	return &DeployKeyClient{Client: r.Client, ref: r.ref}
+}
+
+func (r *userRepository) Commits() gitprovider.CommitClient {
+	return &CommitClient{Client: r.Client, ref: r.ref}
+}
+
+func (r *userRepository) Branches() gitprovider.BranchClient {
+	return &BranchClient{Client: r.Client, ref: r.ref}
+}
+
+func (r *userRepository) PullRequests() gitprovider.PullRequestClient {
+	return &PullRequestClient{Client: r.Client, ref: r.ref}
+}
+
+// Update applies the changes made with Set to the repository.
+//
+// ErrNotFound is returned if the resource does not exist.
+func (r *userRepository) Update(ctx context.Context) error {
+	if r.edit != (editRepositoryOptions{}) {
+		var apiObj apiRepository
+		if err := r.do(ctx, http.MethodPut, repoPath(apiPath, r.ref), r.edit, &apiObj); err != nil {
+			return err
+		}
+		apiObj.defaultBranch = r.r.defaultBranch
+		r.r = apiObj
+		r.edit = editRepositoryOptions{}
+	}
+	if r.defaultBranch != nil {
+		if err := setDefaultBranch(ctx, r.Client, r.ref, *r.defaultBranch); err != nil {
+			return err
+		}
+		r.r.defaultBranch = *r.defaultBranch
+		r.defaultBranch = nil
+	}
+	return nil
+}
+
+// Reconcile makes sure the desired state of the repository becomes the
+// actual state in Bitbucket Server. It does not create the repository if
+// it does not exist, use the Reconcile method of the repositories client
+// instead.
+func (r *userRepository) Reconcile(ctx context.Context) (bool, error) {
+	if r.edit == (editRepositoryOptions{}) && r.defaultBranch == nil {
+		return false, nil
+	}
+	return true, r.Update(ctx)
+}
+
+// Delete schedules the repository for deletion.
+//
+// ErrNotFound is returned if the resource doesn't exist anymore.
+func (r *userRepository) Delete(ctx context.Context) error {
+	return r.do(ctx, http.MethodDelete, repoPath(apiPath, r.ref), nil, nil)
+}
+
+func newOrgRepository(c *Client, apiObj apiRepository, ref gitprovider.RepositoryRef) *orgRepository {
+	return &orgRepository{
+		userRepository: *newUserRepository(c, apiObj, ref),
+	}
+}
+
+var _ gitprovider.OrgRepository = &orgRepository{}
+
+type orgRepository struct {
+	userRepository
+}
+
+func (r *orgRepository) TeamAccess() gitprovider.TeamAccessClient {
+	return &TeamAccessClient{Client: r.Client, ref: r.ref}
+}
+
+// repositoryRef wraps a gitprovider.RepositoryRef to return the clone
+// URLs of Bitbucket Server, which differ from the URLs of other
+// providers: HTTPS URLs are prefixed with "/scm" and SSH is served on a
+// separate port.
+type repositoryRef struct {
+	gitprovider.RepositoryRef
+
+	httpsURL string
+	sshURL   string
+}
+
+// GetCloneURL returns the clone URL for the given transport type, it
+// falls back to the URL format of a default Bitbucket Server
+// installation if the URL was not reported by the API.
+func (r repositoryRef) GetCloneURL(transport gitprovider.TransportType) string {
+	host := strings.TrimSuffix(gitprovider.GetDomainURL(r.GetDomain()), "/")
+	path := fmt.Sprintf("%s/%s.git", strings.ToLower(projectKey(r)), r.GetRepository())
+	switch transport {
+	case gitprovider.TransportTypeHTTPS:
+		if r.httpsURL != "" {
+			return r.httpsURL
+		}
+		return host + "/scm/" + path
+	case gitprovider.TransportTypeSSH:
+		if r.sshURL != "" {
+			return r.sshURL
+		}
+		u, err := url.Parse(host)
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprintf("ssh://git@%s:7999/%s", u.Hostname(), path)
+	}
+	return ""
+}
+
+// withoutUser removes the user information from the given URL, which
+// Bitbucket Server includes in the HTTP clone URL of the authenticated
+// user.
+func withoutUser(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	u.User = nil
+	return u.String()
+}
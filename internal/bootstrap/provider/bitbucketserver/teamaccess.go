@@ -0,0 +1,194 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitbucketserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/fluxcd/go-git-providers/gitprovider"
+)
+
+// TeamAccessClient implements the gitprovider.TeamAccessClient
+// interface.
+var _ gitprovider.TeamAccessClient = &TeamAccessClient{}
+
+// TeamAccessClient operates on the groups with access to a specific
+// repository.
+//
+// Bitbucket Server only knows read, write and admin permissions, which
+// means the triage and maintain permissions are granted as read and
+// write respectively.
+type TeamAccessClient struct {
+	*Client
+	ref gitprovider.RepositoryRef
+}
+
+// Get returns the access of the group with the given name.
+//
+// ErrNotFound is returned if the group does not have access to the
+// repository.
+func (c *TeamAccessClient) Get(ctx context.Context, name string) (gitprovider.TeamAccess, error) {
+	apiObjs, err := c.list(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	// The filter matches on a substring of the group name
+	for _, apiObj := range apiObjs {
+		if apiObj.Group.Name == name {
+			return c.newTeamAccess(apiObj), nil
+		}
+	}
+	return nil, gitprovider.ErrNotFound
+}
+
+// List returns the access of all groups with access to the repository.
+func (c *TeamAccessClient) List(ctx context.Context) ([]gitprovider.TeamAccess, error) {
+	apiObjs, err := c.list(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	teams := make([]gitprovider.TeamAccess, 0, len(apiObjs))
+	for _, apiObj := range apiObjs {
+		teams = append(teams, c.newTeamAccess(apiObj))
+	}
+	return teams, nil
+}
+
+func (c *TeamAccessClient) list(ctx context.Context, filter string) ([]apiGroupPermission, error) {
+	var permissions []apiGroupPermission
+	path := c.path()
+	if filter != "" {
+		path += "?filter=" + url.QueryEscape(filter)
+	}
+	err := c.Client.list(ctx, path, func(values json.RawMessage) error {
+		var apiObjs []apiGroupPermission
+		if err := json.Unmarshal(values, &apiObjs); err != nil {
+			return err
+		}
+		permissions = append(permissions, apiObjs...)
+		return nil
+	})
+	return permissions, err
+}
+
+// Create grants the group access to the repository.
+//
+// ErrAlreadyExists will be returned if the group already has access.
+func (c *TeamAccessClient) Create(ctx context.Context, req gitprovider.TeamAccessInfo) (gitprovider.TeamAccess, error) {
+	if err := gitprovider.ValidateAndDefaultInfo(&req); err != nil {
+		return nil, err
+	}
+	if _, err := c.Get(ctx, req.Name); err == nil {
+		return nil, gitprovider.ErrAlreadyExists
+	} else if !errors.Is(err, gitprovider.ErrNotFound) {
+		return nil, err
+	}
+	ta := &teamAccess{c: c}
+	if err := ta.Set(req); err != nil {
+		return nil, err
+	}
+	return ta, ta.Update(ctx)
+}
+
+// Reconcile makes sure the given desired state (req) becomes the actual
+// state in Bitbucket Server.
+func (c *TeamAccessClient) Reconcile(ctx context.Context, req gitprovider.TeamAccessInfo) (gitprovider.TeamAccess, bool, error) {
+	if err := gitprovider.ValidateAndDefaultInfo(&req); err != nil {
+		return nil, false, err
+	}
+	actual, err := c.Get(ctx, req.Name)
+	if err != nil {
+		if errors.Is(err, gitprovider.ErrNotFound) {
+			resp, err := c.Create(ctx, req)
+			return resp, true, err
+		}
+		return nil, false, err
+	}
+	if actual.APIObject().(*apiGroupPermission).Permission == permissionToAPI(*req.Permission) {
+		return actual, false, nil
+	}
+	if err := actual.Set(req); err != nil {
+		return actual, false, err
+	}
+	return actual, true, actual.Update(ctx)
+}
+
+func (c *TeamAccessClient) path() string {
+	return repoPath(apiPath, c.ref) + "/permissions/groups"
+}
+
+func (c *TeamAccessClient) newTeamAccess(apiObj apiGroupPermission) *teamAccess {
+	return &teamAccess{
+		p: apiObj,
+		c: c,
+	}
+}
+
+var _ gitprovider.TeamAccess = &teamAccess{}
+
+type teamAccess struct {
+	p apiGroupPermission
+	c *TeamAccessClient
+}
+
+func (ta *teamAccess) Get() gitprovider.TeamAccessInfo {
+	return gitprovider.TeamAccessInfo{
+		Name:       ta.p.Group.Name,
+		Permission: permissionFromAPI(ta.p.Permission),
+	}
+}
+
+func (ta *teamAccess) Set(info gitprovider.TeamAccessInfo) error {
+	if err := gitprovider.ValidateAndDefaultInfo(&info); err != nil {
+		return err
+	}
+	ta.p.Group.Name = info.Name
+	ta.p.Permission = permissionToAPI(*info.Permission)
+	return nil
+}
+
+func (ta *teamAccess) APIObject() interface{} {
+	return &ta.p
+}
+
+func (ta *teamAccess) Repository() gitprovider.RepositoryRef {
+	return ta.c.ref
+}
+
+// Update grants the group the configured permission on the repository.
+func (ta *teamAccess) Update(ctx context.Context) error {
+	query := url.Values{}
+	query.Set("name", ta.p.Group.Name)
+	query.Set("permission", ta.p.Permission)
+	return ta.c.do(ctx, http.MethodPut, ta.c.path()+"?"+query.Encode(), nil, nil)
+}
+
+// Reconcile makes sure the desired state of the group access becomes
+// the actual state in Bitbucket Server.
+func (ta *teamAccess) Reconcile(ctx context.Context) (bool, error) {
+	_, changed, err := ta.c.Reconcile(ctx, ta.Get())
+	return changed, err
+}
+
+// Delete revokes the access of the group to the repository.
+func (ta *teamAccess) Delete(ctx context.Context) error {
+	return ta.c.do(ctx, http.MethodDelete, ta.c.path()+"?name="+url.QueryEscape(ta.p.Group.Name), nil, nil)
+}
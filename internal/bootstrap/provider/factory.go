@@ -22,6 +22,9 @@ import (
 	"github.com/fluxcd/go-git-providers/github"
 	"github.com/fluxcd/go-git-providers/gitlab"
 	"github.com/fluxcd/go-git-providers/gitprovider"
+
+	"github.com/fluxcd/flux2/internal/bootstrap/provider/bitbucketserver"
+	"github.com/fluxcd/flux2/internal/bootstrap/provider/gitea"
 )
 
 // BuildGitProvider builds a gitprovider.Client for the provided
@@ -51,6 +54,18 @@ func BuildGitProvider(config Config) (gitprovider.Client, error) {
 		if client, err = gitlab.NewClient(config.Token, "", opts...); err != nil {
 			return nil, err
 		}
+	case GitProviderGitea:
+		var opts []gitea.ClientOption
+		if config.Hostname != "" {
+			opts = append(opts, gitea.WithDomain(config.Hostname))
+		}
+		if client, err = gitea.NewClient(config.Token, opts...); err != nil {
+			return nil, err
+		}
+	case GitProviderBitbucketServer:
+		if client, err = bitbucketserver.NewClient(config.Hostname, config.Token); err != nil {
+			return nil, err
+		}
 	default:
 		return nil, fmt.Errorf("unsupported Git provider '%s'", config.Provider)
 	}
@@ -0,0 +1,258 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package gitea implements the gitprovider.Client interface for Gitea
+// and API compatible forks like Forgejo, using the v1 REST API.
+package gitea
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/fluxcd/go-git-providers/gitprovider"
+)
+
+const (
+	// ProviderID is the provider ID for Gitea.
+	ProviderID = gitprovider.ProviderID("gitea")
+
+	// DefaultDomain specifies the default domain used as the backend.
+	DefaultDomain = "gitea.com"
+
+	// pageSize is the number of items requested per page for paginated
+	// API calls.
+	pageSize = 50
+)
+
+// ClientOption configures the Client returned by NewClient.
+type ClientOption func(*Client)
+
+// WithDomain sets the domain of the Gitea instance, e.g.
+// "gitea.example.com". The domain may be prefixed with a "http://" or
+// "https://" scheme, when omitted HTTPS is assumed.
+func WithDomain(domain string) ClientOption {
+	return func(c *Client) {
+		c.domain = domain
+	}
+}
+
+// WithHTTPClient sets the http.Client used to talk to the Gitea API.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// Client implements the gitprovider.Client interface.
+var _ gitprovider.Client = &Client{}
+
+// Client allows talking to a Gitea instance.
+type Client struct {
+	domain     string
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient returns a new Client authenticating with the given
+// access token.
+func NewClient(token string, opts ...ClientOption) (*Client, error) {
+	c := &Client{
+		domain:     DefaultDomain,
+		token:      token,
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if _, err := url.Parse(gitprovider.GetDomainURL(c.domain)); err != nil {
+		return nil, fmt.Errorf("invalid domain %q: %w", c.domain, err)
+	}
+	return c, nil
+}
+
+// SupportedDomain returns the domain endpoint for this client.
+func (c *Client) SupportedDomain() string {
+	return c.domain
+}
+
+// ProviderID returns the provider ID "gitea".
+func (c *Client) ProviderID() gitprovider.ProviderID {
+	return ProviderID
+}
+
+// Raw returns the http.Client used under the hood for accessing Gitea.
+func (c *Client) Raw() interface{} {
+	return c.httpClient
+}
+
+// HasTokenPermission returns true if the token is valid. Gitea access
+// tokens are not scoped, which means a valid token grants all
+// permissions of the owning user.
+func (c *Client) HasTokenPermission(ctx context.Context, permission gitprovider.TokenPermission) (bool, error) {
+	if permission != gitprovider.TokenPermissionRWRepository {
+		return false, gitprovider.ErrNoProviderSupport
+	}
+	if err := c.do(ctx, http.MethodGet, "/user", nil, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Organizations returns the OrganizationsClient handling sets of
+// organizations.
+func (c *Client) Organizations() gitprovider.OrganizationsClient {
+	return &OrganizationsClient{c}
+}
+
+// OrgRepositories returns the OrgRepositoriesClient handling sets of
+// repositories in an organization.
+func (c *Client) OrgRepositories() gitprovider.OrgRepositoriesClient {
+	return &OrgRepositoriesClient{c}
+}
+
+// UserRepositories returns the UserRepositoriesClient handling sets of
+// repositories for a user.
+func (c *Client) UserRepositories() gitprovider.UserRepositoriesClient {
+	return &UserRepositoriesClient{c}
+}
+
+// apiError is the error object returned by the Gitea API.
+type apiError struct {
+	Message string `json:"message"`
+	URL     string `json:"url"`
+}
+
+// do performs an API request with the given method against the given
+// path, which is relative to the API root. When in is not nil, it is
+// encoded to JSON and sent as the request body. When out is not nil,
+// the response body is decoded into it.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.apiURL(path), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "token "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := errorFromResponse(resp); err != nil {
+		return err
+	}
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("%w: %s", gitprovider.ErrInvalidServerData, err.Error())
+		}
+	}
+	return nil
+}
+
+// list calls the given function for consecutive pages, until fn
+// reports a page with less than pageSize items.
+func list(fn func(page int) (int, error)) error {
+	for page := 1; ; page++ {
+		n, err := fn(page)
+		if err != nil {
+			return err
+		}
+		if n < pageSize {
+			return nil
+		}
+	}
+}
+
+// pagedPath returns the given path with the query parameters for the
+// given page appended.
+func pagedPath(path string, page int) string {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return fmt.Sprintf("%s%spage=%d&limit=%d", path, sep, page, pageSize)
+}
+
+func (c *Client) apiURL(path string) string {
+	return strings.TrimSuffix(gitprovider.GetDomainURL(c.domain), "/") + "/api/v1" + path
+}
+
+// errorFromResponse maps non-2xx responses to gitprovider errors.
+func errorFromResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	var apiErr apiError
+	b, _ := io.ReadAll(resp.Body)
+	_ = json.Unmarshal(b, &apiErr)
+	if apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(b))
+	}
+
+	httpErr := gitprovider.HTTPError{
+		Response:         resp,
+		ErrorMessage:     fmt.Sprintf("%s %s: %d %s", resp.Request.Method, resp.Request.URL, resp.StatusCode, apiErr.Message),
+		Message:          apiErr.Message,
+		DocumentationURL: apiErr.URL,
+	}
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return gitprovider.ErrNotFound
+	case http.StatusConflict:
+		return gitprovider.ErrAlreadyExists
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return &gitprovider.InvalidCredentialsError{HTTPError: httpErr}
+	case http.StatusUnprocessableEntity:
+		if strings.Contains(strings.ToLower(apiErr.Message), "already") {
+			return gitprovider.ErrAlreadyExists
+		}
+		return &gitprovider.ValidationError{HTTPError: httpErr}
+	default:
+		return &httpErr
+	}
+}
+
+// escape escapes the given path segments and joins them with "/".
+func escape(segments ...string) string {
+	escaped := make([]string, 0, len(segments))
+	for _, s := range segments {
+		escaped = append(escaped, url.PathEscape(s))
+	}
+	return strings.Join(escaped, "/")
+}
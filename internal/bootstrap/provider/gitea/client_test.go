@@ -0,0 +1,348 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitea
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fluxcd/go-git-providers/gitprovider"
+)
+
+// fakeGitea is a minimal in-memory stand-in for the Gitea API, serving
+// the endpoints used during bootstrap.
+type fakeGitea struct {
+	mu        sync.Mutex
+	token     string
+	repos     map[string]*apiRepository
+	keys      map[string][]apiDeployKey
+	teams     map[string]apiTeam
+	repoTeams map[string]map[string]bool
+	pulls     map[string][]apiPullRequest
+	nextID    int64
+}
+
+func newFakeGitea(t *testing.T, token string) (*fakeGitea, *Client) {
+	f := &fakeGitea{
+		token:     token,
+		repos:     map[string]*apiRepository{},
+		keys:      map[string][]apiDeployKey{},
+		teams:     map[string]apiTeam{"devs": {ID: 1, Name: "devs", Permission: "write"}},
+		repoTeams: map[string]map[string]bool{},
+		pulls:     map[string][]apiPullRequest{},
+	}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	c, err := NewClient(token, WithDomain(srv.URL))
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+	return f, c
+}
+
+func (f *fakeGitea) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "token "+f.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1"), "/"), "/")
+	switch {
+	case r.Method == http.MethodPost && len(path) == 3 && path[0] == "orgs" && path[2] == "repos":
+		var opts createRepositoryOptions
+		_ = json.NewDecoder(r.Body).Decode(&opts)
+		key := path[1] + "/" + opts.Name
+		if _, ok := f.repos[key]; ok {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.nextID++
+		repo := &apiRepository{
+			ID:            f.nextID,
+			Owner:         apiUser{Login: path[1]},
+			Name:          opts.Name,
+			Description:   opts.Description,
+			Private:       opts.Private,
+			DefaultBranch: opts.DefaultBranch,
+		}
+		f.repos[key] = repo
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(repo)
+	case len(path) >= 3 && path[0] == "repos":
+		key := path[1] + "/" + path[2]
+		repo, ok := f.repos[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		f.serveRepo(w, r, key, repo, path[3:])
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeGitea) serveRepo(w http.ResponseWriter, r *http.Request, key string, repo *apiRepository, path []string) {
+	switch {
+	case len(path) == 0 && r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode(repo)
+	case len(path) == 0 && r.Method == http.MethodPatch:
+		var opts editRepositoryOptions
+		_ = json.NewDecoder(r.Body).Decode(&opts)
+		if opts.Description != nil {
+			repo.Description = *opts.Description
+		}
+		if opts.Private != nil {
+			repo.Private = *opts.Private
+		}
+		if opts.DefaultBranch != nil {
+			repo.DefaultBranch = *opts.DefaultBranch
+		}
+		_ = json.NewEncoder(w).Encode(repo)
+	case len(path) == 1 && path[0] == "keys" && r.Method == http.MethodGet:
+		keys := f.keys[key]
+		if keys == nil {
+			keys = []apiDeployKey{}
+		}
+		_ = json.NewEncoder(w).Encode(keys)
+	case len(path) == 1 && path[0] == "keys" && r.Method == http.MethodPost:
+		var opts createDeployKeyOptions
+		_ = json.NewDecoder(r.Body).Decode(&opts)
+		f.nextID++
+		k := apiDeployKey{ID: f.nextID, Title: opts.Title, Key: opts.Key, ReadOnly: opts.ReadOnly}
+		f.keys[key] = append(f.keys[key], k)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(k)
+	case len(path) == 2 && path[0] == "keys" && r.Method == http.MethodDelete:
+		id, _ := strconv.ParseInt(path[1], 10, 64)
+		var keys []apiDeployKey
+		for _, k := range f.keys[key] {
+			if k.ID != id {
+				keys = append(keys, k)
+			}
+		}
+		f.keys[key] = keys
+		w.WriteHeader(http.StatusNoContent)
+	case len(path) == 1 && path[0] == "pulls" && r.Method == http.MethodPost:
+		var opts createPullRequestOptions
+		_ = json.NewDecoder(r.Body).Decode(&opts)
+		pr := apiPullRequest{}
+		pr.Head.Ref = opts.Head
+		f.pulls[key] = append(f.pulls[key], pr)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(pr)
+	case len(path) == 1 && path[0] == "pulls" && r.Method == http.MethodGet:
+		pulls := []apiPullRequest{}
+		if r.URL.Query().Get("page") == "1" {
+			pulls = append(pulls, f.pulls[key]...)
+		}
+		_ = json.NewEncoder(w).Encode(pulls)
+	case len(path) == 2 && path[0] == "teams":
+		team, ok := f.teams[path[1]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			if !f.repoTeams[key][team.Name] {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(team)
+		case http.MethodPut:
+			if f.repoTeams[key] == nil {
+				f.repoTeams[key] = map[string]bool{}
+			}
+			f.repoTeams[key][team.Name] = true
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestOrgRepositoriesClient(t *testing.T) {
+	f, c := newFakeGitea(t, "token")
+	ctx := context.TODO()
+
+	ref := gitprovider.OrgRepositoryRef{
+		OrganizationRef: gitprovider.OrganizationRef{Domain: c.SupportedDomain(), Organization: "org"},
+		RepositoryName:  "fleet",
+	}
+
+	if _, err := c.OrgRepositories().Get(ctx, ref); !errors.Is(err, gitprovider.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	repo, err := c.OrgRepositories().Create(ctx, ref, gitprovider.RepositoryInfo{
+		DefaultBranch: gitprovider.StringVar("main"),
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if got := *repo.Get().Visibility; got != gitprovider.RepositoryVisibilityPrivate {
+		t.Errorf("expected repository to default to private, got %q", got)
+	}
+	if got := repo.Repository().GetCloneURL(gitprovider.TransportTypeHTTPS); got != c.SupportedDomain()+"/org/fleet.git" {
+		t.Errorf("unexpected clone URL %q", got)
+	}
+
+	if _, err := c.OrgRepositories().Create(ctx, ref, gitprovider.RepositoryInfo{}); !errors.Is(err, gitprovider.ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	}
+
+	_, changed, err := c.OrgRepositories().Reconcile(ctx, ref, gitprovider.RepositoryInfo{
+		DefaultBranch: gitprovider.StringVar("main"),
+		Visibility:    gitprovider.RepositoryVisibilityVar(gitprovider.RepositoryVisibilityPrivate),
+	})
+	if err != nil || changed {
+		t.Errorf("Reconcile() = %v, %v, expected no changes", changed, err)
+	}
+
+	_, changed, err = c.OrgRepositories().Reconcile(ctx, ref, gitprovider.RepositoryInfo{
+		Visibility: gitprovider.RepositoryVisibilityVar(gitprovider.RepositoryVisibilityPublic),
+	})
+	if err != nil || !changed {
+		t.Errorf("Reconcile() = %v, %v, expected changes", changed, err)
+	}
+	if f.repos["org/fleet"].Private {
+		t.Errorf("expected repository to be public")
+	}
+}
+
+func TestDeployKeyClient_Reconcile(t *testing.T) {
+	f, c := newFakeGitea(t, "token")
+	ctx := context.TODO()
+
+	ref := gitprovider.OrgRepositoryRef{
+		OrganizationRef: gitprovider.OrganizationRef{Domain: c.SupportedDomain(), Organization: "org"},
+		RepositoryName:  "fleet",
+	}
+	repo, err := c.OrgRepositories().Create(ctx, ref, gitprovider.RepositoryInfo{})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		key         string
+		readOnly    *bool
+		wantChanged bool
+	}{
+		{"create", "ssh-ed25519 AAAAC3 flux\n", nil, true},
+		{"unchanged", "ssh-ed25519 AAAAC3 other-comment", nil, false},
+		{"read-write", "ssh-ed25519 AAAAC3", gitprovider.BoolVar(false), true},
+		{"rotated", "ssh-ed25519 AAAAC4", gitprovider.BoolVar(false), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, changed, err := repo.DeployKeys().Reconcile(ctx, gitprovider.DeployKeyInfo{
+				Name:     "flux-system-main",
+				Key:      []byte(tt.key),
+				ReadOnly: tt.readOnly,
+			})
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("Reconcile() changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if n := len(f.keys["org/fleet"]); n != 1 {
+				t.Errorf("expected exactly one deploy key, got %d", n)
+			}
+		})
+	}
+}
+
+func TestTeamAccessClient_Reconcile(t *testing.T) {
+	_, c := newFakeGitea(t, "token")
+	ctx := context.TODO()
+
+	ref := gitprovider.OrgRepositoryRef{
+		OrganizationRef: gitprovider.OrganizationRef{Domain: c.SupportedDomain(), Organization: "org"},
+		RepositoryName:  "fleet",
+	}
+	repo, err := c.OrgRepositories().Create(ctx, ref, gitprovider.RepositoryInfo{})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	info := gitprovider.TeamAccessInfo{Name: "devs"}
+	ta, changed, err := repo.TeamAccess().Reconcile(ctx, info)
+	if err != nil || !changed {
+		t.Fatalf("Reconcile() = %v, %v, expected changes", changed, err)
+	}
+	if got := *ta.Get().Permission; got != gitprovider.RepositoryPermissionPush {
+		t.Errorf("expected team permission to be %q, got %q", gitprovider.RepositoryPermissionPush, got)
+	}
+	if _, changed, err = repo.TeamAccess().Reconcile(ctx, info); err != nil || changed {
+		t.Errorf("Reconcile() = %v, %v, expected no changes", changed, err)
+	}
+	if _, _, err = repo.TeamAccess().Reconcile(ctx, gitprovider.TeamAccessInfo{Name: "unknown"}); !errors.Is(err, gitprovider.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for unknown team, got %v", err)
+	}
+}
+
+func TestPullRequestClient_Merged(t *testing.T) {
+	f, c := newFakeGitea(t, "token")
+	ctx := context.TODO()
+
+	ref := gitprovider.OrgRepositoryRef{
+		OrganizationRef: gitprovider.OrganizationRef{Domain: c.SupportedDomain(), Organization: "org"},
+		RepositoryName:  "fleet",
+	}
+	repo, err := c.OrgRepositories().Create(ctx, ref, gitprovider.RepositoryInfo{})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := repo.PullRequests().Create(ctx, "Add Flux", "flux-bootstrap", "main", ""); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	prs := repo.PullRequests().(*PullRequestClient)
+	if merged, err := prs.Merged(ctx, "flux-bootstrap"); err != nil || merged {
+		t.Errorf("Merged() = %v, %v, expected open pull request", merged, err)
+	}
+	f.pulls["org/fleet"][0].Merged = true
+	if merged, err := prs.Merged(ctx, "flux-bootstrap"); err != nil || !merged {
+		t.Errorf("Merged() = %v, %v, expected merged pull request", merged, err)
+	}
+	if merged, err := prs.Merged(ctx, "other"); err != nil || merged {
+		t.Errorf("Merged() = %v, %v, expected no pull request for other branch", merged, err)
+	}
+}
+
+func TestClient_InvalidToken(t *testing.T) {
+	_, c := newFakeGitea(t, "token")
+	c.token = "invalid"
+
+	var credErr *gitprovider.InvalidCredentialsError
+	if _, err := c.HasTokenPermission(context.TODO(), gitprovider.TokenPermissionRWRepository); !errors.As(err, &credErr) {
+		t.Errorf("expected InvalidCredentialsError, got %v", err)
+	}
+}
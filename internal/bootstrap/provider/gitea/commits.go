@@ -0,0 +1,158 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitea
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/fluxcd/go-git-providers/gitprovider"
+)
+
+// apiCommit is the commit object returned by the Gitea API.
+type apiCommit struct {
+	SHA    string `json:"sha"`
+	Commit struct {
+		Tree struct {
+			SHA string `json:"sha"`
+		} `json:"tree"`
+	} `json:"commit"`
+}
+
+// createPullRequestOptions is the request body for creating a pull
+// request.
+type createPullRequestOptions struct {
+	Head  string `json:"head"`
+	Base  string `json:"base"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// apiPullRequest is the pull request object returned by the Gitea API.
+type apiPullRequest struct {
+	Merged bool `json:"merged"`
+	Head   struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+}
+
+// CommitClient implements the gitprovider.CommitClient interface.
+var _ gitprovider.CommitClient = &CommitClient{}
+
+// CommitClient operates on the commits of a specific repository.
+type CommitClient struct {
+	*Client
+	ref gitprovider.RepositoryRef
+}
+
+// ListPage lists the commits of the given branch, for the given page
+// and page size.
+func (c *CommitClient) ListPage(ctx context.Context, branch string, perPage int, page int) ([]gitprovider.Commit, error) {
+	path := fmt.Sprintf("/repos/%s/commits?sha=%s&page=%d&limit=%d",
+		escape(c.ref.GetIdentity(), c.ref.GetRepository()), url.QueryEscape(branch), page, perPage)
+	var apiObjs []apiCommit
+	if err := c.do(ctx, http.MethodGet, path, nil, &apiObjs); err != nil {
+		return nil, err
+	}
+	commits := make([]gitprovider.Commit, 0, len(apiObjs))
+	for _, apiObj := range apiObjs {
+		commits = append(commits, &commit{apiObj})
+	}
+	return commits, nil
+}
+
+// Create is not supported, as the Gitea API does not allow creating a
+// single commit for multiple files.
+func (c *CommitClient) Create(_ context.Context, _ string, _ string, _ []gitprovider.CommitFile) (gitprovider.Commit, error) {
+	return nil, gitprovider.ErrNoProviderSupport
+}
+
+var _ gitprovider.Commit = &commit{}
+
+type commit struct {
+	c apiCommit
+}
+
+func (c *commit) Get() gitprovider.CommitInfo {
+	return gitprovider.CommitInfo{
+		Sha:     c.c.SHA,
+		TreeSha: c.c.Commit.Tree.SHA,
+	}
+}
+
+func (c *commit) APIObject() interface{} {
+	return &c.c
+}
+
+// BranchClient implements the gitprovider.BranchClient interface.
+var _ gitprovider.BranchClient = &BranchClient{}
+
+// BranchClient operates on the branches of a specific repository.
+type BranchClient struct {
+	*Client
+	ref gitprovider.RepositoryRef
+}
+
+// Create is not supported, as the Gitea API only allows creating a
+// branch from another branch and not from a commit SHA.
+func (c *BranchClient) Create(_ context.Context, _, _ string) error {
+	return gitprovider.ErrNoProviderSupport
+}
+
+// PullRequestClient implements the gitprovider.PullRequestClient
+// interface.
+var _ gitprovider.PullRequestClient = &PullRequestClient{}
+
+// PullRequestClient operates on the pull requests of a specific
+// repository.
+type PullRequestClient struct {
+	*Client
+	ref gitprovider.RepositoryRef
+}
+
+// Create opens a pull request to merge branch into baseBranch.
+func (c *PullRequestClient) Create(ctx context.Context, title, branch, baseBranch, description string) error {
+	body := createPullRequestOptions{
+		Head:  branch,
+		Base:  baseBranch,
+		Title: title,
+		Body:  description,
+	}
+	return c.do(ctx, http.MethodPost, "/repos/"+escape(c.ref.GetIdentity(), c.ref.GetRepository())+"/pulls", body, nil)
+}
+
+// Merged returns true if a pull request opened from branch has been
+// merged.
+func (c *PullRequestClient) Merged(ctx context.Context, branch string) (bool, error) {
+	var merged bool
+	err := list(func(page int) (int, error) {
+		var apiObjs []apiPullRequest
+		path := pagedPath("/repos/"+escape(c.ref.GetIdentity(), c.ref.GetRepository())+"/pulls?state=closed", page)
+		if err := c.do(ctx, http.MethodGet, path, nil, &apiObjs); err != nil {
+			return 0, err
+		}
+		for _, apiObj := range apiObjs {
+			if apiObj.Head.Ref == branch && apiObj.Merged {
+				merged = true
+			}
+		}
+		return len(apiObjs), nil
+	})
+	return merged, err
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitea
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/fluxcd/go-git-providers/gitprovider"
+)
+
+// apiDeployKey is the deploy key object returned by the Gitea API.
+type apiDeployKey struct {
+	ID       int64  `json:"id"`
+	Key      string `json:"key"`
+	Title    string `json:"title"`
+	ReadOnly bool   `json:"read_only"`
+}
+
+// createDeployKeyOptions is the request body for creating a deploy key.
+type createDeployKeyOptions struct {
+	Title    string `json:"title"`
+	Key      string `json:"key"`
+	ReadOnly bool   `json:"read_only"`
+}
+
+// DeployKeyClient implements the gitprovider.DeployKeyClient interface.
+var _ gitprovider.DeployKeyClient = &DeployKeyClient{}
+
+// DeployKeyClient operates on the deploy keys of a specific repository.
+type DeployKeyClient struct {
+	*Client
+	ref gitprovider.RepositoryRef
+}
+
+// Get returns the deploy key with the given name.
+//
+// ErrNotFound is returned if the resource does not exist.
+func (c *DeployKeyClient) Get(ctx context.Context, name string) (gitprovider.DeployKey, error) {
+	keys, err := c.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		if k.k.Title == name {
+			return k, nil
+		}
+	}
+	return nil, gitprovider.ErrNotFound
+}
+
+// List returns all deploy keys of the repository.
+func (c *DeployKeyClient) List(ctx context.Context) ([]gitprovider.DeployKey, error) {
+	keys, err := c.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]gitprovider.DeployKey, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, k)
+	}
+	return result, nil
+}
+
+func (c *DeployKeyClient) list(ctx context.Context) ([]*deployKey, error) {
+	var keys []*deployKey
+	err := list(func(page int) (int, error) {
+		var apiObjs []apiDeployKey
+		path := pagedPath("/repos/"+escape(c.ref.GetIdentity(), c.ref.GetRepository())+"/keys", page)
+		if err := c.do(ctx, http.MethodGet, path, nil, &apiObjs); err != nil {
+			return 0, err
+		}
+		for _, apiObj := range apiObjs {
+			keys = append(keys, &deployKey{k: apiObj, c: c})
+		}
+		return len(apiObjs), nil
+	})
+	return keys, err
+}
+
+// Create creates a deploy key with the given specifications.
+//
+// ErrAlreadyExists will be returned if the resource already exists.
+func (c *DeployKeyClient) Create(ctx context.Context, req gitprovider.DeployKeyInfo) (gitprovider.DeployKey, error) {
+	k := &deployKey{c: c}
+	if err := k.Set(req); err != nil {
+		return nil, err
+	}
+	if err := k.create(ctx); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Reconcile makes sure the given desired state (req) becomes the actual
+// state in Gitea. As Gitea does not support updating deploy keys, a key
+// that does not match the desired state is deleted and recreated.
+func (c *DeployKeyClient) Reconcile(ctx context.Context, req gitprovider.DeployKeyInfo) (gitprovider.DeployKey, bool, error) {
+	if err := gitprovider.ValidateAndDefaultInfo(&req); err != nil {
+		return nil, false, err
+	}
+
+	actual, err := c.Get(ctx, req.Name)
+	if err != nil {
+		if errors.Is(err, gitprovider.ErrNotFound) {
+			resp, err := c.Create(ctx, req)
+			return resp, true, err
+		}
+		return nil, false, err
+	}
+
+	if deployKeyInfoEquals(req, actual.Get()) {
+		return actual, false, nil
+	}
+	if err := actual.Set(req); err != nil {
+		return actual, false, err
+	}
+	return actual, true, actual.Update(ctx)
+}
+
+var _ gitprovider.DeployKey = &deployKey{}
+
+type deployKey struct {
+	k apiDeployKey
+	c *DeployKeyClient
+}
+
+func (k *deployKey) Get() gitprovider.DeployKeyInfo {
+	return gitprovider.DeployKeyInfo{
+		Name:     k.k.Title,
+		Key:      []byte(k.k.Key),
+		ReadOnly: gitprovider.BoolVar(k.k.ReadOnly),
+	}
+}
+
+func (k *deployKey) Set(info gitprovider.DeployKeyInfo) error {
+	if err := gitprovider.ValidateAndDefaultInfo(&info); err != nil {
+		return err
+	}
+	k.k.Title = info.Name
+	k.k.Key = string(info.Key)
+	k.k.ReadOnly = *info.ReadOnly
+	return nil
+}
+
+func (k *deployKey) APIObject() interface{} {
+	return &k.k
+}
+
+func (k *deployKey) Repository() gitprovider.RepositoryRef {
+	return k.c.ref
+}
+
+// Update deletes the deploy key and creates it again with the desired
+// state, as Gitea does not support updating deploy keys.
+func (k *deployKey) Update(ctx context.Context) error {
+	if err := k.Delete(ctx); err != nil {
+		return err
+	}
+	return k.create(ctx)
+}
+
+// Reconcile makes sure the desired state of the deploy key becomes the
+// actual state in Gitea.
+func (k *deployKey) Reconcile(ctx context.Context) (bool, error) {
+	_, changed, err := k.c.Reconcile(ctx, k.Get())
+	return changed, err
+}
+
+// Delete deletes the deploy key irreversibly.
+//
+// ErrNotFound is returned if the resource doesn't exist anymore.
+func (k *deployKey) Delete(ctx context.Context) error {
+	path := fmt.Sprintf("/repos/%s/keys/%d", escape(k.c.ref.GetIdentity(), k.c.ref.GetRepository()), k.k.ID)
+	return k.c.do(ctx, http.MethodDelete, path, nil, nil)
+}
+
+func (k *deployKey) create(ctx context.Context) error {
+	body := createDeployKeyOptions{
+		Title:    k.k.Title,
+		Key:      k.k.Key,
+		ReadOnly: k.k.ReadOnly,
+	}
+	var apiObj apiDeployKey
+	path := "/repos/" + escape(k.c.ref.GetIdentity(), k.c.ref.GetRepository()) + "/keys"
+	if err := k.c.do(ctx, http.MethodPost, path, body, &apiObj); err != nil {
+		return err
+	}
+	k.k = apiObj
+	return nil
+}
+
+// deployKeyInfoEquals compares the given deploy keys, ignoring any
+// trailing whitespace or comments in the public keys as Gitea does not
+// return them.
+func deployKeyInfoEquals(desired, actual gitprovider.DeployKeyInfo) bool {
+	if desired.Name != actual.Name {
+		return false
+	}
+	if desired.ReadOnly != nil && actual.ReadOnly != nil && *desired.ReadOnly != *actual.ReadOnly {
+		return false
+	}
+	return bytes.Equal(keyData(desired.Key), keyData(actual.Key))
+}
+
+// keyData returns the type and base64 encoded data of the given
+// authorized key formatted public key.
+func keyData(key []byte) []byte {
+	fields := bytes.Fields(key)
+	if len(fields) < 2 {
+		return bytes.TrimSpace(key)
+	}
+	return bytes.Join(fields[:2], []byte(" "))
+}
@@ -0,0 +1,265 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitea
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/fluxcd/go-git-providers/gitprovider"
+)
+
+// apiOrganization is the organization object returned by the Gitea API.
+type apiOrganization struct {
+	ID          int64  `json:"id"`
+	UserName    string `json:"username"`
+	FullName    string `json:"full_name"`
+	Description string `json:"description"`
+}
+
+// apiTeam is the team object returned by the Gitea API.
+type apiTeam struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	Permission string `json:"permission"`
+}
+
+// apiUser is the user object returned by the Gitea API.
+type apiUser struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+}
+
+// OrganizationsClient implements the gitprovider.OrganizationsClient
+// interface.
+var _ gitprovider.OrganizationsClient = &OrganizationsClient{}
+
+// OrganizationsClient operates on organizations the user has access to.
+type OrganizationsClient struct {
+	*Client
+}
+
+// Get returns the organization for the given reference. Gitea does not
+// support sub-organizations.
+//
+// ErrNotFound is returned if the resource does not exist.
+func (c *OrganizationsClient) Get(ctx context.Context, ref gitprovider.OrganizationRef) (gitprovider.Organization, error) {
+	if len(ref.SubOrganizations) > 0 {
+		return nil, gitprovider.ErrNoProviderSupport
+	}
+	var apiObj apiOrganization
+	if err := c.do(ctx, http.MethodGet, "/orgs/"+escape(ref.Organization), nil, &apiObj); err != nil {
+		return nil, err
+	}
+	return newOrganization(c.Client, apiObj, ref), nil
+}
+
+// List returns all organizations the user is a member of.
+func (c *OrganizationsClient) List(ctx context.Context) ([]gitprovider.Organization, error) {
+	var orgs []gitprovider.Organization
+	err := list(func(page int) (int, error) {
+		var apiObjs []apiOrganization
+		if err := c.do(ctx, http.MethodGet, pagedPath("/user/orgs", page), nil, &apiObjs); err != nil {
+			return 0, err
+		}
+		for _, apiObj := range apiObjs {
+			ref := gitprovider.OrganizationRef{
+				Domain:       c.domain,
+				Organization: apiObj.UserName,
+			}
+			orgs = append(orgs, newOrganization(c.Client, apiObj, ref))
+		}
+		return len(apiObjs), nil
+	})
+	return orgs, err
+}
+
+// Children is not supported by Gitea, as it does not have a concept of
+// sub-organizations.
+func (c *OrganizationsClient) Children(_ context.Context, _ gitprovider.OrganizationRef) ([]gitprovider.Organization, error) {
+	return nil, gitprovider.ErrNoProviderSupport
+}
+
+func newOrganization(c *Client, apiObj apiOrganization, ref gitprovider.OrganizationRef) *organization {
+	return &organization{
+		o:   apiObj,
+		ref: ref,
+		teams: &TeamsClient{
+			Client: c,
+			ref:    ref,
+		},
+	}
+}
+
+var _ gitprovider.Organization = &organization{}
+
+type organization struct {
+	o     apiOrganization
+	ref   gitprovider.OrganizationRef
+	teams *TeamsClient
+}
+
+func (o *organization) Get() gitprovider.OrganizationInfo {
+	info := gitprovider.OrganizationInfo{}
+	if o.o.FullName != "" {
+		info.Name = gitprovider.StringVar(o.o.FullName)
+	}
+	if o.o.Description != "" {
+		info.Description = gitprovider.StringVar(o.o.Description)
+	}
+	return info
+}
+
+func (o *organization) APIObject() interface{} {
+	return &o.o
+}
+
+func (o *organization) Organization() gitprovider.OrganizationRef {
+	return o.ref
+}
+
+func (o *organization) Teams() gitprovider.TeamsClient {
+	return o.teams
+}
+
+// TeamsClient implements the gitprovider.TeamsClient interface.
+var _ gitprovider.TeamsClient = &TeamsClient{}
+
+// TeamsClient allows reading teams for a specific organization.
+type TeamsClient struct {
+	*Client
+	ref gitprovider.OrganizationRef
+}
+
+// Get returns the team with the given name, including its members.
+//
+// ErrNotFound is returned if the resource does not exist.
+func (c *TeamsClient) Get(ctx context.Context, name string) (gitprovider.Team, error) {
+	apiObj, err := getTeam(ctx, c.Client, c.ref.Organization, name)
+	if err != nil {
+		return nil, err
+	}
+	return c.newTeam(ctx, *apiObj)
+}
+
+// List returns all teams of the organization, including their members.
+func (c *TeamsClient) List(ctx context.Context) ([]gitprovider.Team, error) {
+	apiObjs, err := listTeams(ctx, c.Client, c.ref.Organization)
+	if err != nil {
+		return nil, err
+	}
+	teams := make([]gitprovider.Team, 0, len(apiObjs))
+	for _, apiObj := range apiObjs {
+		t, err := c.newTeam(ctx, apiObj)
+		if err != nil {
+			return nil, err
+		}
+		teams = append(teams, t)
+	}
+	return teams, nil
+}
+
+func (c *TeamsClient) newTeam(ctx context.Context, apiObj apiTeam) (*team, error) {
+	var members []string
+	err := list(func(page int) (int, error) {
+		var apiObjs []apiUser
+		path := pagedPath(fmt.Sprintf("/teams/%d/members", apiObj.ID), page)
+		if err := c.do(ctx, http.MethodGet, path, nil, &apiObjs); err != nil {
+			return 0, err
+		}
+		for _, u := range apiObjs {
+			members = append(members, u.Login)
+		}
+		return len(apiObjs), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &team{
+		t:       apiObj,
+		members: members,
+		ref:     c.ref,
+	}, nil
+}
+
+var _ gitprovider.Team = &team{}
+
+type team struct {
+	t       apiTeam
+	members []string
+	ref     gitprovider.OrganizationRef
+}
+
+func (t *team) Get() gitprovider.TeamInfo {
+	return gitprovider.TeamInfo{
+		Name:    t.t.Name,
+		Members: t.members,
+	}
+}
+
+func (t *team) APIObject() interface{} {
+	return &t.t
+}
+
+func (t *team) Organization() gitprovider.OrganizationRef {
+	return t.ref
+}
+
+// getTeam returns the team with the given name in the organization.
+// Gitea only allows retrieving teams by ID, so the teams of the
+// organization are listed to find a match.
+func getTeam(ctx context.Context, c *Client, org, name string) (*apiTeam, error) {
+	teams, err := listTeams(ctx, c, org)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range teams {
+		if t.Name == name {
+			return &t, nil
+		}
+	}
+	return nil, gitprovider.ErrNotFound
+}
+
+func listTeams(ctx context.Context, c *Client, org string) ([]apiTeam, error) {
+	var teams []apiTeam
+	err := list(func(page int) (int, error) {
+		var apiObjs []apiTeam
+		if err := c.do(ctx, http.MethodGet, pagedPath("/orgs/"+escape(org)+"/teams", page), nil, &apiObjs); err != nil {
+			return 0, err
+		}
+		teams = append(teams, apiObjs...)
+		return len(apiObjs), nil
+	})
+	return teams, err
+}
+
+// permissionFromAPI maps a Gitea team permission to a
+// gitprovider.RepositoryPermission.
+func permissionFromAPI(permission string) *gitprovider.RepositoryPermission {
+	switch permission {
+	case "read":
+		return gitprovider.RepositoryPermissionVar(gitprovider.RepositoryPermissionPull)
+	case "write":
+		return gitprovider.RepositoryPermissionVar(gitprovider.RepositoryPermissionPush)
+	case "admin", "owner":
+		return gitprovider.RepositoryPermissionVar(gitprovider.RepositoryPermissionAdmin)
+	default:
+		return nil
+	}
+}
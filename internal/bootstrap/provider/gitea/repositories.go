@@ -0,0 +1,391 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitea
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/fluxcd/go-git-providers/gitprovider"
+)
+
+// apiRepository is the repository object returned by the Gitea API.
+type apiRepository struct {
+	ID            int64   `json:"id"`
+	Owner         apiUser `json:"owner"`
+	Name          string  `json:"name"`
+	Description   string  `json:"description"`
+	Private       bool    `json:"private"`
+	Internal      bool    `json:"internal"`
+	DefaultBranch string  `json:"default_branch"`
+	CloneURL      string  `json:"clone_url"`
+	SSHURL        string  `json:"ssh_url"`
+}
+
+// createRepositoryOptions is the request body for creating a
+// repository.
+type createRepositoryOptions struct {
+	Name          string `json:"name"`
+	Description   string `json:"description,omitempty"`
+	Private       bool   `json:"private"`
+	AutoInit      bool   `json:"auto_init"`
+	DefaultBranch string `json:"default_branch,omitempty"`
+	License       string `json:"license,omitempty"`
+}
+
+// editRepositoryOptions is the request body for updating a repository.
+type editRepositoryOptions struct {
+	Description   *string `json:"description,omitempty"`
+	Private       *bool   `json:"private,omitempty"`
+	DefaultBranch *string `json:"default_branch,omitempty"`
+}
+
+// OrgRepositoriesClient implements the gitprovider.OrgRepositoriesClient
+// interface.
+var _ gitprovider.OrgRepositoriesClient = &OrgRepositoriesClient{}
+
+// OrgRepositoriesClient operates on repositories for organizations.
+type OrgRepositoriesClient struct {
+	*Client
+}
+
+// Get returns the repository for the given reference.
+//
+// ErrNotFound is returned if the resource does not exist.
+func (c *OrgRepositoriesClient) Get(ctx context.Context, ref gitprovider.OrgRepositoryRef) (gitprovider.OrgRepository, error) {
+	if len(ref.SubOrganizations) > 0 {
+		return nil, gitprovider.ErrNoProviderSupport
+	}
+	apiObj, err := getRepository(ctx, c.Client, ref)
+	if err != nil {
+		return nil, err
+	}
+	return newOrgRepository(c.Client, *apiObj, ref), nil
+}
+
+// List returns all repositories in the given organization.
+func (c *OrgRepositoriesClient) List(ctx context.Context, ref gitprovider.OrganizationRef) ([]gitprovider.OrgRepository, error) {
+	apiObjs, err := listRepositories(ctx, c.Client, "/orgs/"+escape(ref.Organization)+"/repos")
+	if err != nil {
+		return nil, err
+	}
+	repos := make([]gitprovider.OrgRepository, 0, len(apiObjs))
+	for _, apiObj := range apiObjs {
+		repos = append(repos, newOrgRepository(c.Client, apiObj, gitprovider.OrgRepositoryRef{
+			OrganizationRef: ref,
+			RepositoryName:  apiObj.Name,
+		}))
+	}
+	return repos, nil
+}
+
+// Create creates a repository for the given organization, with the data
+// and options.
+//
+// ErrAlreadyExists will be returned if the resource already exists.
+func (c *OrgRepositoriesClient) Create(ctx context.Context, ref gitprovider.OrgRepositoryRef, req gitprovider.RepositoryInfo, opts ...gitprovider.RepositoryCreateOption) (gitprovider.OrgRepository, error) {
+	if len(ref.SubOrganizations) > 0 {
+		return nil, gitprovider.ErrNoProviderSupport
+	}
+	apiObj, err := createRepository(ctx, c.Client, "/orgs/"+escape(ref.Organization)+"/repos", ref, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return newOrgRepository(c.Client, *apiObj, ref), nil
+}
+
+// Reconcile makes sure the given desired state (req) becomes the actual
+// state in Gitea.
+func (c *OrgRepositoriesClient) Reconcile(ctx context.Context, ref gitprovider.OrgRepositoryRef, req gitprovider.RepositoryInfo, opts ...gitprovider.RepositoryReconcileOption) (gitprovider.OrgRepository, bool, error) {
+	actual, err := c.Get(ctx, ref)
+	if err != nil {
+		if errors.Is(err, gitprovider.ErrNotFound) {
+			resp, err := c.Create(ctx, ref, req, toCreateOpts(opts...)...)
+			return resp, true, err
+		}
+		return nil, false, err
+	}
+	changed, err := reconcileRepository(ctx, actual, req)
+	return actual, changed, err
+}
+
+// UserRepositoriesClient implements the
+// gitprovider.UserRepositoriesClient interface.
+var _ gitprovider.UserRepositoriesClient = &UserRepositoriesClient{}
+
+// UserRepositoriesClient operates on repositories for users.
+type UserRepositoriesClient struct {
+	*Client
+}
+
+// Get returns the repository for the given reference.
+//
+// ErrNotFound is returned if the resource does not exist.
+func (c *UserRepositoriesClient) Get(ctx context.Context, ref gitprovider.UserRepositoryRef) (gitprovider.UserRepository, error) {
+	apiObj, err := getRepository(ctx, c.Client, ref)
+	if err != nil {
+		return nil, err
+	}
+	return newUserRepository(c.Client, *apiObj, ref), nil
+}
+
+// List returns all repositories of the given user.
+func (c *UserRepositoriesClient) List(ctx context.Context, ref gitprovider.UserRef) ([]gitprovider.UserRepository, error) {
+	apiObjs, err := listRepositories(ctx, c.Client, "/users/"+escape(ref.UserLogin)+"/repos")
+	if err != nil {
+		return nil, err
+	}
+	repos := make([]gitprovider.UserRepository, 0, len(apiObjs))
+	for _, apiObj := range apiObjs {
+		repos = append(repos, newUserRepository(c.Client, apiObj, gitprovider.UserRepositoryRef{
+			UserRef:        ref,
+			RepositoryName: apiObj.Name,
+		}))
+	}
+	return repos, nil
+}
+
+// Create creates a repository for the authenticated user, with the data
+// and options.
+//
+// ErrAlreadyExists will be returned if the resource already exists.
+func (c *UserRepositoriesClient) Create(ctx context.Context, ref gitprovider.UserRepositoryRef, req gitprovider.RepositoryInfo, opts ...gitprovider.RepositoryCreateOption) (gitprovider.UserRepository, error) {
+	apiObj, err := createRepository(ctx, c.Client, "/user/repos", ref, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return newUserRepository(c.Client, *apiObj, ref), nil
+}
+
+// Reconcile makes sure the given desired state (req) becomes the actual
+// state in Gitea.
+func (c *UserRepositoriesClient) Reconcile(ctx context.Context, ref gitprovider.UserRepositoryRef, req gitprovider.RepositoryInfo, opts ...gitprovider.RepositoryReconcileOption) (gitprovider.UserRepository, bool, error) {
+	actual, err := c.Get(ctx, ref)
+	if err != nil {
+		if errors.Is(err, gitprovider.ErrNotFound) {
+			resp, err := c.Create(ctx, ref, req, toCreateOpts(opts...)...)
+			return resp, true, err
+		}
+		return nil, false, err
+	}
+	changed, err := reconcileRepository(ctx, actual, req)
+	return actual, changed, err
+}
+
+func getRepository(ctx context.Context, c *Client, ref gitprovider.RepositoryRef) (*apiRepository, error) {
+	var apiObj apiRepository
+	if err := c.do(ctx, http.MethodGet, "/repos/"+escape(ref.GetIdentity(), ref.GetRepository()), nil, &apiObj); err != nil {
+		return nil, err
+	}
+	return &apiObj, nil
+}
+
+func listRepositories(ctx context.Context, c *Client, path string) ([]apiRepository, error) {
+	var repos []apiRepository
+	err := list(func(page int) (int, error) {
+		var apiObjs []apiRepository
+		if err := c.do(ctx, http.MethodGet, pagedPath(path, page), nil, &apiObjs); err != nil {
+			return 0, err
+		}
+		repos = append(repos, apiObjs...)
+		return len(apiObjs), nil
+	})
+	return repos, err
+}
+
+func createRepository(ctx context.Context, c *Client, path string, ref gitprovider.RepositoryRef, req gitprovider.RepositoryInfo, opts ...gitprovider.RepositoryCreateOption) (*apiRepository, error) {
+	if err := gitprovider.ValidateAndDefaultInfo(&req); err != nil {
+		return nil, err
+	}
+	o, err := gitprovider.MakeRepositoryCreateOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	body := createRepositoryOptions{
+		Name:          ref.GetRepository(),
+		Private:       *req.Visibility != gitprovider.RepositoryVisibilityPublic,
+		DefaultBranch: *req.DefaultBranch,
+	}
+	if req.Description != nil {
+		body.Description = *req.Description
+	}
+	if o.AutoInit != nil {
+		body.AutoInit = *o.AutoInit
+	}
+	if o.LicenseTemplate != nil {
+		body.License = string(*o.LicenseTemplate)
+	}
+
+	var apiObj apiRepository
+	if err := c.do(ctx, http.MethodPost, path, body, &apiObj); err != nil {
+		return nil, err
+	}
+	return &apiObj, nil
+}
+
+// reconcileRepository updates the actual repository to match the
+// desired state, comparing only the fields set in req.
+func reconcileRepository(ctx context.Context, actual gitprovider.UserRepository, req gitprovider.RepositoryInfo) (bool, error) {
+	if err := req.ValidateInfo(); err != nil {
+		return false, err
+	}
+	info := actual.Get()
+	if (req.Description == nil || *req.Description == *info.Description) &&
+		(req.DefaultBranch == nil || *req.DefaultBranch == *info.DefaultBranch) &&
+		(req.Visibility == nil || *req.Visibility == *info.Visibility) {
+		return false, nil
+	}
+	if err := actual.Set(req); err != nil {
+		return false, err
+	}
+	return true, actual.Update(ctx)
+}
+
+func toCreateOpts(opts ...gitprovider.RepositoryReconcileOption) []gitprovider.RepositoryCreateOption {
+	createOpts := make([]gitprovider.RepositoryCreateOption, 0, len(opts))
+	for _, opt := range opts {
+		createOpts = append(createOpts, opt)
+	}
+	return createOpts
+}
+
+func newUserRepository(c *Client, apiObj apiRepository, ref gitprovider.RepositoryRef) *userRepository {
+	return &userRepository{
+		Client: c,
+		r:      apiObj,
+		ref:    ref,
+	}
+}
+
+var _ gitprovider.UserRepository = &userRepository{}
+
+type userRepository struct {
+	*Client
+
+	r   apiRepository
+	ref gitprovider.RepositoryRef
+
+	// edit holds the changes made with Set, to be applied with Update.
+	edit editRepositoryOptions
+}
+
+func (r *userRepository) Get() gitprovider.RepositoryInfo {
+	visibility := gitprovider.RepositoryVisibilityPublic
+	switch {
+	case r.r.Private:
+		visibility = gitprovider.RepositoryVisibilityPrivate
+	case r.r.Internal:
+		visibility = gitprovider.RepositoryVisibilityInternal
+	}
+	return gitprovider.RepositoryInfo{
+		Description:   gitprovider.StringVar(r.r.Description),
+		DefaultBranch: gitprovider.StringVar(r.r.DefaultBranch),
+		Visibility:    gitprovider.RepositoryVisibilityVar(visibility),
+	}
+}
+
+// Set sets the desired state for the repository. Gitea does not allow
+// changing a repository to internal using the API, which means the
+// internal visibility is handled as private.
+func (r *userRepository) Set(info gitprovider.RepositoryInfo) error {
+	if err := info.ValidateInfo(); err != nil {
+		return err
+	}
+	if info.Description != nil {
+		r.edit.Description = info.Description
+	}
+	if info.DefaultBranch != nil {
+		r.edit.DefaultBranch = info.DefaultBranch
+	}
+	if info.Visibility != nil {
+		r.edit.Private = gitprovider.BoolVar(*info.Visibility != gitprovider.RepositoryVisibilityPublic)
+	}
+	return nil
+}
+
+func (r *userRepository) APIObject() interface{} {
+	return &r.r
+}
+
+func (r *userRepository) Repository() gitprovider.RepositoryRef {
+	return r.ref
+}
+
+func (r *userRepository) DeployKeys() gitprovider.DeployKeyClient {
+	return &DeployKeyClient{Client: r.Client, ref: r.ref}
+}
+
+func (r *userRepository) Commits() gitprovider.CommitClient {
+	return &CommitClient{Client: r.Client, ref: r.ref}
+}
+
+func (r *userRepository) Branches() gitprovider.BranchClient {
+	return &BranchClient{Client: r.Client, ref: r.ref}
+}
+
+func (r *userRepository) PullRequests() gitprovider.PullRequestClient {
+	return &PullRequestClient{Client: r.Client, ref: r.ref}
+}
+
+// Update applies the changes made with Set to the repository.
+//
+// ErrNotFound is returned if the resource does not exist.
+func (r *userRepository) Update(ctx context.Context) error {
+	var apiObj apiRepository
+	if err := r.do(ctx, http.MethodPatch, "/repos/"+escape(r.ref.GetIdentity(), r.ref.GetRepository()), r.edit, &apiObj); err != nil {
+		return err
+	}
+	r.r = apiObj
+	r.edit = editRepositoryOptions{}
+	return nil
+}
+
+// Reconcile makes sure the desired state of the repository becomes the
+// actual state in Gitea. It does not create the repository if it does
+// not exist, use the Reconcile method of the repositories client
+// instead.
+func (r *userRepository) Reconcile(ctx context.Context) (bool, error) {
+	if r.edit == (editRepositoryOptions{}) {
+		return false, nil
+	}
+	return true, r.Update(ctx)
+}
+
+// Delete deletes the repository irreversibly.
+//
+// ErrNotFound is returned if the resource doesn't exist anymore.
+func (r *userRepository) Delete(ctx context.Context) error {
+	return r.do(ctx, http.MethodDelete, "/repos/"+escape(r.ref.GetIdentity(), r.ref.GetRepository()), nil, nil)
+}
+
+func newOrgRepository(c *Client, apiObj apiRepository, ref gitprovider.RepositoryRef) *orgRepository {
+	return &orgRepository{
+		userRepository: *newUserRepository(c, apiObj, ref),
+	}
+}
+
+var _ gitprovider.OrgRepository = &orgRepository{}
+
+type orgRepository struct {
+	userRepository
+}
+
+func (r *orgRepository) TeamAccess() gitprovider.TeamAccessClient {
+	return &TeamAccessClient{Client: r.Client, ref: r.ref}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitea
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/fluxcd/go-git-providers/gitprovider"
+)
+
+// TeamAccessClient implements the gitprovider.TeamAccessClient
+// interface.
+var _ gitprovider.TeamAccessClient = &TeamAccessClient{}
+
+// TeamAccessClient operates on the teams with access to a specific
+// repository.
+//
+// Gitea configures permissions on the team instead of per repository,
+// which means the permission of a gitprovider.TeamAccessInfo reflects
+// the team permission, and can not be changed through this client.
+type TeamAccessClient struct {
+	*Client
+	ref gitprovider.RepositoryRef
+}
+
+// Get returns the access of the team with the given name.
+//
+// ErrNotFound is returned if the team does not have access to the
+// repository.
+func (c *TeamAccessClient) Get(ctx context.Context, name string) (gitprovider.TeamAccess, error) {
+	var apiObj apiTeam
+	if err := c.do(ctx, http.MethodGet, c.path(name), nil, &apiObj); err != nil {
+		return nil, err
+	}
+	return c.newTeamAccess(apiObj), nil
+}
+
+// List returns the access of all teams with access to the repository.
+func (c *TeamAccessClient) List(ctx context.Context) ([]gitprovider.TeamAccess, error) {
+	var apiObjs []apiTeam
+	if err := c.do(ctx, http.MethodGet, "/repos/"+escape(c.ref.GetIdentity(), c.ref.GetRepository())+"/teams", nil, &apiObjs); err != nil {
+		return nil, err
+	}
+	teams := make([]gitprovider.TeamAccess, 0, len(apiObjs))
+	for _, apiObj := range apiObjs {
+		teams = append(teams, c.newTeamAccess(apiObj))
+	}
+	return teams, nil
+}
+
+// Create grants the team access to the repository.
+//
+// ErrAlreadyExists will be returned if the team already has access.
+func (c *TeamAccessClient) Create(ctx context.Context, req gitprovider.TeamAccessInfo) (gitprovider.TeamAccess, error) {
+	if err := gitprovider.ValidateAndDefaultInfo(&req); err != nil {
+		return nil, err
+	}
+	if err := c.do(ctx, http.MethodPut, c.path(req.Name), nil, nil); err != nil {
+		return nil, err
+	}
+	return c.Get(ctx, req.Name)
+}
+
+// Reconcile makes sure the team has access to the repository. The
+// requested permission is not enforced, as it is configured on the team.
+func (c *TeamAccessClient) Reconcile(ctx context.Context, req gitprovider.TeamAccessInfo) (gitprovider.TeamAccess, bool, error) {
+	if err := gitprovider.ValidateAndDefaultInfo(&req); err != nil {
+		return nil, false, err
+	}
+	actual, err := c.Get(ctx, req.Name)
+	if err != nil {
+		if errors.Is(err, gitprovider.ErrNotFound) {
+			resp, err := c.Create(ctx, req)
+			return resp, true, err
+		}
+		return nil, false, err
+	}
+	return actual, false, nil
+}
+
+func (c *TeamAccessClient) path(team string) string {
+	return "/repos/" + escape(c.ref.GetIdentity(), c.ref.GetRepository(), "teams", team)
+}
+
+func (c *TeamAccessClient) newTeamAccess(apiObj apiTeam) *teamAccess {
+	return &teamAccess{
+		t: apiObj,
+		c: c,
+	}
+}
+
+var _ gitprovider.TeamAccess = &teamAccess{}
+
+type teamAccess struct {
+	t apiTeam
+	c *TeamAccessClient
+}
+
+func (ta *teamAccess) Get() gitprovider.TeamAccessInfo {
+	return gitprovider.TeamAccessInfo{
+		Name:       ta.t.Name,
+		Permission: permissionFromAPI(ta.t.Permission),
+	}
+}
+
+// Set validates the given info. Only the team name can be changed, as
+// the permission is configured on the team.
+func (ta *teamAccess) Set(info gitprovider.TeamAccessInfo) error {
+	if err := info.ValidateInfo(); err != nil {
+		return err
+	}
+	ta.t.Name = info.Name
+	return nil
+}
+
+func (ta *teamAccess) APIObject() interface{} {
+	return &ta.t
+}
+
+func (ta *teamAccess) Repository() gitprovider.RepositoryRef {
+	return ta.c.ref
+}
+
+// Update makes sure the team has access to the repository.
+func (ta *teamAccess) Update(ctx context.Context) error {
+	return ta.c.do(ctx, http.MethodPut, ta.c.path(ta.t.Name), nil, nil)
+}
+
+// Reconcile makes sure the team has access to the repository.
+func (ta *teamAccess) Reconcile(ctx context.Context) (bool, error) {
+	_, changed, err := ta.c.Reconcile(ctx, ta.Get())
+	return changed, err
+}
+
+// Delete revokes the access of the team to the repository.
+func (ta *teamAccess) Delete(ctx context.Context) error {
+	return ta.c.do(ctx, http.MethodDelete, ta.c.path(ta.t.Name), nil, nil)
+}
@@ -20,8 +20,10 @@ package provider
 type GitProvider string
 
 const (
-	GitProviderGitHub GitProvider = "github"
-	GitProviderGitLab GitProvider = "gitlab"
+	GitProviderGitHub          GitProvider = "github"
+	GitProviderGitLab          GitProvider = "gitlab"
+	GitProviderGitea           GitProvider = "gitea"
+	GitProviderBitbucketServer GitProvider = "bitbucket-server"
 )
 
 // Config defines the configuration for connecting to a GitProvider.
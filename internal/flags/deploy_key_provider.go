@@ -0,0 +1,58 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flags
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fluxcd/flux2/internal/bootstrap/provider"
+	"github.com/fluxcd/flux2/internal/utils"
+)
+
+var supportedDeployKeyProviders = []string{string(provider.GitProviderGitHub), string(provider.GitProviderGitLab)}
+
+// DeployKeyProvider is the Git provider a deploy key is registered with.
+type DeployKeyProvider string
+
+func (p *DeployKeyProvider) String() string {
+	return string(*p)
+}
+
+func (p *DeployKeyProvider) Set(str string) error {
+	if strings.TrimSpace(str) == "" {
+		return fmt.Errorf("no deploy key provider given, please specify %s",
+			p.Description())
+	}
+	if !utils.ContainsItemString(supportedDeployKeyProviders, str) {
+		return fmt.Errorf("deploy key provider '%s' is not supported, must be one of: %v",
+			str, strings.Join(supportedDeployKeyProviders, ", "))
+	}
+	*p = DeployKeyProvider(str)
+	return nil
+}
+
+func (p *DeployKeyProvider) Type() string {
+	return "deployKeyProvider"
+}
+
+func (p *DeployKeyProvider) Description() string {
+	return fmt.Sprintf(
+		"the Git provider the deploy key is registered with, available options are: (%s)",
+		strings.Join(supportedDeployKeyProviders, ", "),
+	)
+}
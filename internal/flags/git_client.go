@@ -0,0 +1,59 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flags
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fluxcd/flux2/internal/utils"
+)
+
+const (
+	// GoGitClient is the Git client backed by go-git.
+	GoGitClient = "go-git"
+	// CLIGitClient is the Git client backed by the git binary.
+	CLIGitClient = "git"
+)
+
+var supportedGitClients = []string{GoGitClient, CLIGitClient}
+
+type GitClient string
+
+func (c *GitClient) String() string {
+	return string(*c)
+}
+
+func (c *GitClient) Set(str string) error {
+	if str == "" {
+		return nil
+	}
+	if !utils.ContainsItemString(supportedGitClients, str) {
+		return fmt.Errorf("unsupported Git client '%s', must be one of: %s",
+			str, strings.Join(supportedGitClients, ", "))
+	}
+	*c = GitClient(str)
+	return nil
+}
+
+func (c *GitClient) Type() string {
+	return "gitClient"
+}
+
+func (c *GitClient) Description() string {
+	return fmt.Sprintf("the Git client used to commit and push the manifests, available options are: (%s), the cli client requires git 2.31 or later and a private key without passphrase", strings.Join(supportedGitClients, ", "))
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/krusty"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+)
+
+// ReadObjects decodes the Kubernetes objects from the multi-doc YAML
+// or JSON read from r, skipping empty documents and flattening lists.
+func ReadObjects(r io.Reader) ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
+	reader := yaml.NewYAMLOrJSONDecoder(r, 2048)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := reader.Decode(obj); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to decode objects: %w", err)
+		}
+		if obj.GetKind() == "" {
+			continue
+		}
+
+		if obj.IsList() {
+			err := obj.EachListItem(func(item runtime.Object) error {
+				objects = append(objects, item.(*unstructured.Unstructured))
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode objects: %w", err)
+			}
+			continue
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// ReadPath returns the objects from the file at the given path,
+// or from the Kustomize build of the directory at the given path.
+func ReadPath(path string) ([]*unstructured.Unstructured, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return ReadKustomization(filesys.MakeFsOnDisk(), path)
+	}
+	manifests, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ReadObjects(bytes.NewReader(manifests))
+}
+
+// ReadKustomization returns the objects from the Kustomize build of the
+// directory at the given path of the file system.
+func ReadKustomization(fs filesys.FileSystem, path string) ([]*unstructured.Unstructured, error) {
+	k := krusty.MakeKustomizer(&krusty.Options{
+		LoadRestrictions: kustypes.LoadRestrictionsNone,
+		PluginConfig:     kustypes.DisabledPluginConfig(),
+	})
+	m, err := k.Run(fs, path)
+	if err != nil {
+		return nil, fmt.Errorf("kustomize build failed: %w", err)
+	}
+	manifests, err := m.AsYaml()
+	if err != nil {
+		return nil, err
+	}
+	return ReadObjects(bytes.NewReader(manifests))
+}
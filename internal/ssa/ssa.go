@@ -0,0 +1,322 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ssa applies Kubernetes objects in-process with server-side apply.
+package ssa
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FieldOwner is the field manager of the objects applied by the flux CLI,
+// shared by the install and bootstrap commands so that they don't
+// conflict over the ownership of the fields of the Flux components.
+const FieldOwner = "flux"
+
+// Action is the result of applying an object.
+type Action string
+
+const (
+	CreatedAction    Action = "created"
+	ConfiguredAction Action = "configured"
+	UnchangedAction  Action = "unchanged"
+)
+
+// ChangeSetEntry is the result of applying a single object.
+type ChangeSetEntry struct {
+	// Subject is the object reference in the Kind/namespace/name format.
+	Subject string
+	Action  Action
+}
+
+func (e ChangeSetEntry) String() string {
+	return fmt.Sprintf("%s %s", e.Subject, e.Action)
+}
+
+// ChangeSet holds the results of applying a set of objects.
+type ChangeSet struct {
+	Entries []ChangeSetEntry
+}
+
+func (c *ChangeSet) add(entries ...ChangeSetEntry) {
+	c.Entries = append(c.Entries, entries...)
+}
+
+func (c *ChangeSet) String() string {
+	var b strings.Builder
+	for _, e := range c.Entries {
+		b.WriteString(e.String())
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// ResourceManager applies objects with server-side apply using a
+// controller-runtime client.
+type ResourceManager struct {
+	client client.Client
+	owner  string
+
+	// DryRun makes the manager report the changes without
+	// persisting them.
+	DryRun bool
+
+	// PollInterval is the interval at which the CRDs
+	// are checked for being established.
+	PollInterval time.Duration
+}
+
+// NewResourceManager returns a ResourceManager applying
+// objects as the given field owner.
+func NewResourceManager(c client.Client, owner string) *ResourceManager {
+	return &ResourceManager{
+		client:       c,
+		owner:        owner,
+		PollInterval: 2 * time.Second,
+	}
+}
+
+// Apply applies the object and reports whether it was
+// created, configured or unchanged.
+func (m *ResourceManager) Apply(ctx context.Context, object *unstructured.Unstructured) (*ChangeSetEntry, error) {
+	entry := &ChangeSetEntry{Subject: FmtObject(object)}
+
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(object.GroupVersionKind())
+	err := m.client.Get(ctx, client.ObjectKeyFromObject(object), existing)
+	if err != nil {
+		// Objects of kinds that are not installed yet do not exist,
+		// which is only expected in dry-run mode
+		if !apierrors.IsNotFound(err) && !(m.DryRun && apimeta.IsNoMatchError(err)) {
+			return nil, fmt.Errorf("%s query failed: %w", entry.Subject, err)
+		}
+		entry.Action = CreatedAction
+		if m.DryRun {
+			return entry, nil
+		}
+		if err := m.apply(ctx, object.DeepCopy(), false); err != nil {
+			return nil, fmt.Errorf("%s apply failed: %w", entry.Subject, err)
+		}
+		return entry, nil
+	}
+
+	dryRunObject := object.DeepCopy()
+	if err := m.apply(ctx, dryRunObject, true); err != nil {
+		return nil, fmt.Errorf("%s dry-run failed: %w", entry.Subject, err)
+	}
+	if !hasDrifted(existing, dryRunObject) {
+		entry.Action = UnchangedAction
+		return entry, nil
+	}
+
+	entry.Action = ConfiguredAction
+	if m.DryRun {
+		return entry, nil
+	}
+	if err := m.apply(ctx, object.DeepCopy(), false); err != nil {
+		return nil, fmt.Errorf("%s apply failed: %w", entry.Subject, err)
+	}
+	return entry, nil
+}
+
+// ApplyAll applies the objects sorted in dependency order,
+// and stops at the first failure.
+func (m *ResourceManager) ApplyAll(ctx context.Context, objects []*unstructured.Unstructured) (*ChangeSet, error) {
+	changeSet := &ChangeSet{}
+	for _, object := range SortObjects(objects) {
+		entry, err := m.Apply(ctx, object)
+		if err != nil {
+			return changeSet, err
+		}
+		changeSet.add(*entry)
+	}
+	return changeSet, nil
+}
+
+// ApplyAllStaged applies the CRDs and namespaces first, waits for the CRDs
+// to be established, then applies the rest of the objects.
+func (m *ResourceManager) ApplyAllStaged(ctx context.Context, objects []*unstructured.Unstructured, timeout time.Duration) (*ChangeSet, error) {
+	var definitions, resources []*unstructured.Unstructured
+	for _, object := range objects {
+		if isDefinition(object) {
+			definitions = append(definitions, object)
+		} else {
+			resources = append(resources, object)
+		}
+	}
+
+	changeSet, err := m.ApplyAll(ctx, definitions)
+	if err != nil {
+		return changeSet, err
+	}
+	if !m.DryRun {
+		if err := m.WaitForEstablished(ctx, definitions, timeout); err != nil {
+			return changeSet, err
+		}
+	}
+
+	resourcesChangeSet, err := m.ApplyAll(ctx, resources)
+	changeSet.add(resourcesChangeSet.Entries...)
+	return changeSet, err
+}
+
+// WaitForEstablished waits for the CRDs in the given
+// objects to have the Established condition.
+func (m *ResourceManager) WaitForEstablished(ctx context.Context, objects []*unstructured.Unstructured, timeout time.Duration) error {
+	for _, object := range objects {
+		if object.GetKind() != "CustomResourceDefinition" {
+			continue
+		}
+		crd := &unstructured.Unstructured{}
+		crd.SetGroupVersionKind(object.GroupVersionKind())
+		err := wait.PollImmediate(m.PollInterval, timeout, func() (bool, error) {
+			if err := m.client.Get(ctx, client.ObjectKeyFromObject(object), crd); err != nil {
+				if apierrors.IsNotFound(err) {
+					return false, nil
+				}
+				return false, err
+			}
+			return isEstablished(crd), nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s is not established: %w", FmtObject(object), err)
+		}
+	}
+	return nil
+}
+
+func (m *ResourceManager) apply(ctx context.Context, object *unstructured.Unstructured, dryRun bool) error {
+	opts := []client.PatchOption{
+		client.ForceOwnership,
+		client.FieldOwner(m.owner),
+	}
+	if dryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+	return m.client.Patch(ctx, object, client.Apply, opts...)
+}
+
+// FmtObject returns the object reference in the Kind/namespace/name format.
+func FmtObject(object *unstructured.Unstructured) string {
+	if ns := object.GetNamespace(); ns != "" {
+		return fmt.Sprintf("%s/%s/%s", object.GetKind(), ns, object.GetName())
+	}
+	return fmt.Sprintf("%s/%s", object.GetKind(), object.GetName())
+}
+
+// kindOrder is the order in which objects are applied,
+// kinds that are not listed are applied last.
+var kindOrder = []string{
+	"CustomResourceDefinition",
+	"Namespace",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"PodDisruptionBudget",
+	"ServiceAccount",
+	"Secret",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"Role",
+	"RoleBinding",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicaSet",
+	"Deployment",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"Ingress",
+	"NetworkPolicy",
+	"APIService",
+	"MutatingWebhookConfiguration",
+	"ValidatingWebhookConfiguration",
+}
+
+// SortObjects returns the objects sorted by kind in dependency
+// order, then by namespace and name.
+func SortObjects(objects []*unstructured.Unstructured) []*unstructured.Unstructured {
+	rank := func(object *unstructured.Unstructured) int {
+		for i, kind := range kindOrder {
+			if object.GetKind() == kind {
+				return i
+			}
+		}
+		return len(kindOrder)
+	}
+	sorted := make([]*unstructured.Unstructured, len(objects))
+	copy(sorted, objects)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ri, rj := rank(sorted[i]), rank(sorted[j])
+		if ri != rj {
+			return ri < rj
+		}
+		if sorted[i].GetKind() != sorted[j].GetKind() {
+			return sorted[i].GetKind() < sorted[j].GetKind()
+		}
+		if sorted[i].GetNamespace() != sorted[j].GetNamespace() {
+			return sorted[i].GetNamespace() < sorted[j].GetNamespace()
+		}
+		return sorted[i].GetName() < sorted[j].GetName()
+	})
+	return sorted
+}
+
+func isDefinition(object *unstructured.Unstructured) bool {
+	return object.GetKind() == "CustomResourceDefinition" || object.GetKind() == "Namespace"
+}
+
+func isEstablished(crd *unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == "Established" && condition["status"] == "True" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasDrifted returns true if the dry-run applied object differs from
+// the existing one, ignoring the metadata set by the API server.
+func hasDrifted(existing, dryRunObject *unstructured.Unstructured) bool {
+	return !equality.Semantic.DeepEqual(stripServerFields(existing), stripServerFields(dryRunObject))
+}
+
+func stripServerFields(object *unstructured.Unstructured) map[string]interface{} {
+	o := object.DeepCopy()
+	for _, field := range []string{"managedFields", "resourceVersion", "generation", "creationTimestamp", "uid", "selfLink"} {
+		unstructured.RemoveNestedField(o.Object, "metadata", field)
+	}
+	unstructured.RemoveNestedField(o.Object, "status")
+	return o.Object
+}
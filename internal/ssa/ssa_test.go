@@ -0,0 +1,237 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluxcd/flux2/internal/utils"
+)
+
+// applyClient emulates server-side apply on top of the fake client, which
+// does not support it. The applied object replaces the existing one, and
+// CRDs are established as soon as they are created.
+type applyClient struct {
+	client.Client
+	patches []string
+}
+
+func (c *applyClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+	po := &client.PatchOptions{}
+	po.ApplyOptions(opts)
+	dryRun := len(po.DryRun) > 0
+
+	desired := obj.(*unstructured.Unstructured)
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(desired.GroupVersionKind())
+	if err := c.Get(ctx, client.ObjectKeyFromObject(desired), existing); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		if dryRun {
+			return nil
+		}
+		c.patches = append(c.patches, FmtObject(desired))
+		if desired.GetKind() == "CustomResourceDefinition" {
+			_ = unstructured.SetNestedSlice(desired.Object, []interface{}{
+				map[string]interface{}{"type": "Established", "status": "True"},
+			}, "status", "conditions")
+		}
+		return c.Create(ctx, desired)
+	}
+	for _, field := range []string{"resourceVersion", "creationTimestamp", "uid"} {
+		if v, ok, _ := unstructured.NestedFieldCopy(existing.Object, "metadata", field); ok {
+			_ = unstructured.SetNestedField(desired.Object, v, "metadata", field)
+		}
+	}
+	if status, ok, _ := unstructured.NestedFieldCopy(existing.Object, "status"); ok {
+		_ = unstructured.SetNestedField(desired.Object, status, "status")
+	}
+	if dryRun {
+		return nil
+	}
+	c.patches = append(c.patches, FmtObject(desired))
+	return c.Update(ctx, desired)
+}
+
+const testObjects = `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: test
+data:
+  key: value
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: tests.example.com
+spec:
+  group: example.com
+  names:
+    kind: Test
+    plural: tests
+  scope: Namespaced
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: test
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: account
+  namespace: test
+`
+
+func TestResourceManager_ApplyAllStaged(t *testing.T) {
+	ctx := context.TODO()
+	objects, err := ReadObjects(strings.NewReader(testObjects))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 4 {
+		t.Fatalf("expected 4 objects, got %d", len(objects))
+	}
+
+	c := &applyClient{Client: fake.NewClientBuilder().WithScheme(utils.NewScheme()).Build()}
+	m := NewResourceManager(c, "flux")
+	m.PollInterval = 10 * time.Millisecond
+
+	changeSet, err := m.ApplyAllStaged(ctx, objects, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `CustomResourceDefinition/tests.example.com created
+Namespace/test created
+ServiceAccount/test/account created
+ConfigMap/test/config created`
+	if changeSet.String() != want {
+		t.Errorf("unexpected change set:\n%s\nwant:\n%s", changeSet, want)
+	}
+
+	// Applying again changes nothing
+	c.patches = nil
+	changeSet, err = m.ApplyAllStaged(ctx, objects, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range changeSet.Entries {
+		if e.Action != UnchangedAction {
+			t.Errorf("expected %s to be unchanged, got %s", e.Subject, e.Action)
+		}
+	}
+	if len(c.patches) != 0 {
+		t.Errorf("expected no patches for unchanged objects, got %v", c.patches)
+	}
+
+	// Dry-run reports the change without applying it
+	_ = unstructured.SetNestedField(objects[0].Object, "changed", "data", "key")
+	m.DryRun = true
+	entry, err := m.Apply(ctx, objects[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Action != ConfiguredAction || len(c.patches) != 0 {
+		t.Errorf("expected dry-run configured without patches, got %s and %v", entry.Action, c.patches)
+	}
+
+	m.DryRun = false
+	if entry, err = m.Apply(ctx, objects[0]); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Action != ConfiguredAction || len(c.patches) != 1 {
+		t.Errorf("expected configured with one patch, got %s and %v", entry.Action, c.patches)
+	}
+}
+
+func TestSortObjects(t *testing.T) {
+	objects, err := ReadObjects(strings.NewReader(testObjects + `---
+apiVersion: example.com/v1
+kind: Test
+metadata:
+  name: test
+  namespace: test
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: test
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, o := range SortObjects(objects) {
+		kinds = append(kinds, o.GetKind())
+	}
+	want := "CustomResourceDefinition,Namespace,ServiceAccount,ConfigMap,Deployment,Test"
+	if got := strings.Join(kinds, ","); got != want {
+		t.Errorf("SortObjects() = %s, want %s", got, want)
+	}
+}
+
+func TestReadObjects(t *testing.T) {
+	objects, err := ReadObjects(strings.NewReader(`---
+# empty document
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: test
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: first
+    namespace: test
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: second
+    namespace: test
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, o := range objects {
+		names = append(names, o.GetKind()+"/"+o.GetName())
+	}
+	want := "Namespace/test,ConfigMap/first,ConfigMap/second"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("ReadObjects() = %s, want %s", got, want)
+	}
+}
@@ -33,14 +33,11 @@ import (
 	networkingv1 "k8s.io/api/networking/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	apiruntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
-	sigyaml "k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/yaml"
 
 	helmv2 "github.com/fluxcd/helm-controller/api/v2beta1"
 	imageautov1 "github.com/fluxcd/image-automation-controller/api/v1beta1"
@@ -310,41 +307,3 @@ func CompatibleVersion(binary, target string) bool {
 	}
 	return binSv.Major() == targetSv.Major() && binSv.Minor() == targetSv.Minor()
 }
-
-func ExtractCRDs(inManifestPath, outManifestPath string) error {
-	manifests, err := os.ReadFile(inManifestPath)
-	if err != nil {
-		return err
-	}
-
-	crds := ""
-	reader := sigyaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifests), 2048)
-
-	for {
-		var obj unstructured.Unstructured
-		err := reader.Decode(&obj)
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			return err
-		}
-
-		if obj.GetKind() == "CustomResourceDefinition" {
-			b, err := obj.MarshalJSON()
-			if err != nil {
-				return err
-			}
-			y, err := yaml.JSONToYAML(b)
-			if err != nil {
-				return err
-			}
-			crds += "---\n" + string(y)
-		}
-	}
-
-	if crds == "" {
-		return fmt.Errorf("no CRDs found in %s", inManifestPath)
-	}
-
-	return os.WriteFile(outManifestPath, []byte(crds), os.ModePerm)
-}
@@ -19,8 +19,6 @@ limitations under the License.
 package utils
 
 import (
-	"os"
-	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -120,32 +118,3 @@ func TestValidateComponents(t *testing.T) {
 		})
 	}
 }
-
-func TestExtractCRDs(t *testing.T) {
-	tests := []struct {
-		name           string
-		inManifestFile string
-		expectErr      bool
-	}{
-		{"with crds", "components-with-crds.yaml", false},
-		{"without crds", "components-without-crds.yaml", true},
-		{"non-existent file", "non-existent-file.yaml", true},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Create temporary directory to write the result in.
-			dir, err := os.MkdirTemp("", "flux-TestExtractCRDs")
-			if err != nil {
-				t.Fatalf("failed to create temporary directory: %v", err)
-			}
-			defer os.RemoveAll(dir)
-
-			outManifestPath := filepath.Join(dir, "crds.yaml")
-			inManifestPath := filepath.Join("testdata", tt.inManifestFile)
-			if err = ExtractCRDs(inManifestPath, outManifestPath); (err != nil) != tt.expectErr {
-				t.Errorf("ExtractCRDs() error = %v, expectErr %v", err, tt.expectErr)
-			}
-		})
-	}
-}
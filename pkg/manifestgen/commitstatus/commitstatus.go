@@ -0,0 +1,102 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commitstatus
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+	notificationv1 "github.com/fluxcd/notification-controller/api/v1beta1"
+	"github.com/fluxcd/pkg/apis/meta"
+
+	"github.com/fluxcd/flux2/pkg/manifestgen"
+)
+
+// Generate returns the manifest of a notification Provider setting the
+// commit status on the repository at the configured address, and of an
+// Alert routing the events of the Kustomization to it. The manifest is
+// placed next to the sync manifests.
+func Generate(options Options) (*manifestgen.Manifest, error) {
+	gvk := notificationv1.GroupVersion.WithKind(notificationv1.ProviderKind)
+	provider := notificationv1.Provider{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       gvk.Kind,
+			APIVersion: gvk.GroupVersion().String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      options.Name,
+			Namespace: options.Namespace,
+		},
+		Spec: notificationv1.ProviderSpec{
+			Type:    options.Type,
+			Address: options.Address,
+			SecretRef: &meta.LocalObjectReference{
+				Name: options.Secret,
+			},
+		},
+	}
+
+	providerData, err := yaml.Marshal(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	gvk = notificationv1.GroupVersion.WithKind(notificationv1.AlertKind)
+	alert := notificationv1.Alert{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       gvk.Kind,
+			APIVersion: gvk.GroupVersion().String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      options.Name,
+			Namespace: options.Namespace,
+		},
+		Spec: notificationv1.AlertSpec{
+			ProviderRef: meta.LocalObjectReference{
+				Name: options.Name,
+			},
+			EventSeverity: "info",
+			EventSources: []notificationv1.CrossNamespaceObjectReference{
+				{
+					Kind: kustomizev1.KustomizationKind,
+					Name: options.KustomizationName,
+				},
+			},
+		},
+	}
+
+	alertData, err := yaml.Marshal(alert)
+	if err != nil {
+		return nil, err
+	}
+
+	return &manifestgen.Manifest{
+		Path:    path.Join(options.TargetPath, options.Namespace, options.ManifestFile),
+		Content: fmt.Sprintf("---\n%s---\n%s", resourceToString(providerData), resourceToString(alertData)),
+	}, nil
+}
+
+func resourceToString(data []byte) string {
+	data = bytes.Replace(data, []byte("  creationTimestamp: null\n"), []byte(""), 1)
+	data = bytes.Replace(data, []byte("status: {}\n"), []byte(""), 1)
+	return string(data)
+}
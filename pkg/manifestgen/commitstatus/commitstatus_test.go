@@ -0,0 +1,53 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commitstatus
+
+import (
+	"strings"
+	"testing"
+
+	notificationv1 "github.com/fluxcd/notification-controller/api/v1beta1"
+)
+
+func TestGenerate(t *testing.T) {
+	opts := MakeDefaultOptions()
+	opts.Address = "https://github.com/org/repo"
+	opts.TargetPath = "clusters/production"
+	output, err := Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "clusters/production/flux-system/gotk-commit-status.yaml"; output.Path != want {
+		t.Errorf("Path = %q, want %q", output.Path, want)
+	}
+	for _, s := range []string{
+		"apiVersion: " + notificationv1.GroupVersion.String(),
+		"kind: Provider",
+		"address: https://github.com/org/repo",
+		"name: flux-system-commit-status",
+		"kind: Alert",
+		"kind: Kustomization",
+	} {
+		if !strings.Contains(output.Content, s) {
+			t.Errorf("%q not found in:\n%s", s, output.Content)
+		}
+	}
+	if strings.Contains(output.Content, "status:") {
+		t.Errorf("unexpected status in:\n%s", output.Content)
+	}
+}
@@ -0,0 +1,376 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/fluxcd/pkg/untar"
+)
+
+const (
+	// BundleMetadataFile is the name of the file in the root of a bundle
+	// that holds the Flux version and the image list.
+	BundleMetadataFile = "bundle.json"
+
+	// BundleImagesDir is the directory in the root of a bundle that holds
+	// the image layers as an OCI image layout.
+	BundleImagesDir = "oci"
+
+	bundleRefAnnotation = "org.opencontainers.image.ref.name"
+)
+
+// BundleImage is a container image referenced by the install manifests.
+type BundleImage struct {
+	// Name is the image repository, e.g. ghcr.io/fluxcd/source-controller.
+	Name string `json:"name"`
+
+	// Tag is the image tag, e.g. v0.15.4.
+	Tag string `json:"tag"`
+
+	// Digest is the content digest of the image manifest or index.
+	Digest string `json:"digest"`
+}
+
+// Ref returns the image reference pinned to its digest.
+func (i BundleImage) Ref() string {
+	return fmt.Sprintf("%s:%s@%s", i.Name, i.Tag, i.Digest)
+}
+
+// Bundle is an extracted air-gapped install bundle.
+type Bundle struct {
+	// Version is the Flux version the bundle was exported for.
+	Version string `json:"version"`
+
+	// Registry is the container registry the images were exported from.
+	Registry string `json:"registry"`
+
+	// Images is the list of images referenced by the install manifests.
+	Images []BundleImage `json:"images"`
+
+	dir string
+}
+
+// ManifestsBase returns the path of the Kustomize base in the bundle,
+// to be used as the manifestsBase argument of Generate.
+func (b *Bundle) ManifestsBase() string {
+	return b.dir
+}
+
+// HasImageLayers returns true if the bundle contains the image layers.
+func (b *Bundle) HasImageLayers() bool {
+	_, err := os.Stat(filepath.Join(b.dir, BundleImagesDir, "index.json"))
+	return err == nil
+}
+
+// PushImages pushes the image layers from the bundle to the given
+// registry, preserving the repository base name, tag and digest of
+// every image.
+func (b *Bundle) PushImages(ctx context.Context, registry string, options ...remote.Option) error {
+	p, err := layout.FromPath(filepath.Join(b.dir, BundleImagesDir))
+	if err != nil {
+		return fmt.Errorf("failed to read the bundle image layout: %w", err)
+	}
+	index, err := p.ImageIndex()
+	if err != nil {
+		return fmt.Errorf("failed to read the bundle image index: %w", err)
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return fmt.Errorf("failed to read the bundle image index: %w", err)
+	}
+
+	options = append(options, remote.WithContext(ctx))
+	for _, desc := range manifest.Manifests {
+		src, ok := desc.Annotations[bundleRefAnnotation]
+		if !ok {
+			continue
+		}
+		srcRef, err := name.NewTag(src)
+		if err != nil {
+			return fmt.Errorf("invalid image reference '%s' in bundle: %w", src, err)
+		}
+		dst := retag(srcRef, registry)
+		dstRef, err := name.NewTag(dst)
+		if err != nil {
+			return fmt.Errorf("invalid image reference '%s': %w", dst, err)
+		}
+
+		switch {
+		case desc.MediaType.IsIndex():
+			ii, err := index.ImageIndex(desc.Digest)
+			if err != nil {
+				return fmt.Errorf("failed to read image %s from bundle: %w", src, err)
+			}
+			if err := remote.WriteIndex(dstRef, ii, options...); err != nil {
+				return fmt.Errorf("failed to push image %s: %w", dst, err)
+			}
+		case desc.MediaType.IsImage():
+			img, err := index.Image(desc.Digest)
+			if err != nil {
+				return fmt.Errorf("failed to read image %s from bundle: %w", src, err)
+			}
+			if err := remote.Write(dstRef, img, options...); err != nil {
+				return fmt.Errorf("failed to push image %s: %w", dst, err)
+			}
+		default:
+			return fmt.Errorf("unsupported media type %s for image %s", desc.MediaType, src)
+		}
+	}
+	return nil
+}
+
+// ExportBundle writes a gzipped tarball to w containing the Kustomize base
+// for options.Version, the list of images used by options.Components with
+// their digests and, when includeLayers is true, the image layers as an
+// OCI image layout. The manifestsBase should be set to an empty string to
+// download the base from options.BaseURL.
+func ExportBundle(ctx context.Context, options Options, manifestsBase string, includeLayers bool, w io.Writer, remoteOptions ...remote.Option) error {
+	tmpDir, err := os.MkdirTemp("", "flux-bundle-")
+	if err != nil {
+		return fmt.Errorf("temp dir error: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	bundleDir := filepath.Join(tmpDir, "bundle")
+	if err := os.MkdirAll(bundleDir, os.ModePerm); err != nil {
+		return err
+	}
+	if manifestsBase == "" {
+		if err := fetch(ctx, options.releaseSource(), options.BaseURL, options.Version, options.CacheDir, bundleDir); err != nil {
+			return err
+		}
+	} else if err := copyDir(manifestsBase, bundleDir); err != nil {
+		return fmt.Errorf("failed to copy manifests base: %w", err)
+	}
+
+	// build the manifests in a scratch copy of the base to find the images
+	buildDir := filepath.Join(tmpDir, "build")
+	if err := copyDir(bundleDir, buildDir); err != nil {
+		return fmt.Errorf("failed to copy manifests base: %w", err)
+	}
+	if err := generate(buildDir, options); err != nil {
+		return err
+	}
+	output := filepath.Join(buildDir, options.ManifestFile)
+	if err := build(buildDir, output); err != nil {
+		return err
+	}
+	refs, err := imagesFromManifests(output)
+	if err != nil {
+		return err
+	}
+
+	remoteOptions = append(remoteOptions, remote.WithContext(ctx))
+	var p layout.Path
+	if includeLayers {
+		p, err = layout.Write(filepath.Join(bundleDir, BundleImagesDir), empty.Index)
+		if err != nil {
+			return fmt.Errorf("failed to create image layout: %w", err)
+		}
+	}
+
+	bundle := Bundle{
+		Version:  options.Version,
+		Registry: options.Registry,
+	}
+	for _, ref := range refs {
+		tag, err := name.NewTag(ref)
+		if err != nil {
+			return fmt.Errorf("invalid image reference '%s': %w", ref, err)
+		}
+		desc, err := remote.Get(tag, remoteOptions...)
+		if err != nil {
+			return fmt.Errorf("failed to resolve image %s: %w", ref, err)
+		}
+		bundle.Images = append(bundle.Images, BundleImage{
+			Name:   tag.Context().Name(),
+			Tag:    tag.TagStr(),
+			Digest: desc.Digest.String(),
+		})
+
+		if !includeLayers {
+			continue
+		}
+		annotations := layout.WithAnnotations(map[string]string{bundleRefAnnotation: tag.Name()})
+		if desc.MediaType.IsIndex() {
+			ii, err := desc.ImageIndex()
+			if err != nil {
+				return fmt.Errorf("failed to pull image %s: %w", ref, err)
+			}
+			if err := p.AppendIndex(ii, annotations); err != nil {
+				return fmt.Errorf("failed to write image %s: %w", ref, err)
+			}
+		} else {
+			img, err := desc.Image()
+			if err != nil {
+				return fmt.Errorf("failed to pull image %s: %w", ref, err)
+			}
+			if err := p.AppendImage(img, annotations); err != nil {
+				return fmt.Errorf("failed to write image %s: %w", ref, err)
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(bundleDir, BundleMetadataFile), data, 0644); err != nil {
+		return err
+	}
+
+	return writeTarball(bundleDir, w)
+}
+
+// OpenBundle extracts the bundle tarball read from r into dir
+// and returns its metadata.
+func OpenBundle(r io.Reader, dir string) (*Bundle, error) {
+	if _, err := untar.Untar(r, dir); err != nil {
+		return nil, fmt.Errorf("failed to extract bundle: %w", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, BundleMetadataFile))
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	var bundle Bundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("invalid bundle metadata: %w", err)
+	}
+	if bundle.Version == "" {
+		return nil, fmt.Errorf("invalid bundle metadata: version is missing")
+	}
+	bundle.dir = dir
+	return &bundle, nil
+}
+
+// retag returns the image reference with its registry and
+// repository path replaced by the given registry.
+func retag(ref name.Tag, registry string) string {
+	repo := ref.RepositoryStr()
+	if i := strings.LastIndex(repo, "/"); i >= 0 {
+		repo = repo[i+1:]
+	}
+	return fmt.Sprintf("%s/%s:%s", strings.TrimSuffix(registry, "/"), repo, ref.TagStr())
+}
+
+// imagesFromManifests returns the sorted list of container
+// images referenced by the Deployments in the given file.
+func imagesFromManifests(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	images := map[string]bool{}
+	decoder := yaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		var deployment appsv1.Deployment
+		if err := decoder.Decode(&deployment); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("failed to decode manifests: %w", err)
+		}
+		if deployment.Kind != "Deployment" {
+			continue
+		}
+		for _, c := range deployment.Spec.Template.Spec.Containers {
+			images[c.Image] = true
+		}
+	}
+
+	var result []string
+	for image := range images {
+		result = append(result, image)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, os.ModePerm)
+		}
+		return copyFile(p, target)
+	})
+}
+
+func writeTarball(dir string, w io.Writer) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
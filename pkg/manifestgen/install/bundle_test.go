@@ -0,0 +1,134 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestExportBundle(t *testing.T) {
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := random.Index(64, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := fmt.Sprintf("%s/fluxcd/source-controller:v0.1.0", u.Host)
+	if err := remote.WriteIndex(mustParseTag(t, src), index); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := index.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := testManifestsBase(t)
+
+	opts := MakeDefaultOptions()
+	opts.Version = "v0.1.0"
+	opts.Components = []string{"source-controller"}
+	opts.NetworkPolicy = false
+	opts.Registry = fmt.Sprintf("%s/fluxcd", u.Host)
+
+	var buf bytes.Buffer
+	if err := ExportBundle(context.TODO(), opts, base, true, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := OpenBundle(&buf, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bundle.Version != opts.Version {
+		t.Errorf("expected version %s, got %s", opts.Version, bundle.Version)
+	}
+	if len(bundle.Images) != 1 {
+		t.Fatalf("expected one image, got %v", bundle.Images)
+	}
+	if want := fmt.Sprintf("%s@%s", src, digest); bundle.Images[0].Ref() != want {
+		t.Errorf("expected image %s, got %s", want, bundle.Images[0].Ref())
+	}
+	if !bundle.HasImageLayers() {
+		t.Fatal("expected bundle to contain the image layers")
+	}
+
+	private := fmt.Sprintf("%s/private", u.Host)
+	if err := bundle.PushImages(context.TODO(), private); err != nil {
+		t.Fatal(err)
+	}
+	desc, err := remote.Head(mustParseTag(t, private+"/source-controller:v0.1.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Digest != digest {
+		t.Errorf("expected pushed digest %s, got %s", digest, desc.Digest)
+	}
+
+	opts.Registry = private
+	output, err := Generate(opts, bundle.ManifestsBase())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img := private + "/source-controller:v0.1.0"; !strings.Contains(output.Content, img) {
+		t.Errorf("image '%s' not found in:\n%s", img, output.Content)
+	}
+}
+
+func TestRetag(t *testing.T) {
+	tests := []struct {
+		ref      string
+		registry string
+		want     string
+	}{
+		{"ghcr.io/fluxcd/source-controller:v0.1.0", "registry.local/flux", "registry.local/flux/source-controller:v0.1.0"},
+		{"ghcr.io/fluxcd/helm-controller:v0.1.0", "registry.local:5000/", "registry.local:5000/helm-controller:v0.1.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			if got := retag(mustParseTag(t, tt.ref), tt.registry); got != tt.want {
+				t.Errorf("retag() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func mustParseTag(t *testing.T, ref string) name.Tag {
+	t.Helper()
+	tag, err := name.NewTag(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tag
+}
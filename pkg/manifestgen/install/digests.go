@@ -0,0 +1,87 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// resolveImageDigests builds the manifests base to output and returns the
+// digests of the component images, keyed by component name. The digests
+// found in options.ImageDigests are kept, the others are resolved from the
+// images registry.
+func resolveImageDigests(ctx context.Context, base, output string, options Options) (map[string]string, error) {
+	if err := build(base, output); err != nil {
+		return nil, err
+	}
+	refs, err := imagesFromManifests(output)
+	if err != nil {
+		return nil, err
+	}
+
+	digests := make(map[string]string, len(refs))
+	for component, digest := range options.ImageDigests {
+		digests[component] = digest
+	}
+	for _, ref := range refs {
+		r, err := name.ParseReference(ref)
+		if err != nil {
+			return nil, fmt.Errorf("invalid image reference '%s': %w", ref, err)
+		}
+		component := imageComponent(r)
+		if !containsItemString(options.Components, component) {
+			continue
+		}
+		if _, ok := digests[component]; ok {
+			continue
+		}
+		desc, err := remote.Head(r, remote.WithContext(ctx), remote.WithAuthFromKeychain(authn.DefaultKeychain))
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve the digest of image %s: %w", ref, err)
+		}
+		digests[component] = desc.Digest.String()
+	}
+	return digests, nil
+}
+
+// validateImageDigests returns an error if a digest
+// is not in the algorithm:hex format.
+func validateImageDigests(options Options) error {
+	for component, digest := range options.ImageDigests {
+		if _, err := v1.NewHash(digest); err != nil {
+			return fmt.Errorf("invalid image digest for component '%s': %w", component, err)
+		}
+	}
+	return nil
+}
+
+// imageComponent returns the last element of the image repository path,
+// which is the name of the component the image belongs to.
+func imageComponent(ref name.Reference) string {
+	repo := ref.Context().RepositoryStr()
+	if i := strings.LastIndex(repo, "/"); i >= 0 {
+		repo = repo[i+1:]
+	}
+	return repo
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestGenerate_imageDigests(t *testing.T) {
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := fmt.Sprintf("%s/fluxcd/source-controller:v0.1.0", u.Host)
+	if err := remote.Write(mustParseTag(t, src), img); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pinned := "sha256:" + strings.Repeat("a", 64)
+	opts := MakeDefaultOptions()
+	opts.Version = "v0.1.0"
+	opts.Components = []string{"source-controller", "kustomize-controller"}
+	opts.NetworkPolicy = false
+	opts.Registry = fmt.Sprintf("%s/fluxcd", u.Host)
+	opts.ResolveImageDigests = true
+	opts.ImageDigests = map[string]string{"kustomize-controller": pinned}
+
+	output, err := Generate(opts, testManifestsBase(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, img := range []string{
+		fmt.Sprintf("%s/fluxcd/source-controller@%s", u.Host, digest),
+		fmt.Sprintf("%s/fluxcd/kustomize-controller@%s", u.Host, pinned),
+	} {
+		if !strings.Contains(output.Content, "image: "+img+"\n") {
+			t.Errorf("image '%s' not found in:\n%s", img, output.Content)
+		}
+	}
+
+	opts.ImageDigests = map[string]string{"kustomize-controller": "v0.1.0"}
+	if _, err := Generate(opts, testManifestsBase(t)); err == nil {
+		t.Error("expected error for invalid digest")
+	}
+}
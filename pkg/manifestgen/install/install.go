@@ -18,13 +18,10 @@ package install
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"net/http"
 	"os"
 	"path"
 	"strings"
-	"time"
 
 	securejoin "github.com/cyphar/filepath-securejoin"
 
@@ -42,6 +39,16 @@ func Generate(options Options, manifestsBase string) (*manifestgen.Manifest, err
 
 	var err error
 
+	if options.Namespaced && options.WatchAllNamespaces {
+		return nil, fmt.Errorf("namespaced install requires watch all namespaces to be disabled")
+	}
+	if options.MultiTenant && options.DefaultServiceAccount == "" {
+		return nil, fmt.Errorf("multi-tenant install requires a default service account")
+	}
+	if err := validateImageDigests(options); err != nil {
+		return nil, err
+	}
+
 	output, err := securejoin.SecureJoin(manifestsBase, options.ManifestFile)
 	if err != nil {
 		return nil, err
@@ -63,7 +70,13 @@ func Generate(options Options, manifestsBase string) (*manifestgen.Manifest, err
 			if err != nil {
 				return nil, err
 			}
-			if err := fetch(ctx, options.BaseURL, options.Version, manifestsBase); err != nil {
+			if err := fetch(ctx, options.releaseSource(), options.BaseURL, options.Version, options.CacheDir, manifestsBase); err != nil {
+				return nil, err
+			}
+		}
+
+		if options.MultiTenant {
+			if err := validateMultiTenant(manifestsBase, output, options); err != nil {
 				return nil, err
 			}
 		}
@@ -72,7 +85,21 @@ func Generate(options Options, manifestsBase string) (*manifestgen.Manifest, err
 			return nil, err
 		}
 
-		if err := build(manifestsBase, output); err != nil {
+		if options.ResolveImageDigests {
+			options.ImageDigests, err = resolveImageDigests(ctx, manifestsBase, output, options)
+			if err != nil {
+				return nil, err
+			}
+			if err := generate(manifestsBase, options); err != nil {
+				return nil, err
+			}
+		}
+
+		if options.Namespaced {
+			if err := buildNamespaced(manifestsBase, output); err != nil {
+				return nil, err
+			}
+		} else if err := build(manifestsBase, output); err != nil {
 			return nil, err
 		}
 	}
@@ -89,56 +116,21 @@ func Generate(options Options, manifestsBase string) (*manifestgen.Manifest, err
 }
 
 // GetLatestVersion calls the GitHub API and returns the latest released version.
+//
+// Deprecated: use the LatestVersion of a ReleaseSource, as returned by
+// NewReleaseSource, to honour mirrors and offline release indexes.
 func GetLatestVersion() (string, error) {
-	ghURL := "https://api.github.com/repos/fluxcd/flux2/releases/latest"
-	c := http.DefaultClient
-	c.Timeout = 15 * time.Second
-
-	res, err := c.Get(ghURL)
-	if err != nil {
-		return "", fmt.Errorf("GitHub API call failed: %w", err)
-	}
-
-	if res.Body != nil {
-		defer res.Body.Close()
-	}
-
-	type meta struct {
-		Tag string `json:"tag_name"`
-	}
-	var m meta
-	if err := json.NewDecoder(res.Body).Decode(&m); err != nil {
-		return "", fmt.Errorf("decoding GitHub API response failed: %w", err)
-	}
-
-	return m.Tag, err
+	ctx, cancel := context.WithTimeout(context.Background(), releasesHTTPClient.Timeout)
+	defer cancel()
+	return (&GitHubReleaseSource{URL: DefaultReleasesURL}).LatestVersion(ctx)
 }
 
 // ExistingVersion calls the GitHub API to confirm the given version does exist.
+//
+// Deprecated: use the VersionExists of a ReleaseSource, as returned by
+// NewReleaseSource, to honour mirrors and offline release indexes.
 func ExistingVersion(version string) (bool, error) {
-	if !strings.HasPrefix(version, "v") {
-		version = "v" + version
-	}
-
-	ghURL := fmt.Sprintf("https://api.github.com/repos/fluxcd/flux2/releases/tags/%s", version)
-	c := http.DefaultClient
-	c.Timeout = 15 * time.Second
-
-	res, err := c.Get(ghURL)
-	if err != nil {
-		return false, fmt.Errorf("GitHub API call failed: %w", err)
-	}
-
-	if res.Body != nil {
-		defer res.Body.Close()
-	}
-
-	switch res.StatusCode {
-	case http.StatusOK:
-		return true, nil
-	case http.StatusNotFound:
-		return false, nil
-	default:
-		return false, fmt.Errorf("GitHub API returned an unexpected status code (%d)", res.StatusCode)
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), releasesHTTPClient.Timeout)
+	defer cancel()
+	return (&GitHubReleaseSource{URL: DefaultReleasesURL}).VersionExists(ctx, version)
 }
@@ -17,13 +17,41 @@ limitations under the License.
 package install
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+
+	"github.com/Masterminds/semver/v3"
 )
 
 func TestGenerate(t *testing.T) {
 	opts := MakeDefaultOptions()
+	files := map[string]string{"rbac.yaml": testRBAC, "policies.yaml": testPolicies}
+	for _, component := range opts.Components {
+		files[component+".yaml"] = fmt.Sprintf(testDeploymentTmpl, component, "v0.2.0")
+	}
+	archive := testManifestsArchive(t, files)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"tag_name": "v0.2.0"}`)
+	})
+	mux.HandleFunc("/download/v0.2.0/manifests.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	})
+	mux.HandleFunc("/download/v0.2.0/flux_0.2.0_checksums.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%x  manifests.tar.gz\n", sha256.Sum256(archive))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	opts.BaseURL = server.URL
+	opts.ReleaseSource = &GitHubReleaseSource{URL: server.URL + "/releases"}
 	opts.TolerationKeys = []string{"node.kubernetes.io/controllers"}
 	output, err := Generate(opts, "")
 	if err != nil {
@@ -41,5 +69,231 @@ func TestGenerate(t *testing.T) {
 		t.Errorf("toleration key '%s' not found", opts.TolerationKeys[0])
 	}
 
-	fmt.Println(output)
+}
+
+func TestGenerate_namespaced(t *testing.T) {
+	opts := MakeDefaultOptions()
+	opts.Version = "v0.1.0"
+	opts.Components = []string{"source-controller", "kustomize-controller"}
+	opts.NetworkPolicy = false
+	opts.Namespaced = true
+	opts.TargetNamespaces = []string{"apps", opts.Namespace}
+
+	if _, err := Generate(opts, testManifestsBase(t)); err == nil {
+		t.Fatal("expected error with watch all namespaces enabled")
+	}
+
+	opts.WatchAllNamespaces = false
+	output, err := Generate(opts, testManifestsBase(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, kind := range clusterScopedKinds {
+		if strings.Contains(output.Content, "\nkind: "+kind+"\n") {
+			t.Errorf("unexpected cluster-scoped kind %s in:\n%s", kind, output.Content)
+		}
+	}
+	for _, want := range []string{
+		"kind: Role\nmetadata:\n  name: crd-controller-flux-system\n  namespace: flux-system\n",
+		"name: reconciler-flux-system\n  namespace: flux-system\n",
+		"name: reconciler-flux-system\n  namespace: apps\n",
+		"kind: Deployment",
+	} {
+		if !strings.Contains(output.Content, want) {
+			t.Errorf("'%s' not found in:\n%s", want, output.Content)
+		}
+	}
+	if n := strings.Count(output.Content, "name: reconciler-flux-system"); n != 2 {
+		t.Errorf("expected 2 reconciler role bindings, got %d", n)
+	}
+}
+
+func TestGenerate_multiTenant(t *testing.T) {
+	pinned := pinnedComponentVersion(t, "kustomize-controller")
+	tests := []struct {
+		name    string
+		version string
+		wantErr bool
+	}{
+		{
+			name:    "pinned version",
+			version: pinned,
+			wantErr: semver.MustParse(pinned).LessThan(semver.MustParse(multiTenantMinVersions["kustomize-controller"])),
+		},
+		{
+			name:    "version without the flags",
+			version: "v0.19.2",
+			wantErr: true,
+		},
+		{
+			name:    "version with the flags",
+			version: "v0.20.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := MakeDefaultOptions()
+			opts.Version = "v0.1.0"
+			opts.Components = []string{"source-controller", "kustomize-controller"}
+			opts.NetworkPolicy = false
+			opts.MultiTenant = true
+			opts.DefaultServiceAccount = "tenant"
+
+			output, err := Generate(opts, testManifestsBaseVersion(t, tt.version))
+			if tt.wantErr {
+				want := fmt.Sprintf("multi-tenant install requires kustomize-controller v0.20.0 or later, got %s", tt.version)
+				if err == nil || err.Error() != want {
+					t.Fatalf("expected error '%s', got: %v", want, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// source-controller has no cross-namespace references
+			for flag, want := range map[string]int{
+				"--no-cross-namespace-refs=true":   1,
+				"--default-service-account=tenant": 1,
+			} {
+				if n := strings.Count(output.Content, flag); n != want {
+					t.Errorf("expected %s %d time(s), got %d in:\n%s", flag, want, n, output.Content)
+				}
+			}
+
+			opts.DefaultServiceAccount = ""
+			if _, err := Generate(opts, testManifestsBaseVersion(t, tt.version)); err == nil {
+				t.Error("expected error without default service account")
+			}
+		})
+	}
+}
+
+// pinnedComponentVersion returns the version of the component
+// pinned in the manifests bases of the repository.
+func pinnedComponentVersion(t *testing.T, component string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "..", "..", "manifests", "bases", component, "kustomization.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := regexp.MustCompile(`/releases/download/(v[^/]+)/`).FindStringSubmatch(string(data))
+	if m == nil {
+		t.Fatalf("no release of %s found in the manifests bases", component)
+	}
+	return m[1]
+}
+
+func TestGenerate_releaseSource(t *testing.T) {
+	files := map[string]string{"rbac.yaml": testRBAC}
+	for _, component := range []string{"source-controller", "kustomize-controller"} {
+		files[component+".yaml"] = fmt.Sprintf(testDeploymentTmpl, component, "v0.1.0")
+	}
+	archive := testManifestsArchive(t, files)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/download/v0.2.0/manifests.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	})
+	mux.HandleFunc("/download/v0.2.0/flux_0.2.0_checksums.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%x  manifests.tar.gz\n", sha256.Sum256(archive))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	index := filepath.Join(t.TempDir(), "releases.yaml")
+	if err := os.WriteFile(index, []byte("versions:\n- v0.1.0\n- v0.2.0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := MakeDefaultOptions()
+	opts.BaseURL = server.URL
+	opts.ReleaseSource = &FileReleaseSource{Path: index}
+	opts.Components = []string{"source-controller", "kustomize-controller"}
+	opts.NetworkPolicy = false
+
+	output, err := Generate(opts, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, component := range opts.Components {
+		if !strings.Contains(output.Content, "name: "+component) {
+			t.Errorf("component '%s' not found in:\n%s", component, output.Content)
+		}
+	}
+}
+
+const testDeploymentTmpl = `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: %[1]s
+spec:
+  selector:
+    matchLabels:
+      app: %[1]s
+  template:
+    metadata:
+      labels:
+        app: %[1]s
+    spec:
+      containers:
+      - name: manager
+        image: fluxcd/%[1]s:%[2]s
+        args:
+        - --events-addr=
+        - --watch-all-namespaces=true
+        - --log-level=info
+        - --log-encoding=json
+        - --enable-leader-election
+        - --storage-path=/data
+        - --storage-adv-addr=
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: %[1]ss.toolkit.fluxcd.io
+`
+
+const testRBAC = `---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: crd-controller
+rules: []
+`
+
+const testPolicies = `---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: allow-egress
+spec:
+  podSelector: {}
+  policyTypes:
+  - Egress
+`
+
+// testManifestsBase writes a manifests base with the source-controller
+// and kustomize-controller components to a temporary directory.
+func testManifestsBase(t *testing.T) string {
+	t.Helper()
+	return testManifestsBaseVersion(t, "v0.1.0")
+}
+
+// testManifestsBaseVersion writes a manifests base with the given version
+// of the components images to a temporary directory.
+func testManifestsBaseVersion(t *testing.T, version string) string {
+	t.Helper()
+	base := t.TempDir()
+	for _, component := range []string{"source-controller", "kustomize-controller"} {
+		data := fmt.Sprintf(testDeploymentTmpl, component, version)
+		if err := os.WriteFile(filepath.Join(base, component+".yaml"), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(base, "rbac.yaml"), []byte(testRBAC), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return base
 }
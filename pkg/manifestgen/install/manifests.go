@@ -19,7 +19,9 @@ package install
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path"
@@ -34,35 +36,145 @@ import (
 	"github.com/fluxcd/pkg/untar"
 )
 
-func fetch(ctx context.Context, url, version, dir string) error {
-	ghURL := fmt.Sprintf("%s/latest/download/manifests.tar.gz", url)
-	if strings.HasPrefix(version, "v") {
-		ghURL = fmt.Sprintf("%s/download/%s/manifests.tar.gz", url, version)
+const manifestsArchive = "manifests.tar.gz"
+
+// fetch downloads the manifests archive of the given version from the release
+// url, verifies it against the release checksums and extracts it to dir.
+// The "latest" version is resolved with the given release source, other
+// versions are used as is, so that a cached version is served without any
+// request. When cacheDir is not empty, the verified archive is stored in and
+// reused from a versioned directory under cacheDir.
+func fetch(ctx context.Context, releases ReleaseSource, url, version, cacheDir, dir string) error {
+	if version == "" || version == MakeDefaultOptions().Version {
+		latest, err := releases.LatestVersion(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to determine the latest version: %w", err)
+		}
+		version = latest
+	}
+	version = normalizeVersion(version)
+
+	var cachePath string
+	if cacheDir != "" {
+		cachePath = filepath.Join(cacheDir, fmt.Sprintf("%x", sha256.Sum256([]byte(url)))[:12], version)
+		if data, ok := readCachedArchive(cachePath); ok {
+			if _, err := untar.Untar(bytes.NewReader(data), dir); err != nil {
+				return fmt.Errorf("failed to untar cached %s from %s, error: %w", manifestsArchive, cachePath, err)
+			}
+			return nil
+		}
 	}
 
-	req, err := http.NewRequest("GET", ghURL, nil)
+	ghURL := fmt.Sprintf("%s/download/%s/%s", url, version, manifestsArchive)
+	data, err := download(ctx, ghURL)
 	if err != nil {
-		return fmt.Errorf("failed to create HTTP request for %s, error: %w", ghURL, err)
+		return err
+	}
+
+	checksumsURL := fmt.Sprintf("%s/download/%s/flux_%s_checksums.txt", url, version, strings.TrimPrefix(version, "v"))
+	checksums, err := download(ctx, checksumsURL)
+	if err != nil {
+		return err
+	}
+	expected, err := findChecksum(checksums, manifestsArchive)
+	if err != nil {
+		return fmt.Errorf("failed to verify %s from %s, error: %w", manifestsArchive, ghURL, err)
+	}
+	sum := fmt.Sprintf("%x", sha256.Sum256(data))
+	if sum != expected {
+		return fmt.Errorf("checksum mismatch for %s from %s, expected %s got %s", manifestsArchive, ghURL, expected, sum)
+	}
+
+	// extract
+	if _, err = untar.Untar(bytes.NewReader(data), dir); err != nil {
+		return fmt.Errorf("failed to untar %s from %s, error: %w", manifestsArchive, ghURL, err)
+	}
+
+	if cachePath != "" {
+		// a failure to write the cache must not fail the install
+		_ = writeCachedArchive(cachePath, data, sum)
+	}
+
+	return nil
+}
+
+func download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request for %s, error: %w", url, err)
 	}
 
-	// download
 	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
 	if err != nil {
-		return fmt.Errorf("failed to download manifests.tar.gz from %s, error: %w", ghURL, err)
+		return nil, fmt.Errorf("failed to download %s, error: %w", url, err)
 	}
 	defer resp.Body.Close()
 
 	// check response
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download manifests.tar.gz from %s, status: %s", ghURL, resp.Status)
+		return nil, fmt.Errorf("failed to download %s, status: %s", url, resp.Status)
 	}
 
-	// extract
-	if _, err = untar.Untar(resp.Body, dir); err != nil {
-		return fmt.Errorf("failed to untar manifests.tar.gz from %s, error: %w", ghURL, err)
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s, error: %w", url, err)
 	}
+	return data, nil
+}
 
-	return nil
+// findChecksum returns the SHA-256 checksum of the named file
+// from the content of a checksums file in sha256sum format.
+func findChecksum(checksums []byte, file string) (string, error) {
+	for _, line := range strings.Split(string(checksums), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == file {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("checksum for %s not found", file)
+}
+
+// readCachedArchive returns the archive stored in the cache path,
+// if present and matching the checksum it was stored with.
+func readCachedArchive(cachePath string) ([]byte, bool) {
+	sum, err := os.ReadFile(filepath.Join(cachePath, manifestsArchive+".sha256"))
+	if err != nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(filepath.Join(cachePath, manifestsArchive))
+	if err != nil {
+		return nil, false
+	}
+	if fmt.Sprintf("%x", sha256.Sum256(data)) != strings.TrimSpace(string(sum)) {
+		return nil, false
+	}
+	return data, true
+}
+
+func writeCachedArchive(cachePath string, data []byte, sum string) error {
+	if err := os.MkdirAll(cachePath, 0o755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(cachePath, manifestsArchive), data); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(cachePath, manifestsArchive+".sha256"), []byte(sum+"\n"))
+}
+
+func writeFileAtomic(name string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
 }
 
 func generate(base string, options Options) error {
@@ -86,6 +198,18 @@ func generate(base string, options Options) error {
 		return fmt.Errorf("generate kustomization failed: %w", err)
 	}
 
+	if len(options.Patches) > 0 {
+		if err := addPatchesToFile(path.Join(base, "kustomization.yaml"), options.Patches); err != nil {
+			return fmt.Errorf("generate kustomization patches failed: %w", err)
+		}
+	}
+
+	if options.Namespaced {
+		if err := execTemplate(newNamespacedRBAC(options), namespacedRBACTmpl, path.Join(base, namespacedRBACFile)); err != nil {
+			return fmt.Errorf("generate namespaced rbac failed: %w", err)
+		}
+	}
+
 	if err := os.MkdirAll(path.Join(base, "roles"), os.ModePerm); err != nil {
 		return fmt.Errorf("generate roles failed: %w", err)
 	}
@@ -114,9 +238,38 @@ func generate(base string, options Options) error {
 	return nil
 }
 
+const namespacedRBACFile = "namespaced-rbac.yaml"
+
+// clusterScopedKinds are the kinds left out of a namespaced install.
+var clusterScopedKinds = []string{"Namespace", "CustomResourceDefinition", "ClusterRole", "ClusterRoleBinding"}
+
+// buildNamespaced builds the base without the cluster-scoped objects, and
+// appends the namespaced RBAC. The RBAC is not part of the Kustomize build,
+// as the namespace transformer would move it to the install namespace.
+func buildNamespaced(base, output string) error {
+	if err := build(base, output, clusterScopedKinds...); err != nil {
+		return err
+	}
+	rbac, err := os.ReadFile(filepath.Join(base, namespacedRBACFile))
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(output, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(rbac); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 var kustomizeBuildMutex sync.Mutex
 
-func build(base, output string) error {
+// build runs kustomize on the base and writes the resources to the
+// output file, leaving out the resources of the given kinds.
+func build(base, output string, skipKinds ...string) error {
 	// TODO(stefan): temporary workaround for concurrent map read and map write bug
 	// https://github.com/kubernetes-sigs/kustomize/issues/3659
 	kustomizeBuildMutex.Lock()
@@ -159,6 +312,14 @@ func build(base, output string) error {
 		return err
 	}
 
+	for _, r := range m.Resources() {
+		if containsItemString(skipKinds, r.GetKind()) {
+			if err := m.Remove(r.CurId()); err != nil {
+				return err
+			}
+		}
+	}
+
 	resources, err := m.AsYaml()
 	if err != nil {
 		return err
@@ -0,0 +1,235 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFetch(t *testing.T) {
+	archive := testManifestsArchive(t, map[string]string{"rbac.yaml": "---\n"})
+	checksum := fmt.Sprintf("%x", sha256.Sum256(archive))
+
+	tests := []struct {
+		name      string
+		checksums string
+		wantErr   string
+	}{
+		{
+			name:      "valid checksum",
+			checksums: fmt.Sprintf("%s  flux_0.1.0_darwin_amd64.tar.gz\n%s  manifests.tar.gz\n", strings.Repeat("0", 64), checksum),
+		},
+		{
+			name:      "checksum mismatch",
+			checksums: fmt.Sprintf("%s  manifests.tar.gz\n", strings.Repeat("0", 64)),
+			wantErr:   "checksum mismatch",
+		},
+		{
+			name:      "checksum not found",
+			checksums: fmt.Sprintf("%s  install.yaml\n", checksum),
+			wantErr:   "checksum for manifests.tar.gz not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/download/v0.1.0/manifests.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+				w.Write(archive)
+			})
+			mux.HandleFunc("/download/v0.1.0/flux_0.1.0_checksums.txt", func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.checksums))
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			cacheDir := t.TempDir()
+			dir := t.TempDir()
+			err := fetch(context.TODO(), &FileReleaseSource{}, server.URL, "v0.1.0", cacheDir, dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing '%s', got %v", tt.wantErr, err)
+				}
+				if _, err := os.Stat(filepath.Join(dir, "rbac.yaml")); err == nil {
+					t.Error("expected manifests not to be extracted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "rbac.yaml")); err != nil {
+				t.Fatal(err)
+			}
+
+			// the second fetch must be served from the cache
+			server.Close()
+			dir = t.TempDir()
+			if err := fetch(context.TODO(), &FileReleaseSource{}, server.URL, "v0.1.0", cacheDir, dir); err != nil {
+				t.Fatalf("expected cached manifests, got %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "rbac.yaml")); err != nil {
+				t.Fatal(err)
+			}
+
+			// caching disabled
+			if err := fetch(context.TODO(), &FileReleaseSource{}, server.URL, "v0.1.0", "", t.TempDir()); err == nil {
+				t.Fatal("expected download error with caching disabled")
+			}
+		})
+	}
+}
+
+func TestFetch_cache(t *testing.T) {
+	archive := testManifestsArchive(t, map[string]string{"rbac.yaml": "---\n"})
+	downloads := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/download/v0.2.0/manifests.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		w.Write(archive)
+	})
+	mux.HandleFunc("/download/v0.2.0/flux_0.2.0_checksums.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%x  manifests.tar.gz\n", sha256.Sum256(archive))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	releases := &countingReleaseSource{latest: "v0.2.0"}
+	cacheDir := t.TempDir()
+	fetchRBAC := func(version string) error {
+		t.Helper()
+		dir := t.TempDir()
+		if err := fetch(context.TODO(), releases, server.URL, version, cacheDir, dir); err != nil {
+			return err
+		}
+		if _, err := os.Stat(filepath.Join(dir, "rbac.yaml")); err != nil {
+			t.Fatal(err)
+		}
+		return nil
+	}
+
+	// cache miss, the latest version is resolved with the release source
+	if err := fetchRBAC("latest"); err != nil {
+		t.Fatal(err)
+	}
+	if releases.calls != 1 || downloads != 1 {
+		t.Fatalf("expected 1 release source call and 1 download, got %d and %d", releases.calls, downloads)
+	}
+
+	// cache hit, versions without the v prefix are not resolved as latest
+	if err := fetchRBAC("0.2.0"); err != nil {
+		t.Fatal(err)
+	}
+	if releases.calls != 1 || downloads != 1 {
+		t.Errorf("expected cached manifests, got %d release source calls and %d downloads", releases.calls, downloads)
+	}
+
+	// cache miss, the cached archive does not match its checksum anymore
+	cached := filepath.Join(cacheDir, fmt.Sprintf("%x", sha256.Sum256([]byte(server.URL)))[:12], "v0.2.0", manifestsArchive)
+	if err := os.WriteFile(cached, []byte("corrupted"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fetchRBAC("v0.2.0"); err != nil {
+		t.Fatal(err)
+	}
+	if downloads != 2 {
+		t.Errorf("expected the corrupted cache to be downloaded again, got %d downloads", downloads)
+	}
+
+	// the latest version can't be resolved without the release source
+	releases.err = fmt.Errorf("offline")
+	if err := fetchRBAC("latest"); err == nil || !strings.Contains(err.Error(), "offline") {
+		t.Errorf("expected release source error, got %v", err)
+	}
+}
+
+func TestFindChecksum(t *testing.T) {
+	sum := strings.Repeat("a", 64)
+	tests := []struct {
+		name      string
+		checksums string
+		want      string
+		wantErr   bool
+	}{
+		{name: "text mode", checksums: sum + "  manifests.tar.gz\n", want: sum},
+		{name: "binary mode", checksums: sum + " *manifests.tar.gz\n", want: sum},
+		{name: "upper case", checksums: strings.ToUpper(sum) + "  manifests.tar.gz", want: sum},
+		{name: "among others", checksums: strings.Repeat("0", 64) + "  flux_0.1.0_linux_amd64.tar.gz\n" + sum + "  manifests.tar.gz\n", want: sum},
+		{name: "prefix match", checksums: sum + "  manifests.tar.gz.sig\n", wantErr: true},
+		{name: "malformed", checksums: sum + "\n", wantErr: true},
+		{name: "empty", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findChecksum([]byte(tt.checksums), manifestsArchive)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("findChecksum() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("findChecksum() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// countingReleaseSource records the number of LatestVersion calls.
+type countingReleaseSource struct {
+	latest string
+	err    error
+	calls  int
+}
+
+func (s *countingReleaseSource) LatestVersion(context.Context) (string, error) {
+	s.calls++
+	return s.latest, s.err
+}
+
+func (s *countingReleaseSource) VersionExists(_ context.Context, version string) (bool, error) {
+	return version == s.latest, s.err
+}
+
+func testManifestsArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
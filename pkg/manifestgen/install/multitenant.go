@@ -0,0 +1,77 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"fmt"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// multiTenantMinVersions are the first releases of the controllers
+// supporting the --no-cross-namespace-refs flag, and for the reconcilers
+// the --default-service-account flag, set by the multi-tenant install.
+var multiTenantMinVersions = map[string]string{
+	"kustomize-controller":        "v0.20.0",
+	"helm-controller":             "v0.16.0",
+	"notification-controller":     "v0.21.0",
+	"image-reflector-controller":  "v0.16.0",
+	"image-automation-controller": "v0.20.0",
+}
+
+// validateMultiTenant builds the manifests base to output, without the
+// image digests so that the images keep their version tag, and returns an
+// error if a component is older than the release supporting the
+// multi-tenant flags.
+func validateMultiTenant(base, output string, options Options) error {
+	options.ImageDigests = nil
+	if err := generate(base, options); err != nil {
+		return err
+	}
+	if err := build(base, output); err != nil {
+		return err
+	}
+	refs, err := imagesFromManifests(output)
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range refs {
+		r, err := name.ParseReference(ref)
+		if err != nil {
+			return fmt.Errorf("invalid image reference '%s': %w", ref, err)
+		}
+		component := imageComponent(r)
+		minVersion, ok := multiTenantMinVersions[component]
+		if !ok || !containsItemString(options.Components, component) {
+			continue
+		}
+		tag, ok := r.(name.Tag)
+		if !ok {
+			return fmt.Errorf("multi-tenant install requires a version tag for the %s image, got '%s'", component, ref)
+		}
+		version, err := semver.NewVersion(tag.TagStr())
+		if err != nil {
+			return fmt.Errorf("multi-tenant install requires a version tag for the %s image, got '%s'", component, ref)
+		}
+		if version.LessThan(semver.MustParse(minVersion)) {
+			return fmt.Errorf("multi-tenant install requires %s %s or later, got %s", component, minVersion, tag.TagStr())
+		}
+	}
+	return nil
+}
@@ -16,7 +16,11 @@ limitations under the License.
 
 package install
 
-import "time"
+import (
+	"time"
+
+	kustypes "sigs.k8s.io/kustomize/api/types"
+)
 
 type Options struct {
 	BaseURL                string
@@ -36,6 +40,46 @@ type Options struct {
 	TargetPath             string
 	ClusterDomain          string
 	TolerationKeys         []string
+
+	// ReleaseSource resolves the "latest" Version to a released version,
+	// it defaults to the GitHub releases of Flux when nil.
+	ReleaseSource ReleaseSource
+
+	// CacheDir is the directory where the release manifests downloaded
+	// from BaseURL are cached, caching is disabled when empty.
+	CacheDir string
+
+	// Patches are Kustomize patches applied to the generated manifests.
+	Patches []kustypes.Patch
+
+	// Namespaced limits the controllers RBAC to Roles and RoleBindings in
+	// Namespace and TargetNamespaces, and leaves the cluster-scoped objects
+	// out of the manifests. The CRDs and the namespace must already exist.
+	// It requires WatchAllNamespaces to be false.
+	Namespaced bool
+
+	// TargetNamespaces are the namespaces, in addition to Namespace, the
+	// reconcilers can apply resources to when Namespaced is true.
+	TargetNamespaces []string
+
+	// MultiTenant configures the controllers to deny cross-namespace
+	// references, and the reconcilers to impersonate DefaultServiceAccount
+	// when a Kustomization or HelmRelease has no service account set.
+	// The controllers must be recent enough to support these flags,
+	// Generate returns an error otherwise.
+	MultiTenant bool
+
+	// DefaultServiceAccount is the service account the reconcilers
+	// impersonate by default when MultiTenant is true.
+	DefaultServiceAccount string
+
+	// ImageDigests pins the component images to the given digests,
+	// keyed by component name, in the Kustomize images section.
+	ImageDigests map[string]string
+
+	// ResolveImageDigests resolves the digests of the component images
+	// missing from ImageDigests by querying the images registry.
+	ResolveImageDigests bool
 }
 
 func MakeDefaultOptions() Options {
@@ -56,7 +100,17 @@ func MakeDefaultOptions() Options {
 		Timeout:                time.Minute,
 		TargetPath:             "",
 		ClusterDomain:          "cluster.local",
+		DefaultServiceAccount:  "default",
+	}
+}
+
+// releaseSource returns the ReleaseSource of the options,
+// defaulting to the GitHub releases of Flux.
+func (o Options) releaseSource() ReleaseSource {
+	if o.ReleaseSource != nil {
+		return o.ReleaseSource
 	}
+	return &GitHubReleaseSource{URL: DefaultReleasesURL}
 }
 
 func containsItemString(s []string, e string) bool {
@@ -0,0 +1,108 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"fmt"
+	"os"
+
+	kustypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+)
+
+// ParsePatches parses a YAML list of Kustomize patches. Every patch must
+// be inline, as strategic merge or JSON6902, and may have a target selector.
+func ParsePatches(data []byte) ([]kustypes.Patch, error) {
+	var patches []kustypes.Patch
+	if err := yaml.UnmarshalStrict(data, &patches); err != nil {
+		return nil, fmt.Errorf("failed to parse patches: %w", err)
+	}
+	for i, p := range patches {
+		if p.Path != "" {
+			return nil, fmt.Errorf("patch %d: path is not supported, the patch must be inline", i)
+		}
+		if p.Patch == "" {
+			return nil, fmt.Errorf("patch %d: patch is empty", i)
+		}
+	}
+	return patches, nil
+}
+
+// AddPatches adds the given patches to the Kustomization content,
+// skipping those it already contains.
+func AddPatches(content []byte, patches []kustypes.Patch) ([]byte, error) {
+	var kus kustypes.Kustomization
+	if err := yaml.Unmarshal(content, &kus); err != nil {
+		return nil, err
+	}
+	changed := false
+	for _, p := range patches {
+		found := false
+		for _, existing := range kus.Patches {
+			if existing.Equals(p) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			kus.Patches = append(kus.Patches, p)
+			changed = true
+		}
+	}
+	if !changed {
+		return content, nil
+	}
+	return yaml.Marshal(kus)
+}
+
+// SetPatches replaces the patches of the Kustomization content with the
+// given patches, removing those it contains which are not given.
+func SetPatches(content []byte, patches []kustypes.Patch) ([]byte, error) {
+	var kus kustypes.Kustomization
+	if err := yaml.Unmarshal(content, &kus); err != nil {
+		return nil, err
+	}
+	if patchesEqual(kus.Patches, patches) {
+		return content, nil
+	}
+	kus.Patches = patches
+	return yaml.Marshal(kus)
+}
+
+func patchesEqual(a, b []kustypes.Patch) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equals(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func addPatchesToFile(file string, patches []kustypes.Patch) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	content, err = AddPatches(content, patches)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, content, 0o644)
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"strings"
+	"testing"
+
+	kustypes "sigs.k8s.io/kustomize/api/types"
+)
+
+const testPatches = `
+- target:
+    kind: Deployment
+    name: source-controller
+  patch: |
+    - op: add
+      path: /spec/template/spec/containers/0/args/-
+      value: --concurrent=10
+- patch: |
+    apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: source-controller
+    spec:
+      template:
+        spec:
+          containers:
+          - name: manager
+            resources:
+              limits:
+                memory: 2Gi
+`
+
+func TestParsePatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    int
+		wantErr string
+	}{
+		{name: "inline patches", data: testPatches, want: 2},
+		{name: "path patch", data: "- path: patch.yaml\n", wantErr: "path is not supported"},
+		{name: "empty patch", data: "- target:\n    kind: Deployment\n", wantErr: "patch is empty"},
+		{name: "unknown field", data: "- patches: foo\n", wantErr: "failed to parse patches"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patches, err := ParsePatches([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing '%s', got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(patches) != tt.want {
+				t.Errorf("expected %d patches, got %d", tt.want, len(patches))
+			}
+		})
+	}
+}
+
+func TestAddPatches(t *testing.T) {
+	patches, err := ParsePatches([]byte(testPatches))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n- gotk-components.yaml\n")
+	for i := 0; i < 2; i++ {
+		if content, err = AddPatches(content, patches); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var kus kustypes.Kustomization
+	if err := kus.Unmarshal(content); err != nil {
+		t.Fatal(err)
+	}
+	if len(kus.Patches) != 2 {
+		t.Errorf("expected 2 patches, got %d:\n%s", len(kus.Patches), content)
+	}
+	if len(kus.Resources) != 1 {
+		t.Errorf("expected resources to be kept, got %v", kus.Resources)
+	}
+}
+
+func TestSetPatches(t *testing.T) {
+	patches, err := ParsePatches([]byte(testPatches))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n- gotk-components.yaml\n")
+	for _, want := range [][]kustypes.Patch{patches, patches, patches[1:], nil} {
+		if content, err = SetPatches(content, want); err != nil {
+			t.Fatal(err)
+		}
+		var kus kustypes.Kustomization
+		if err := kus.Unmarshal(content); err != nil {
+			t.Fatal(err)
+		}
+		if !patchesEqual(kus.Patches, want) {
+			t.Errorf("expected %d patches, got %d:\n%s", len(want), len(kus.Patches), content)
+		}
+		if len(kus.Resources) != 1 {
+			t.Errorf("expected resources to be kept, got %v", kus.Resources)
+		}
+	}
+}
+
+func TestGenerate_patches(t *testing.T) {
+	base := testManifestsBase(t)
+
+	opts := MakeDefaultOptions()
+	opts.Version = "v0.1.0"
+	opts.Components = []string{"source-controller"}
+	opts.NetworkPolicy = false
+	patches, err := ParsePatches([]byte(testPatches))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.Patches = patches
+	output, err := Generate(opts, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"--concurrent=10", "memory: 2Gi", "kubernetes.io/os: linux"} {
+		if !strings.Contains(output.Content, want) {
+			t.Errorf("'%s' not found in:\n%s", want, output.Content)
+		}
+	}
+}
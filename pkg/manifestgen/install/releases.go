@@ -0,0 +1,276 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"sigs.k8s.io/yaml"
+
+	"github.com/fluxcd/pkg/version"
+)
+
+// DefaultReleasesURL is the GitHub API endpoint of the Flux releases.
+const DefaultReleasesURL = "https://api.github.com/repos/fluxcd/flux2/releases"
+
+// ReleaseSource discovers the released versions of Flux.
+type ReleaseSource interface {
+	// LatestVersion returns the latest released version.
+	LatestVersion(ctx context.Context) (string, error)
+	// VersionExists returns true if the given version has been released.
+	VersionExists(ctx context.Context, version string) (bool, error)
+}
+
+// ReleaseIndex is the document listing the released versions,
+// served by a releases mirror or stored in a local file.
+type ReleaseIndex struct {
+	// Latest is the latest released version, it defaults
+	// to the highest of Versions when empty.
+	Latest   string   `json:"latest,omitempty"`
+	Versions []string `json:"versions"`
+}
+
+// NewReleaseSource returns the release source for the given URL:
+// the GitHub API for an empty URL or an api.github.com URL, a release
+// index read from the URL for other HTTP URLs, and a release index read
+// from a local file for file:// URLs and paths. The token is used to
+// authenticate to the GitHub API.
+func NewReleaseSource(releasesURL, token string) (ReleaseSource, error) {
+	if releasesURL == "" {
+		return &GitHubReleaseSource{URL: DefaultReleasesURL, Token: token}, nil
+	}
+	u, err := url.Parse(releasesURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid releases URL '%s': %w", releasesURL, err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		if u.Host == "api.github.com" {
+			return &GitHubReleaseSource{URL: strings.TrimSuffix(releasesURL, "/"), Token: token}, nil
+		}
+		return &IndexReleaseSource{URL: releasesURL}, nil
+	case "file":
+		return &FileReleaseSource{Path: u.Path}, nil
+	case "":
+		return &FileReleaseSource{Path: releasesURL}, nil
+	default:
+		return nil, fmt.Errorf("unsupported releases URL scheme '%s'", u.Scheme)
+	}
+}
+
+// releasesHTTPClient is the default client of the HTTP release sources.
+var releasesHTTPClient = &http.Client{Timeout: 15 * time.Second}
+
+// GitHubReleaseSource discovers the releases with the GitHub API.
+type GitHubReleaseSource struct {
+	// URL is the releases endpoint of the repository.
+	URL string
+	// Token is the optional GitHub token used to
+	// authenticate the API calls.
+	Token string
+	// Client defaults to a client with a 15s timeout.
+	Client *http.Client
+}
+
+func (s *GitHubReleaseSource) LatestVersion(ctx context.Context) (string, error) {
+	res, err := s.get(ctx, s.URL+"/latest")
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GitHub API returned an unexpected status code (%d)", res.StatusCode)
+	}
+
+	type meta struct {
+		Tag string `json:"tag_name"`
+	}
+	var m meta
+	if err := json.NewDecoder(res.Body).Decode(&m); err != nil {
+		return "", fmt.Errorf("decoding GitHub API response failed: %w", err)
+	}
+	return m.Tag, nil
+}
+
+func (s *GitHubReleaseSource) VersionExists(ctx context.Context, version string) (bool, error) {
+	res, err := s.get(ctx, fmt.Sprintf("%s/tags/%s", s.URL, normalizeVersion(version)))
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("GitHub API returned an unexpected status code (%d)", res.StatusCode)
+	}
+}
+
+func (s *GitHubReleaseSource) get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.Token != "" {
+		req.Header.Set("Authorization", "token "+s.Token)
+	}
+	res, err := httpClientOrDefault(s.Client).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("GitHub API call failed: %w", err)
+	}
+	return res, nil
+}
+
+// IndexReleaseSource discovers the releases from a
+// ReleaseIndex served by a mirror, in YAML or JSON.
+type IndexReleaseSource struct {
+	URL string
+	// Client defaults to a client with a 15s timeout.
+	Client *http.Client
+}
+
+func (s *IndexReleaseSource) LatestVersion(ctx context.Context) (string, error) {
+	index, err := s.index(ctx)
+	if err != nil {
+		return "", err
+	}
+	return index.latest()
+}
+
+func (s *IndexReleaseSource) VersionExists(ctx context.Context, version string) (bool, error) {
+	index, err := s.index(ctx)
+	if err != nil {
+		return false, err
+	}
+	return index.contains(version), nil
+}
+
+func (s *IndexReleaseSource) index(ctx context.Context) (*ReleaseIndex, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := httpClientOrDefault(s.Client).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download the release index from %s: %w", s.URL, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download the release index from %s, status: %s", s.URL, res.Status)
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download the release index from %s: %w", s.URL, err)
+	}
+	return parseReleaseIndex(data)
+}
+
+// FileReleaseSource discovers the releases from a
+// ReleaseIndex stored in a local file, in YAML or JSON.
+type FileReleaseSource struct {
+	Path string
+}
+
+func (s *FileReleaseSource) LatestVersion(ctx context.Context) (string, error) {
+	index, err := s.index()
+	if err != nil {
+		return "", err
+	}
+	return index.latest()
+}
+
+func (s *FileReleaseSource) VersionExists(ctx context.Context, version string) (bool, error) {
+	index, err := s.index()
+	if err != nil {
+		return false, err
+	}
+	return index.contains(version), nil
+}
+
+func (s *FileReleaseSource) index() (*ReleaseIndex, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the release index: %w", err)
+	}
+	return parseReleaseIndex(data)
+}
+
+func parseReleaseIndex(data []byte) (*ReleaseIndex, error) {
+	var index ReleaseIndex
+	if err := yaml.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse the release index: %w", err)
+	}
+	return &index, nil
+}
+
+func (i *ReleaseIndex) latest() (string, error) {
+	if i.Latest != "" {
+		return normalizeVersion(i.Latest), nil
+	}
+	var latest string
+	var latestSv *semver.Version
+	for _, v := range i.Versions {
+		sv, err := version.ParseVersion(v)
+		if err != nil {
+			continue
+		}
+		if latestSv == nil || sv.GreaterThan(latestSv) {
+			latest, latestSv = normalizeVersion(v), sv
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("the release index has no versions")
+	}
+	return latest, nil
+}
+
+func (i *ReleaseIndex) contains(v string) bool {
+	v = normalizeVersion(v)
+	for _, r := range i.Versions {
+		if normalizeVersion(r) == v {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeVersion(version string) string {
+	if !strings.HasPrefix(version, "v") {
+		return "v" + version
+	}
+	return version
+}
+
+func httpClientOrDefault(c *http.Client) *http.Client {
+	if c != nil {
+		return c
+	}
+	return releasesHTTPClient
+}
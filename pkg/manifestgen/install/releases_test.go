@@ -0,0 +1,147 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewReleaseSource(t *testing.T) {
+	tests := []struct {
+		url  string
+		want ReleaseSource
+	}{
+		{"", &GitHubReleaseSource{URL: DefaultReleasesURL, Token: "token"}},
+		{"https://api.github.com/repos/org/flux2/releases/", &GitHubReleaseSource{URL: "https://api.github.com/repos/org/flux2/releases", Token: "token"}},
+		{"https://mirror.local/flux/releases.yaml", &IndexReleaseSource{URL: "https://mirror.local/flux/releases.yaml"}},
+		{"file:///etc/flux/releases.yaml", &FileReleaseSource{Path: "/etc/flux/releases.yaml"}},
+		{"releases.yaml", &FileReleaseSource{Path: "releases.yaml"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			got, err := NewReleaseSource(tt.url, "token")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprintf("%#v", got) != fmt.Sprintf("%#v", tt.want) {
+				t.Errorf("expected %#v, got %#v", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := NewReleaseSource("oci://mirror.local/flux", ""); err == nil {
+		t.Error("expected error for unsupported scheme")
+	}
+}
+
+func TestGitHubReleaseSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/releases/latest":
+			fmt.Fprint(w, `{"tag_name": "v0.2.0"}`)
+		case "/releases/tags/v0.1.0":
+			fmt.Fprint(w, `{"tag_name": "v0.1.0"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	source := &GitHubReleaseSource{URL: server.URL + "/releases", Token: "secret"}
+	latest, err := source.LatestVersion(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest != "v0.2.0" {
+		t.Errorf("expected latest version v0.2.0, got %s", latest)
+	}
+	for version, want := range map[string]bool{"0.1.0": true, "v0.1.1": false} {
+		ok, err := source.VersionExists(context.TODO(), version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != want {
+			t.Errorf("expected version %s to exist %v, got %v", version, want, ok)
+		}
+	}
+
+	source.Token = ""
+	if _, err := source.LatestVersion(context.TODO()); err == nil {
+		t.Error("expected error without token")
+	}
+}
+
+func TestIndexReleaseSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "versions:\n- v0.1.0\n- v0.10.0\n- v0.9.1\n")
+	}))
+	defer server.Close()
+
+	source := &IndexReleaseSource{URL: server.URL}
+	latest, err := source.LatestVersion(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest != "v0.10.0" {
+		t.Errorf("expected latest version v0.10.0, got %s", latest)
+	}
+	ok, err := source.VersionExists(context.TODO(), "0.9.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Error("expected version 0.9.1 to exist")
+	}
+}
+
+func TestFileReleaseSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "releases.json")
+	if err := os.WriteFile(path, []byte(`{"latest": "0.2.0", "versions": ["v0.1.0", "v0.2.0"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source := &FileReleaseSource{Path: path}
+	latest, err := source.LatestVersion(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest != "v0.2.0" {
+		t.Errorf("expected latest version v0.2.0, got %s", latest)
+	}
+	ok, err := source.VersionExists(context.TODO(), "v0.3.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("expected version v0.3.0 to not exist")
+	}
+
+	source.Path = filepath.Join(t.TempDir(), "missing.json")
+	if _, err := source.LatestVersion(context.TODO()); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
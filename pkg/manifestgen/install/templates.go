@@ -30,6 +30,9 @@ var kustomizationTmpl = `---
 {{- $registry := .Registry }}
 {{- $logLevel := .LogLevel }}
 {{- $clusterDomain := .ClusterDomain }}
+{{- $multiTenant := .MultiTenant }}
+{{- $defaultServiceAccount := .DefaultServiceAccount }}
+{{- $imageDigests := .ImageDigests }}
 apiVersion: kustomize.config.k8s.io/v1beta1
 kind: Kustomization
 namespace: {{.Namespace}}
@@ -103,14 +106,29 @@ patchesJson6902:
       path: /spec/template/spec/containers/0/args/2
       value: --log-level={{$logLevel}}
 {{- end }}
+{{- if and $multiTenant (ne $component "source-controller") }}
+    - op: add
+      path: /spec/template/spec/containers/0/args/-
+      value: --no-cross-namespace-refs=true
+{{- end }}
+{{- if and $multiTenant (or (eq $component "kustomize-controller") (eq $component "helm-controller")) }}
+    - op: add
+      path: /spec/template/spec/containers/0/args/-
+      value: --default-service-account={{$defaultServiceAccount}}
+{{- end }}
 {{- end }}
 
-{{- if $registry }}
+{{- if or $registry $imageDigests }}
 images:
 {{- range $i, $component := .Components }}
   - name: fluxcd/{{$component}}
+{{- if $registry }}
     newName: {{$registry}}/{{$component}}
 {{- end }}
+{{- with index $imageDigests $component }}
+    digest: {{.}}
+{{- end }}
+{{- end }}
 {{- end }}
 `
 
@@ -167,6 +185,111 @@ metadata:
   name: {{.Namespace}}
 `
 
+// namespacedRBAC is the data of the namespacedRBACTmpl template.
+type namespacedRBAC struct {
+	Namespace        string
+	Version          string
+	Components       []string
+	Reconcilers      []string
+	TargetNamespaces []string
+}
+
+func newNamespacedRBAC(options Options) namespacedRBAC {
+	data := namespacedRBAC{
+		Namespace:  options.Namespace,
+		Version:    options.Version,
+		Components: options.Components,
+	}
+	for _, c := range []string{"kustomize-controller", "helm-controller"} {
+		if containsItemString(options.Components, c) {
+			data.Reconcilers = append(data.Reconcilers, c)
+		}
+	}
+	data.TargetNamespaces = []string{options.Namespace}
+	for _, ns := range options.TargetNamespaces {
+		if !containsItemString(data.TargetNamespaces, ns) {
+			data.TargetNamespaces = append(data.TargetNamespaces, ns)
+		}
+	}
+	return data
+}
+
+var namespacedRBACTmpl = `
+{{- $ns := .Namespace }}
+{{- define "labels" }}
+  labels:
+    app.kubernetes.io/instance: {{.Namespace}}
+    app.kubernetes.io/part-of: flux
+    app.kubernetes.io/version: "{{.Version}}"
+{{- end }}
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: crd-controller-{{$ns}}
+  namespace: {{$ns}}
+{{- template "labels" . }}
+rules:
+- apiGroups:
+  - source.toolkit.fluxcd.io
+  - kustomize.toolkit.fluxcd.io
+  - helm.toolkit.fluxcd.io
+  - notification.toolkit.fluxcd.io
+  - image.toolkit.fluxcd.io
+  resources: ['*']
+  verbs: ['*']
+- apiGroups: ['']
+  resources: ['secrets']
+  verbs: ['get', 'list', 'watch']
+- apiGroups: ['']
+  resources: ['events']
+  verbs: ['create', 'patch']
+- apiGroups: ['']
+  resources: ['configmaps', 'configmaps/status']
+  verbs: ['get', 'list', 'watch', 'create', 'update', 'patch', 'delete']
+- apiGroups: ['coordination.k8s.io']
+  resources: ['leases']
+  verbs: ['get', 'list', 'watch', 'create', 'update', 'patch', 'delete']
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: crd-controller-{{$ns}}
+  namespace: {{$ns}}
+{{- template "labels" . }}
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: crd-controller-{{$ns}}
+subjects:
+{{- range .Components }}
+- kind: ServiceAccount
+  name: {{.}}
+  namespace: {{$ns}}
+{{- end }}
+{{- if .Reconcilers }}
+{{- range $target := .TargetNamespaces }}
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: reconciler-{{$ns}}
+  namespace: {{$target}}
+{{- template "labels" $ }}
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: admin
+subjects:
+{{- range $.Reconcilers }}
+- kind: ServiceAccount
+  name: {{.}}
+  namespace: {{$ns}}
+{{- end }}
+{{- end }}
+{{- end }}
+`
+
 func execTemplate(obj interface{}, tmpl, filename string) error {
 	t, err := template.New("tmpl").Parse(tmpl)
 	if err != nil {
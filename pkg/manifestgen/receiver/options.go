@@ -0,0 +1,41 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package receiver
+
+type Options struct {
+	Name         string
+	Namespace    string
+	Type         string
+	Events       []string
+	Secret       string
+	SourceName   string
+	TargetPath   string
+	ManifestFile string
+}
+
+func MakeDefaultOptions() Options {
+	return Options{
+		Name:         "flux-system",
+		Namespace:    "flux-system",
+		Type:         "github",
+		Events:       []string{"ping", "push"},
+		Secret:       "flux-system-webhook-token",
+		SourceName:   "flux-system",
+		TargetPath:   "",
+		ManifestFile: "gotk-receiver.yaml",
+	}
+}